@@ -0,0 +1,480 @@
+// goredis-cli is a small redis-cli look-alike built on goredis.
+//
+//	goredis-cli -h 127.0.0.1 -p 6379 [-a password] [-c] [command args...]
+//	goredis-cli --pipe < commands.txt
+//	goredis-cli --scan [--pattern 'user:*']
+//	goredis-cli --bigkeys
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	msgredis "github.com/alextomshion/goredis/goredis"
+)
+
+// commands sent per PipeExec in --pipe mode
+const pipeBatch = 1000
+
+type cli struct {
+	addr     string
+	password string
+	cluster  bool
+	conn     *msgredis.Conn
+	// cluster mode, follows MOVED/ASK like redis-cli -c
+	reshard *msgredis.Resharding
+}
+
+func main() {
+	host := flag.String("h", "127.0.0.1", "server hostname")
+	port := flag.Int("p", 6379, "server port")
+	password := flag.String("a", "", "password")
+	cluster := flag.Bool("c", false, "cluster mode, follow -MOVED and -ASK redirections")
+	pipe := flag.Bool("pipe", false, "send commands read from stdin in pipelines")
+	scan := flag.Bool("scan", false, "list all keys with SCAN")
+	pattern := flag.String("pattern", "", "SCAN MATCH pattern used by --scan and --bigkeys")
+	bigkeys := flag.Bool("bigkeys", false, "find the biggest key of every type")
+	flag.Parse()
+
+	c := &cli{
+		addr:     net.JoinHostPort(*host, strconv.Itoa(*port)),
+		password: *password,
+		cluster:  *cluster,
+	}
+	if e := c.connect(); e != nil {
+		fmt.Fprintln(os.Stderr, "Could not connect to Redis at "+c.addr+": "+e.Error())
+		os.Exit(1)
+	}
+	defer c.close()
+
+	var e error
+	switch {
+	case *scan:
+		e = c.scanKeys(*pattern, func(key string) error {
+			fmt.Println(key)
+			return nil
+		})
+	case *bigkeys:
+		e = c.bigKeys(*pattern)
+	case *pipe:
+		e = c.pipe(os.Stdin, os.Stdout)
+	case flag.NArg() > 0:
+		e = c.run(flag.Args())
+	default:
+		e = c.repl(os.Stdin)
+	}
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) connect() error {
+	if c.cluster {
+		c.reshard = msgredis.NewResharding(c.password)
+	}
+	conn, e := msgredis.Dial(c.addr, c.password, msgredis.ConnectTimeout, msgredis.ReadTimeout, msgredis.WriteTimeout, false, nil)
+	if e != nil {
+		return e
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *cli) close() {
+	if c.reshard != nil {
+		c.reshard.Close()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *cli) call(args []string) (interface{}, error) {
+	cmdArgs := make([]interface{}, len(args)-1)
+	for i, a := range args[1:] {
+		cmdArgs[i] = a
+	}
+	if c.reshard != nil {
+		return c.reshard.Call(c.addr, args[0], cmdArgs...)
+	}
+	return c.conn.Call(args[0], cmdArgs...)
+}
+
+func (c *cli) run(args []string) error {
+	ret, e := c.call(args)
+	if e != nil && !isServerError(e) {
+		return e
+	}
+	if e != nil {
+		ret = e
+	}
+	fmt.Print(format(ret, ""))
+	return nil
+}
+
+/******************* interactive *******************/
+func (c *cli) repl(r io.Reader) error {
+	in := bufio.NewScanner(r)
+	prompt := c.addr
+	if c.cluster {
+		prompt += "(cluster)"
+	}
+	for {
+		fmt.Print(prompt + "> ")
+		if !in.Scan() {
+			fmt.Println()
+			return in.Err()
+		}
+		args, e := splitArgs(in.Text())
+		if e != nil {
+			fmt.Println("Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToLower(args[0]) {
+		case "quit", "exit":
+			return nil
+		}
+		if e = c.run(args); e != nil {
+			// network error, try once to reconnect like redis-cli does
+			fmt.Println("Error: " + e.Error())
+			c.close()
+			if e = c.connect(); e != nil {
+				return e
+			}
+		}
+	}
+}
+
+/******************* pipe *******************/
+// pipe sends r line by line in batches of pipeBatch commands and prints a
+// summary to w, server errors are counted and the first one is reported.
+func (c *cli) pipe(r io.Reader, w io.Writer) error {
+	if c.cluster {
+		return errors.New("--pipe is not supported in cluster mode")
+	}
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 512*1024*1024)
+	var replies, errs int
+	var first error
+	flush := func() error {
+		_, replyErrs, e := c.conn.PipeExecErrs()
+		if e != nil {
+			return e
+		}
+		for _, err := range replyErrs {
+			if err != nil && !isReplyError(err) {
+				// network error, the rest of the batch is lost
+				return err
+			}
+			replies++
+			if err != nil {
+				errs++
+				if first == nil {
+					first = err
+				}
+			}
+		}
+		return nil
+	}
+
+	pending := 0
+	for in.Scan() {
+		args, e := splitArgs(in.Text())
+		if e != nil {
+			return e
+		}
+		if len(args) == 0 {
+			continue
+		}
+		cmdArgs := make([]interface{}, len(args)-1)
+		for i, a := range args[1:] {
+			cmdArgs[i] = a
+		}
+		if e = c.conn.PipeSend(args[0], cmdArgs...); e != nil {
+			return e
+		}
+		if pending++; pending == pipeBatch {
+			if e = flush(); e != nil {
+				return e
+			}
+			pending = 0
+		}
+	}
+	if e := in.Err(); e != nil {
+		return e
+	}
+	if pending > 0 {
+		if e := flush(); e != nil {
+			return e
+		}
+	}
+	if first != nil {
+		fmt.Fprintln(w, first)
+	}
+	fmt.Fprintf(w, "errors: %d, replies: %d\n", errs, replies)
+	return nil
+}
+
+/******************* scan and bigkeys *******************/
+// one conn per master in cluster mode, the connected node otherwise
+func (c *cli) nodes() ([]*msgredis.Conn, error) {
+	if !c.cluster {
+		return []*msgredis.Conn{c.conn}, nil
+	}
+	ranges, e := c.conn.CLUSTERSLOTS()
+	if e != nil {
+		return nil, e
+	}
+	seen := make(map[string]bool)
+	conns := []*msgredis.Conn{}
+	for _, sr := range ranges {
+		if seen[sr.Master] {
+			continue
+		}
+		seen[sr.Master] = true
+		conn, e := msgredis.Dial(sr.Master, c.password, msgredis.ConnectTimeout, msgredis.ReadTimeout, msgredis.WriteTimeout, false, nil)
+		if e != nil {
+			for _, opened := range conns {
+				opened.Close()
+			}
+			return nil, e
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+func (c *cli) scanKeys(pattern string, fn func(key string) error) error {
+	conns, e := c.nodes()
+	if e != nil {
+		return e
+	}
+	if c.cluster {
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+	}
+	for _, conn := range conns {
+		cursor := 0
+		for {
+			next, keys, e := conn.SCAN(cursor, pattern != "", pattern, true, 1000)
+			if e != nil {
+				return e
+			}
+			for _, k := range keys {
+				if e = fn(string(k.([]byte))); e != nil {
+					return e
+				}
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return nil
+}
+
+type bigKey struct {
+	key  string
+	size int64
+}
+
+// bigKeys reports the biggest key of every type, sizes are STRLEN for
+// strings and the number of elements for collections, like redis-cli.
+func (c *cli) bigKeys(pattern string) error {
+	conns, e := c.nodes()
+	if e != nil {
+		return e
+	}
+	if c.cluster {
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+	}
+
+	biggest := make(map[string]bigKey)
+	counts := make(map[string]int64)
+	var total int64
+	for _, conn := range conns {
+		b := msgredis.NewKeyBrowser(conn, msgredis.BrowseOptions{Match: pattern, PageSize: 1000})
+		cursor := 0
+		for {
+			page, e := b.Page(cursor)
+			if e != nil {
+				return e
+			}
+			for _, k := range page.Keys {
+				total++
+				counts[k.Type]++
+				if k.Size > biggest[k.Type].size || biggest[k.Type].key == "" {
+					biggest[k.Type] = bigKey{k.Key, k.Size}
+				}
+			}
+			if page.Cursor == 0 {
+				break
+			}
+			cursor = page.Cursor
+		}
+	}
+
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	fmt.Printf("Sampled %d keys in the keyspace!\n\n", total)
+	for _, t := range types {
+		fmt.Printf("Biggest %6s found '%s' has %d %s\n", t, biggest[t].key, biggest[t].size, unit(t))
+	}
+	fmt.Println()
+	for _, t := range types {
+		fmt.Printf("%d %ss\n", counts[t], t)
+	}
+	return nil
+}
+
+func unit(t string) string {
+	switch t {
+	case "string":
+		return "bytes"
+	case "list":
+		return "items"
+	case "hash":
+		return "fields"
+	case "stream":
+		return "entries"
+	}
+	return "members"
+}
+
+/******************* helpers *******************/
+func isServerError(e error) bool {
+	return strings.HasPrefix(e.Error(), msgredis.CommonErrPrefix)
+}
+
+// an error reply, the conn is still usable
+func isReplyError(e error) bool {
+	switch e.(type) {
+	case *msgredis.ReplyError, *msgredis.BusyScriptError:
+		return true
+	}
+	return false
+}
+
+// format renders a reply the way redis-cli does in a terminal
+func format(v interface{}, indent string) string {
+	switch r := v.(type) {
+	case nil:
+		return "(nil)\n"
+	case error:
+		return "(error) " + strings.TrimPrefix(r.Error(), msgredis.CommonErrPrefix) + "\n"
+	case int64:
+		return "(integer) " + strconv.FormatInt(r, 10) + "\n"
+	case []byte:
+		return strconv.Quote(string(r)) + "\n"
+	case []interface{}:
+		if len(r) == 0 {
+			return "(empty array)\n"
+		}
+		var sb strings.Builder
+		width := len(strconv.Itoa(len(r)))
+		for i, elem := range r {
+			prefix := fmt.Sprintf("%*d) ", width, i+1)
+			if i > 0 {
+				sb.WriteString(indent)
+			}
+			sb.WriteString(prefix)
+			sb.WriteString(format(elem, indent+strings.Repeat(" ", len(prefix))))
+		}
+		return sb.String()
+	}
+	return fmt.Sprint(v) + "\n"
+}
+
+// splitArgs splits a command line like redis-cli, double quotes support
+// \n \r \t \" \\ and \xHH escapes, single quotes are taken literally.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+		var sb strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i == len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				ch := line[i]
+				if ch == '"' {
+					i++
+					break
+				}
+				if ch == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						ch = '\n'
+					case 'r':
+						ch = '\r'
+					case 't':
+						ch = '\t'
+					case 'x':
+						if i+2 < len(line) {
+							if n, e := strconv.ParseUint(line[i+1:i+3], 16, 8); e == nil {
+								ch = byte(n)
+								i += 2
+								break
+							}
+						}
+						ch = 'x'
+					default:
+						ch = line[i]
+					}
+				}
+				sb.WriteByte(ch)
+				i++
+			}
+		case '\'':
+			i++
+			end := strings.IndexByte(line[i:], '\'')
+			if end < 0 {
+				return nil, errors.New("unbalanced quotes")
+			}
+			sb.WriteString(line[i : i+end])
+			i += end + 1
+		default:
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				sb.WriteByte(line[i])
+				i++
+			}
+		}
+		if i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			return nil, errors.New("closing quote must be followed by a space")
+		}
+		args = append(args, sb.String())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	msgredis "github.com/alextomshion/goredis/goredis"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{}},
+		{"  GET   k ", []string{"GET", "k"}},
+		{`SET k "a b\n\x41\"\\"`, []string{"SET", "k", "a b\nA\"\\"}},
+		{`SET k 'a "b" \n'`, []string{"SET", "k", `a "b" \n`}},
+		{`SET k "\xZZ"`, []string{"SET", "k", "xZZ"}},
+		{"SET k ''", []string{"SET", "k", ""}},
+	}
+	for _, tc := range cases {
+		got, e := splitArgs(tc.line)
+		if e != nil || strings.Join(got, "|") != strings.Join(tc.want, "|") || len(got) != len(tc.want) {
+			t.Fatalf("%q: %q %v", tc.line, got, e)
+		}
+	}
+	for _, line := range []string{`GET "k`, `GET 'k`, `GET "k"x`, `GET 'k'x`} {
+		if _, e := splitArgs(line); e == nil {
+			t.Fatalf("%q accepted", line)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want string
+	}{
+		{nil, "(nil)\n"},
+		{int64(3), "(integer) 3\n"},
+		{[]byte("a\"b"), "\"a\\\"b\"\n"},
+		{&msgredis.ReplyError{Msg: "ERR wrong"}, "(error) ERR wrong\n"},
+		{[]interface{}{}, "(empty array)\n"},
+		{[]interface{}{[]byte("a"), nil, int64(1)}, "1) \"a\"\n2) (nil)\n3) (integer) 1\n"},
+		{[]interface{}{[]interface{}{[]byte("x"), []byte("y")}, []byte("z")},
+			"1) 1) \"x\"\n   2) \"y\"\n2) \"z\"\n"},
+	}
+	for _, tc := range cases {
+		if got := format(tc.v, ""); got != tc.want {
+			t.Fatalf("%v: %q, want %q", tc.v, got, tc.want)
+		}
+	}
+	// ten elements widen the index column
+	arr := make([]interface{}, 10)
+	if got := format(arr, ""); !strings.HasPrefix(got, " 1) (nil)\n") || !strings.HasSuffix(got, "10) (nil)\n") {
+		t.Fatalf("%q", got)
+	}
+}
+
+// serve answers every command with +OK, BAD with an error reply
+func serve(t *testing.T) string {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, e := ln.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			args, e := readCommand(r)
+			if e != nil {
+				return
+			}
+			reply := "+OK\r\n"
+			if args[0] == "BAD" {
+				reply = "-ERR bad command\r\n"
+			}
+			io.WriteString(conn, reply)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, e := r.ReadString('\n')
+	if e != nil {
+		return nil, e
+	}
+	n, e := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if e != nil || n <= 0 {
+		return nil, errors.New("bad request")
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, e = r.ReadString('\n'); e != nil {
+			return nil, e
+		}
+		arg, e := r.ReadString('\n')
+		if e != nil {
+			return nil, e
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestPipe(t *testing.T) {
+	c := &cli{addr: serve(t)}
+	if e := c.connect(); e != nil {
+		t.Fatal(e)
+	}
+	defer c.close()
+	var out bytes.Buffer
+	if e := c.pipe(strings.NewReader("SET a 1\nBAD\n\nSET b 2\nBAD x\n"), &out); e != nil {
+		t.Fatal(e)
+	}
+	if out.String() != msgredis.CommonErrPrefix+"ERR bad command\nerrors: 2, replies: 4\n" {
+		t.Fatalf("%q", out.String())
+	}
+}
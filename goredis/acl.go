@@ -0,0 +1,134 @@
+package msgredis
+
+import (
+	"strings"
+)
+
+// ACL GETUSER reply
+type ACLUser struct {
+	Flags []string
+	// sha256 hex of every password
+	Passwords []string
+	// command rules, e.g. "+@all -debug"
+	Commands string
+	// key patterns, e.g. "~cache:*" or "%R~read:*"
+	Keys     []string
+	Channels []string
+}
+
+/******************* acl commands *******************/
+func (c *Conn) ACLWHOAMI() (string, error) {
+	v, e := c.Call("ACL", "WHOAMI")
+	if e != nil {
+		return "", e
+	}
+	return replyString(v)
+}
+
+// one ACL rule line per user
+func (c *Conn) ACLLIST() ([]string, error) {
+	v, e := c.Call("ACL", "LIST")
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+func (c *Conn) ACLGETUSER(name string) (*ACLUser, error) {
+	v, e := c.Call("ACL", "GETUSER", name)
+	if e != nil {
+		return nil, e
+	}
+	if v == nil {
+		return nil, ErrKeyNotExist
+	}
+	return parseACLUser(v)
+}
+
+// [flags, [...], passwords, [...], commands, "...", keys, [...] or "...", ...]
+func parseACLUser(v interface{}) (*ACLUser, error) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr)%2 != 0 {
+		return nil, ErrBadType
+	}
+	u := &ACLUser{}
+	for i := 0; i < len(arr); i += 2 {
+		name, e := replyString(arr[i])
+		if e != nil {
+			return nil, e
+		}
+		// redis 6 returns arrays, redis 7 space separated strings
+		var values []string
+		switch data := arr[i+1].(type) {
+		case []byte:
+			values = strings.Fields(string(data))
+		case []interface{}:
+			if values, e = replyStrings(data); e != nil {
+				values = nil
+			}
+		}
+		switch name {
+		case "flags":
+			u.Flags = values
+		case "passwords":
+			u.Passwords = values
+		case "commands":
+			u.Commands = strings.Join(values, " ")
+		case "keys":
+			u.Keys = values
+		case "channels":
+			u.Channels = values
+		}
+	}
+	return u, nil
+}
+
+// ACL SETUSER name rule..., e.g. ACLSETUSER("app", "on", ">secret", "~app:*", "+@read")
+func (c *Conn) ACLSETUSER(name string, rules ...string) error {
+	args := make([]interface{}, 0, len(rules)+2)
+	args = append(args, "SETUSER", name)
+	for _, r := range rules {
+		args = append(args, r)
+	}
+	return c.okCall("ACL", args...)
+}
+
+// returns the number of deleted users
+func (c *Conn) ACLDELUSER(names ...string) (int64, error) {
+	args := make([]interface{}, 0, len(names)+1)
+	args = append(args, "DELUSER")
+	for _, n := range names {
+		args = append(args, n)
+	}
+	n, e := c.Call("ACL", args...)
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+// empty category lists the categories, otherwise the commands of category
+func (c *Conn) ACLCAT(category string) ([]string, error) {
+	args := []interface{}{"CAT"}
+	if category != "" {
+		args = append(args, category)
+	}
+	v, e := c.Call("ACL", args...)
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+// bits <= 0 uses the server default of 256
+func (c *Conn) ACLGENPASS(bits int) (string, error) {
+	args := []interface{}{"GENPASS"}
+	if bits > 0 {
+		args = append(args, bits)
+	}
+	v, e := c.Call("ACL", args...)
+	if e != nil {
+		return "", e
+	}
+	return replyString(v)
+}
@@ -0,0 +1,49 @@
+package msgredis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func bulks(ss ...string) []interface{} {
+	arr := make([]interface{}, len(ss))
+	for i, s := range ss {
+		arr[i] = []byte(s)
+	}
+	return arr
+}
+
+func TestParseACLUser(t *testing.T) {
+	want := &ACLUser{
+		Flags:     []string{"on"},
+		Passwords: []string{"2bb80d5"},
+		Commands:  "+@all -debug",
+		Keys:      []string{"~app:*", "%R~read:*"},
+		Channels:  []string{"&*"},
+	}
+
+	// redis 7
+	u, e := parseACLUser([]interface{}{
+		[]byte("flags"), bulks("on"),
+		[]byte("passwords"), bulks("2bb80d5"),
+		[]byte("commands"), []byte("+@all -debug"),
+		[]byte("keys"), []byte("~app:* %R~read:*"),
+		[]byte("channels"), []byte("&*"),
+		[]byte("selectors"), []interface{}{},
+	})
+	if e != nil || !reflect.DeepEqual(u, want) {
+		t.Errorf("redis 7 user %+v %v", u, e)
+	}
+
+	// redis 6
+	u, e = parseACLUser([]interface{}{
+		[]byte("flags"), bulks("on"),
+		[]byte("passwords"), bulks("2bb80d5"),
+		[]byte("commands"), []byte("+@all -debug"),
+		[]byte("keys"), bulks("~app:*", "%R~read:*"),
+		[]byte("channels"), bulks("&*"),
+	})
+	if e != nil || !reflect.DeepEqual(u, want) {
+		t.Errorf("redis 6 user %+v %v", u, e)
+	}
+}
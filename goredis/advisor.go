@@ -0,0 +1,150 @@
+package msgredis
+
+import (
+	"sort"
+	"strconv"
+)
+
+const DefaultAdviseRatio = 0.8
+
+// compact encodings and the CONFIG parameter bounding their entries. Names
+// differ by version, listpack since 7.0 and ziplist before, the first one
+// the server knows is used.
+var encodingLimits = map[string]map[string][]string{
+	"hash": {
+		"listpack": {"hash-max-listpack-entries", "hash-max-ziplist-entries"},
+		"ziplist":  {"hash-max-ziplist-entries", "hash-max-listpack-entries"},
+	},
+	"zset": {
+		"listpack": {"zset-max-listpack-entries", "zset-max-ziplist-entries"},
+		"ziplist":  {"zset-max-ziplist-entries", "zset-max-listpack-entries"},
+	},
+	"set": {
+		"intset":   {"set-max-intset-entries"},
+		"listpack": {"set-max-listpack-entries"},
+	},
+}
+
+// EncodingAdvice is a key in a compact encoding close to its entries limit,
+// growing past Limit converts it to hashtable/skiplist which usually takes
+// several times the memory.
+type EncodingAdvice struct {
+	Key      string
+	Type     string
+	Encoding string
+	Size     int64
+	Limit    int64
+	// Size / Limit
+	Ratio float64
+}
+
+type AdviseOptions struct {
+	// SCAN MATCH pattern
+	Match string
+	// report keys with Size >= Ratio * Limit, DefaultAdviseRatio if 0
+	Ratio    float64
+	PageSize int
+}
+
+// OBJECT ENCODING <key>
+func (c *Conn) OBJECTENCODING(key string) (string, error) {
+	v, e := c.Call("OBJECT", "ENCODING", key)
+	if e != nil {
+		return "", e
+	}
+	if v == nil {
+		return "", ErrKeyNotExist
+	}
+	return replyString(v)
+}
+
+// encodingThresholds reads the entries limits from CONFIG GET
+func encodingThresholds(c *Conn) (map[string]int64, error) {
+	config, e := c.CONFIGGET("*-max-*-entries")
+	if e != nil {
+		return nil, e
+	}
+	limits := make(map[string]int64, len(config))
+	for name, value := range config {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			limits[name] = n
+		}
+	}
+	return limits, nil
+}
+
+func limitOf(limits map[string]int64, typ, encoding string) (int64, bool) {
+	for _, name := range encodingLimits[typ][encoding] {
+		if n, ok := limits[name]; ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// AdviseEncodings scans the keyspace and reports hashes, sorted sets and
+// sets whose compact encoding is about to convert, fullest first. Only the
+// entries limits are checked, a single value longer than *-max-*-value
+// converts a key regardless of its size.
+func AdviseEncodings(c *Conn, opt AdviseOptions) ([]EncodingAdvice, error) {
+	if opt.Ratio <= 0 {
+		opt.Ratio = DefaultAdviseRatio
+	}
+	limits, e := encodingThresholds(c)
+	if e != nil {
+		return nil, e
+	}
+
+	b := NewKeyBrowser(c, BrowseOptions{Match: opt.Match, PageSize: opt.PageSize})
+	advice := []EncodingAdvice{}
+	cursor := 0
+	for {
+		page, e := b.Page(cursor)
+		if e != nil {
+			return nil, e
+		}
+		candidates := make([]KeyInfo, 0, len(page.Keys))
+		for _, k := range page.Keys {
+			if _, ok := encodingLimits[k.Type]; ok {
+				candidates = append(candidates, k)
+			}
+		}
+		for _, k := range candidates {
+			c.PipeSend("OBJECT", "ENCODING", k.Key)
+		}
+		if len(candidates) > 0 {
+			ret, e := c.PipeExec()
+			if e != nil {
+				return nil, e
+			}
+			for i, k := range candidates {
+				encoding, err := replyString(ret[i])
+				if err != nil {
+					// deleted since SCAN
+					continue
+				}
+				limit, ok := limitOf(limits, k.Type, encoding)
+				if !ok || limit <= 0 {
+					continue
+				}
+				ratio := float64(k.Size) / float64(limit)
+				if ratio >= opt.Ratio {
+					advice = append(advice, EncodingAdvice{
+						Key:      k.Key,
+						Type:     k.Type,
+						Encoding: encoding,
+						Size:     k.Size,
+						Limit:    limit,
+						Ratio:    ratio,
+					})
+				}
+			}
+		}
+		if page.Cursor == 0 {
+			break
+		}
+		cursor = page.Cursor
+	}
+	sort.Slice(advice, func(i, j int) bool { return advice[i].Ratio > advice[j].Ratio })
+	return advice, nil
+}
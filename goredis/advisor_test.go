@@ -0,0 +1,45 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestAdviseEncodings(t *testing.T) {
+	keys := map[string][3]string{
+		// type, size, encoding
+		"h:big":   {"hash", "120", "listpack"},
+		"h:small": {"hash", "3", "listpack"},
+		"h:table": {"hash", "900", "hashtable"},
+		"s:ints":  {"set", "500", "intset"},
+		"str":     {"string", "10", "embstr"},
+	}
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "CONFIG":
+			return respArray("hash-max-listpack-entries", "128", "set-max-intset-entries", "512", "zset-max-listpack-entries", "128")
+		case "SCAN":
+			return "*2\r\n$1\r\n0\r\n" + respArray("h:big", "h:small", "h:table", "s:ints", "str")
+		case "TYPE":
+			return "+" + keys[args[1]][0] + "\r\n"
+		case "PTTL":
+			return ":-1\r\n"
+		case "HLEN", "SCARD", "STRLEN":
+			return ":" + keys[args[1]][1] + "\r\n"
+		case "OBJECT":
+			return "+" + keys[args[2]][2] + "\r\n"
+		}
+		return "-ERR unknown\r\n"
+	})
+	c := dialFake(t, s)
+
+	advice, e := AdviseEncodings(c, AdviseOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(advice) != 2 {
+		t.Fatalf("got %+v", advice)
+	}
+	if advice[0].Key != "s:ints" || advice[0].Limit != 512 || advice[1].Key != "h:big" || advice[1].Limit != 128 {
+		t.Fatalf("got %+v", advice)
+	}
+}
@@ -0,0 +1,148 @@
+package msgredis
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Args builds variadic argument lists, e.g.
+//
+//	c.Call("HSET", Args{"user:1"}.AddFlat(user)...)
+//	c.Call("MSET", Args{}.AddFlat(map[string]string{"a": "1", "b": "2"})...)
+type Args []interface{}
+
+// Add appends values, slices other than []byte are flattened
+func (a Args) Add(values ...interface{}) Args {
+	for _, v := range values {
+		a = a.add(v)
+	}
+	return a
+}
+
+func (a Args) add(v interface{}) Args {
+	switch v.(type) {
+	case nil, []byte, string:
+		return append(a, v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			a = a.add(rv.Index(i).Interface())
+		}
+		return a
+	}
+	return append(a, v)
+}
+
+// AddFlat appends a map as key value pairs sorted by key, or a struct (or
+// pointer to one) as field value pairs. Struct fields are named by their
+// `redis` tag, "-" skips a field and ",omitempty" skips zero values;
+// untagged exported fields use the field name. Anything else is Add'ed.
+func (a Args) AddFlat(v interface{}) Args {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return a
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return argString(keys[i].Interface()) < argString(keys[j].Interface())
+		})
+		for _, k := range keys {
+			a = append(a, k.Interface(), rv.MapIndex(k).Interface())
+		}
+		return a
+	case reflect.Struct:
+		return a.addStruct(rv)
+	}
+	return a.add(v)
+}
+
+func (a Args) addStruct(rv reflect.Value) Args {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := f.Name
+		omitEmpty := false
+		if tag, ok := f.Tag.Lookup("redis"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		a = append(a, name, fv.Interface())
+	}
+	return a
+}
+
+// KV is one key value pair of MSET, HSET or XADD in a fixed order
+type KV struct {
+	Key   string
+	Value interface{}
+}
+
+// Pairs flattens kvs keeping their order, XADD fields are ordered
+func Pairs(kvs ...KV) Args {
+	a := make(Args, 0, 2*len(kvs))
+	for _, kv := range kvs {
+		a = append(a, kv.Key, kv.Value)
+	}
+	return a
+}
+
+var ErrShortReader = errors.New(CommonErrPrefix + "reader argument ended before its length")
+
+// ReaderArg is an argument of N bytes streamed from R through the write
+// buffer, so a large upload is never held in memory:
+//
+//	f, _ := os.Open(path)
+//	st, _ := f.Stat()
+//	c.Call("SET", "file", ReaderArg{R: f, N: st.Size()})
+//
+// R is consumed by the first attempt, a CallN retry fails with
+// ErrShortReader. Values of ValueTransformer keys cannot be streamed.
+type ReaderArg struct {
+	R io.Reader
+	N int64
+}
+
+// writeReader copies exactly N bytes, a short reader leaves a partial
+// request on the wire and the conn broken
+func (c *Conn) writeReader(r ReaderArg) error {
+	var e error
+	if e = c.writeLen('$', int(r.N)); e != nil {
+		return e
+	}
+	n, e := io.CopyN(c.wb, r.R, r.N)
+	if n < r.N {
+		if e == nil || e == io.EOF {
+			e = ErrShortReader
+		}
+		return e
+	}
+	_, e = c.wb.WriteString("\r\n")
+	return e
+}
@@ -0,0 +1,51 @@
+package msgredis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	type user struct {
+		Name  string `redis:"name"`
+		Age   int    `redis:"age,omitempty"`
+		Email string
+		Pass  string `redis:"-"`
+		note  string
+	}
+
+	cases := []struct {
+		got  Args
+		want Args
+	}{
+		{Args{"k"}.Add([]string{"a", "b"}, 1, []byte("raw")), Args{"k", "a", "b", 1, []byte("raw")}},
+		{Args{}.AddFlat(map[string]string{"b": "2", "a": "1"}), Args{"a", "1", "b", "2"}},
+		{Args{"user:1"}.AddFlat(&user{Name: "x", Email: "e", Pass: "p", note: "n"}), Args{"user:1", "name", "x", "Email", "e"}},
+		{Args{"user:1"}.AddFlat(user{Name: "x", Age: 3}), Args{"user:1", "name", "x", "age", 3, "Email", ""}},
+		{Args{"s", "*"}.Add(Pairs(KV{"z", 1}, KV{"a", 2})), Args{"s", "*", "z", 1, "a", 2}},
+	}
+	for i, tc := range cases {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%d: got %v, want %v", i, tc.got, tc.want)
+		}
+	}
+}
+
+func TestReaderArg(t *testing.T) {
+	m := newMemServer(t)
+	c := dialFake(t, m.fakeServer)
+	value := strings.Repeat("0123456789", 10000)
+	if _, e := c.Call("SET", "file", ReaderArg{R: strings.NewReader(value), N: int64(len(value))}); e != nil {
+		t.Fatal(e)
+	}
+	if v, e := c.Call("GET", "file"); e != nil || string(v.([]byte)) != value {
+		t.Fatalf("got %d bytes, %v", len(v.([]byte)), e)
+	}
+	if got := DefaultRedactor.Redact([]interface{}{ReaderArg{N: 3}})[0]; got != "(3 bytes from a reader)" {
+		t.Fatal(got)
+	}
+	if _, e := c.Call("SET", "file", ReaderArg{R: strings.NewReader("short"), N: 10}); e != ErrShortReader || !c.Broken() {
+		t.Fatalf("got %v, broken %v", e, c.Broken())
+	}
+}
@@ -0,0 +1,86 @@
+package msgredis
+
+import (
+	"sort"
+	"time"
+)
+
+// keys per MGET/MSET sent by MGetMap and MSetMap, larger batches are split
+// into several commands sent in one pipeline
+const BatchChunkSize = 500
+
+// MGetMap gets keys in chunked MGETs, missing keys are absent from the map
+func (c *Conn) MGetMap(keys ...string) (map[string][]byte, error) {
+	ret := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return ret, nil
+	}
+	for i := 0; i < len(keys); i += BatchChunkSize {
+		j := i + BatchChunkSize
+		if j > len(keys) {
+			j = len(keys)
+		}
+		if e := c.PipeSend("MGET", Args{}.Add(keys[i:j])...); e != nil {
+			// the chunks queued are still read
+			c.pipeExec(time.Time{})
+			return nil, e
+		}
+	}
+	replies, e := c.pipeExecAll()
+	if e != nil {
+		return nil, e
+	}
+	for n, reply := range replies {
+		values, ok := reply.([]interface{})
+		if !ok {
+			return nil, ErrBadType
+		}
+		for k, v := range values {
+			if b, ok := v.([]byte); ok {
+				ret[keys[n*BatchChunkSize+k]] = b
+			}
+		}
+	}
+	return ret, nil
+}
+
+// MissingKeys returns the keys absent from an MGetMap result
+func MissingKeys(keys []string, found map[string][]byte) []string {
+	missing := []string{}
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// MSetMap sets kv in chunked MSETs in one pipeline. Each chunk is atomic,
+// the whole map is not.
+func (c *Conn) MSetMap(kv map[string][]byte) error {
+	if len(kv) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i := 0; i < len(keys); i += BatchChunkSize {
+		j := i + BatchChunkSize
+		if j > len(keys) {
+			j = len(keys)
+		}
+		args := make(Args, 0, 2*(j-i))
+		for _, k := range keys[i:j] {
+			args = append(args, k, kv[k])
+		}
+		if e := c.PipeSend("MSET", args...); e != nil {
+			// the chunks queued are still read
+			c.pipeExec(time.Time{})
+			return e
+		}
+	}
+	_, e := c.pipeExecAll()
+	return e
+}
@@ -0,0 +1,95 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBatchMap(t *testing.T) {
+	var mu sync.Mutex
+	store := map[string]string{}
+	msets := 0
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "MSET":
+			msets++
+			for i := 1; i < len(args); i += 2 {
+				store[args[i]] = args[i+1]
+			}
+			return "+OK\r\n"
+		case "MGET":
+			r := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+			for _, k := range args[1:] {
+				v, ok := store[k]
+				if !ok {
+					r += "$-1\r\n"
+					continue
+				}
+				r += "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			}
+			return r
+		}
+		return "-ERR unknown\r\n"
+	})
+	c := dialFake(t, s)
+
+	kv := map[string][]byte{}
+	keys := []string{}
+	for i := 0; i < 2*BatchChunkSize+10; i++ {
+		k := "k" + strconv.Itoa(i)
+		keys = append(keys, k)
+		if i%2 == 0 {
+			kv[k] = []byte("v" + strconv.Itoa(i))
+		}
+	}
+	if e := c.MSetMap(kv); e != nil {
+		t.Fatal(e)
+	}
+	if msets != 2 {
+		t.Fatalf("%d MSETs", msets)
+	}
+
+	got, e := c.MGetMap(keys...)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(got) != len(kv) || string(got["k1000"]) != "v1000" {
+		t.Fatalf("got %d keys", len(got))
+	}
+	if missing := MissingKeys(keys, got); len(missing) != len(keys)-len(kv) || missing[0] != "k1" {
+		t.Fatalf("missing %v", missing[:3])
+	}
+}
+
+func TestBatchMapReplyErrors(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		// only the first chunk fails
+		if calls[args[0]]++; calls[args[0]] == 1 {
+			return "-OOM command not allowed when used memory > 'maxmemory'\r\n"
+		}
+		if args[0] == "MGET" {
+			return "*0\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	kv := map[string][]byte{}
+	keys := []string{}
+	for i := 0; i < BatchChunkSize+1; i++ {
+		kv["k"+strconv.Itoa(i)] = []byte("v")
+		keys = append(keys, "k"+strconv.Itoa(i))
+	}
+	if e := c.MSetMap(kv); e == nil || !isReplyError(e) {
+		t.Fatalf("mset: %v", e)
+	}
+	if _, e := c.MGetMap(keys...); e == nil || !isReplyError(e) {
+		t.Fatalf("mget: %v", e)
+	}
+}
@@ -0,0 +1,178 @@
+package msgredis
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrBatcherClosed = errors.New(CommonErrPrefix + "batcher closed")
+
+const (
+	DefaultBatchSize     = 100
+	DefaultBatchInterval = 200 * time.Microsecond
+)
+
+type batchCall struct {
+	command string
+	args    []interface{}
+	reply   interface{}
+	err     error
+	done    chan struct{}
+}
+
+// batchQueue collects items from many goroutines and hands them to flush
+// every size items or interval after the first one queued, whichever comes
+// first. Batcher and Coalescer queue their calls on one.
+type batchQueue struct {
+	size     int
+	interval time.Duration
+	flush    func(batch []interface{})
+	items    chan interface{}
+	mu       sync.RWMutex
+	closed   bool
+	done     chan struct{}
+}
+
+func newBatchQueue(size int, interval time.Duration, flush func([]interface{})) *batchQueue {
+	q := &batchQueue{
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		items:    make(chan interface{}, size),
+		done:     make(chan struct{}),
+	}
+	go q.loop()
+	return q
+}
+
+// add queues item, false once the queue is closed
+func (q *batchQueue) add(item interface{}) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	q.items <- item
+	return true
+}
+
+// close flushes the queued items and stops the loop
+func (q *batchQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.items)
+	q.mu.Unlock()
+	<-q.done
+}
+
+func (q *batchQueue) loop() {
+	defer close(q.done)
+	timer := time.NewTimer(q.interval)
+	timer.Stop()
+	batch := make([]interface{}, 0, q.size)
+	for {
+		select {
+		case item, ok := <-q.items:
+			if !ok {
+				if len(batch) > 0 {
+					q.flush(batch)
+				}
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(q.interval)
+			}
+			batch = append(batch, item)
+			if len(batch) < q.size {
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+		if len(batch) > 0 {
+			q.flush(batch)
+		}
+		batch = batch[:0]
+	}
+}
+
+// Batcher collects commands from many goroutines and sends them as one
+// pipeline every Size commands or Interval after the first one queued,
+// whichever comes first. Each caller waits at most Interval longer, in
+// exchange one round trip serves the whole batch.
+type Batcher struct {
+	p *Pool
+	q *batchQueue
+}
+
+// NewBatcher starts the flush loop, size and interval <= 0 take the defaults
+func NewBatcher(p *Pool, size int, interval time.Duration) *Batcher {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultBatchInterval
+	}
+	b := &Batcher{p: p}
+	b.q = newBatchQueue(size, interval, b.flush)
+	return b
+}
+
+// Do queues a command and waits for its reply. Commands of a batch run in
+// queue order but are not atomic, and commands that change the conn state
+// (SELECT, MULTI, blocking reads) must not be batched.
+func (b *Batcher) Do(command string, args ...interface{}) (interface{}, error) {
+	call := &batchCall{command: command, args: args, done: make(chan struct{})}
+	if !b.q.add(call) {
+		return nil, ErrBatcherClosed
+	}
+	<-call.done
+	return call.reply, call.err
+}
+
+// Close flushes the queued commands and stops the loop
+func (b *Batcher) Close() {
+	b.q.close()
+}
+
+func (b *Batcher) flush(items []interface{}) {
+	batch := make([]*batchCall, len(items))
+	for i, item := range items {
+		batch[i] = item.(*batchCall)
+	}
+	defer func() {
+		for _, call := range batch {
+			close(call.done)
+		}
+	}()
+	c := b.p.Pop()
+	if c == nil {
+		for _, call := range batch {
+			call.err = ErrNoConn
+		}
+		return
+	}
+	defer b.p.Push(c)
+	sent := make([]*batchCall, 0, len(batch))
+	for _, call := range batch {
+		// refused client-side, e.g. by a policy
+		if call.err = c.PipeSend(call.command, call.args...); call.err == nil {
+			sent = append(sent, call)
+		}
+	}
+	ret, errs, e := c.pipeExec(time.Time{})
+	for i, call := range sent {
+		if e != nil {
+			call.err = e
+		} else {
+			call.reply, call.err = ret[i], errs[i]
+		}
+	}
+}
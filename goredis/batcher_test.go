@@ -0,0 +1,48 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "BAD" {
+			return "-ERR unknown command\r\n"
+		}
+		return "$" + strconv.Itoa(len(args[1])) + "\r\n" + args[1] + "\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	b := NewBatcher(p, 10, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 35; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "k" + strconv.Itoa(i)
+			ret, e := b.Do("GET", key)
+			if e != nil || string(ret.([]byte)) != key {
+				t.Errorf("%s: %v %v", key, ret, e)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, e := b.Do("BAD", "x"); !isReplyError(e) {
+		t.Fatalf("got %v", e)
+	}
+	start := time.Now()
+	if _, e := b.Do("GET", "alone"); e != nil {
+		t.Fatal(e)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("single command waited for a full batch")
+	}
+	b.Close()
+	if _, e := b.Do("GET", "k"); e != ErrBatcherClosed {
+		t.Fatalf("got %v", e)
+	}
+}
@@ -0,0 +1,258 @@
+// Package bench is a redis-benchmark like load generator for goredis. It
+// drives a weighted command mix through a plain Conn per client, a shared
+// Pool or pipelined Conns and reports throughput and latency percentiles,
+// so client performance regressions are measurable.
+package bench
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	msgredis "github.com/alextomshion/goredis/goredis"
+)
+
+const (
+	// one Dial per client
+	ModeConn = "conn"
+	// every client pops from one shared Pool per request
+	ModePool = "pool"
+	// one Dial per client, Pipeline commands per round trip
+	ModePipeline = "pipeline"
+)
+
+var ErrBadMode = errors.New("bench: unknown mode")
+
+// Op is one entry of the command mix. Args builds the arguments from the
+// key picked for this request.
+type Op struct {
+	Weight  int
+	Command string
+	Args    func(key string, value []byte) []interface{}
+}
+
+type Config struct {
+	Mode     string
+	Addr     string
+	Password string
+	Clients  int
+	// stop after Requests commands or Duration, whichever comes first
+	Requests int64
+	Duration time.Duration
+	// commands per round trip in ModePipeline
+	Pipeline  int
+	Mix       []Op
+	KeySpace  int
+	ValueSize int
+}
+
+type Result struct {
+	Requests  int64
+	Errors    int64
+	Elapsed   time.Duration
+	OpsPerSec float64
+	// per command, per round trip in ModePipeline
+	Latency msgredis.LatencySnapshot
+}
+
+func (r *Result) String() string {
+	return strconv.FormatInt(r.Requests, 10) + " requests in " + r.Elapsed.String() +
+		", " + strconv.FormatFloat(r.OpsPerSec, 'f', 0, 64) + " ops/s, " +
+		strconv.FormatInt(r.Errors, 10) + " errors, p50=" + r.Latency.P50.String() +
+		" p95=" + r.Latency.P95.String() + " p99=" + r.Latency.P99.String() +
+		" max=" + r.Latency.Max.String()
+}
+
+// 50% GET, 50% SET like redis-benchmark -t get,set
+func DefaultMix() []Op {
+	return []Op{
+		{Weight: 1, Command: "SET", Args: func(key string, value []byte) []interface{} {
+			return []interface{}{key, value}
+		}},
+		{Weight: 1, Command: "GET", Args: func(key string, value []byte) []interface{} {
+			return []interface{}{key}
+		}},
+	}
+}
+
+type runner struct {
+	cfg     Config
+	total   int
+	value   []byte
+	hist    msgredis.LatencyHistogram
+	issued  int64
+	errors  int64
+	stopped int32
+}
+
+// Run executes the benchmark described by cfg
+func Run(cfg Config) (*Result, error) {
+	if cfg.Clients <= 0 {
+		cfg.Clients = 50
+	}
+	if cfg.Pipeline <= 0 {
+		cfg.Pipeline = 1
+	}
+	if cfg.KeySpace <= 0 {
+		cfg.KeySpace = 100000
+	}
+	if cfg.ValueSize <= 0 {
+		cfg.ValueSize = 3
+	}
+	if len(cfg.Mix) == 0 {
+		cfg.Mix = DefaultMix()
+	}
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		cfg.Requests = 100000
+	}
+	r := &runner{cfg: cfg, value: []byte(strings.Repeat("x", cfg.ValueSize))}
+	for _, op := range cfg.Mix {
+		r.total += op.Weight
+	}
+
+	var pool *msgredis.Pool
+	switch cfg.Mode {
+	case ModeConn, ModePipeline:
+	case ModePool:
+		pool = msgredis.NewPool(cfg.Addr, cfg.Password)
+		// every client has pushed its conn back when Run returns
+		defer pool.Shutdown(context.Background())
+	default:
+		return nil, ErrBadMode
+	}
+
+	if cfg.Duration > 0 {
+		timer := time.AfterFunc(cfg.Duration, func() { atomic.StoreInt32(&r.stopped, 1) })
+		defer timer.Stop()
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, cfg.Clients)
+	for i := 0; i < cfg.Clients; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			if e := r.client(pool, rand.New(rand.NewSource(seed))); e != nil {
+				errs <- e
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(errs)
+	if e := <-errs; e != nil {
+		return nil, e
+	}
+
+	done := atomic.LoadInt64(&r.issued)
+	if cfg.Requests > 0 && done > cfg.Requests {
+		done = cfg.Requests
+	}
+	return &Result{
+		Requests:  done,
+		Errors:    atomic.LoadInt64(&r.errors),
+		Elapsed:   elapsed,
+		OpsPerSec: float64(done) / elapsed.Seconds(),
+		Latency:   r.hist.Snapshot(),
+	}, nil
+}
+
+// claims n more requests, returns how many may be sent
+func (r *runner) claim(n int) int {
+	if atomic.LoadInt32(&r.stopped) == 1 {
+		return 0
+	}
+	end := atomic.AddInt64(&r.issued, int64(n))
+	if r.cfg.Requests <= 0 || end <= r.cfg.Requests {
+		return n
+	}
+	if left := r.cfg.Requests - (end - int64(n)); left > 0 {
+		return int(left)
+	}
+	return 0
+}
+
+func (r *runner) pick(rnd *rand.Rand) (string, []interface{}) {
+	w := rnd.Intn(r.total)
+	op := r.cfg.Mix[0]
+	for _, o := range r.cfg.Mix {
+		if w < o.Weight {
+			op = o
+			break
+		}
+		w -= o.Weight
+	}
+	key := "key:" + strconv.Itoa(rnd.Intn(r.cfg.KeySpace))
+	return op.Command, op.Args(key, r.value)
+}
+
+func (r *runner) client(pool *msgredis.Pool, rnd *rand.Rand) error {
+	var c *msgredis.Conn
+	if pool == nil {
+		var e error
+		c, e = msgredis.Dial(r.cfg.Addr, r.cfg.Password, msgredis.ConnectTimeout, msgredis.ReadTimeout, msgredis.WriteTimeout, false, nil)
+		if e != nil {
+			return e
+		}
+		defer c.Close()
+	}
+
+	batch := 1
+	if r.cfg.Mode == ModePipeline {
+		batch = r.cfg.Pipeline
+	}
+	for {
+		n := r.claim(batch)
+		if n == 0 {
+			return nil
+		}
+		start := time.Now()
+		switch r.cfg.Mode {
+		case ModePool:
+			pc := pool.Pop()
+			if pc == nil {
+				atomic.AddInt64(&r.errors, 1)
+				continue
+			}
+			command, args := r.pick(rnd)
+			if _, e := pc.Call(command, args...); e != nil {
+				atomic.AddInt64(&r.errors, 1)
+			}
+			pool.Push(pc)
+		case ModeConn:
+			command, args := r.pick(rnd)
+			if _, e := c.Call(command, args...); e != nil {
+				atomic.AddInt64(&r.errors, 1)
+			}
+		case ModePipeline:
+			sent := 0
+			for i := 0; i < n; i++ {
+				command, args := r.pick(rnd)
+				if e := c.PipeSend(command, args...); e != nil {
+					atomic.AddInt64(&r.errors, 1)
+					continue
+				}
+				sent++
+			}
+			_, errs, e := c.PipeExecErrs()
+			if e != nil {
+				atomic.AddInt64(&r.errors, int64(sent))
+			}
+			for _, err := range errs {
+				if err != nil {
+					atomic.AddInt64(&r.errors, 1)
+				}
+			}
+		}
+		r.hist.Record(time.Since(start))
+		if c != nil && c.Broken() {
+			return errors.New("bench: conn broken")
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package bench
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers SET with +OK and every other command with an error reply
+func serve(t *testing.T) string {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, e := ln.Accept()
+			if e != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					command, e := readCommand(r)
+					if e != nil {
+						return
+					}
+					reply := "-ERR not supported\r\n"
+					if command == "SET" {
+						reply = "+OK\r\n"
+					}
+					if _, e = io.WriteString(conn, reply); e != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// readCommand reads one RESP request and returns its name
+func readCommand(r *bufio.Reader) (string, error) {
+	line, e := r.ReadString('\n')
+	if e != nil {
+		return "", e
+	}
+	n, e := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if e != nil || n <= 0 {
+		return "", errors.New("bad request")
+	}
+	var command string
+	for i := 0; i < n; i++ {
+		if _, e = r.ReadString('\n'); e != nil {
+			return "", e
+		}
+		arg, e := r.ReadString('\n')
+		if e != nil {
+			return "", e
+		}
+		if i == 0 {
+			command = strings.TrimSuffix(arg, "\r\n")
+		}
+	}
+	return command, nil
+}
+
+func TestRun(t *testing.T) {
+	addr := serve(t)
+	getOnly := []Op{{Weight: 1, Command: "GET", Args: func(key string, value []byte) []interface{} {
+		return []interface{}{key}
+	}}}
+	cases := []struct {
+		mode   string
+		mix    []Op
+		errors int64
+		// latency samples: one per command, per round trip when pipelining
+		samples uint64
+	}{
+		{ModeConn, nil, -1, 100},
+		{ModePool, getOnly, 100, 100},
+		{ModePipeline, getOnly, 100, 20},
+		{ModePipeline, nil, -1, 20},
+	}
+	for _, tc := range cases {
+		r, e := Run(Config{Mode: tc.mode, Addr: addr, Clients: 2, Requests: 100, Pipeline: 5, Mix: tc.mix})
+		if e != nil {
+			t.Fatalf("%s: %v", tc.mode, e)
+		}
+		if r.Requests != 100 || r.Latency.Count != tc.samples || r.OpsPerSec <= 0 {
+			t.Fatalf("%s: %s, %d samples", tc.mode, r, r.Latency.Count)
+		}
+		// the default mix is half GETs
+		if tc.errors >= 0 && r.Errors != tc.errors || tc.errors < 0 && (r.Errors == 0 || r.Errors == 100) {
+			t.Fatalf("%s: %d errors", tc.mode, r.Errors)
+		}
+		l := r.Latency
+		if l.P50 > l.P95 || l.P95 > l.P99 || l.P99 > l.Max || l.Max <= 0 {
+			t.Fatalf("%s: percentiles %+v", tc.mode, l)
+		}
+	}
+	if _, e := Run(Config{Mode: "nope", Addr: addr}); e != ErrBadMode {
+		t.Fatalf("got %v", e)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	r := &runner{cfg: Config{Requests: 10}}
+	got := []int{r.claim(4), r.claim(4), r.claim(4), r.claim(4)}
+	if got[0] != 4 || got[1] != 4 || got[2] != 2 || got[3] != 0 {
+		t.Fatalf("claims %v", got)
+	}
+	// by duration only
+	r = &runner{}
+	if n := r.claim(5); n != 5 {
+		t.Fatalf("claimed %d", n)
+	}
+	r.stopped = 1
+	if n := r.claim(5); n != 0 {
+		t.Fatalf("claimed %d after stop", n)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	r := &Result{Requests: 1000, Errors: 2, Elapsed: time.Second, OpsPerSec: 1000}
+	r.Latency.P50, r.Latency.P99 = time.Millisecond, 3*time.Millisecond
+	if s := r.String(); s != "1000 requests in 1s, 1000 ops/s, 2 errors, p50=1ms p95=0s p99=3ms max=0s" {
+		t.Fatalf("%q", s)
+	}
+}
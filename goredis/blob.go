@@ -0,0 +1,159 @@
+package msgredis
+
+import (
+	"errors"
+	"io"
+)
+
+// 512KB per GETRANGE/SETRANGE
+const DefaultBlobChunkSize = 512 * 1024
+
+var (
+	ErrBadOffset    = errors.New(CommonErrPrefix + "negative offset")
+	ErrBadChunkSize = errors.New(CommonErrPrefix + "blob chunk size must be positive")
+)
+
+// binary safe SETRANGE, returns the length of the string after the write
+func (c *Conn) SETRANGEBYTES(key string, offset int64, value []byte) (int64, error) {
+	v, e := c.Call("SETRANGE", key, offset, value)
+	if e != nil {
+		return -1, e
+	}
+	return v.(int64), nil
+}
+
+// GETRANGE with int64 offsets, end is inclusive
+func (c *Conn) GETRANGEBYTES(key string, start, end int64) ([]byte, error) {
+	v, e := c.Call("GETRANGE", key, start, end)
+	if e != nil {
+		return nil, e
+	}
+	return v.([]byte), nil
+}
+
+// Blob reads and writes one large string value in ChunkSize ranges, so
+// files or model weights never travel in a single huge request or reply.
+// It implements io.Reader, io.ReaderAt, io.Writer, io.WriterAt and io.Seeker.
+// Reads and writes fail with ErrBadChunkSize if ChunkSize is not positive.
+type Blob struct {
+	c         *Conn
+	key       string
+	off       int64
+	ChunkSize int
+}
+
+func NewBlob(c *Conn, key string) *Blob {
+	return &Blob{c: c, key: key, ChunkSize: DefaultBlobChunkSize}
+}
+
+func (b *Blob) Size() (int64, error) {
+	return b.c.STRLEN(b.key)
+}
+
+func (b *Blob) check(off int64) error {
+	if off < 0 {
+		return ErrBadOffset
+	}
+	if b.ChunkSize <= 0 {
+		return ErrBadChunkSize
+	}
+	return nil
+}
+
+func (b *Blob) ReadAt(p []byte, off int64) (int, error) {
+	if e := b.check(off); e != nil {
+		return 0, e
+	}
+	n := 0
+	for n < len(p) {
+		size := len(p) - n
+		if size > b.ChunkSize {
+			size = b.ChunkSize
+		}
+		chunk, e := b.c.GETRANGEBYTES(b.key, off+int64(n), off+int64(n+size)-1)
+		if e != nil {
+			return n, e
+		}
+		n += copy(p[n:], chunk)
+		if len(chunk) < size {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (b *Blob) Read(p []byte) (int, error) {
+	n, e := b.ReadAt(p, b.off)
+	b.off += int64(n)
+	if e == io.EOF && n > 0 {
+		e = nil
+	}
+	return n, e
+}
+
+// chunks are pipelined, one round trip per write
+func (b *Blob) WriteAt(p []byte, off int64) (int, error) {
+	if e := b.check(off); e != nil {
+		return 0, e
+	}
+	var e error
+	for i := 0; i < len(p) && e == nil; i += b.ChunkSize {
+		j := i + b.ChunkSize
+		if j > len(p) {
+			j = len(p)
+		}
+		e = b.c.PipeSend("SETRANGE", b.key, off+int64(i), p[i:j])
+	}
+	// read what was queued even after a failed PipeSend, any chunk may fail
+	if _, pe := b.c.pipeExecAll(); e == nil {
+		e = pe
+	}
+	if e != nil {
+		return 0, e
+	}
+	return len(p), nil
+}
+
+func (b *Blob) Write(p []byte) (int, error) {
+	n, e := b.WriteAt(p, b.off)
+	b.off += int64(n)
+	return n, e
+}
+
+func (b *Blob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.off
+	case io.SeekEnd:
+		size, e := b.Size()
+		if e != nil {
+			return b.off, e
+		}
+		offset += size
+	default:
+		return b.off, ErrBadArgs
+	}
+	if offset < 0 {
+		return b.off, ErrBadOffset
+	}
+	b.off = offset
+	return offset, nil
+}
+
+// PutBlob replaces key with the content of r, returns bytes written
+func PutBlob(c *Conn, key string, r io.Reader) (int64, error) {
+	if _, e := c.DEL([]string{key}); e != nil {
+		return 0, e
+	}
+	b := NewBlob(c, key)
+	buf := make([]byte, b.ChunkSize*4)
+	return io.CopyBuffer(b, r, buf)
+}
+
+// GetBlob streams the value of key into w, returns bytes read
+func GetBlob(c *Conn, key string, w io.Writer) (int64, error) {
+	b := NewBlob(c, key)
+	buf := make([]byte, b.ChunkSize)
+	return io.CopyBuffer(w, b, buf)
+}
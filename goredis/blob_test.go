@@ -0,0 +1,97 @@
+package msgredis
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// in memory GETRANGE/SETRANGE/STRLEN/DEL of a single value
+func rangeHandler() func(args []string) string {
+	var mu sync.Mutex
+	var value []byte
+	return func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "DEL":
+			value = nil
+			return ":1\r\n"
+		case "STRLEN":
+			return ":" + strconv.Itoa(len(value)) + "\r\n"
+		case "SETRANGE":
+			off, _ := strconv.Atoi(args[2])
+			if need := off + len(args[3]); need > len(value) {
+				value = append(value, make([]byte, need-len(value))...)
+			}
+			copy(value[off:], args[3])
+			return ":" + strconv.Itoa(len(value)) + "\r\n"
+		case "GETRANGE":
+			start, _ := strconv.Atoi(args[2])
+			end, _ := strconv.Atoi(args[3])
+			if end >= len(value) {
+				end = len(value) - 1
+			}
+			if start > end {
+				return "$0\r\n\r\n"
+			}
+			v := value[start : end+1]
+			return "$" + strconv.Itoa(len(v)) + "\r\n" + string(v) + "\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestBlob(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, rangeHandler()))
+	data := bytes.Repeat([]byte("0123456789abcdef\x00\r\n"), 1000)
+
+	b := NewBlob(c, "blob")
+	b.ChunkSize = 333
+	if n, e := b.Write(data); e != nil || n != len(data) {
+		t.Fatalf("Write: n=%d e=%v", n, e)
+	}
+	if _, e := b.Seek(0, 0); e != nil {
+		t.Fatal(e)
+	}
+	var out bytes.Buffer
+	buf := make([]byte, 1000)
+	for {
+		n, e := b.Read(buf)
+		out.Write(buf[:n])
+		if e != nil || n == 0 {
+			break
+		}
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("read back %d bytes, want %d", out.Len(), len(data))
+	}
+
+	part := make([]byte, 5)
+	if n, e := b.ReadAt(part, 16); e != nil || string(part[:n]) != "\x00\r\n01" {
+		t.Fatalf("ReadAt: %q %v", part[:n], e)
+	}
+}
+
+func TestBlobErrors(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		// the first chunk overflows, the last one succeeds
+		if args[0] == "SETRANGE" && args[2] == "0" {
+			return "-ERR string exceeds maximum allowed size\r\n"
+		}
+		return ":8\r\n"
+	}))
+	b := NewBlob(c, "blob")
+	b.ChunkSize = 4
+	if n, e := b.WriteAt([]byte("abcdefgh"), 0); n != 0 || !isReplyError(e) {
+		t.Fatalf("wrote %d, %v", n, e)
+	}
+	b.ChunkSize = 0
+	if _, e := b.WriteAt([]byte("abc"), 0); e != ErrBadChunkSize {
+		t.Fatalf("write: %v", e)
+	}
+	if _, e := b.ReadAt(make([]byte, 3), 0); e != ErrBadChunkSize {
+		t.Fatalf("read: %v", e)
+	}
+}
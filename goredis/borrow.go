@@ -0,0 +1,58 @@
+package msgredis
+
+import (
+	"errors"
+)
+
+// ErrReplyReused is returned by CheckReply for a borrowed reply whose
+// buffer the conn has reused since
+var ErrReplyReused = errors.New(CommonErrPrefix + "borrowed reply used after the next command")
+
+// CallBorrowed is Call on the fast path: the bulk strings of the reply
+// borrow the conn buffer and are only valid until the next command is sent
+// on c, or c is pushed back to its pool. Keep a part of it with CopyReply.
+// Call stays the safe path, its replies are owned by the caller.
+//
+// Builds with the redisdebug tag poison the buffer when it is reused and
+// CheckReply reports borrowed replies used too late.
+func (c *Conn) CallBorrowed(command string, args ...interface{}) (interface{}, error) {
+	mode := c.decodeMode
+	c.decodeMode = DecodeZeroCopy
+	defer func() {
+		c.decodeMode = mode
+	}()
+	return c.Call(command, args...)
+}
+
+// CopyReply returns a deep copy of a reply owning all its bytes
+func CopyReply(v interface{}) interface{} {
+	switch data := v.(type) {
+	case []byte:
+		return append([]byte(nil), data...)
+	case []interface{}:
+		out := make([]interface{}, len(data))
+		for i, x := range data {
+			out[i] = CopyReply(x)
+		}
+		return out
+	}
+	return v
+}
+
+// CheckReply returns ErrReplyReused if v borrows a buffer that was reused,
+// always nil without the redisdebug build tag
+func CheckReply(v interface{}) error {
+	switch data := v.(type) {
+	case []byte:
+		if len(data) > 0 && retired(data) {
+			return ErrReplyReused
+		}
+	case []interface{}:
+		for _, x := range data {
+			if e := CheckReply(x); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build redisdebug
+
+package msgredis
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// poison written over reused buffers, stale values read as 0xdd bytes
+const arenaPoison = 0xdd
+
+// buffers kept to recognize stale values, the oldest are forgotten
+const maxRetiredArenas = 1024
+
+var retiredArenas struct {
+	mu sync.Mutex
+	// kept referenced so their addresses are not handed out again
+	arenas [][]byte
+}
+
+// resetArena poisons the zero-copy buffer and retires it instead of
+// reusing it, a new one is allocated for the next replies
+func (c *Conn) resetArena() {
+	if len(c.arena) == 0 {
+		return
+	}
+	for i := range c.arena {
+		c.arena[i] = arenaPoison
+	}
+	retiredArenas.mu.Lock()
+	if len(retiredArenas.arenas) >= maxRetiredArenas {
+		retiredArenas.arenas = retiredArenas.arenas[1:]
+	}
+	retiredArenas.arenas = append(retiredArenas.arenas, c.arena)
+	retiredArenas.mu.Unlock()
+	c.arena = nil
+}
+
+// retired reports whether b lies in a retired buffer
+func retired(b []byte) bool {
+	p := uintptr(unsafe.Pointer(unsafe.SliceData(b)))
+	retiredArenas.mu.Lock()
+	defer retiredArenas.mu.Unlock()
+	for _, arena := range retiredArenas.arenas {
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(arena)))
+		if p >= start && p < start+uintptr(cap(arena)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+//go:build redisdebug
+
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestCheckReply(t *testing.T) {
+	c := dialFake(t, echoServer(t))
+	v, _ := c.CallBorrowed("ECHO", "one")
+	kept := CopyReply(v)
+	if e := CheckReply(v); e != nil {
+		t.Fatal(e)
+	}
+	c.Call("ECHO", "two")
+	if e := CheckReply(v); e != ErrReplyReused {
+		t.Fatalf("got %v", e)
+	}
+	if b := v.([]interface{})[0].([]byte); b[0] != arenaPoison {
+		t.Fatalf("not poisoned: %q", b)
+	}
+	if e := CheckReply(kept); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,34 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func echoServer(t *testing.T) *fakeServer {
+	return newFakeServer(t, func(args []string) string {
+		return respArray(args[1:]...)
+	})
+}
+
+func TestCallBorrowed(t *testing.T) {
+	c := dialFake(t, echoServer(t))
+	v, e := c.CallBorrowed("ECHO", "one", "two")
+	if e != nil {
+		t.Fatal(e)
+	}
+	kept := CopyReply(v).([]interface{})
+	borrowed := v.([]interface{})[0].([]byte)
+	if c.decodeMode != DecodeCopy {
+		t.Fatal("mode not restored")
+	}
+	c.CallBorrowed("ECHO", "xyz")
+	if string(borrowed) == "one" {
+		t.Fatal("buffer not reused")
+	}
+	if string(kept[0].([]byte)) != "one" || string(kept[1].([]byte)) != "two" {
+		t.Fatalf("copy changed: %q", kept)
+	}
+	if e = CheckReply(kept); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,111 @@
+package msgredis
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBridgeCount = 100
+	DefaultBridgeBlock = 5 * time.Second
+	// checkpoint keys are <prefix><bridge name>
+	BridgeCheckpointPrefix = "stream-bridge:"
+)
+
+// StreamBridge tails a stream and hands every entry to Handler, the ID of
+// the last handled entry is checkpointed in redis so a restarted bridge
+// resumes where it stopped. Delivery is at least once: an entry whose
+// checkpoint was not written is handled again after a restart. Bridges with
+// different names tail the same stream independently.
+type StreamBridge struct {
+	Name    string
+	Stream  string
+	Handler func(StreamEntry) error
+	// entries per XREAD and how long XREAD blocks, must stay below the
+	// conn ReadTimeout
+	Count int
+	Block time.Duration
+	// first ID to read when no checkpoint exists, "0" reads the whole
+	// stream, "$" only new entries
+	StartID string
+
+	p        *Pool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewStreamBridge(p *Pool, name, stream string, handler func(StreamEntry) error) *StreamBridge {
+	return &StreamBridge{
+		Name:    name,
+		Stream:  stream,
+		Handler: handler,
+		Count:   DefaultBridgeCount,
+		Block:   DefaultBridgeBlock,
+		StartID: "0",
+		p:       p,
+		stop:    make(chan struct{}),
+	}
+}
+
+func (b *StreamBridge) checkpointKey() string {
+	return BridgeCheckpointPrefix + b.Name
+}
+
+// Checkpoint returns the ID of the last handled entry, "" if none
+func (b *StreamBridge) Checkpoint() (string, error) {
+	c := b.p.Pop()
+	if c == nil {
+		return "", ErrNoConn
+	}
+	defer b.p.Push(c)
+	id, _, e := c.GETOPT(b.checkpointKey())
+	return id, e
+}
+
+// Run tails the stream until Stop, or returns the first error of Handler
+// or redis. Entries after the failed one are not checkpointed, calling Run
+// again retries from the failed entry.
+func (b *StreamBridge) Run() error {
+	last, e := b.Checkpoint()
+	if e != nil {
+		return e
+	}
+	if last == "" {
+		last = b.StartID
+	}
+	for {
+		select {
+		case <-b.stop:
+			return nil
+		default:
+		}
+
+		c := b.p.Pop()
+		if c == nil {
+			return ErrNoConn
+		}
+		entries, e := c.XREAD(b.Count, b.Block, b.Stream, last)
+		if e != nil {
+			b.p.Push(c)
+			return e
+		}
+		for _, entry := range entries {
+			if e = b.Handler(entry); e != nil {
+				break
+			}
+			if _, e = c.Call("SET", b.checkpointKey(), entry.ID); e != nil {
+				break
+			}
+			last = entry.ID
+		}
+		b.p.Push(c)
+		if e != nil {
+			return e
+		}
+	}
+}
+
+// Stop ends Run after the current XREAD returns, at most Block later
+func (b *StreamBridge) Stop() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
@@ -0,0 +1,85 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamBridge(t *testing.T) {
+	var mu sync.Mutex
+	kv := map[string]string{}
+	ids := []string{"1-0", "2-0", "3-0"}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "GET":
+			v, ok := kv[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+		case "SET":
+			kv[args[1]] = args[2]
+			return "+OK\r\n"
+		case "XREAD":
+			// XREAD COUNT n BLOCK ms STREAMS key id
+			after := args[len(args)-1]
+			r := ""
+			n := 0
+			for _, id := range ids {
+				if id > after {
+					r += "*2\r\n$3\r\n" + id + "\r\n" + respArray("f", id)
+					n++
+				}
+			}
+			if n == 0 {
+				time.Sleep(10 * time.Millisecond)
+				return "*-1\r\n"
+			}
+			return "*1\r\n*2\r\n$1\r\ns\r\n*" + strconv.Itoa(n) + "\r\n" + r
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+
+	var handled []string
+	fail := errors.New("handler failed")
+	b := NewStreamBridge(p, "cdc", "s", func(e StreamEntry) error {
+		if e.ID == "2-0" && len(handled) == 1 {
+			return fail
+		}
+		handled = append(handled, e.ID)
+		return nil
+	})
+	if e := b.Run(); e != fail {
+		t.Fatalf("run: %v", e)
+	}
+	if id, _ := b.Checkpoint(); id != "1-0" {
+		t.Fatalf("checkpoint: %q", id)
+	}
+
+	// restart resumes after the checkpoint
+	b2 := NewStreamBridge(p, "cdc", "s", func(e StreamEntry) error {
+		handled = append(handled, e.ID)
+		return nil
+	})
+	b2.Block = 10 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- b2.Run() }()
+	time.Sleep(50 * time.Millisecond)
+	b2.Stop()
+	if e := <-done; e != nil {
+		t.Fatal(e)
+	}
+	if want := "1-0 2-0 3-0"; strings.Join(handled, " ") != want {
+		t.Fatalf("handled %v", handled)
+	}
+	if id, _ := b2.Checkpoint(); id != "3-0" {
+		t.Fatalf("checkpoint: %q", id)
+	}
+}
@@ -0,0 +1,139 @@
+package msgredis
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NodeFunc runs on one conn of every node of a broadcast
+type NodeFunc func(c *Conn) (interface{}, error)
+
+// NodeCall is a NodeFunc sending one command, e.g. CONFIG SET or SCRIPT LOAD
+func NodeCall(command string, args ...interface{}) NodeFunc {
+	return func(c *Conn) (interface{}, error) {
+		return c.Call(command, args...)
+	}
+}
+
+// NodeResult is the outcome of a broadcast on one node
+type NodeResult struct {
+	Addr string
+	Val  interface{}
+	Err  error
+}
+
+// BroadcastError lists the nodes a broadcast failed on, the results of
+// the others are still returned
+type BroadcastError struct {
+	Errs map[string]error
+}
+
+func (e *BroadcastError) Error() string {
+	addrs := make([]string, 0, len(e.Errs))
+	for addr := range e.Errs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	msgs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		msgs[i] = addr + ": " + e.Errs[addr].Error()
+	}
+	return CommonErrPrefix + "broadcast failed on " + strings.Join(msgs, ", ")
+}
+
+// broadcast runs fn on every pool at once, results are in pools order
+func broadcast(pools []*Pool, fn NodeFunc) ([]NodeResult, error) {
+	results := make([]NodeResult, len(pools))
+	var wg sync.WaitGroup
+	for i, p := range pools {
+		results[i].Addr = p.address()
+		wg.Add(1)
+		go func(r *NodeResult, p *Pool) {
+			defer wg.Done()
+			c := p.Pop()
+			if c == nil {
+				r.Err = ErrNoConn
+				return
+			}
+			defer p.Push(c)
+			r.Val, r.Err = fn(c)
+		}(&results[i], p)
+	}
+	wg.Wait()
+	var be *BroadcastError
+	for _, r := range results {
+		if r.Err != nil {
+			if be == nil {
+				be = &BroadcastError{Errs: make(map[string]error)}
+			}
+			be.Errs[r.Addr] = r.Err
+		}
+	}
+	if be != nil {
+		return results, be
+	}
+	return results, nil
+}
+
+// ForEachShard runs fn on every shard concurrently, ejected ones included
+func (mp *MultiPool) ForEachShard(fn NodeFunc) ([]NodeResult, error) {
+	pools := make([]*Pool, len(mp.servers))
+	for i, addr := range mp.servers {
+		pools[i] = mp.pools[addr]
+	}
+	return broadcast(pools, fn)
+}
+
+// ForEachNode runs fn on the master and every replica concurrently, also
+// the ones weighted out of rotation
+func (r *ReadRouter) ForEachNode(fn NodeFunc) ([]NodeResult, error) {
+	r.mu.RLock()
+	pools := []*Pool{r.master}
+	for _, rep := range r.replicas {
+		pools = append(pools, rep.pool)
+	}
+	r.mu.RUnlock()
+	return broadcast(pools, fn)
+}
+
+// ForEachShard runs fn on every master of the cluster, found with CLUSTER
+// SLOTS on seed
+func (r *Resharding) ForEachShard(seed string, fn NodeFunc) ([]NodeResult, error) {
+	return r.forEach(seed, false, fn)
+}
+
+// ForEachNode runs fn on every master and replica of the cluster
+func (r *Resharding) ForEachNode(seed string, fn NodeFunc) ([]NodeResult, error) {
+	return r.forEach(seed, true, fn)
+}
+
+func (r *Resharding) forEach(seed string, replicas bool, fn NodeFunc) ([]NodeResult, error) {
+	p := r.pool(seed)
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	ranges, e := c.CLUSTERSLOTS()
+	p.Push(c)
+	if e != nil {
+		return nil, e
+	}
+	seen := make(map[string]bool)
+	var pools []*Pool
+	add := func(addr string) {
+		if !seen[addr] {
+			seen[addr] = true
+			pools = append(pools, r.pool(addr))
+		}
+	}
+	for _, sr := range ranges {
+		add(sr.Master)
+		if replicas {
+			for _, addr := range sr.Replicas {
+				add(addr)
+			}
+		}
+	}
+	return broadcast(pools, fn)
+}
@@ -0,0 +1,69 @@
+package msgredis
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// a CLUSTER SLOTS node entry for addr
+func slotNode(t *testing.T, addr string) string {
+	host, port, e := net.SplitHostPort(addr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return "*2\r\n$" + strconv.Itoa(len(host)) + "\r\n" + host + "\r\n:" + port + "\r\n"
+}
+
+func TestForEachShard(t *testing.T) {
+	var calls int32
+	handler := func(args []string) string {
+		if args[0] == "CONFIG" {
+			atomic.AddInt32(&calls, 1)
+		}
+		return "+OK\r\n"
+	}
+	a, b := newFakeServer(t, handler), newFakeServer(t, handler)
+	dead := newFakeServer(t, okHandler)
+	dead.ln.Close()
+
+	mp := NewMultiPool([]string{a.Addr(), b.Addr(), dead.Addr()})
+	ret, e := mp.ForEachShard(NodeCall("CONFIG", "SET", "maxmemory", "1gb"))
+	be, ok := e.(*BroadcastError)
+	if !ok || len(be.Errs) != 1 || be.Errs[dead.Addr()] != ErrNoConn {
+		t.Fatalf("got %v", e)
+	}
+	if len(ret) != 3 || ret[0].Addr != a.Addr() || string(ret[1].Val.([]byte)) != "OK" || calls != 2 {
+		t.Fatalf("results %+v, %d calls", ret, calls)
+	}
+}
+
+func TestReshardingForEachNode(t *testing.T) {
+	var flushes int32
+	replica := newFakeServer(t, func(args []string) string {
+		atomic.AddInt32(&flushes, 1)
+		return "-READONLY You can't write against a read only replica.\r\n"
+	})
+	master := newFakeServer(t, func(args []string) string {
+		atomic.AddInt32(&flushes, 1)
+		return "+OK\r\n"
+	})
+	seed := newFakeServer(t, func(args []string) string {
+		return "*1\r\n*4\r\n:0\r\n:16383\r\n" + slotNode(t, master.Addr()) + slotNode(t, replica.Addr())
+	})
+	r := NewResharding("")
+	defer r.Close()
+
+	ret, e := r.ForEachShard(seed.Addr(), NodeCall("FLUSHDB"))
+	if e != nil || len(ret) != 1 || ret[0].Addr != master.Addr() {
+		t.Fatalf("got %+v %v", ret, e)
+	}
+	ret, e = r.ForEachNode(seed.Addr(), NodeCall("FLUSHDB"))
+	if be, ok := e.(*BroadcastError); !ok || len(be.Errs) != 1 || !isReplyError(be.Errs[replica.Addr()]) {
+		t.Fatalf("got %v", e)
+	}
+	if len(ret) != 2 || ret[0].Err != nil || flushes != 3 {
+		t.Fatalf("results %+v, %d flushes", ret, flushes)
+	}
+}
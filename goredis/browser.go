@@ -0,0 +1,162 @@
+package msgredis
+
+const (
+	DefaultPageSize = 50
+	// SCAN calls per page when filters drop most keys
+	DefaultMaxScans = 10
+)
+
+// size command per key type
+var sizeCommands = map[string]string{
+	"string": "STRLEN",
+	"hash":   "HLEN",
+	"list":   "LLEN",
+	"set":    "SCARD",
+	"zset":   "ZCARD",
+	"stream": "XLEN",
+}
+
+type KeyInfo struct {
+	Key  string
+	Type string
+	// milliseconds, -1 if the key has no expire
+	TTL int64
+	// bytes for strings, number of elements for collections
+	Size int64
+	// MEMORY USAGE, only probed if BrowseOptions.Memory is set
+	Memory int64
+}
+
+type BrowseOptions struct {
+	// SCAN MATCH pattern
+	Match string
+	// only keys of this type
+	Type string
+	// keys per page, a page may hold a few more since SCAN batches are never split
+	PageSize int
+	// SCAN calls per page, a page may come back short (even empty) with a
+	// non-zero cursor rather than walk the whole keyspace
+	MaxScans int
+	Memory   bool
+	// extra filter applied after probing, nil keeps every key
+	Filter func(*KeyInfo) bool
+}
+
+type KeyPage struct {
+	Keys []KeyInfo
+	// cursor of the next page, 0 when the scan is complete
+	Cursor int
+}
+
+// KeyBrowser pages through the keyspace for admin UIs.
+type KeyBrowser struct {
+	c   *Conn
+	opt BrowseOptions
+}
+
+func NewKeyBrowser(c *Conn, opt BrowseOptions) *KeyBrowser {
+	if opt.PageSize <= 0 {
+		opt.PageSize = DefaultPageSize
+	}
+	if opt.MaxScans <= 0 {
+		opt.MaxScans = DefaultMaxScans
+	}
+	return &KeyBrowser{c: c, opt: opt}
+}
+
+// Page returns the keys found starting at cursor, 0 is the first page
+func (b *KeyBrowser) Page(cursor int) (*KeyPage, error) {
+	page := &KeyPage{}
+	for scans := 1; ; scans++ {
+		next, elements, e := b.c.SCAN(cursor, b.opt.Match != "", b.opt.Match, true, b.opt.PageSize)
+		if e != nil {
+			return nil, e
+		}
+		keys := make([]string, len(elements))
+		for i, el := range elements {
+			keys[i] = string(el.([]byte))
+		}
+		infos, e := b.probe(keys)
+		if e != nil {
+			return nil, e
+		}
+		page.Keys = append(page.Keys, infos...)
+		page.Cursor = next
+		if next == 0 || len(page.Keys) >= b.opt.PageSize || scans >= b.opt.MaxScans {
+			return page, nil
+		}
+		cursor = next
+	}
+}
+
+func (b *KeyBrowser) probe(keys []string) ([]KeyInfo, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	var e error
+	for _, key := range keys {
+		if e = b.c.PipeSend("TYPE", key); e == nil {
+			e = b.c.PipeSend("PTTL", key)
+		}
+		if e != nil {
+			break
+		}
+	}
+	// read what was queued even after a failed PipeSend
+	ret, pe := b.c.pipeExecAll()
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for i, key := range keys {
+		t, _ := ret[2*i].([]byte)
+		ttl, _ := ret[2*i+1].(int64)
+		// expired since SCAN
+		if string(t) == "none" || (b.opt.Type != "" && string(t) != b.opt.Type) {
+			continue
+		}
+		infos = append(infos, KeyInfo{Key: key, Type: string(t), TTL: ttl})
+	}
+	if len(infos) == 0 {
+		return infos, nil
+	}
+
+	for _, info := range infos {
+		command, ok := sizeCommands[info.Type]
+		if !ok {
+			command = "EXISTS"
+		}
+		e = b.c.PipeSend(command, info.Key)
+		if e == nil && b.opt.Memory {
+			e = b.c.PipeSend("MEMORY", "USAGE", info.Key)
+		}
+		if e != nil {
+			break
+		}
+	}
+	if ret, pe = b.c.pipeExecAll(); e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, e
+	}
+	step := 1
+	if b.opt.Memory {
+		step = 2
+	}
+	filtered := infos[:0]
+	for i := range infos {
+		infos[i].Size, _ = ret[i*step].(int64)
+		if b.opt.Memory {
+			infos[i].Memory, _ = ret[i*step+1].(int64)
+		}
+		if b.opt.Filter == nil || b.opt.Filter(&infos[i]) {
+			filtered = append(filtered, infos[i])
+		}
+	}
+	return filtered, nil
+}
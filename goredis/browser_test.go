@@ -0,0 +1,106 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyBrowser(t *testing.T) {
+	types := map[string]string{"s1": "string", "h1": "hash", "gone": "none", "s2": "string", "l1": "list"}
+	sizes := map[string]string{"STRLEN": ":5\r\n", "HLEN": ":3\r\n", "LLEN": ":2\r\n"}
+	var scans []string
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SCAN":
+			scans = append(scans, args[1])
+			if args[1] == "0" {
+				return "*2\r\n" + bulk("7") + respArray("s1", "h1", "gone")
+			}
+			return "*2\r\n" + bulk("0") + respArray("s2", "l1")
+		case "TYPE":
+			return "+" + types[args[1]] + "\r\n"
+		case "PTTL":
+			if args[1] == "s1" {
+				return ":1000\r\n"
+			}
+			return ":-1\r\n"
+		case "MEMORY":
+			return ":" + map[string]string{"s1": "101", "h1": "102", "s2": "103", "l1": "104"}[args[2]] + "\r\n"
+		}
+		return sizes[args[0]]
+	}))
+	render := func(p *KeyPage) string {
+		var s []string
+		for _, k := range p.Keys {
+			s = append(s, k.Key+":"+k.Type)
+		}
+		return strings.Join(s, ",")
+	}
+
+	// the expired key is skipped, the next page continues from the cursor
+	b := NewKeyBrowser(c, BrowseOptions{PageSize: 2, Memory: true})
+	p, e := b.Page(0)
+	if e != nil || render(p) != "s1:string,h1:hash" || p.Cursor != 7 {
+		t.Fatalf("first page %+v %v", p, e)
+	}
+	// sizes and memory are read in pairs per key
+	if s1, h1 := p.Keys[0], p.Keys[1]; s1.TTL != 1000 || s1.Size != 5 || s1.Memory != 101 ||
+		h1.TTL != -1 || h1.Size != 3 || h1.Memory != 102 {
+		t.Fatalf("probed %+v", p.Keys)
+	}
+	if p, e = b.Page(p.Cursor); e != nil || render(p) != "s2:string,l1:list" || p.Cursor != 0 ||
+		p.Keys[1].Size != 2 || p.Keys[1].Memory != 104 {
+		t.Fatalf("second page %+v %v", p, e)
+	}
+
+	// too few strings in the first batch: the page scans on
+	scans = nil
+	b = NewKeyBrowser(c, BrowseOptions{PageSize: 2, Type: "string"})
+	if p, e = b.Page(0); e != nil || render(p) != "s1:string,s2:string" || p.Cursor != 0 || len(scans) != 2 {
+		t.Fatalf("type filter %+v %v, scans %v", p, e, scans)
+	}
+	if p.Keys[0].Memory != 0 {
+		t.Fatalf("memory probed %+v", p.Keys[0])
+	}
+
+	b = NewKeyBrowser(c, BrowseOptions{Filter: func(k *KeyInfo) bool { return k.Size > 2 }})
+	if p, e = b.Page(0); e != nil || render(p) != "s1:string,h1:hash,s2:string" {
+		t.Fatalf("filter %+v %v", p, e)
+	}
+}
+
+func TestKeyBrowserLimits(t *testing.T) {
+	scans := 0
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SCAN":
+			// an endless keyspace of hashes
+			scans++
+			return "*2\r\n" + bulk("7") + respArray("h1", "h2")
+		case "TYPE":
+			return "+hash\r\n"
+		case "PTTL":
+			return ":-1\r\n"
+		case "MEMORY":
+			return "-ERR MEMORY disabled\r\n"
+		}
+		return ":1\r\n"
+	}))
+
+	b := NewKeyBrowser(c, BrowseOptions{Type: "string", MaxScans: 3})
+	p, e := b.Page(0)
+	if e != nil || len(p.Keys) != 0 || p.Cursor != 7 || scans != 3 {
+		t.Fatalf("page %+v %v after %d scans", p, e, scans)
+	}
+
+	// a failed probe is reported, not read as a zero
+	b = NewKeyBrowser(c, BrowseOptions{Memory: true})
+	if p, e = b.Page(0); e == nil || !isReplyError(e) {
+		t.Fatalf("memory error: %+v %v", p, e)
+	}
+	// every reply was read, the conn is still in sync
+	b = NewKeyBrowser(c, BrowseOptions{MaxScans: 1})
+	if p, e = b.Page(0); e != nil || len(p.Keys) != 2 || p.Keys[0].Size != 1 {
+		t.Fatalf("next page %+v %v", p, e)
+	}
+}
@@ -0,0 +1,48 @@
+package msgredis
+
+import (
+	"strconv"
+	"time"
+)
+
+// RetryError is the last error of CallBudget with the attempts it took
+type RetryError struct {
+	Err      error
+	Attempts int
+	Elapsed  time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error() + " (" + strconv.Itoa(e.Attempts) + " attempts in " + e.Elapsed.String() + ")"
+}
+
+// CallBudget is CallN with an overall budget: reads, writes and the waits
+// between retries never run past budget from now. A failure is returned as
+// a *RetryError.
+func (c *Conn) CallBudget(budget time.Duration, retry int, command string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	ret, attempts, e := c.callRetry(start.Add(budget), retry, false, command, args)
+	if e != nil {
+		return nil, &RetryError{Err: e, Attempts: attempts, Elapsed: time.Since(start)}
+	}
+	return ret, nil
+}
+
+// Call with the conn timeouts shortened to end at deadline
+func (c *Conn) callBefore(deadline time.Time, command string, args []interface{}) (interface{}, error) {
+	left := time.Until(deadline)
+	if left <= 0 {
+		return nil, ErrTimeout
+	}
+	rt, wt := c.readTimeout, c.writeTimeout
+	if rt <= 0 || rt > left {
+		c.readTimeout = left
+	}
+	if wt <= 0 || wt > left {
+		c.writeTimeout = left
+	}
+	defer func() {
+		c.readTimeout, c.writeTimeout = rt, wt
+	}()
+	return c.Call(command, args...)
+}
@@ -0,0 +1,24 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallBudget(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		time.Sleep(300 * time.Millisecond)
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+
+	start := time.Now()
+	_, e := c.CallBudget(100*time.Millisecond, 3, "SLOW")
+	if time.Since(start) > 250*time.Millisecond {
+		t.Fatalf("budget exceeded: %v", time.Since(start))
+	}
+	re, ok := e.(*RetryError)
+	if !ok || re.Attempts != 1 {
+		t.Fatalf("err=%v", e)
+	}
+}
@@ -0,0 +1,148 @@
+package msgredis
+
+import (
+	"strings"
+	"time"
+)
+
+// BusyScriptError is returned for -BUSY replies, the server is blocked by
+// a long running script or function and only accepts SCRIPT KILL,
+// FUNCTION KILL or SHUTDOWN NOSAVE. The command was not executed.
+type BusyScriptError struct {
+	Msg string
+	// the kill sent by the watchdog failed, e.g. -UNKILLABLE for a script
+	// that already wrote
+	KillErr error
+}
+
+func (e *BusyScriptError) Error() string {
+	return CommonErrPrefix + e.Msg
+}
+
+// SetBusyScriptKill enables the watchdog: on -BUSY the conn sends SCRIPT
+// KILL (or FUNCTION KILL) and retries the command once. Redis only kills
+// scripts that did not write yet, others are reported in KillErr.
+func (c *Conn) SetBusyScriptKill(on bool) {
+	c.killBusy = on
+}
+
+// callBusy is call with -BUSY turned into a BusyScriptError
+func (c *Conn) callBusy(command string, args []interface{}) (interface{}, error) {
+	ret, e := c.call(command, args)
+	re, ok := e.(*ReplyError)
+	if !ok || !strings.HasPrefix(re.Msg, "BUSY ") {
+		return ret, e
+	}
+	busy := &BusyScriptError{Msg: re.Msg}
+	if !c.killBusy {
+		return nil, busy
+	}
+	kill := "SCRIPT"
+	if strings.Contains(re.Msg, "FUNCTION KILL") {
+		kill = "FUNCTION"
+	}
+	// -NOTBUSY: it finished meanwhile
+	if _, e = c.call(kill, []interface{}{"KILL"}); e != nil && !strings.Contains(e.Error(), "NOTBUSY") {
+		busy.KillErr = e
+		return nil, busy
+	}
+	return c.call(command, args)
+}
+
+/******************* script and function admin *******************/
+func (c *Conn) SCRIPTKILL() error {
+	return c.okCall("SCRIPT", "KILL")
+}
+
+func (c *Conn) FUNCTIONKILL() error {
+	return c.okCall("FUNCTION", "KILL")
+}
+
+type RunningScript struct {
+	Name     string
+	Command  []string
+	Duration time.Duration
+}
+
+type EngineStats struct {
+	Libraries int64
+	Functions int64
+}
+
+type FunctionStats struct {
+	// nil if no function is running
+	Running *RunningScript
+	Engines map[string]EngineStats
+}
+
+// FUNCTION STATS, also answered while the server is busy
+func (c *Conn) FUNCTIONSTATS() (*FunctionStats, error) {
+	v, e := c.Call("FUNCTION", "STATS")
+	if e != nil {
+		return nil, e
+	}
+	fields, ok := v.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, ErrBadType
+	}
+	stats := &FunctionStats{Engines: make(map[string]EngineStats)}
+	for i := 0; i < len(fields); i += 2 {
+		name, _ := replyString(fields[i])
+		switch name {
+		case "running_script":
+			if fields[i+1] == nil {
+				continue
+			}
+			if stats.Running, e = parseRunningScript(fields[i+1]); e != nil {
+				return nil, e
+			}
+		case "engines":
+			engines, ok := fields[i+1].([]interface{})
+			if !ok || len(engines)%2 != 0 {
+				return nil, ErrBadType
+			}
+			for j := 0; j < len(engines); j += 2 {
+				engine, _ := replyString(engines[j])
+				counts, ok := engines[j+1].([]interface{})
+				if !ok || len(counts)%2 != 0 {
+					return nil, ErrBadType
+				}
+				var es EngineStats
+				for k := 0; k < len(counts); k += 2 {
+					key, _ := replyString(counts[k])
+					n, _ := counts[k+1].(int64)
+					switch key {
+					case "libraries_count":
+						es.Libraries = n
+					case "functions_count":
+						es.Functions = n
+					}
+				}
+				stats.Engines[engine] = es
+			}
+		}
+	}
+	return stats, nil
+}
+
+// [name, n, command, [...], duration_ms, ms]
+func parseRunningScript(v interface{}) (*RunningScript, error) {
+	fields, ok := v.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, ErrBadType
+	}
+	rs := &RunningScript{}
+	for i := 0; i < len(fields); i += 2 {
+		key, _ := replyString(fields[i])
+		switch key {
+		case "name":
+			rs.Name, _ = replyString(fields[i+1])
+		case "command":
+			rs.Command, _ = replyStrings(fields[i+1])
+		case "duration_ms":
+			ms, _ := fields[i+1].(int64)
+			rs.Duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return rs, nil
+}
@@ -0,0 +1,61 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestBusyScript(t *testing.T) {
+	busy, unkillable := true, false
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "SCRIPT" {
+			if unkillable {
+				return "-UNKILLABLE Sorry the script already executed write commands against the dataset.\r\n"
+			}
+			busy = false
+			return "+OK\r\n"
+		}
+		if busy {
+			return "-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n"
+		}
+		return "$1\r\nv\r\n"
+	})
+	c := dialFake(t, s)
+
+	_, e := c.Call("GET", "k")
+	if be, ok := e.(*BusyScriptError); !ok || be.KillErr != nil {
+		t.Fatalf("without watchdog: %#v", e)
+	}
+
+	c.SetBusyScriptKill(true)
+	unkillable = true
+	_, e = c.Call("GET", "k")
+	if be, ok := e.(*BusyScriptError); !ok || be.KillErr == nil {
+		t.Fatalf("unkillable: %#v", e)
+	}
+
+	unkillable = false
+	v, e := c.Call("GET", "k")
+	if e != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("after kill: %v %v", v, e)
+	}
+}
+
+func TestFunctionStats(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		return "*4\r\n$14\r\nrunning_script\r\n" +
+			"*6\r\n$4\r\nname\r\n$4\r\nslow\r\n$7\r\ncommand\r\n*2\r\n$5\r\nFCALL\r\n$4\r\nslow\r\n$11\r\nduration_ms\r\n:1500\r\n" +
+			"$7\r\nengines\r\n*2\r\n$3\r\nLUA\r\n*4\r\n$15\r\nlibraries_count\r\n:2\r\n$15\r\nfunctions_count\r\n:5\r\n"
+	})
+	c := dialFake(t, s)
+
+	stats, e := c.FUNCTIONSTATS()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if stats.Running == nil || stats.Running.Name != "slow" || stats.Running.Duration.Seconds() != 1.5 || len(stats.Running.Command) != 2 {
+		t.Fatalf("running: %+v", stats.Running)
+	}
+	if stats.Engines["LUA"] != (EngineStats{Libraries: 2, Functions: 5}) {
+		t.Fatalf("engines: %+v", stats.Engines)
+	}
+}
@@ -0,0 +1,278 @@
+package msgredis
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// first byte of a cached entry
+const (
+	cacheValue    = 'v'
+	cacheNotFound = 'n'
+	// followed by the soft expiry, unix nanoseconds big endian
+	cacheSoftValue = 's'
+)
+
+// Loader fetches a value from the backing store on a cache miss, it
+// returns ErrKeyNotExist when the entity does not exist
+type Loader func(key string) ([]byte, error)
+
+// loadGroup runs one loader call per key at a time, concurrent callers
+// of the same key wait for it and share its result
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+type loadCall struct {
+	value []byte
+	err   error
+	done  chan struct{}
+}
+
+func (g *loadGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.value, call.err = fn()
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// Cache is a read-through cache in redis, key Prefix+key. With NegativeTTL
+// set, loader misses are cached too, as a "not found" marker living
+// NegativeTTL, so lookups of nonexistent entities do not reach the backing
+// store every time.
+//
+// With SoftTTL set (below TTL), entries older than SoftTTL are stale: the
+// stale value is returned at once and one goroutine per key reloads it in
+// the background, so callers never wait for a recomputation until TTL.
+type Cache struct {
+	Prefix      string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	SoftTTL     time.Duration
+
+	p     *Pool
+	loads loadGroup
+	// keys being refreshed in the background
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+func NewCache(p *Pool, prefix string, ttl time.Duration) *Cache {
+	return &Cache{Prefix: prefix, TTL: ttl, p: p}
+}
+
+// lookup returns the entry of key, ok false if nothing is cached
+func (ca *Cache) lookup(key string) (value []byte, ok, stale bool, e error) {
+	c := ca.p.Pop()
+	if c == nil {
+		return nil, false, false, ErrNoConn
+	}
+	defer ca.p.Push(c)
+	v, e := c.Call("GET", ca.Prefix+key)
+	if e != nil || v == nil {
+		return nil, false, false, e
+	}
+	entry, isBytes := v.([]byte)
+	if !isBytes || len(entry) == 0 {
+		return nil, false, false, ErrBadType
+	}
+	switch entry[0] {
+	case cacheNotFound:
+		return nil, true, false, ErrKeyNotExist
+	case cacheSoftValue:
+		if len(entry) < 9 {
+			return nil, false, false, ErrBadType
+		}
+		soft := int64(binary.BigEndian.Uint64(entry[1:9]))
+		return entry[9:], true, time.Now().UnixNano() > soft, nil
+	}
+	return entry[1:], true, false, nil
+}
+
+// store caches value, or the not found marker
+func (ca *Cache) store(key string, value []byte, found bool) error {
+	entry, ttl := append([]byte{cacheValue}, value...), ca.TTL
+	if ca.SoftTTL > 0 {
+		entry = make([]byte, 9, 9+len(value))
+		entry[0] = cacheSoftValue
+		binary.BigEndian.PutUint64(entry[1:], uint64(time.Now().Add(ca.SoftTTL).UnixNano()))
+		entry = append(entry, value...)
+	}
+	if !found {
+		entry, ttl = []byte{cacheNotFound}, ca.NegativeTTL
+	}
+	c := ca.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer ca.p.Push(c)
+	if ttl > 0 {
+		return c.okCall("SET", ca.Prefix+key, entry, "PX", ttl)
+	}
+	return c.okCall("SET", ca.Prefix+key, entry)
+}
+
+// GetOrLoad returns the cached value of key, or loads and caches it.
+// Concurrent misses of one key call load once. Redis errors on the way do
+// not fail the lookup, the value is loaded instead.
+func (ca *Cache) GetOrLoad(key string, load Loader) ([]byte, error) {
+	value, ok, stale, e := ca.lookup(key)
+	if stale {
+		ca.refresh(key, load)
+	}
+	if ok {
+		return value, e
+	}
+	return ca.loads.do(key, func() ([]byte, error) {
+		return ca.load(key, load)
+	})
+}
+
+func (ca *Cache) load(key string, load Loader) ([]byte, error) {
+	value, e := load(key)
+	switch {
+	case e == nil:
+		ca.store(key, value, true)
+	case e == ErrKeyNotExist && ca.NegativeTTL > 0:
+		ca.store(key, nil, false)
+	}
+	return value, e
+}
+
+// refresh reloads a stale key in the background unless already underway.
+// A failed reload keeps the stale value until TTL.
+func (ca *Cache) refresh(key string, load Loader) {
+	ca.mu.Lock()
+	if ca.refreshing == nil {
+		ca.refreshing = make(map[string]bool)
+	}
+	if ca.refreshing[key] {
+		ca.mu.Unlock()
+		return
+	}
+	ca.refreshing[key] = true
+	ca.mu.Unlock()
+	go func() {
+		defer func() {
+			ca.mu.Lock()
+			delete(ca.refreshing, key)
+			ca.mu.Unlock()
+		}()
+		_, e := ca.loads.do(key, func() ([]byte, error) {
+			return ca.load(key, load)
+		})
+		switch {
+		case e == ErrKeyNotExist && ca.NegativeTTL <= 0:
+			// gone from the backing store, drop the stale value
+			ca.Delete(key)
+		case e != nil && e != ErrKeyNotExist:
+			fmt.Println("[Cache] refresh " + key + ": " + e.Error())
+		}
+	}()
+}
+
+// Delete drops cached entries, e.g. after the entity was written
+func (ca *Cache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c := ca.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer ca.p.Push(c)
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = ca.Prefix + key
+	}
+	_, e := c.Call("DEL", args...)
+	return e
+}
+
+type localEntry struct {
+	value []byte
+	// a cached "not found"
+	missing bool
+	expires time.Time
+}
+
+// TwoLevelCache keeps up to MaxLocal entries (0 for no limit) in process
+// for LocalTTL in front of a redis Cache. Local entries are not
+// invalidated by other processes, LocalTTL bounds how stale they get. Not
+// found markers are kept locally for at most the remote NegativeTTL.
+type TwoLevelCache struct {
+	Remote   *Cache
+	LocalTTL time.Duration
+	MaxLocal int
+
+	mu    sync.Mutex
+	local map[string]localEntry
+}
+
+func NewTwoLevelCache(remote *Cache, localTTL time.Duration, maxLocal int) *TwoLevelCache {
+	return &TwoLevelCache{Remote: remote, LocalTTL: localTTL, MaxLocal: maxLocal, local: make(map[string]localEntry)}
+}
+
+func (tc *TwoLevelCache) GetOrLoad(key string, load Loader) ([]byte, error) {
+	now := time.Now()
+	tc.mu.Lock()
+	entry, ok := tc.local[key]
+	tc.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		if entry.missing {
+			return nil, ErrKeyNotExist
+		}
+		return entry.value, nil
+	}
+	value, e := tc.Remote.GetOrLoad(key, load)
+	ttl := tc.LocalTTL
+	if e == ErrKeyNotExist {
+		if tc.Remote.NegativeTTL <= 0 {
+			return nil, e
+		}
+		if tc.Remote.NegativeTTL < ttl {
+			ttl = tc.Remote.NegativeTTL
+		}
+	} else if e != nil {
+		return nil, e
+	}
+	tc.mu.Lock()
+	if tc.MaxLocal > 0 && len(tc.local) >= tc.MaxLocal {
+		// evict an arbitrary entry, map order is random enough
+		for k := range tc.local {
+			delete(tc.local, k)
+			break
+		}
+	}
+	tc.local[key] = localEntry{value: value, missing: e != nil, expires: now.Add(ttl)}
+	tc.mu.Unlock()
+	return value, e
+}
+
+// Delete drops the entries from both levels of this process
+func (tc *TwoLevelCache) Delete(keys ...string) error {
+	tc.mu.Lock()
+	for _, key := range keys {
+		delete(tc.local, key)
+	}
+	tc.mu.Unlock()
+	return tc.Remote.Delete(keys...)
+}
@@ -0,0 +1,122 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// in-memory GET/SET/DEL server, ttls records the PX of each SET
+type memServer struct {
+	mu   sync.Mutex
+	data map[string]string
+	ttls map[string]string
+	gets int32
+	*fakeServer
+}
+
+func newMemServer(t *testing.T) *memServer {
+	m := &memServer{data: map[string]string{}, ttls: map[string]string{}}
+	m.fakeServer = newFakeServer(t, func(args []string) string {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		switch args[0] {
+		case "GET":
+			atomic.AddInt32(&m.gets, 1)
+			v, ok := m.data[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+		case "SET":
+			m.data[args[1]] = args[2]
+			if len(args) == 5 {
+				m.ttls[args[1]] = args[4]
+			}
+		case "DEL":
+			for _, k := range args[1:] {
+				delete(m.data, k)
+			}
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	return m
+}
+
+func TestCacheNegative(t *testing.T) {
+	m := newMemServer(t)
+	ca := NewCache(NewPool(m.Addr(), ""), "c:", time.Minute)
+	ca.NegativeTTL = 5 * time.Second
+	var loads int32
+	load := func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		if key == "ghost" {
+			return nil, ErrKeyNotExist
+		}
+		return []byte("user " + key), nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, e := ca.GetOrLoad("42", load); e != nil || string(v) != "user 42" {
+			t.Fatalf("got %q %v", v, e)
+		}
+		if _, e := ca.GetOrLoad("ghost", load); e != ErrKeyNotExist {
+			t.Fatalf("got %v", e)
+		}
+	}
+	if loads != 2 || m.ttls["c:42"] != "60000" || m.ttls["c:ghost"] != "5000" {
+		t.Fatalf("%d loads, ttls %v", loads, m.ttls)
+	}
+
+	tc := NewTwoLevelCache(ca, time.Minute, 10)
+	gets := atomic.LoadInt32(&m.gets)
+	for i := 0; i < 3; i++ {
+		tc.GetOrLoad("42", load)
+		if _, e := tc.GetOrLoad("ghost", load); e != ErrKeyNotExist {
+			t.Fatalf("got %v", e)
+		}
+	}
+	if n := atomic.LoadInt32(&m.gets) - gets; n != 2 || loads != 2 {
+		t.Fatalf("%d GETs, %d loads", n, loads)
+	}
+
+	ca.NegativeTTL = 0
+	tc.Delete("ghost")
+	tc.GetOrLoad("ghost", load)
+	if _, ok := m.data["c:ghost"]; ok || loads != 3 {
+		t.Fatalf("miss cached without NegativeTTL, %d loads", loads)
+	}
+}
+
+func TestCacheSoftTTL(t *testing.T) {
+	m := newMemServer(t)
+	ca := NewCache(NewPool(m.Addr(), ""), "c:", time.Minute)
+	ca.SoftTTL = 20 * time.Millisecond
+	var loads int32
+	load := func(key string) ([]byte, error) {
+		n := atomic.AddInt32(&loads, 1)
+		if n > 1 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return []byte("v" + strconv.Itoa(int(n))), nil
+	}
+	if v, _ := ca.GetOrLoad("k", load); string(v) != "v1" {
+		t.Fatalf("got %q", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if v, e := ca.GetOrLoad("k", load); e != nil || string(v) != "v1" {
+			t.Fatalf("got %q %v", v, e)
+		}
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Fatal("stale reads waited for the reload")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if v, _ := ca.GetOrLoad("k", load); string(v) != "v2" || atomic.LoadInt32(&loads) != 2 {
+		t.Fatalf("got %q after %d loads", v, loads)
+	}
+}
@@ -0,0 +1,241 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is a server version as reported by INFO server
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses "7.2.4", missing parts are 0
+func ParseVersion(s string) Version {
+	var v Version
+	parts := strings.SplitN(s, ".", 3)
+	dst := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		*dst[i], _ = strconv.Atoi(part)
+	}
+	return v
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+}
+
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// first server version of commands and subcommands
+var commandSince = map[string]Version{
+	"UNLINK":              {4, 0, 0},
+	"SWAPDB":              {4, 0, 0},
+	"MEMORY":              {4, 0, 0},
+	"OBJECT FREQ":         {4, 0, 0},
+	"XADD":                {5, 0, 0},
+	"ZPOPMIN":             {5, 0, 0},
+	"ZPOPMAX":             {5, 0, 0},
+	"HELLO":               {6, 0, 0},
+	"ACL":                 {6, 0, 0},
+	"LPOS":                {6, 0, 6},
+	"GETEX":               {6, 2, 0},
+	"GETDEL":              {6, 2, 0},
+	"COPY":                {6, 2, 0},
+	"LMOVE":               {6, 2, 0},
+	"BLMOVE":              {6, 2, 0},
+	"CLIENT TRACKINGINFO": {6, 2, 0},
+	"RESET":               {6, 2, 0},
+	"ZRANGESTORE":         {6, 2, 0},
+	"ZRANDMEMBER":         {6, 2, 0},
+	"HRANDFIELD":          {6, 2, 0},
+	"GEOSEARCH":           {6, 2, 0},
+	"SMISMEMBER":          {6, 2, 0},
+	"SINTERCARD":          {7, 0, 0},
+	"LMPOP":               {7, 0, 0},
+	"ZMPOP":               {7, 0, 0},
+	"LCS":                 {7, 0, 0},
+	"EXPIRETIME":          {7, 0, 0},
+	"FUNCTION":            {7, 0, 0},
+	"FCALL":               {7, 0, 0},
+	"FCALL_RO":            {7, 0, 0},
+	"EVAL_RO":             {7, 0, 0},
+	"SPUBLISH":            {7, 0, 0},
+	"SSUBSCRIBE":          {7, 0, 0},
+}
+
+// UnsupportedError is returned before sending a command the server is too
+// old for
+type UnsupportedError struct {
+	Command string
+	Need    Version
+	Have    Version
+	// set when the server flavor lacks the command altogether
+	Flavor string
+}
+
+func (e *UnsupportedError) Error() string {
+	if e.Flavor != "" {
+		return CommonErrPrefix + e.Command + " is not available on " + e.Flavor
+	}
+	return CommonErrPrefix + e.Command + " needs redis " + e.Need.String() + ", server is " + e.Have.String()
+}
+
+// Capabilities of a server, see Pool.Capabilities
+type Capabilities struct {
+	Version Version
+	// standalone, cluster or sentinel
+	Mode string
+	// loaded modules and their version
+	Modules map[string]int
+	// redis, valkey, keydb or dragonfly, and its own version
+	Flavor        string
+	FlavorVersion Version
+	// threads serving commands, 1 for redis
+	Threads int
+}
+
+// since returns the version command (with its first argument for
+// subcommands) appeared in
+func since(command string, args []interface{}) (string, Version, bool) {
+	name := strings.ToUpper(command)
+	if len(args) > 0 {
+		sub := name + " " + strings.ToUpper(argString(args[0]))
+		if v, ok := commandSince[sub]; ok {
+			return sub, v, true
+		}
+	}
+	v, ok := commandSince[name]
+	return name, v, ok
+}
+
+// Supports reports whether the server knows command, unknown commands are
+// assumed supported
+func (caps *Capabilities) Supports(command string, args ...interface{}) bool {
+	return caps.check(command, args) == nil
+}
+
+func (caps *Capabilities) check(command string, args []interface{}) error {
+	if name := strings.ToUpper(command); flavorMissing[caps.Flavor][name] {
+		return &UnsupportedError{Command: name, Flavor: caps.Flavor}
+	}
+	name, need, ok := since(command, args)
+	if !ok || !caps.Version.Less(need) {
+		return nil
+	}
+	return &UnsupportedError{Command: name, Need: need, Have: caps.Version}
+}
+
+func (caps *Capabilities) HasModule(name string) bool {
+	_, ok := caps.Modules[strings.ToLower(name)]
+	return ok
+}
+
+// DetectCapabilities reads INFO server and MODULE LIST. Servers without
+// modules support (before 4.0, Dragonfly) or ACL users without MODULE get
+// no modules.
+func (c *Conn) DetectCapabilities() (*Capabilities, error) {
+	info, e := c.InfoSection("server")
+	if e != nil {
+		return nil, e
+	}
+	caps := &Capabilities{
+		Version: ParseVersion(info["redis_version"]),
+		Mode:    info["redis_mode"],
+		Modules: make(map[string]int),
+	}
+	caps.detectFlavor(info)
+	if flavorMissing[caps.Flavor]["MODULE"] {
+		return caps, nil
+	}
+	v, e := c.Call("MODULE", "LIST")
+	if e != nil {
+		if isReplyError(e) {
+			return caps, nil
+		}
+		return nil, e
+	}
+	modules, _ := v.([]interface{})
+	for _, m := range modules {
+		fields, ok := flattenPairs(m)
+		arr, _ := fields.([]interface{})
+		if !ok {
+			continue
+		}
+		var name string
+		var ver int64
+		for i := 0; i+1 < len(arr); i += 2 {
+			switch k, _ := replyString(arr[i]); k {
+			case "name":
+				name, _ = replyString(arr[i+1])
+			case "ver":
+				ver, _ = arr[i+1].(int64)
+			}
+		}
+		if name != "" {
+			caps.Modules[strings.ToLower(name)] = int(ver)
+		}
+	}
+	return caps, nil
+}
+
+// poolCaps caches the capabilities of a pool, errors are not cached
+type poolCaps struct {
+	mu   sync.Mutex
+	caps *Capabilities
+}
+
+// Capabilities detects the server capabilities once and caches them
+func (p *Pool) Capabilities() (*Capabilities, error) {
+	if caps := p.cachedCaps(); caps != nil {
+		return caps, nil
+	}
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer p.Push(c)
+	return p.detect(c)
+}
+
+func (p *Pool) cachedCaps() *Capabilities {
+	p.caps.mu.Lock()
+	defer p.caps.mu.Unlock()
+	return p.caps.caps
+}
+
+func (p *Pool) detect(c *Conn) (*Capabilities, error) {
+	caps, e := c.DetectCapabilities()
+	if e != nil {
+		return nil, e
+	}
+	p.caps.mu.Lock()
+	if p.caps.caps == nil {
+		p.caps.caps = caps
+	}
+	caps = p.caps.caps
+	p.caps.mu.Unlock()
+	return caps, nil
+}
+
+// checkCapabilities refuses commands the pool's server is too old for, once
+// the capabilities are known
+func (c *Conn) checkCapabilities(command string, args []interface{}) error {
+	if c.pool == nil || !c.pool.CheckCapabilities {
+		return nil
+	}
+	caps := c.pool.cachedCaps()
+	if caps == nil {
+		return nil
+	}
+	return caps.check(command, args)
+}
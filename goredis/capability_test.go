@@ -0,0 +1,56 @@
+package msgredis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	v := ParseVersion("6.2.14")
+	if v != (Version{6, 2, 14}) || v.String() != "6.2.14" {
+		t.Fatalf("got %v", v)
+	}
+	if !ParseVersion("6.0.5").Less(ParseVersion("6.0.6")) || ParseVersion("7").Less(ParseVersion("6.2")) {
+		t.Fatal("Less")
+	}
+}
+
+func capsHandler(version string) func([]string) string {
+	return func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			info := "# Server\r\nredis_version:" + version + "\r\nredis_mode:standalone\r\n"
+			return "$" + strconv.Itoa(len(info)) + "\r\n" + info + "\r\n"
+		case "MODULE":
+			return "*1\r\n*4\r\n$4\r\nname\r\n$6\r\nsearch\r\n$3\r\nver\r\n:20603\r\n"
+		}
+		return "+OK\r\n"
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	s := newFakeServer(t, capsHandler("6.0.9"))
+	p := NewPool(s.Addr(), "")
+	p.CheckCapabilities = true
+
+	c := p.Pop()
+	defer p.Push(c)
+	caps, e := p.Capabilities()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if caps.Version != (Version{6, 0, 9}) || caps.Mode != "standalone" || caps.Modules["search"] != 20603 || !caps.HasModule("Search") {
+		t.Fatalf("caps %+v", caps)
+	}
+	if !caps.Supports("GET") || !caps.Supports("LPOS") || caps.Supports("GETDEL") || !caps.Supports("OBJECT", "ENCODING") {
+		t.Fatal("Supports")
+	}
+
+	_, e = c.Call("getdel", "k")
+	if ue, ok := e.(*UnsupportedError); !ok || ue.Command != "GETDEL" || ue.Need != (Version{6, 2, 0}) {
+		t.Fatalf("got %v", e)
+	}
+	if _, e = c.Call("SET", "k", "v"); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,175 @@
+package msgredis
+
+import (
+	"strings"
+	"time"
+)
+
+// how the replies of the chunks are merged
+const (
+	mergeOK     = iota // first status reply, e.g. +OK of MSET
+	mergeSum           // integers added up, e.g. DEL, SADD
+	mergeLast          // last integer, e.g. the list length of RPUSH
+	mergeConcat        // arrays appended, e.g. MGET
+)
+
+// chunkSpec describes a variadic command: prefix leading args repeated in
+// every chunk (the key), then items of step args each
+type chunkSpec struct {
+	prefix int
+	step   int
+	merge  int
+}
+
+var chunkCommands = map[string]chunkSpec{
+	"MSET":   {0, 2, mergeOK},
+	"MGET":   {0, 1, mergeConcat},
+	"DEL":    {0, 1, mergeSum},
+	"UNLINK": {0, 1, mergeSum},
+	"EXISTS": {0, 1, mergeSum},
+	"TOUCH":  {0, 1, mergeSum},
+	"SADD":   {1, 1, mergeSum},
+	"SREM":   {1, 1, mergeSum},
+	"HSET":   {1, 2, mergeSum},
+	"HMSET":  {1, 2, mergeOK},
+	"HDEL":   {1, 1, mergeSum},
+	"ZREM":   {1, 1, mergeSum},
+	"LPUSH":  {1, 1, mergeLast},
+	"RPUSH":  {1, 1, mergeLast},
+}
+
+// SetAutoChunk splits the commands of chunkCommands with more than n args
+// into several pipelined commands of at most n args. The split command is
+// not atomic anymore: other clients may see it half applied, and a failed
+// chunk does not undo the others. 0 disables it.
+func (c *Conn) SetAutoChunk(n int) {
+	c.chunkArgs = n
+}
+
+// callAuto is callBusy, or callChunked for commands over the chunk size
+// and callPush for subscriptions in RESP3
+func (c *Conn) callAuto(command string, args []interface{}) (interface{}, error) {
+	if spec, ok := c.chunkable(command, args); ok {
+		return c.callChunked(command, spec, args)
+	}
+	if _, ok := subscribeKinds[strings.ToUpper(command)]; ok && c.resp3 {
+		return c.callPush(command, args)
+	}
+	return c.callBusy(command, args)
+}
+
+// chunkable returns the spec when command must be split
+func (c *Conn) chunkable(command string, args []interface{}) (chunkSpec, bool) {
+	if c.chunkArgs <= 0 || len(args) <= c.chunkArgs {
+		return chunkSpec{}, false
+	}
+	spec, ok := chunkCommands[strings.ToUpper(command)]
+	if !ok || len(args) < spec.prefix || (len(args)-spec.prefix)%spec.step != 0 {
+		// malformed, let the server report it
+		return chunkSpec{}, false
+	}
+	return spec, true
+}
+
+// callChunked writes all chunks, then reads every reply so the conn stays
+// in sync even when a chunk fails. The first error is returned.
+func (c *Conn) callChunked(command string, spec chunkSpec, args []interface{}) (interface{}, error) {
+	c.lastActiveTime = time.Now().Unix()
+	start := time.Now()
+	if c.broken {
+		return nil, ErrBrokenConn
+	}
+	size := c.chunkArgs - spec.prefix
+	size -= size % spec.step
+	if size < spec.step {
+		size = spec.step
+	}
+	prefix, items := args[:spec.prefix], args[spec.prefix:]
+
+	var e error
+	if c.writeTimeout > 0 {
+		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+			return nil, e
+		}
+	}
+	n := 0
+	for off := 0; off < len(items); off += size {
+		end := off + size
+		if end > len(items) {
+			end = len(items)
+		}
+		chunk := make([]interface{}, 0, spec.prefix+end-off)
+		chunk = append(append(chunk, prefix...), items[off:end]...)
+		if e = c.writeRequest(command, chunk); e != nil {
+			c.broken = true
+			return nil, e
+		}
+		n++
+	}
+	if e = c.wb.Flush(); e != nil {
+		c.broken = true
+		return nil, e
+	}
+	c.addInflight(n)
+	defer c.addInflight(-n)
+	if c.pool != nil {
+		c.pool.callMu.Lock()
+		c.pool.CallNum += int64(n)
+		c.pool.callMu.Unlock()
+	}
+
+	var ret interface{}
+	var sum int64
+	var arr []interface{}
+	var first error
+	for i := 0; i < n; i++ {
+		if c.readTimeout > 0 {
+			if e = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); e != nil {
+				c.broken = true
+				return nil, e
+			}
+		}
+		c.replySize = 0
+		reply, e := c.readResponse()
+		if e != nil {
+			c.checkBroken(e)
+			if c.broken {
+				return nil, e
+			}
+			if first == nil {
+				first = e
+			}
+			continue
+		}
+		switch spec.merge {
+		case mergeOK:
+			if ret == nil {
+				ret = reply
+			}
+		case mergeSum, mergeLast:
+			v, ok := reply.(int64)
+			if !ok {
+				if first == nil {
+					first = ErrBadType
+				}
+				continue
+			}
+			if spec.merge == mergeLast {
+				sum = 0
+			}
+			sum += v
+			ret = sum
+		case mergeConcat:
+			a, _ := reply.([]interface{})
+			arr = append(arr, a...)
+			ret = arr
+		}
+	}
+	if c.pool != nil {
+		c.pool.recordCall(command, time.Since(start), first)
+	}
+	if first != nil {
+		return nil, first
+	}
+	return ret, nil
+}
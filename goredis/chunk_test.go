@@ -0,0 +1,64 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAutoChunk(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]string
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		calls = append(calls, args)
+		mu.Unlock()
+		switch args[0] {
+		case "SADD":
+			return ":" + strconv.Itoa(len(args)-2) + "\r\n"
+		case "MGET":
+			return respArray(args[1:]...)
+		case "SREM":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	c.SetAutoChunk(4)
+
+	ret, e := c.Call("SADD", "s", "a", "b", "c", "d", "e", "f", "g")
+	if e != nil || ret.(int64) != 7 {
+		t.Fatalf("SADD %v %v", ret, e)
+	}
+	// 3 members plus the key per chunk
+	if len(calls) != 3 || len(calls[0]) != 5 || calls[2][1] != "s" || calls[2][2] != "g" {
+		t.Fatalf("calls %v", calls)
+	}
+
+	calls = nil
+	ret, e = c.Call("MSET", "k1", "v1", "k2", "v2", "k3", "v3")
+	if e != nil || string(ret.([]byte)) != "OK" || len(calls) != 2 || len(calls[0]) != 5 {
+		t.Fatalf("MSET %v %v %v", ret, e, calls)
+	}
+
+	ret, e = c.Call("MGET", "a", "b", "c", "d", "e")
+	arr := ret.([]interface{})
+	if e != nil || len(arr) != 5 || string(arr[4].([]byte)) != "e" {
+		t.Fatalf("MGET %v %v", ret, e)
+	}
+
+	// every reply is read even when the chunks fail
+	if _, e = c.Call("SREM", "s", "a", "b", "c", "d", "e"); !isReplyError(e) {
+		t.Fatalf("SREM %v", e)
+	}
+	if ret, e = c.Call("SADD", "s", "a"); e != nil || ret.(int64) != 1 {
+		t.Fatalf("after SREM %v %v", ret, e)
+	}
+
+	// odd pairs are left to the server
+	calls = nil
+	c.Call("MSET", "k1", "v1", "k2", "v2", "k3")
+	if len(calls) != 1 {
+		t.Fatalf("calls %v", calls)
+	}
+}
@@ -0,0 +1,189 @@
+package msgredis
+
+import (
+	"sync"
+	"time"
+)
+
+// TrackingInfo is the reply of CLIENT TRACKINGINFO
+type TrackingInfo struct {
+	// on, off, bcast, optin, optout, caching-yes, caching-no, noloop,
+	// broken_redirect
+	Flags []string
+	// client receiving the invalidations, 0 for none, -1 when not tracking
+	Redirect int64
+	// bcast prefixes
+	Prefixes []string
+}
+
+func (ti *TrackingInfo) Has(flag string) bool {
+	for _, f := range ti.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// CLIENTTRACKINGINFO works in RESP2 and RESP3, maps are flat arrays either way
+func (c *Conn) CLIENTTRACKINGINFO() (*TrackingInfo, error) {
+	v, e := c.Call("CLIENT", "TRACKINGINFO")
+	if e != nil {
+		return nil, e
+	}
+	fields, ok := v.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, ErrBadType
+	}
+	ti := &TrackingInfo{}
+	for i := 0; i < len(fields); i += 2 {
+		k, _ := replyString(fields[i])
+		switch k {
+		case "flags":
+			ti.Flags, e = replyStrings(fields[i+1])
+		case "redirect":
+			if ti.Redirect, ok = fields[i+1].(int64); !ok {
+				e = ErrBadType
+			}
+		case "prefixes":
+			ti.Prefixes, e = replyStrings(fields[i+1])
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+	return ti, nil
+}
+
+// ClientCache keeps GET replies in process and drops them when the server
+// invalidates the keys (client side caching, redis >= 6). It owns a RESP3
+// conn; invalidations are applied whenever that conn reads, so a hit may
+// be stale until the next miss or Poll.
+type ClientCache struct {
+	mu      sync.Mutex
+	c       *Conn
+	entries map[string][]byte
+
+	invalidations int64
+	flushes       int64
+	since         time.Time
+}
+
+// NewClientCache turns tracking on for c, in BCAST mode when prefixes are
+// given. c must not be used for anything else afterwards.
+func NewClientCache(c *Conn, prefixes ...string) (*ClientCache, error) {
+	if !c.resp3 {
+		if e := c.HELLO3(); e != nil {
+			return nil, e
+		}
+	}
+	cc := &ClientCache{
+		c:       c,
+		entries: make(map[string][]byte),
+		since:   time.Now(),
+	}
+	c.SetPushHandler(cc.onPush)
+	args := Args{"TRACKING", "ON"}
+	if len(prefixes) > 0 {
+		args = append(args, "BCAST")
+		for _, prefix := range prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+	}
+	if e := c.okCall("CLIENT", args...); e != nil {
+		return nil, e
+	}
+	return cc, nil
+}
+
+// runs with cc.mu held, the conn only reads under it
+func (cc *ClientCache) onPush(m *PushMessage) {
+	if m.Kind != "invalidate" || len(m.Data) != 2 {
+		return
+	}
+	// a nil key list is a FLUSHALL/FLUSHDB, or tracking was lost
+	if m.Data[1] == nil {
+		cc.flushes++
+		cc.entries = make(map[string][]byte)
+		return
+	}
+	keys, _ := replyStrings(m.Data[1])
+	for _, key := range keys {
+		delete(cc.entries, key)
+	}
+	cc.invalidations += int64(len(keys))
+}
+
+// Get answers from the cache or with GET, ErrKeyNotExist is not cached
+func (cc *ClientCache) Get(key string) ([]byte, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if v, ok := cc.entries[key]; ok {
+		return v, nil
+	}
+	v, e := cc.c.GET(key)
+	if e != nil {
+		return nil, e
+	}
+	cc.entries[key] = v
+	return v, nil
+}
+
+// Poll applies the invalidations received within timeout
+func (cc *ClientCache) Poll(timeout time.Duration) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.c.PollPush(timeout)
+}
+
+func (cc *ClientCache) Len() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.entries)
+}
+
+func (cc *ClientCache) Close() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.entries = make(map[string][]byte)
+	cc.c.Close()
+}
+
+// TrackingDiagnostics tells whether a cache is still kept coherent
+type TrackingDiagnostics struct {
+	// keys held locally
+	Keys int
+	// as the server sees the conn
+	Flags    []string
+	Redirect int64
+	Prefixes []string
+	// keys invalidated and full flushes since the cache was made
+	Invalidations int64
+	Flushes       int64
+	// invalidated keys per second
+	InvalidationRate float64
+}
+
+// Diagnostics asks the server for the tracking state of the cache conn. A
+// broken_redirect flag or prefixes other than the ones asked for mean the
+// cache no longer gets invalidations.
+func (cc *ClientCache) Diagnostics() (*TrackingDiagnostics, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	ti, e := cc.c.CLIENTTRACKINGINFO()
+	if e != nil {
+		return nil, e
+	}
+	d := &TrackingDiagnostics{
+		Keys:          len(cc.entries),
+		Flags:         ti.Flags,
+		Redirect:      ti.Redirect,
+		Prefixes:      ti.Prefixes,
+		Invalidations: cc.invalidations,
+		Flushes:       cc.flushes,
+	}
+	if secs := time.Since(cc.since).Seconds(); secs > 0 {
+		d.InvalidationRate = float64(cc.invalidations) / secs
+	}
+	return d, nil
+}
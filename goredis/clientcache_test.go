@@ -0,0 +1,55 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestClientCache(t *testing.T) {
+	gets := 0
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n$5\r\nproto\r\n:3\r\n"
+		case "GET":
+			gets++
+			if args[1] == "b" {
+				// the write to a lands before the reply
+				return ">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\na\r\n$2\r\nvb\r\n"
+			}
+			return "$2\r\nv" + args[1] + "\r\n"
+		case "CLIENT":
+			if args[1] == "TRACKINGINFO" {
+				return "%3\r\n$5\r\nflags\r\n~2\r\n+on\r\n+bcast\r\n" +
+					"$8\r\nredirect\r\n:0\r\n$8\r\nprefixes\r\n*1\r\n$5\r\nuser:\r\n"
+			}
+			if len(args) != 6 || args[3] != "BCAST" || args[5] != "user:" {
+				return "-ERR bad tracking args\r\n"
+			}
+		}
+		return "+OK\r\n"
+	})
+	cc, e := NewClientCache(dialFake(t, s), "user:")
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i := 0; i < 2; i++ {
+		if v, e := cc.Get("a"); e != nil || string(v) != "va" {
+			t.Fatalf("got %q %v", v, e)
+		}
+	}
+	if gets != 1 {
+		t.Fatalf("%d GETs", gets)
+	}
+	cc.Get("b")
+	if cc.Len() != 1 {
+		t.Fatalf("a not invalidated, %d keys", cc.Len())
+	}
+	d, e := cc.Diagnostics()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if d.Keys != 1 || d.Invalidations != 1 || d.InvalidationRate <= 0 || d.Redirect != 0 ||
+		len(d.Flags) != 2 || d.Flags[1] != "bcast" || len(d.Prefixes) != 1 || d.Prefixes[0] != "user:" {
+		t.Fatalf("diagnostics %+v", d)
+	}
+}
@@ -0,0 +1,206 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrOwnConnection       = errors.New(CommonErrPrefix + "refusing to kill a connection of this pool")
+	ErrClientIDsNotTracked = errors.New(CommonErrPrefix + "pool does not track client ids, set TrackClientIDs")
+)
+
+// ClientInfo is one line of CLIENT LIST
+type ClientInfo struct {
+	ID    int64
+	Addr  string
+	Name  string
+	User  string
+	Flags string
+	DB    int
+	Age   int64
+	Idle  int64
+	// every field of the line
+	Fields map[string]string
+}
+
+func parseClientList(list []byte) []ClientInfo {
+	var clients []ClientInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(list)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ci := ClientInfo{Fields: make(map[string]string)}
+		for _, field := range strings.Fields(line) {
+			if i := strings.IndexByte(field, '='); i > 0 {
+				ci.Fields[field[:i]] = field[i+1:]
+			}
+		}
+		ci.ID, _ = strconv.ParseInt(ci.Fields["id"], 10, 64)
+		ci.Addr = ci.Fields["addr"]
+		ci.Name = ci.Fields["name"]
+		ci.User = ci.Fields["user"]
+		ci.Flags = ci.Fields["flags"]
+		ci.DB, _ = strconv.Atoi(ci.Fields["db"])
+		ci.Age, _ = strconv.ParseInt(ci.Fields["age"], 10, 64)
+		ci.Idle, _ = strconv.ParseInt(ci.Fields["idle"], 10, 64)
+		clients = append(clients, ci)
+	}
+	return clients
+}
+
+/******************* client commands *******************/
+func (c *Conn) CLIENTLIST() ([]ClientInfo, error) {
+	v, e := c.Call("CLIENT", "LIST")
+	if e != nil {
+		return nil, e
+	}
+	list, ok := v.([]byte)
+	if !ok {
+		return nil, ErrBadType
+	}
+	return parseClientList(list), nil
+}
+
+// ClientID returns the server side id of the conn, asked once
+func (c *Conn) ClientID() (int64, error) {
+	if c.clientID != 0 {
+		return c.clientID, nil
+	}
+	v, e := c.Call("CLIENT", "ID")
+	if e != nil {
+		return 0, e
+	}
+	id, ok := v.(int64)
+	if !ok {
+		return 0, ErrBadType
+	}
+	c.clientID = id
+	return id, nil
+}
+
+// CLIENTKILLID kills one client, never c itself
+func (c *Conn) CLIENTKILLID(id int64) (int64, error) {
+	v, e := c.Call("CLIENT", "KILL", "ID", id, "SKIPME", "yes")
+	if e != nil {
+		return 0, e
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, ErrBadType
+	}
+	return n, nil
+}
+
+// ids of the live conns of a pool
+type clientIDs struct {
+	mu  sync.Mutex
+	ids map[int64]bool
+}
+
+func (ids *clientIDs) add(id int64) {
+	ids.mu.Lock()
+	if ids.ids == nil {
+		ids.ids = make(map[int64]bool)
+	}
+	ids.ids[id] = true
+	ids.mu.Unlock()
+}
+
+func (ids *clientIDs) remove(id int64) {
+	ids.mu.Lock()
+	delete(ids.ids, id)
+	ids.mu.Unlock()
+}
+
+func (ids *clientIDs) has(id int64) bool {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	return ids.ids[id]
+}
+
+// ClientIDs returns the ids of the pool's live conns, see TrackClientIDs
+func (p *Pool) ClientIDs() []int64 {
+	p.clients.mu.Lock()
+	defer p.clients.mu.Unlock()
+	ids := make([]int64, 0, len(p.clients.ids))
+	for id := range p.clients.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// OwnsClient reports whether id is one of the pool's conns
+func (p *Pool) OwnsClient(id int64) bool {
+	return p.clients.has(id)
+}
+
+// registerClient asks a new conn for its id. A conn without one must not
+// be used, KillOtherSessions would not know to spare it.
+func (p *Pool) registerClient(c *Conn) error {
+	id, e := c.ClientID()
+	if e != nil {
+		return e
+	}
+	p.clients.add(id)
+	return nil
+}
+
+// KillOtherSessions kills the clients matched by filter, skipping every
+// conn of this pool, e.g. to drop sessions of a retired service user.
+// It returns the killed ids.
+func (p *Pool) KillOtherSessions(filter func(ClientInfo) bool) ([]int64, error) {
+	if !p.TrackClientIDs {
+		return nil, ErrClientIDsNotTracked
+	}
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer p.Push(c)
+	clients, e := c.CLIENTLIST()
+	if e != nil {
+		return nil, e
+	}
+	var killed []int64
+	for _, ci := range clients {
+		if p.OwnsClient(ci.ID) || ci.ID == c.clientID || !filter(ci) {
+			continue
+		}
+		n, e := c.CLIENTKILLID(ci.ID)
+		if e != nil {
+			return killed, e
+		}
+		if n > 0 {
+			killed = append(killed, ci.ID)
+		}
+	}
+	return killed, nil
+}
+
+// FenceConnection kills the client id, e.g. a zombie lock holder, and
+// refuses with ErrOwnConnection when the id belongs to this pool
+func (p *Pool) FenceConnection(id int64) error {
+	if !p.TrackClientIDs {
+		return ErrClientIDsNotTracked
+	}
+	if p.OwnsClient(id) {
+		return ErrOwnConnection
+	}
+	c := p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer p.Push(c)
+	n, e := c.CLIENTKILLID(id)
+	if e != nil {
+		return e
+	}
+	if n == 0 {
+		return ErrKeyNotExist
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package msgredis
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestKillOtherSessions(t *testing.T) {
+	var mu sync.Mutex
+	next := int64(0)
+	var killed []string
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if args[0] != "CLIENT" {
+			return "+OK\r\n"
+		}
+		switch args[1] {
+		case "ID":
+			next++
+			return ":" + strconv.FormatInt(next, 10) + "\r\n"
+		case "LIST":
+			list := ""
+			for id := int64(1); id <= next; id++ {
+				list += "id=" + strconv.FormatInt(id, 10) + " addr=127.0.0.1:1 name= age=5 idle=0 flags=N db=0 user=app\n"
+			}
+			list += "id=100 addr=10.0.0.2:2 name=worker age=9 idle=9 flags=N db=0 user=old\n"
+			list += "id=101 addr=10.0.0.3:3 name= age=1 idle=0 flags=N db=0 user=app\n"
+			return "$" + strconv.Itoa(len(list)) + "\r\n" + list + "\r\n"
+		case "KILL":
+			killed = append(killed, args[3])
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	if _, e := p.KillOtherSessions(nil); e != ErrClientIDsNotTracked {
+		t.Fatalf("got %v", e)
+	}
+	p.TrackClientIDs = true
+	c1, c2 := p.Pop(), p.Pop()
+	p.Push(c2)
+	if len(p.ClientIDs()) != 2 || !p.OwnsClient(c1.clientID) {
+		t.Fatalf("ids %v", p.ClientIDs())
+	}
+
+	// a filter matching everything still spares the pool
+	ids, e := p.KillOtherSessions(func(ClientInfo) bool { return true })
+	if e != nil || len(ids) != 2 || ids[0] != 100 || ids[1] != 101 {
+		t.Fatalf("killed %v %v", ids, e)
+	}
+	killed = nil
+	ids, _ = p.KillOtherSessions(func(ci ClientInfo) bool { return ci.User == "old" && ci.Idle > 5 })
+	if len(ids) != 1 || len(killed) != 1 || killed[0] != "100" {
+		t.Fatalf("killed %v", killed)
+	}
+
+	if e = p.FenceConnection(c1.clientID); e != ErrOwnConnection {
+		t.Fatalf("got %v", e)
+	}
+	if e = p.FenceConnection(100); e != nil {
+		t.Fatal(e)
+	}
+	c1.Close()
+	if p.OwnsClient(c1.clientID) {
+		t.Fatal("closed conn still tracked")
+	}
+}
+
+func TestTrackClientIDsFails(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "CLIENT" {
+			return "-NOPERM this user has no permissions to run the 'client|id' command\r\n"
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	p.TrackClientIDs = true
+	// an untracked conn would be killed by KillOtherSessions
+	if c, e := p.PopContext(context.Background()); c != nil || !isReplyError(e) {
+		t.Fatalf("got %v %v", c, e)
+	}
+	if p.Actives() != 0 || p.Idles() != 0 {
+		t.Fatalf("%d actives, %d idles", p.Actives(), p.Idles())
+	}
+}
@@ -0,0 +1,406 @@
+package msgredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ClusterSlots = 16384
+
+	// COUNTKEYSINSLOT requests sent per pipeline round
+	slotPipeBatch = 1024
+)
+
+// one entry of CLUSTER SLOTS
+type SlotRange struct {
+	Start    int
+	End      int
+	Master   string
+	Replicas []string
+}
+
+/******************* cluster commands *******************/
+func (c *Conn) CLUSTERSLOTS() ([]SlotRange, error) {
+	v, e := c.Call("CLUSTER", "SLOTS")
+	if e != nil {
+		return nil, e
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	ranges := make([]SlotRange, 0, len(rows))
+	for _, row := range rows {
+		r, ok := row.([]interface{})
+		if !ok || len(r) < 3 {
+			return nil, ErrBadType
+		}
+		start, ok1 := r[0].(int64)
+		end, ok2 := r[1].(int64)
+		if !ok1 || !ok2 {
+			return nil, ErrBadType
+		}
+		sr := SlotRange{Start: int(start), End: int(end)}
+		for i := 2; i < len(r); i++ {
+			addr, e := parseNodeAddr(r[i])
+			if e != nil {
+				return nil, e
+			}
+			if i == 2 {
+				sr.Master = addr
+			} else {
+				sr.Replicas = append(sr.Replicas, addr)
+			}
+		}
+		ranges = append(ranges, sr)
+	}
+	return ranges, nil
+}
+
+// CLUSTERSHARDS reads the slot map with CLUSTER SHARDS (redis 7+, where
+// CLUSTER SLOTS is deprecated) as SlotRanges, one per range of a shard.
+// Replicas not reported online are left out.
+func (c *Conn) CLUSTERSHARDS() ([]SlotRange, error) {
+	v, e := c.Call("CLUSTER", "SHARDS")
+	if e != nil {
+		return nil, e
+	}
+	shards, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	var ranges []SlotRange
+	for _, shard := range shards {
+		fields, ok := replyPairs(shard)
+		if !ok {
+			return nil, ErrBadType
+		}
+		slots, ok1 := fields["slots"].([]interface{})
+		nodes, ok2 := fields["nodes"].([]interface{})
+		if !ok1 || !ok2 || len(slots)%2 != 0 {
+			return nil, ErrBadType
+		}
+		var master string
+		var replicas []string
+		for _, node := range nodes {
+			info, ok := replyPairs(node)
+			if !ok {
+				return nil, ErrBadType
+			}
+			addr, e := shardNodeAddr(info)
+			if e != nil {
+				return nil, e
+			}
+			if replyText(info["role"]) == "master" {
+				master = addr
+			} else if replyText(info["health"]) == "online" {
+				replicas = append(replicas, addr)
+			}
+		}
+		for i := 0; i < len(slots); i += 2 {
+			start, ok1 := slots[i].(int64)
+			end, ok2 := slots[i+1].(int64)
+			if !ok1 || !ok2 {
+				return nil, ErrBadType
+			}
+			ranges = append(ranges, SlotRange{Start: int(start), End: int(end), Master: master, Replicas: replicas})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges, nil
+}
+
+// the endpoint of a CLUSTER SHARDS node, its ip when unknown ("?")
+func shardNodeAddr(info map[string]interface{}) (string, error) {
+	host := replyText(info["endpoint"])
+	if host == "" || host == "?" {
+		host = replyText(info["ip"])
+	}
+	port, ok := info["port"].(int64)
+	if !ok {
+		port, ok = info["tls-port"].(int64)
+	}
+	if host == "" || !ok {
+		return "", ErrBadType
+	}
+	return net.JoinHostPort(host, strconv.FormatInt(port, 10)), nil
+}
+
+// [name, value, name, value...] => map
+func replyPairs(v interface{}) (map[string]interface{}, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr)%2 != 0 {
+		return nil, false
+	}
+	m := make(map[string]interface{}, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		name, ok := arr[i].([]byte)
+		if !ok {
+			return nil, false
+		}
+		m[string(name)] = arr[i+1]
+	}
+	return m, true
+}
+
+func replyText(v interface{}) string {
+	b, _ := v.([]byte)
+	return string(b)
+}
+
+// [ip, port, id, ...] => ip:port
+func parseNodeAddr(v interface{}) (string, error) {
+	node, ok := v.([]interface{})
+	if !ok || len(node) < 2 {
+		return "", ErrBadType
+	}
+	ip, ok1 := node[0].([]byte)
+	port, ok2 := node[1].(int64)
+	if !ok1 || !ok2 {
+		return "", ErrBadType
+	}
+	return net.JoinHostPort(string(ip), strconv.FormatInt(port, 10)), nil
+}
+
+func (c *Conn) CLUSTERCOUNTKEYSINSLOT(slot int) (int64, error) {
+	n, e := c.Call("CLUSTER", "COUNTKEYSINSLOT", slot)
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+func (c *Conn) CLUSTERGETKEYSINSLOT(slot, count int) ([][]byte, error) {
+	v, e := c.Call("CLUSTER", "GETKEYSINSLOT", slot, count)
+	if e != nil {
+		return nil, e
+	}
+	keys := make([][]byte, len(v.([]interface{})))
+	for i, key := range v.([]interface{}) {
+		keys[i] = key.([]byte)
+	}
+	return keys, nil
+}
+
+/******************* slot distribution *******************/
+type SlotStat struct {
+	Slot       int
+	Keys       int64
+	SampleKeys []string
+}
+
+type NodeDistribution struct {
+	Addr  string
+	Slots int
+	Keys  int64
+	// Keys / average keys of all masters, 1.0 means perfectly balanced
+	Skew float64
+	// the most loaded slots of this node, hottest first
+	HotSlots []SlotStat
+}
+
+type SlotReport struct {
+	TotalKeys int64
+	Nodes     []NodeDistribution
+}
+
+// AnalyzeSlots walks every slot of the cluster reachable from addr, counts keys
+// with CLUSTER COUNTKEYSINSLOT on the owning master and reports per node skew.
+// The hotN most loaded slots of every node are sampled with CLUSTER GETKEYSINSLOT
+// (sampleN keys each), which usually reveals the hash tag causing a hot slot.
+func AnalyzeSlots(addr, password string, hotN, sampleN int) (*SlotReport, error) {
+	return AnalyzeSlotsWith(addr, DialOptions{Password: password}, hotN, sampleN)
+}
+
+// AnalyzeSlotsWith is AnalyzeSlots dialing every node with opts, e.g. for
+// an ACL user or TLS
+func AnalyzeSlotsWith(addr string, opts DialOptions, hotN, sampleN int) (*SlotReport, error) {
+	seed, e := DialWith(context.Background(), addr, opts)
+	if e != nil {
+		return nil, e
+	}
+	ranges, e := seed.CLUSTERSLOTS()
+	seed.Close()
+	if e != nil {
+		return nil, e
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New(CommonErrPrefix + "no slots assigned")
+	}
+
+	owned := make(map[string][]SlotRange)
+	masters := []string{}
+	for _, sr := range ranges {
+		if _, ok := owned[sr.Master]; !ok {
+			masters = append(masters, sr.Master)
+		}
+		owned[sr.Master] = append(owned[sr.Master], sr)
+	}
+	sort.Strings(masters)
+
+	report := &SlotReport{Nodes: make([]NodeDistribution, 0, len(masters))}
+	for _, master := range masters {
+		nd, e := analyzeNode(master, opts, owned[master], hotN, sampleN)
+		if e != nil {
+			return nil, e
+		}
+		report.TotalKeys += nd.Keys
+		report.Nodes = append(report.Nodes, *nd)
+	}
+
+	avg := float64(report.TotalKeys) / float64(len(report.Nodes))
+	for i := range report.Nodes {
+		if avg > 0 {
+			report.Nodes[i].Skew = float64(report.Nodes[i].Keys) / avg
+		}
+	}
+	return report, nil
+}
+
+func analyzeNode(addr string, opts DialOptions, ranges []SlotRange, hotN, sampleN int) (*NodeDistribution, error) {
+	c, e := DialWith(context.Background(), addr, opts)
+	if e != nil {
+		return nil, e
+	}
+	defer c.Close()
+
+	slots := make([]int, 0)
+	for _, sr := range ranges {
+		for s := sr.Start; s <= sr.End; s++ {
+			slots = append(slots, s)
+		}
+	}
+
+	nd := &NodeDistribution{Addr: addr, Slots: len(slots)}
+	stats := make([]SlotStat, 0, len(slots))
+	for i := 0; i < len(slots); i += slotPipeBatch {
+		j := i + slotPipeBatch
+		if j > len(slots) {
+			j = len(slots)
+		}
+		for _, s := range slots[i:j] {
+			if e = c.PipeSend("CLUSTER", "COUNTKEYSINSLOT", s); e != nil {
+				return nil, e
+			}
+		}
+		ret, e := c.PipeExec()
+		if e != nil {
+			return nil, e
+		}
+		for k, v := range ret {
+			n, ok := v.(int64)
+			if !ok {
+				return nil, ErrBadType
+			}
+			nd.Keys += n
+			if n > 0 {
+				stats = append(stats, SlotStat{Slot: slots[i+k], Keys: n})
+			}
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Keys > stats[j].Keys })
+	if hotN < 0 {
+		hotN = 0
+	}
+	if len(stats) > hotN {
+		stats = stats[:hotN]
+	}
+	for i := range stats {
+		if sampleN <= 0 {
+			break
+		}
+		keys, e := c.CLUSTERGETKEYSINSLOT(stats[i].Slot, sampleN)
+		if e != nil {
+			return nil, e
+		}
+		for _, k := range keys {
+			stats[i].SampleKeys = append(stats[i].SampleKeys, string(k))
+		}
+	}
+	nd.HotSlots = stats
+	return nd, nil
+}
+
+/******************* redirections *******************/
+// -MOVED 3999 127.0.0.1:6381 or -ASK 3999 127.0.0.1:6381
+type RedirectError struct {
+	Ask  bool
+	Slot int
+	Addr string
+}
+
+func (e *RedirectError) Error() string {
+	kind := "MOVED"
+	if e.Ask {
+		kind = "ASK"
+	}
+	return CommonErrPrefix + kind + " " + strconv.Itoa(e.Slot) + " " + e.Addr
+}
+
+// ParseRedirect reports whether e is a MOVED or ASK reply and decodes it.
+func ParseRedirect(e error) (*RedirectError, bool) {
+	if e == nil {
+		return nil, false
+	}
+	if re, ok := e.(*RedirectError); ok {
+		return re, true
+	}
+	msg := strings.TrimPrefix(e.Error(), CommonErrPrefix)
+	fields := strings.Fields(msg)
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil, false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, false
+	}
+	return &RedirectError{Ask: fields[0] == "ASK", Slot: slot, Addr: fields[2]}, true
+}
+
+// TRYAGAIN is returned for multi-key commands while their slot is migrating
+func IsTryAgain(e error) bool {
+	return e != nil && strings.HasPrefix(e.Error(), CommonErrPrefix+"TRYAGAIN")
+}
+
+// must be sent right before a command redirected by -ASK
+func (c *Conn) ASKING() error {
+	ret, e := c.Call("ASKING")
+	if e != nil {
+		return e
+	}
+	if r, ok := ret.([]byte); ok && string(r) == "OK" {
+		return nil
+	}
+	return ErrBadType
+}
+
+// a standalone server answers CLUSTER commands with
+// -ERR This instance has cluster support disabled
+func IsClusterDisabled(e error) bool {
+	return e != nil && strings.Contains(e.Error(), "cluster support disabled")
+}
+
+// ClusterEnabled reads cluster_enabled from INFO cluster, so callers can
+// fall back to single node routing against a standalone server.
+func (c *Conn) ClusterEnabled() (bool, error) {
+	v, e := c.Call("INFO", "cluster")
+	if e != nil {
+		return false, e
+	}
+	info, ok := v.([]byte)
+	if !ok {
+		return false, ErrBadType
+	}
+	for _, line := range strings.Split(string(info), "\r\n") {
+		if strings.HasPrefix(line, "cluster_enabled:") {
+			return strings.TrimPrefix(line, "cluster_enabled:") == "1", nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseRedirect(t *testing.T) {
+	re, ok := ParseRedirect(errors.New(CommonErrPrefix + "ASK 3999 127.0.0.1:6381"))
+	if !ok || !re.Ask || re.Slot != 3999 || re.Addr != "127.0.0.1:6381" {
+		t.Fatalf("ASK parsed as %+v %v", re, ok)
+	}
+	re, ok = ParseRedirect(errors.New(CommonErrPrefix + "MOVED 12182 10.0.0.2:7000"))
+	if !ok || re.Ask || re.Slot != 12182 || re.Addr != "10.0.0.2:7000" {
+		t.Fatalf("MOVED parsed as %+v %v", re, ok)
+	}
+	if _, ok = ParseRedirect(errors.New(CommonErrPrefix + "ERR unknown command")); ok {
+		t.Fatal("ERR parsed as redirect")
+	}
+	if _, ok = ParseRedirect(re); !ok {
+		t.Fatal("RedirectError not recognized")
+	}
+}
+
+func TestIsClusterDisabled(t *testing.T) {
+	if !IsClusterDisabled(errors.New(CommonErrPrefix + "ERR This instance has cluster support disabled")) {
+		t.Fatal("cluster disabled error not detected")
+	}
+	if IsClusterDisabled(nil) || IsClusterDisabled(ErrNil) {
+		t.Fatal("unexpected cluster disabled")
+	}
+}
+
+func TestAnalyzeSlots(t *testing.T) {
+	// a owns slots 0-9 and 20-21, b 10-19; slot s holds s%7 keys
+	var auths int32
+	node := func(args []string) string {
+		switch {
+		case args[0] == "AUTH":
+			if args[1] != "app" || args[2] != "secret" {
+				return "-WRONGPASS invalid username-password pair\r\n"
+			}
+			atomic.AddInt32(&auths, 1)
+			return "+OK\r\n"
+		case args[1] == "COUNTKEYSINSLOT":
+			slot, _ := strconv.Atoi(args[2])
+			return ":" + strconv.Itoa(slot%7) + "\r\n"
+		case args[1] == "GETKEYSINSLOT":
+			return respArray("{" + args[2] + "}x")
+		}
+		return "-ERR unexpected\r\n"
+	}
+	a, b := newFakeServer(t, node), newFakeServer(t, node)
+	seed := newFakeServer(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			return node(args)
+		}
+		return "*3\r\n" +
+			"*3\r\n:0\r\n:9\r\n" + slotNode(t, a.Addr()) +
+			"*3\r\n:10\r\n:19\r\n" + slotNode(t, b.Addr()) +
+			"*3\r\n:20\r\n:21\r\n" + slotNode(t, a.Addr())
+	})
+	r, e := AnalyzeSlotsWith(seed.Addr(), DialOptions{Username: "app", Password: "secret"}, 2, 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if atomic.LoadInt32(&auths) != 3 {
+		t.Fatalf("%d AUTH", auths)
+	}
+	// a: 0..6, 0,1,2 for 0-9 (24) and 6,0 for 20-21 (30); b: 3,4,5,6,0,1,2,3,4,5 (33)
+	nodes := map[string]NodeDistribution{}
+	for _, nd := range r.Nodes {
+		nodes[nd.Addr] = nd
+	}
+	na, nb := nodes[a.Addr()], nodes[b.Addr()]
+	if r.TotalKeys != 63 || na.Slots != 12 || na.Keys != 30 || nb.Slots != 10 || nb.Keys != 33 {
+		t.Fatalf("report %+v", r)
+	}
+	if na.Skew < 0.95 || na.Skew > 0.96 {
+		t.Fatalf("skew %v", na.Skew)
+	}
+	// the hot slots of a: 6 and 20 hold 6 keys, sampled
+	if len(na.HotSlots) != 2 || na.HotSlots[0].Keys != 6 || na.HotSlots[1].Keys != 6 ||
+		len(na.HotSlots[0].SampleKeys) != 1 || na.HotSlots[0].SampleKeys[0] != "{"+strconv.Itoa(na.HotSlots[0].Slot)+"}x" {
+		t.Fatalf("hot slots of a %+v", na.HotSlots)
+	}
+	if len(nb.HotSlots) != 2 || nb.HotSlots[0].Slot != 13 || nb.HotSlots[1].Keys != 5 {
+		t.Fatalf("hot slots of b %+v", nb.HotSlots)
+	}
+
+	// a negative hotN samples none
+	if r, e = AnalyzeSlotsWith(seed.Addr(), DialOptions{Username: "app", Password: "secret"}, -1, 1); e != nil {
+		t.Fatal(e)
+	}
+	for _, nd := range r.Nodes {
+		if len(nd.HotSlots) != 0 {
+			t.Fatalf("hot slots %+v", nd.HotSlots)
+		}
+	}
+}
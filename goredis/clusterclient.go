@@ -0,0 +1,208 @@
+package msgredis
+
+import (
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+)
+
+// ClusterOptions configures the node conns of a ClusterClient
+type ClusterOptions struct {
+	// AUTH [Username] Password on every node
+	Username string
+	Password string
+	// consulted instead of Username and Password, see Pool.Credentials
+	Credentials CredentialsProvider
+	// dials TLS when set, see DialTLS
+	TLSConfig *tls.Config
+	// 0 takes DefaultMaxRedirects
+	MaxRedirects int
+}
+
+// ClusterClient routes commands to the master owning the slot of their
+// keys, with the slot map read from CLUSTER SLOTS, or CLUSTER SHARDS where
+// SLOTS is refused. MOVED updates the slot at once and reloads the whole
+// map in the background, as a moved slot rarely moves alone; ASK is
+// followed for the one command. Commands without keys go to any master.
+// A standalone server, one with cluster support disabled, owns every slot
+// and takes keys of any slots together.
+type ClusterClient struct {
+	*Resharding
+	Seeds []string
+
+	mu         sync.RWMutex
+	slots      [ClusterSlots]string
+	standalone bool
+	// a background refresh is underway
+	refreshing int32
+}
+
+// NewClusterClient loads the slot map from the first seed answering
+func NewClusterClient(opts ClusterOptions, seeds ...string) (*ClusterClient, error) {
+	if len(seeds) == 0 {
+		return nil, ErrBadArgs
+	}
+	r := NewResharding(opts.Password)
+	r.TLSConfig = opts.TLSConfig
+	r.Credentials = opts.Credentials
+	if r.Credentials == nil && opts.Username != "" {
+		r.Credentials = staticCredentials(opts.Username, opts.Password)
+	}
+	if opts.MaxRedirects > 0 {
+		r.MaxRedirects = opts.MaxRedirects
+	}
+	cc := &ClusterClient{Resharding: r, Seeds: seeds}
+	if e := cc.Refresh(); e != nil {
+		return nil, e
+	}
+	return cc, nil
+}
+
+// Refresh reloads the slot map, asking the known masters then the seeds
+func (cc *ClusterClient) Refresh() error {
+	var e error
+	for _, addr := range append(cc.Masters(), cc.Seeds...) {
+		var ranges []SlotRange
+		var standalone bool
+		if ranges, standalone, e = cc.clusterSlots(addr); e != nil {
+			continue
+		}
+		cc.mu.Lock()
+		cc.standalone = standalone
+		cc.slots = [ClusterSlots]string{}
+		for _, sr := range ranges {
+			for s := sr.Start; s <= sr.End && s < ClusterSlots; s++ {
+				cc.slots[s] = sr.Master
+			}
+		}
+		cc.mu.Unlock()
+		return nil
+	}
+	return e
+}
+
+// clusterSlots reads the slot map of addr, a standalone server gets every
+// slot
+func (cc *ClusterClient) clusterSlots(addr string) ([]SlotRange, bool, error) {
+	p := cc.pool(addr)
+	c := p.Pop()
+	if c == nil {
+		return nil, false, ErrNoConn
+	}
+	defer p.Push(c)
+	ranges, e := c.CLUSTERSLOTS()
+	if isReplyError(e) && !IsClusterDisabled(e) {
+		ranges, e = c.CLUSTERSHARDS()
+	}
+	standalone := IsClusterDisabled(e)
+	if !standalone && isReplyError(e) {
+		// e.g. CLUSTER renamed away, INFO tells
+		enabled, ie := c.ClusterEnabled()
+		standalone = ie == nil && !enabled
+	}
+	if standalone {
+		return []SlotRange{{Start: 0, End: ClusterSlots - 1, Master: addr}}, true, nil
+	}
+	if e == nil && len(ranges) == 0 {
+		e = ErrNoConn
+	}
+	return ranges, false, e
+}
+
+// Standalone tells the slot map is a single server without cluster support
+func (cc *ClusterClient) Standalone() bool {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.standalone
+}
+
+// Masters lists the nodes owning slots
+func (cc *ClusterClient) Masters() []string {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	var masters []string
+	seen := make(map[string]bool)
+	for _, addr := range cc.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			masters = append(masters, addr)
+		}
+	}
+	return masters
+}
+
+// NodeOf returns the master of the slot of key, "" if the slot is not
+// assigned
+func (cc *ClusterClient) NodeOf(key string) string {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.slots[KeySlot(key)]
+}
+
+// route returns the node for command, the keys must share one slot
+func (cc *ClusterClient) route(command string, args []interface{}) (string, error) {
+	var keys []string
+	if ci := LookupCommand(command); ci != nil {
+		for _, pos := range ci.KeyPositions(args) {
+			keys = append(keys, argString(args[pos]))
+		}
+	}
+	if len(keys) == 0 {
+		if masters := cc.Masters(); len(masters) > 0 {
+			return masters[0], nil
+		}
+		return "", ErrNoConn
+	}
+	cc.mu.RLock()
+	standalone, addr := cc.standalone, cc.slots[0]
+	cc.mu.RUnlock()
+	if !standalone {
+		slot, e := CheckSlot(keys...)
+		if e != nil {
+			return "", e
+		}
+		cc.mu.RLock()
+		addr = cc.slots[slot]
+		cc.mu.RUnlock()
+	}
+	if addr == "" {
+		return "", ErrNoConn
+	}
+	return addr, nil
+}
+
+// Call sends command to the master of its keys. On a network error the
+// slot map is reloaded, as the node may have failed over, and idempotent
+// commands are sent once more.
+func (cc *ClusterClient) Call(command string, args ...interface{}) (interface{}, error) {
+	addr, e := cc.route(command, args)
+	if e != nil {
+		return nil, e
+	}
+	ret, e := cc.call(addr, cc.moved, command, args...)
+	if e == nil || isReplyError(e) || e == ErrTooManyRedirects {
+		return ret, e
+	}
+	if cc.Refresh() != nil || !IsIdempotent(command) {
+		return ret, e
+	}
+	if addr, e = cc.route(command, args); e != nil {
+		return nil, e
+	}
+	return cc.call(addr, cc.moved, command, args...)
+}
+
+func (cc *ClusterClient) moved(re *RedirectError) {
+	if re.Slot >= 0 && re.Slot < ClusterSlots {
+		cc.mu.Lock()
+		cc.slots[re.Slot] = re.Addr
+		cc.mu.Unlock()
+	}
+	if !atomic.CompareAndSwapInt32(&cc.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&cc.refreshing, 0)
+		cc.Refresh()
+	}()
+}
@@ -0,0 +1,186 @@
+package msgredis
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// CLUSTER SHARDS of one shard owning every slot
+func shardsReply(t *testing.T, addr string) string {
+	host, port, e := net.SplitHostPort(addr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	node := "*10\r\n" + bulk("id") + bulk("n1") + bulk("port") + ":" + port + "\r\n" + bulk("ip") + bulk(host) +
+		bulk("endpoint") + bulk("?") + bulk("role") + bulk("master")
+	return "*1\r\n*4\r\n" + bulk("slots") + "*2\r\n:0\r\n:16383\r\n" + bulk("nodes") + "*1\r\n" + node
+}
+
+func TestClusterClient(t *testing.T) {
+	var owner atomic.Value
+	slots := func(args []string) string {
+		if args[1] == "SLOTS" {
+			// redis 7 without the deprecated form
+			return "-ERR unknown subcommand 'SLOTS'\r\n"
+		}
+		return shardsReply(t, owner.Load().(string))
+	}
+	var asking int32
+	c := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "ASKING":
+			atomic.StoreInt32(&asking, 1)
+			return "+OK\r\n"
+		case "GET":
+			if atomic.SwapInt32(&asking, 0) == 1 {
+				return bulk("imported")
+			}
+		}
+		return "-ERR not asking\r\n"
+	})
+	b := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "CLUSTER":
+			return slots(args)
+		case args[1] == "migrating":
+			return "-ASK " + strconv.Itoa(int(KeySlot("migrating"))) + " " + c.Addr() + "\r\n"
+		}
+		return bulk("v")
+	})
+	a := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "CLUSTER":
+			return slots(args)
+		case args[0] == "GET" && owner.Load().(string) == b.Addr():
+			return "-MOVED " + strconv.Itoa(int(KeySlot(args[1]))) + " " + b.Addr() + "\r\n"
+		}
+		return "+OK\r\n"
+	})
+	owner.Store(a.Addr())
+
+	cc, e := NewClusterClient(ClusterOptions{}, a.Addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cc.Close()
+	if cc.NodeOf("k") != a.Addr() {
+		t.Fatalf("node of k: %s", cc.NodeOf("k"))
+	}
+	if _, e = cc.Call("SET", "k", "v"); e != nil {
+		t.Fatal(e)
+	}
+
+	owner.Store(b.Addr())
+	v, e := cc.Call("GET", "k")
+	if e != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("moved: %v %v", v, e)
+	}
+	if cc.NodeOf("k") != b.Addr() {
+		t.Fatalf("slot not updated: %s", cc.NodeOf("k"))
+	}
+
+	// ASK is followed for the one command
+	v, e = cc.Call("GET", "migrating")
+	if e != nil || string(v.([]byte)) != "imported" || cc.NodeOf("migrating") != b.Addr() {
+		t.Fatalf("ask: %v %v %s", v, e, cc.NodeOf("migrating"))
+	}
+
+	if _, e = cc.Call("MGET", "a", "b"); e != ErrCrossSlot {
+		t.Fatalf("cross slot: %v", e)
+	}
+}
+
+func TestClusterClientOptions(t *testing.T) {
+	var mu sync.Mutex
+	var auths []string
+	var s *fakeServer
+	s, cfg := newTLSFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			mu.Lock()
+			auths = append(auths, args[1]+" "+args[2])
+			mu.Unlock()
+			return "+OK\r\n"
+		case "CLUSTER":
+			return "*1\r\n*3\r\n:0\r\n:16383\r\n" + slotNode(t, s.Addr())
+		}
+		return bulk("v")
+	})
+	// the seed is dialed by the constructor, with the options
+	cc, e := NewClusterClient(ClusterOptions{Username: "app", Password: "secret", TLSConfig: cfg}, s.Addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cc.Close()
+	if v, e := cc.Call("GET", "k"); e != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("get: %v %v", v, e)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(auths) == 0 || auths[0] != "app secret" {
+		t.Fatalf("auth: %v", auths)
+	}
+}
+
+func TestClusterClientStandalone(t *testing.T) {
+	var mu sync.Mutex
+	store := map[string]string{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "CLUSTER":
+			return "-ERR This instance has cluster support disabled\r\n"
+		case "SET":
+			store[args[1]] = args[2]
+			return "+OK\r\n"
+		case "MGET":
+			var vals []string
+			for _, k := range args[1:] {
+				vals = append(vals, store[k])
+			}
+			return respArray(vals...)
+		}
+		return "-ERR unexpected " + args[0] + "\r\n"
+	})
+	cc, e := NewClusterClient(ClusterOptions{}, s.Addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cc.Close()
+	if !cc.Standalone() || cc.NodeOf("a") != s.Addr() || cc.NodeOf("b") != s.Addr() {
+		t.Fatalf("standalone %v, node of a: %s", cc.Standalone(), cc.NodeOf("a"))
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, e = cc.Call("SET", k, "v"+k); e != nil {
+			t.Fatal(e)
+		}
+	}
+	// keys of different slots are no CROSSSLOT on a single server
+	v, e := cc.Call("MGET", "a", "b")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if vals, _ := replyStrings(v); len(vals) != 2 || vals[0] != "va" || vals[1] != "vb" {
+		t.Fatalf("mget: %v", vals)
+	}
+
+	// CLUSTER refused otherwise, INFO cluster decides
+	renamed := newFakeServer(t, func(args []string) string {
+		if args[0] == "INFO" {
+			return bulk("# Cluster\r\ncluster_enabled:0\r\n")
+		}
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	})
+	cc2, e := NewClusterClient(ClusterOptions{}, renamed.Addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cc2.Close()
+	if !cc2.Standalone() {
+		t.Fatal("cluster_enabled:0 not standalone")
+	}
+}
@@ -0,0 +1,73 @@
+package msgredis
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandStats counts the calls of one command on a pool
+type CommandStats struct {
+	Calls int64
+	// error replies and network errors
+	Errors int64
+	// sum of the round trips
+	Latency time.Duration
+}
+
+// Mean is the average round trip
+func (s CommandStats) Mean() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Calls)
+}
+
+type commandStats struct {
+	mu    sync.Mutex
+	stats map[string]*CommandStats
+}
+
+func (cs *commandStats) record(command string, d time.Duration, failed bool) {
+	name := strings.ToUpper(command)
+	cs.mu.Lock()
+	s, ok := cs.stats[name]
+	if !ok {
+		if cs.stats == nil {
+			cs.stats = make(map[string]*CommandStats)
+		}
+		s = &CommandStats{}
+		cs.stats[name] = s
+	}
+	s.Calls++
+	if failed {
+		s.Errors++
+	}
+	s.Latency += d
+	cs.mu.Unlock()
+}
+
+// recordCall feeds the latency histogram and the per command stats
+func (p *Pool) recordCall(command string, d time.Duration, e error) {
+	p.latency.Record(d)
+	p.cmdStats.record(command, d, e != nil)
+}
+
+// CommandStats returns a snapshot of the stats per command name, e.g. to
+// see which commands dominate the traffic. Pipelined and transaction
+// commands are not counted.
+func (p *Pool) CommandStats() map[string]CommandStats {
+	p.cmdStats.mu.Lock()
+	defer p.cmdStats.mu.Unlock()
+	snap := make(map[string]CommandStats, len(p.cmdStats.stats))
+	for name, s := range p.cmdStats.stats {
+		snap[name] = *s
+	}
+	return snap
+}
+
+func (p *Pool) ResetCommandStats() {
+	p.cmdStats.mu.Lock()
+	p.cmdStats.stats = nil
+	p.cmdStats.mu.Unlock()
+}
@@ -0,0 +1,33 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestCommandStats(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "HGETALL" {
+			return "-WRONGTYPE Operation against a key\r\n"
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	c := p.Pop()
+	c.Call("set", "a", 1)
+	c.Call("SET", "b", 2)
+	c.Call("HGETALL", "a")
+	p.Push(c)
+
+	stats := p.CommandStats()
+	set, hgetall := stats["SET"], stats["HGETALL"]
+	if len(stats) != 2 || set.Calls != 2 || set.Errors != 0 || hgetall.Calls != 1 || hgetall.Errors != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+	if set.Latency <= 0 || set.Mean() != set.Latency/2 {
+		t.Fatalf("latency %v mean %v", set.Latency, set.Mean())
+	}
+	p.ResetCommandStats()
+	if len(p.CommandStats()) != 0 {
+		t.Fatal("not reset")
+	}
+}
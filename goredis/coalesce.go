@@ -0,0 +1,111 @@
+package msgredis
+
+import (
+	"time"
+)
+
+const (
+	DefaultCoalesceKeys   = 100
+	DefaultCoalesceWindow = 100 * time.Microsecond
+)
+
+type coalescedGet struct {
+	key   string
+	value []byte
+	err   error
+	done  chan struct{}
+}
+
+// Coalescer merges the GETs of concurrent goroutines issued within Window
+// into one MGET and hands each caller its value. Keys asked for twice in
+// a window are fetched once, each caller gets its own copy of the value.
+// Like Batcher, callers trade up to Window of latency for a shared round
+// trip.
+type Coalescer struct {
+	p *Pool
+	q *batchQueue
+}
+
+// NewCoalescer starts the flush loop, maxKeys and window <= 0 take the
+// defaults
+func NewCoalescer(p *Pool, maxKeys int, window time.Duration) *Coalescer {
+	if maxKeys <= 0 {
+		maxKeys = DefaultCoalesceKeys
+	}
+	if window <= 0 {
+		window = DefaultCoalesceWindow
+	}
+	co := &Coalescer{p: p}
+	co.q = newBatchQueue(maxKeys, window, co.flush)
+	return co
+}
+
+// Get returns the value of key, ErrKeyNotExist if it is missing
+func (co *Coalescer) Get(key string) ([]byte, error) {
+	g := &coalescedGet{key: key, done: make(chan struct{})}
+	if !co.q.add(g) {
+		return nil, ErrBatcherClosed
+	}
+	<-g.done
+	return g.value, g.err
+}
+
+// Close fetches the queued keys and stops the loop
+func (co *Coalescer) Close() {
+	co.q.close()
+}
+
+func (co *Coalescer) flush(items []interface{}) {
+	batch := make([]*coalescedGet, len(items))
+	for i, item := range items {
+		batch[i] = item.(*coalescedGet)
+	}
+	defer func() {
+		for _, g := range batch {
+			close(g.done)
+		}
+	}()
+	// index of each distinct key in the MGET
+	index := make(map[string]int, len(batch))
+	keys := make([]interface{}, 0, len(batch))
+	for _, g := range batch {
+		if _, ok := index[g.key]; !ok {
+			index[g.key] = len(keys)
+			keys = append(keys, g.key)
+		}
+	}
+	c := co.p.Pop()
+	if c == nil {
+		for _, g := range batch {
+			g.err = ErrNoConn
+		}
+		return
+	}
+	v, e := c.Call("MGET", keys...)
+	co.p.Push(c)
+	values, ok := v.([]interface{})
+	if e == nil && (!ok || len(values) != len(keys)) {
+		e = ErrBadType
+	}
+	// values already handed to a caller, the next ones get a copy
+	handed := make(map[int]bool, len(keys))
+	for _, g := range batch {
+		if e != nil {
+			g.err = e
+			continue
+		}
+		i := index[g.key]
+		switch value := values[i].(type) {
+		case []byte:
+			if handed[i] {
+				value = append([]byte(nil), value...)
+			}
+			handed[i] = true
+			g.value = value
+		case nil:
+			g.err = ErrKeyNotExist
+		default:
+			g.err = ErrBadType
+		}
+	}
+}
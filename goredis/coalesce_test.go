@@ -0,0 +1,76 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	var mgets, keys int32
+	s := newFakeServer(t, func(args []string) string {
+		atomic.AddInt32(&mgets, 1)
+		atomic.AddInt32(&keys, int32(len(args)-1))
+		r := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+		for _, k := range args[1:] {
+			if k == "missing" {
+				r += "$-1\r\n"
+				continue
+			}
+			r += "$" + strconv.Itoa(len(k)) + "\r\n" + k + "\r\n"
+		}
+		return r
+	})
+	co := NewCoalescer(NewPool(s.Addr(), ""), 50, 5*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every key asked twice
+			key := "k" + strconv.Itoa(i%20)
+			if v, e := co.Get(key); e != nil || string(v) != key {
+				t.Errorf("%s: %q %v", key, v, e)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if mgets >= 40 || keys > 20*mgets {
+		t.Fatalf("%d MGETs for %d keys", mgets, keys)
+	}
+	if _, e := co.Get("missing"); e != ErrKeyNotExist {
+		t.Fatalf("got %v", e)
+	}
+	co.Close()
+	if _, e := co.Get("k"); e != ErrBatcherClosed {
+		t.Fatalf("got %v", e)
+	}
+}
+
+func TestCoalescerCopies(t *testing.T) {
+	release := make(chan struct{})
+	s := newFakeServer(t, func(args []string) string {
+		<-release
+		return "*1\r\n" + bulk("shared")
+	})
+	co := NewCoalescer(NewPool(s.Addr(), ""), 2, time.Second)
+	defer co.Close()
+
+	values := make(chan []byte, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			v, _ := co.Get("k")
+			values <- v
+		}()
+	}
+	close(release)
+	a, b := <-values, <-values
+	// one caller changing its value must not change the other's
+	a[0] = 'S'
+	if string(b) != "shared" {
+		t.Fatalf("value shared between callers: %q", b)
+	}
+}
@@ -446,6 +446,9 @@ func (c *Conn) SRANDMEMBER(key string, count int) ([][]byte, error) {
 		if e != nil {
 			return nil, e
 		}
+		if v == nil {
+			return nil, ErrKeyNotExist
+		}
 		members := make([][]byte, 1)
 		members[0] = v.([]byte)
 		return members, nil
@@ -567,7 +570,7 @@ func (c *Conn) INCRBYFLOAT(key string, f float64) ([]byte, error) {
 	if e != nil {
 		return nil, e
 	}
-	return n.([]byte), e
+	return replyFloatBytes(n), e
 }
 
 func (c *Conn) SET(key, value string) ([]byte, error) {
@@ -790,7 +793,7 @@ func (c *Conn) HINCRBYFLOAT(key string, field string, increment float64) ([]byte
 	if e != nil {
 		return nil, e
 	}
-	return n.([]byte), nil
+	return replyFloatBytes(n), nil
 }
 
 func (c *Conn) HKEYS(key string) ([][]byte, error) {
@@ -1328,7 +1331,7 @@ func (c *Conn) ZSCORE(key, member string) ([]byte, error) {
 	if v == nil {
 		return nil, ErrKeyNotExist
 	}
-	return v.([]byte), nil
+	return replyFloatBytes(v), nil
 }
 
 func (c *Conn) ZUNIONSTORE(destination string, numkeys int, keys []string, weights bool, ws []int, aggregate bool, ag string) (int64, error) {
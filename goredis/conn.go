@@ -2,9 +2,10 @@ package msgredis
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"strconv"
 	"strings"
@@ -24,6 +25,17 @@ const (
 	TypeBulkString   = '$'
 	TypeIntegers     = ':'
 	TypeArrays       = '*'
+	// RESP3, sent after HELLO 3
+	TypeDouble    = ','
+	TypeMap       = '%'
+	TypeSet       = '~'
+	TypePush      = '>'
+	TypeNull      = '_'
+	TypeBool      = '#'
+	TypeBigNumber = '('
+	TypeVerbatim  = '='
+	TypeBlobError = '!'
+	TypeAttribute = '|'
 )
 
 var (
@@ -36,25 +48,121 @@ var (
 	ErrKeyNotExist   = errors.New(CommonErrPrefix + "key not exist")
 	ErrBadArgs       = errors.New(CommonErrPrefix + "request args invalid")
 	ErrEmptyDB       = errors.New(CommonErrPrefix + "empty db")
+	ErrBrokenConn    = errors.New("conn is broken")
 
 	CommonErrPrefix = "CommonError:"
 )
 
+// an -ERR reply of the server, the conn is still in sync after it
+type ReplyError struct {
+	Msg string
+}
+
+func (e *ReplyError) Error() string {
+	return CommonErrPrefix + e.Msg
+}
+
+func isReplyError(e error) bool {
+	switch e.(type) {
+	case *ReplyError, *BusyScriptError:
+		return true
+	}
+	return false
+}
+
 //
 type Conn struct {
 	keepAlive      bool
 	pipeCount      int
 	lastActiveTime int64
 	buffer         []byte
-	conn           *net.TCPConn
+	conn           net.Conn
 	rb             *bufio.Reader
 	wb             *bufio.Writer
 	readTimeout    time.Duration
 	writeTimeout   time.Duration
 	pool           *Pool
+	addr           string
+
+	hooks      []Hook
+	batchID    uint64
+	pipeEvents []*HookEvent
+	pipeReads  []pipeRead
+	redactor   *Redactor
+	idle       int32
+	checkOwner bool
+	owner      int64
+	// set when the protocol stream can no longer be trusted
+	broken bool
+	// the last request was flushed to the socket
+	sent bool
+	// strconv format and precision of float arguments, see SetFloatFormat
+	floatFmt  byte
+	floatPrec int
+	// see SetBusyScriptKill
+	killBusy bool
+	// session state to undo before the next borrower, see recoverState
+	state    int
+	username string
+	password string
+	// see SetStrict
+	strict bool
+	// see SetMaxReplySize, replySize counts the reply being read
+	maxReply  int64
+	replySize int64
+	// see SetAutoChunk
+	chunkArgs int
+	// see SetRenameCommands
+	renames map[string]string
+	// see SetReadOnly
+	readOnly bool
+	// see AddPolicy
+	policies []Policy
+	// see SetDryRun
+	dryRun bool
+	// see SetClusterMode
+	clusterMode bool
+	// see SetEmulation
+	emulate bool
+	// see HELLO3 and SetPushHandler
+	resp3       bool
+	pushHandler func(*PushMessage)
+	// see ClientID
+	clientID int64
+	// see InFlight and Queued
+	inflight int32
+	queued   int32
+	// handles of the commands queued since MULTI
+	txCmds []*TxCmd
+	// see SetWatchdog
+	watchdog        time.Duration
+	watchdogActions WatchdogAction
+	// see Pool.RotateCredentials
+	credGen    uint64
+	credExpiry time.Time
+	// see SetValueTransformer
+	transformer       ValueTransformer
+	transformPrefixes []string
+	// see SetProxyCompat
+	proxyCompat bool
+	// see SetMicroCache
+	microCache *MicroCache
+	// see SetTrace
+	trace *Trace
+	// see Pool.Rotate
+	dialedAt time.Time
+	// see SetDecodeMode, arena backs the zero-copy values
+	decodeMode DecodeMode
+	arena      []byte
+	// see DialTLS, kept for redials
+	tlsConfig *tls.Config
+	// see DialOptions, restored after RESET
+	db         int
+	clientName string
+	protocol   int
 }
 
-func NewConn(conn *net.TCPConn, connectTimeout, readTimeout, writeTimeout time.Duration, keepAlive bool, pool *Pool) *Conn {
+func NewConn(conn net.Conn, connectTimeout, readTimeout, writeTimeout time.Duration, keepAlive bool, pool *Pool) *Conn {
 	return &Conn{
 		conn:           conn,
 		lastActiveTime: time.Now().Unix(),
@@ -70,84 +178,238 @@ func NewConn(conn *net.TCPConn, connectTimeout, readTimeout, writeTimeout time.D
 
 // connect with timeout
 func Dial(address, password string, connectTimeout, readTimeout, writeTimeout time.Duration, keepAlive bool, pool *Pool) (*Conn, error) {
-	c, e := net.DialTimeout("tcp", address, connectTimeout)
+	return DialContext(context.Background(), address, password, connectTimeout, readTimeout, writeTimeout, keepAlive, pool)
+}
+
+// DialContext is Dial giving up when ctx is done, AUTH included
+func DialContext(ctx context.Context, address, password string, connectTimeout, readTimeout, writeTimeout time.Duration, keepAlive bool, pool *Pool) (*Conn, error) {
+	return dialContext(ctx, address, DialOptions{
+		Password:       password,
+		ConnectTimeout: connectTimeout,
+		ReadTimeout:    readTimeout,
+		WriteTimeout:   writeTimeout,
+		KeepAlive:      keepAlive,
+		Pool:           pool,
+	})
+}
+
+// dialContext dials with the timeouts of opts as they are, 0 for none. The
+// TLS handshake counts in the connect timeout.
+func dialContext(ctx context.Context, address string, opts DialOptions) (*Conn, error) {
+	d := &net.Dialer{Timeout: opts.ConnectTimeout}
+	var c net.Conn
+	var e error
+	if opts.TLSConfig != nil {
+		c, e = (&tls.Dialer{NetDialer: d, Config: opts.TLSConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		c, e = d.DialContext(ctx, "tcp", address)
+	}
 	if e != nil {
 		return nil, e
 	}
-	if _, ok := c.(*net.TCPConn); !ok {
+	if _, ok := c.(*net.TCPConn); !ok && opts.TLSConfig == nil {
+		c.Close()
 		return nil, ErrBadTcpConn
 	}
 
-	conn := NewConn(c.(*net.TCPConn), connectTimeout, readTimeout, writeTimeout, keepAlive, pool)
-	if password != "" {
-		if _, e := conn.AUTH(password); e != nil {
-			return nil, e
-		}
+	conn := NewConn(c, opts.ConnectTimeout, opts.ReadTimeout, opts.WriteTimeout, opts.KeepAlive, opts.Pool)
+	conn.addr = address
+	conn.tlsConfig = opts.TLSConfig
+	e = conn.withContext(ctx, func() error {
+		return conn.setup(opts)
+	})
+	if e != nil {
+		conn.Close()
+		return nil, e
 	}
 	return conn, nil
 }
 
+// anything but an error reply leaves unread or partially read bytes behind
+func (c *Conn) checkBroken(e error) {
+	if e != nil && !isReplyError(e) {
+		c.broken = true
+	}
+}
+
+// Broken reports whether a network or protocol error desynchronized the
+// conn. Pools close broken conns instead of handing them out again.
+func (c *Conn) Broken() bool {
+	return c.broken
+}
+
 func (c *Conn) Close() {
 	if c.conn != nil {
 		c.conn.Close()
 	}
+	if c.pool != nil && c.clientID != 0 {
+		c.pool.clients.remove(c.clientID)
+	}
 }
 
 // 连接无效了，无法从这里取一条新的连接，除非c里面有一个pool的指针
+// A failure after the request was sent is ambiguous, the server may have
+// executed it, so only commands flagged idempotent in the registry are
+// retried then.
 func (c *Conn) CallN(retry int, command string, args ...interface{}) (interface{}, error) {
+	ret, _, e := c.callRetry(time.Time{}, retry, false, command, args)
+	return ret, e
+}
+
+// CallNIdempotent is CallN for a call the caller knows is safe to repeat,
+// whatever the registry says about command
+func (c *Conn) CallNIdempotent(retry int, command string, args ...interface{}) (interface{}, error) {
+	ret, _, e := c.callRetry(time.Time{}, retry, true, command, args)
+	return ret, e
+}
+
+// retries network errors until retry attempts are used or deadline passes,
+// a zero deadline means no limit
+func (c *Conn) callRetry(deadline time.Time, retry int, idempotent bool, command string, args []interface{}) (interface{}, int, error) {
+	idempotent = idempotent || IsIdempotent(command)
 	var ret interface{}
 	var e error
-	for i := 0; i < retry; i++ {
-		ret, e = c.Call(command, args...)
-		if e != nil && !strings.Contains(e.Error(), CommonErrPrefix) {
-			time.Sleep(RetryWaitSeconds)
-			// get a new conn from pool
-			c.Close()
-			if c.pool == nil {
-				return nil, e
-			}
-			c = c.pool.Pop()
-			if c == nil {
-				return nil, e
-			}
-			continue
+	i := 0
+	for i < retry {
+		i++
+		if deadline.IsZero() {
+			ret, e = c.Call(command, args...)
+		} else {
+			ret, e = c.callBefore(deadline, command, args)
+		}
+		if e == nil || strings.Contains(e.Error(), CommonErrPrefix) {
+			break
+		}
+		if c.sent && !idempotent {
+			break
+		}
+		if i == retry || (!deadline.IsZero() && time.Now().Add(RetryWaitSeconds).After(deadline)) {
+			break
+		}
+		time.Sleep(RetryWaitSeconds)
+		// get a new conn from pool
+		c.Close()
+		if c.pool == nil || c.proxyCompat {
+			break
+		}
+		if c = c.pool.Pop(); c == nil {
+			break
 		}
 	}
-	return ret, e
+	return ret, i, e
 }
 
 // call redis command with request => response model
 func (c *Conn) Call(command string, args ...interface{}) (interface{}, error) {
+	if e := c.enter(command); e != nil {
+		return nil, e
+	}
+	defer c.leave()
+	if c.dryRun {
+		return c.dryRunCall(command, args)
+	}
+	command, args, e := c.applyPolicies(command, args)
+	if e != nil {
+		return nil, e
+	}
+	if c.disabled(command) {
+		return nil, ErrCommandDisabled
+	}
+	if e = c.checkReadOnly(command); e != nil {
+		return nil, e
+	}
+	if e = c.checkProxy(command); e != nil {
+		return nil, e
+	}
+	if e = c.checkReplyMode(command, args); e != nil {
+		return nil, e
+	}
+	if e = c.checkCapabilities(command, args); e != nil {
+		if em := c.emulated(command, e); em != nil {
+			return em(c, args)
+		}
+		return nil, e
+	}
+	if args, e = c.encryptArgs(command, args); e != nil {
+		return nil, e
+	}
+	if v, ok := c.microLookup(command, args); ok {
+		return v, nil
+	}
+	c.trackState(command, args)
+	var ret interface{}
+	if len(c.hooks) == 0 && c.trace == nil {
+		ret, e = c.callAuto(command, args)
+	} else {
+		ev := c.newEvent(command, args)
+		c.before(ev)
+		ev.Reply, ev.Err = c.callAuto(command, args)
+		c.after(ev)
+		ret, e = ev.Reply, ev.Err
+	}
+	c.microUpdate(command, args, ret, e)
+	return c.decryptReply(command, args, ret, e)
+}
+
+func (c *Conn) call(command string, args []interface{}) (interface{}, error) {
 	c.lastActiveTime = time.Now().Unix()
-	// start := time.Now()
+	start := time.Now()
 	if c.pool != nil {
 		c.pool.callMu.Lock()
 		c.pool.CallNum++
 		c.pool.callMu.Unlock()
 	}
 	var e error
+	c.sent = false
+	if c.broken {
+		return nil, ErrBrokenConn
+	}
 	if c.writeTimeout > 0 {
 		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
 			return nil, e
 		}
 	}
 	if e = c.writeRequest(command, args); e != nil {
+		c.broken = true
 		return nil, e
 	}
 
 	if e = c.wb.Flush(); e != nil {
+		c.broken = true
 		return nil, e
 	}
+	c.sent = true
+	c.addInflight(1)
+	defer c.addInflight(-1)
 
 	if c.readTimeout > 0 {
-		if e = c.conn.SetReadDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+		if e = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); e != nil {
 			return nil, e
 		}
 	}
+	c.replySize = 0
+	timer := c.armWatchdog()
 	response, e := c.readResponse()
+	if disarmWatchdog(timer) {
+		c.broken = true
+		e = &WatchdogError{Command: command, Timeout: c.watchdog}
+	}
+	if tl, ok := e.(*TooLargeError); ok {
+		tl.Command = command
+	}
+	if c.pool != nil {
+		c.pool.recordCall(command, time.Since(start), e)
+	}
 	if e != nil {
+		c.checkBroken(e)
 		return nil, e
 	}
+	// inside MULTI replies are +QUEUED, TransExec checks the EXEC elements
+	if c.strict && c.state&stateMulti == 0 {
+		if e = c.checkReply(command, response); e != nil {
+			return nil, e
+		}
+	}
 	// fmt.Println(command+" costs:", time.Now().Sub(start).String())
 	return response, e
 }
@@ -155,15 +417,18 @@ func (c *Conn) Call(command string, args ...interface{}) (interface{}, error) {
 // write response
 func (c *Conn) writeRequest(command string, args []interface{}) error {
 	var e error
+	if len(c.arena) > 0 {
+		c.resetArena()
+	}
 	if e = c.writeLen('*', 1+len(args)); e != nil {
 		return e
 	}
 
-	if e = c.writeString(command); e != nil {
+	if e = c.writeString(c.wireName(command)); e != nil {
 		return e
 	}
 
-	for _, arg := range args {
+	for i, arg := range args {
 		if e != nil {
 			return e
 		}
@@ -173,7 +438,9 @@ func (c *Conn) writeRequest(command string, args []interface{}) error {
 		case int64:
 			e = c.writeInt64(data)
 		case float64:
-			e = c.writeFloat64(data)
+			e = c.writeFloat(data, 64)
+		case float32:
+			e = c.writeFloat(float64(data), 32)
 		case string:
 			e = c.writeString(data)
 		case []byte:
@@ -186,6 +453,12 @@ func (c *Conn) writeRequest(command string, args []interface{}) error {
 			}
 		case nil:
 			e = c.writeString("")
+		case time.Duration:
+			e = c.writeString(durationArg(command, prevArg(args, i), data))
+		case time.Time:
+			e = c.writeString(timeArg(command, prevArg(args, i), data))
+		case ReaderArg:
+			e = c.writeReader(data)
 		default:
 			e = c.writeString(fmt.Sprintf("%v", data))
 		}
@@ -243,17 +516,31 @@ func (c *Conn) writeString(s string) error {
 
 }
 
-func (c *Conn) writeFloat64(f float64) error {
-	// Negative precision means "only as much as needed to be exact."
-	return c.writeBytes(strconv.AppendFloat([]byte{}, f, 'g', -1, 64))
+func (c *Conn) writeFloat(f float64, bitSize int) error {
+	if c.floatFmt == 0 {
+		// Negative precision means "only as much as needed to be exact."
+		return c.writeBytes(strconv.AppendFloat([]byte{}, f, 'g', -1, bitSize))
+	}
+	return c.writeBytes(strconv.AppendFloat([]byte{}, f, c.floatFmt, c.floatPrec, bitSize))
 }
 
 func (c *Conn) writeInt64(n int64) error {
 	return c.writeBytes(strconv.AppendInt([]byte{}, n, 10))
 }
 
-// read
+// read, RESP3 push frames in front of the reply go to the push handler
 func (c *Conn) readResponse() (interface{}, error) {
+	for {
+		v, e := c.readFrame()
+		push, ok := v.(pushFrame)
+		if !ok || e != nil {
+			return v, e
+		}
+		c.dispatchPush(push)
+	}
+}
+
+func (c *Conn) readFrame() (interface{}, error) {
 	var e error
 	p, e := c.readLine()
 	if e != nil {
@@ -264,7 +551,7 @@ func (c *Conn) readResponse() (interface{}, error) {
 	switch resType {
 	case TypeError:
 		// 错误操作，非网络错误，不应该重建连接
-		return nil, errors.New(CommonErrPrefix + string(p))
+		return nil, &ReplyError{Msg: string(p)}
 	case TypeIntegers:
 		return c.parseInt(p)
 	case TypeSimpleString:
@@ -273,6 +560,11 @@ func (c *Conn) readResponse() (interface{}, error) {
 		return c.parseBulkString(p)
 	case TypeArrays:
 		return c.parseArray(p)
+	case TypeDouble:
+		return parseDouble(p)
+	case TypeMap, TypeSet, TypePush, TypeNull, TypeBool, TypeBigNumber,
+		TypeVerbatim, TypeBlobError, TypeAttribute:
+		return c.readResp3(resType, p)
 	default:
 	}
 	return nil, errors.New(CommonErrPrefix + "Err type")
@@ -314,10 +606,10 @@ func (c *Conn) parseBulkString(p []byte) (interface{}, error) {
 	if n == -1 {
 		return nil, nil
 	}
-
-	result := make([]byte, n+2)
-	_, e = io.ReadFull(c.rb, result)
-	return result[:n], e
+	if e = c.chargeReply(n); e != nil {
+		return nil, e
+	}
+	return c.readBulk(n)
 }
 
 func (c *Conn) parseArray(p []byte) ([]interface{}, error) {
@@ -329,11 +621,19 @@ func (c *Conn) parseArray(p []byte) ([]interface{}, error) {
 	if n == -1 {
 		return nil, nil
 	}
+	if e = c.chargeReply(n * replyElemCost); e != nil {
+		return nil, e
+	}
 
 	result := make([]interface{}, n)
 	var i int64
 	for ; i < n; i++ {
 		result[i], e = c.readResponse()
+		if isReplyError(e) {
+			// e.g. a failed command inside EXEC, keep reading the array
+			result[i] = e
+			continue
+		}
 		if e != nil {
 			return nil, e
 		}
@@ -344,26 +644,174 @@ func (c *Conn) parseArray(p []byte) ([]interface{}, error) {
 // pipeline与transactions没有用callN，失败没有重试
 // pipeline
 func (c *Conn) PipeSend(command string, args ...interface{}) error {
+	if e := c.enter(command); e != nil {
+		return e
+	}
+	if c.dryRun {
+		_, e := c.dryRunCall(command, args)
+		c.leave()
+		return e.(*DryRunError).Explanation.Err()
+	}
+	command, args, e := c.applyPolicies(command, args)
+	if e != nil {
+		c.leave()
+		return e
+	}
+	if c.disabled(command) {
+		c.leave()
+		return ErrCommandDisabled
+	}
+	if e = c.checkReadOnly(command); e != nil {
+		c.leave()
+		return e
+	}
+	if e = c.checkProxy(command); e != nil {
+		c.leave()
+		return e
+	}
+	if e = c.checkReplyMode(command, args); e != nil {
+		c.leave()
+		return e
+	}
+	if e = c.checkCapabilities(command, args); e != nil {
+		c.leave()
+		return e
+	}
+	if c.transformer != nil {
+		if args, e = c.encryptArgs(command, args); e != nil {
+			c.leave()
+			return e
+		}
+		if _, ok := readLayout(command, args); ok && c.state&stateMulti == 0 {
+			c.pipeReads = append(c.pipeReads, pipeRead{index: c.pipeCount, command: command, args: args})
+		}
+	}
+	if len(c.hooks) > 0 || c.trace != nil {
+		if c.pipeCount == 0 {
+			c.batchID = nextBatchID()
+		}
+		ev := c.newEvent(command, args)
+		ev.BatchID = c.batchID
+		ev.Index = c.pipeCount
+		c.before(ev)
+		c.pipeEvents = append(c.pipeEvents, ev)
+	}
 	c.pipeCount++
-	return c.writeRequest(command, args)
+	c.addQueued(1)
+	c.trackState(command, args)
+	e = c.writeRequest(command, args)
+	c.leave()
+	return e
 }
 
+// PipeExec reads the replies of the queued commands. A network error or
+// timeout part way returns the replies read so far with a *PartialError,
+// the conn is broken then.
 func (c *Conn) PipeExec() ([]interface{}, error) {
-	var e error
-	if e = c.wb.Flush(); e != nil {
-		return nil, e
+	return c.PipeExecDeadline(time.Time{})
+}
+
+// PipeExecDeadline is PipeExec with one read deadline for the whole batch
+// instead of the read timeout per reply
+func (c *Conn) PipeExecDeadline(deadline time.Time) ([]interface{}, error) {
+	ret, errs, e := c.pipeExec(deadline)
+	if e != nil {
+		return ret, e
+	}
+	for i, err := range errs {
+		if err != nil && !isReplyError(err) {
+			return ret, &PartialError{Completed: i, Total: len(errs), Err: err}
+		}
+	}
+	if len(errs) > 0 {
+		e = errs[len(errs)-1]
+	}
+	return ret, e
+}
+
+// PipeExecErrs is PipeExec returning the error of every reply, replies
+// failing with a server error are nil in ret. e is set when nothing was read.
+func (c *Conn) PipeExecErrs() (ret []interface{}, errs []error, e error) {
+	return c.pipeExec(time.Time{})
+}
+
+// pipeExecAll reads every queued reply, e is the first error among them
+func (c *Conn) pipeExecAll() ([]interface{}, error) {
+	ret, errs, e := c.pipeExec(time.Time{})
+	if e != nil {
+		return ret, e
+	}
+	for _, err := range errs {
+		if err != nil {
+			return ret, err
+		}
+	}
+	return ret, nil
+}
+
+// pipeExec returns the error of every reply, e is set when nothing was read
+func (c *Conn) pipeExec(deadline time.Time) (ret []interface{}, errs []error, e error) {
+	if e = c.enter("PipeExec"); e != nil {
+		return nil, nil, e
 	}
 	n := c.pipeCount
-	ret := make([]interface{}, c.pipeCount)
+	events := c.pipeEvents
+	reads := c.pipeReads
 	c.pipeCount = 0
+	c.pipeEvents = nil
+	c.pipeReads = nil
+	c.addQueued(-n)
+	defer c.leave()
+	if c.broken {
+		return nil, nil, ErrBrokenConn
+	}
+	if e = c.wb.Flush(); e != nil {
+		c.broken = true
+		return nil, nil, e
+	}
+	if !deadline.IsZero() {
+		if e = c.conn.SetReadDeadline(deadline); e != nil {
+			c.broken = true
+			return nil, nil, e
+		}
+	}
+	c.addInflight(n)
+	ret = make([]interface{}, n)
+	errs = make([]error, n)
 	for i := 0; i < n; i++ {
-		ret[i], e = c.readResponse()
+		if c.broken {
+			// the rest of the stream cannot be parsed
+			ret[i], errs[i] = nil, ErrBrokenConn
+		} else {
+			if deadline.IsZero() && c.readTimeout > 0 {
+				errs[i] = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+			}
+			if errs[i] == nil {
+				c.replySize = 0
+				ret[i], errs[i] = c.readResponse()
+			}
+			c.checkBroken(errs[i])
+		}
+		c.addInflight(-1)
+		if i < len(events) {
+			events[i].Reply, events[i].Err = ret[i], errs[i]
+			c.after(events[i])
+		}
 	}
-	return ret, e
+	for _, r := range reads {
+		if r.index < n && errs[r.index] == nil {
+			ret[r.index], errs[r.index] = c.decryptReply(r.command, r.args, ret[r.index], nil)
+		}
+	}
+	return ret, errs, nil
 }
 
 // Transactions
 func (c *Conn) MULTI() error {
+	if c.isIdle() {
+		return ErrConnNotOwned
+	}
+	c.txCmds = nil
 	ret, e := c.Call("MULTI")
 	if e != nil {
 		return e
@@ -379,10 +827,25 @@ func (c *Conn) MULTI() error {
 }
 
 func (c *Conn) TransSend(command string, args ...interface{}) error {
+	_, e := c.TransQueue(command, args...)
+	return e
+}
+
+// TransQueue queues a command and returns its handle, filled by TransExec
+func (c *Conn) TransQueue(command string, args ...interface{}) (*TxCmd, error) {
 	ret, e := c.Call(command, args...)
 	if e != nil {
-		return e
+		return nil, e
+	}
+	if e = checkQueued(ret); e != nil {
+		return nil, e
 	}
+	cmd := &TxCmd{Command: strings.ToUpper(command), Err: ErrTxPending, args: args}
+	c.txCmds = append(c.txCmds, cmd)
+	return cmd, nil
+}
+
+func checkQueued(ret interface{}) error {
 	if _, ok := ret.([]byte); !ok {
 		return ErrBadType
 	}
@@ -394,19 +857,31 @@ func (c *Conn) TransSend(command string, args ...interface{}) error {
 	return errors.New("invalid return:" + string(r))
 }
 
+// TransExec runs the queued commands. Each element is checked against the
+// reply kinds of its command: failed commands are *ReplyError elements,
+// mismatched ones *UnexpectedReplyError. The TxCmd handles get the same.
 func (c *Conn) TransExec() ([]interface{}, error) {
+	cmds := c.txCmds
+	c.txCmds = nil
 	ret, e := c.Call("EXEC")
-	if e = c.wb.Flush(); e != nil {
+	if e != nil {
+		failTx(cmds, e)
 		return nil, e
 	}
-	if ret == nil {
+	// *-1 decodes to a nil []interface{}, not a nil interface
+	arr, _ := ret.([]interface{})
+	if arr == nil {
 		// nil indicate transaction failed
+		failTx(cmds, ErrNil)
 		return nil, ErrNil
 	}
-	return ret.([]interface{}), e
+	decodeTx(c, cmds, arr)
+	return arr, nil
 }
 
 func (c *Conn) Discard() error {
+	failTx(c.txCmds, ErrTxDiscarded)
+	c.txCmds = nil
 	ret, e := c.Call("DISCARD")
 	if e != nil {
 		return e
@@ -0,0 +1,63 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+// a reply arriving after the read timeout must never be handed to the next
+// caller of the conn
+func TestTimeoutThenReuse(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "SLOW" {
+			time.Sleep(200 * time.Millisecond)
+			return "+slow\r\n"
+		}
+		return "+" + args[0] + "\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+
+	c := p.Pop()
+	c.readTimeout = 50 * time.Millisecond
+	if _, e := c.Call("SLOW"); e == nil {
+		t.Fatal("SLOW did not time out")
+	}
+	if !c.Broken() {
+		t.Fatal("conn not marked broken after timeout")
+	}
+	if _, e := c.Call("GET"); e != ErrBrokenConn {
+		t.Fatalf("call on broken conn err=%v", e)
+	}
+	p.Push(c)
+	if p.Idles() != 0 || p.Actives() != 0 {
+		t.Fatalf("broken conn kept by pool, idles=%d actives=%d", p.Idles(), p.Actives())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	c = p.Pop()
+	defer p.Push(c)
+	v, e := c.Call("GET")
+	if e != nil || string(v.([]byte)) != "GET" {
+		t.Fatalf("fresh conn got %q %v", v, e)
+	}
+}
+
+func TestErrorInsideArray(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		if args[0] == "EXEC" {
+			return "*2\r\n-ERR wrong type\r\n:1\r\n"
+		}
+		return "+PONG\r\n"
+	}))
+	v, e := c.Call("EXEC")
+	if e != nil {
+		t.Fatal(e)
+	}
+	arr := v.([]interface{})
+	if _, ok := arr[0].(*ReplyError); !ok || arr[1].(int64) != 1 {
+		t.Fatalf("EXEC reply %v", arr)
+	}
+	if v, e = c.Call("PING"); e != nil || string(v.([]byte)) != "PONG" {
+		t.Fatalf("conn out of sync: %q %v", v, e)
+	}
+}
@@ -0,0 +1,133 @@
+package msgredis
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StreamMessage is an entry delivered by a StreamConsumer, it stays in the
+// group's pending list until Ack.
+type StreamMessage struct {
+	StreamEntry
+	sc *StreamConsumer
+}
+
+// Ack removes the message from the pending list with XACK
+func (m *StreamMessage) Ack() error {
+	c := m.sc.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer m.sc.p.Push(c)
+	_, e := c.XACK(m.sc.Stream, m.sc.Group, m.ID)
+	return e
+}
+
+// Nack hands the message to the channel again, it is not acknowledged
+func (m *StreamMessage) Nack() {
+	m.sc.mu.Lock()
+	m.sc.nacked = append(m.sc.nacked, m)
+	m.sc.mu.Unlock()
+}
+
+// StreamConsumer turns XREADGROUP into a channel of messages. Pending
+// entries of the consumer, left over from a previous run, are delivered
+// before new ones.
+type StreamConsumer struct {
+	Stream string
+	Group  string
+	Name   string
+	// entries per XREADGROUP and how long it blocks, Block bounds how long
+	// a cancelled context waits for the read in flight
+	Count int
+	Block time.Duration
+
+	p      *Pool
+	mu     sync.Mutex
+	nacked []*StreamMessage
+	err    error
+}
+
+func NewStreamConsumer(p *Pool, stream, group, name string) *StreamConsumer {
+	return &StreamConsumer{
+		Stream: stream,
+		Group:  group,
+		Name:   name,
+		Count:  DefaultBridgeCount,
+		Block:  time.Second,
+		p:      p,
+	}
+}
+
+// Err returns the error that closed the channel, nil if ctx was cancelled
+func (sc *StreamConsumer) Err() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.err
+}
+
+// Messages starts consuming, the channel is closed when ctx is done or
+// reading fails, see Err.
+func (sc *StreamConsumer) Messages(ctx context.Context) <-chan *StreamMessage {
+	ch := make(chan *StreamMessage)
+	go func() {
+		defer close(ch)
+		if e := sc.consume(ctx, ch); e != nil {
+			sc.mu.Lock()
+			sc.err = e
+			sc.mu.Unlock()
+		}
+	}()
+	return ch
+}
+
+func (sc *StreamConsumer) consume(ctx context.Context, ch chan<- *StreamMessage) error {
+	// our pending list is read from "0" until drained, then ">"
+	pending, id := true, "0"
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		sc.mu.Lock()
+		batch := sc.nacked
+		sc.nacked = nil
+		sc.mu.Unlock()
+
+		if len(batch) == 0 {
+			c := sc.p.Pop()
+			if c == nil {
+				return ErrNoConn
+			}
+			block := sc.Block
+			if pending {
+				// pending entries are returned at once
+				block = -1
+			}
+			entries, e := c.XREADGROUP(sc.Group, sc.Name, sc.Count, block, sc.Stream, id)
+			sc.p.Push(c)
+			if e != nil {
+				return e
+			}
+			if pending {
+				if len(entries) == 0 {
+					pending, id = false, ">"
+					continue
+				}
+				// next page of the pending list
+				id = entries[len(entries)-1].ID
+			}
+			for _, entry := range entries {
+				batch = append(batch, &StreamMessage{StreamEntry: entry, sc: sc})
+			}
+		}
+
+		for _, m := range batch {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package msgredis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamConsumer(t *testing.T) {
+	var mu sync.Mutex
+	acked := []string{}
+	newDelivered := false
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "XREADGROUP":
+			id := args[len(args)-1]
+			switch {
+			case id == "0":
+				// one entry pending from a previous run
+				return "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-0\r\n" + respArray("f", "old")
+			case id == ">" && !newDelivered:
+				newDelivered = true
+				return "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n2-0\r\n" + respArray("f", "new")
+			case id == ">":
+				time.Sleep(5 * time.Millisecond)
+				return "*-1\r\n"
+			}
+			return "*1\r\n*2\r\n$1\r\ns\r\n*0\r\n"
+		case "XACK":
+			acked = append(acked, args[3])
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+	})
+
+	sc := NewStreamConsumer(NewPool(s.Addr(), ""), "s", "g", "c1")
+	sc.Block = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := sc.Messages(ctx)
+
+	got := []string{}
+	nacked := false
+	for m := range ch {
+		got = append(got, m.ID+"="+m.Fields["f"])
+		if m.ID == "2-0" && !nacked {
+			nacked = true
+			m.Nack()
+			continue
+		}
+		if e := m.Ack(); e != nil {
+			t.Fatal(e)
+		}
+		if len(got) == 3 {
+			cancel()
+		}
+	}
+	if e := sc.Err(); e != nil {
+		t.Fatal(e)
+	}
+	want := "1-0=old 2-0=new 2-0=new"
+	if s := strings.Join(got, " "); s != want {
+		t.Fatalf("got %q", s)
+	}
+	if s := strings.Join(acked, " "); s != "1-0 2-0" {
+		t.Fatalf("acked %q", s)
+	}
+}
@@ -0,0 +1,71 @@
+package msgredis
+
+import (
+	"context"
+)
+
+// withContext runs fn closing the socket when ctx is done, like the
+// watchdog, as a deadline set under a pending command could be reset by
+// the next read. The conn is broken then and ctx.Err() replaces the i/o
+// error of fn.
+func (c *Conn) withContext(ctx context.Context, fn func() error) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	e := fn()
+	if !stop() {
+		c.broken = true
+		if e != nil && !isReplyError(e) {
+			return ctx.Err()
+		}
+	}
+	return e
+}
+
+// CallContext is Call cancelled with ctx, e.g. the context of an HTTP
+// request: when ctx is done, by cancellation or deadline, the command
+// fails at once with ctx.Err() and the conn is closed. A command cancelled
+// after being sent may still run on the server.
+func (c *Conn) CallContext(ctx context.Context, command string, args ...interface{}) (interface{}, error) {
+	if t := TraceFrom(ctx); t != nil && c.trace != t {
+		prev := c.trace
+		c.trace = t
+		defer func() { c.trace = prev }()
+	}
+	var ret interface{}
+	e := c.withContext(ctx, func() error {
+		var e error
+		ret, e = c.Call(command, args...)
+		return e
+	})
+	if e != nil {
+		return nil, e
+	}
+	return ret, nil
+}
+
+// PopContext is Pop giving up when ctx is done, the dial of a new conn
+// included. It returns ErrNoConn when the pool is shut down or full. The
+// conn records into the Trace of ctx until pushed back.
+func (p *Pool) PopContext(ctx context.Context) (*Conn, error) {
+	c, e := p.pop(ctx)
+	if e != nil {
+		return nil, e
+	}
+	c.trace = TraceFrom(ctx)
+	return c, nil
+}
+
+// CallContext runs one command on a pool conn, see Conn.CallContext
+func (p *Pool) CallContext(ctx context.Context, command string, args ...interface{}) (interface{}, error) {
+	c, e := p.pop(ctx)
+	if e != nil {
+		return nil, e
+	}
+	defer p.Push(c)
+	return c.CallContext(ctx, command, args...)
+}
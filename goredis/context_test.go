@@ -0,0 +1,61 @@
+package msgredis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCallContext(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "BLPOP" {
+			// never answers
+			return ""
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	if v, e := c.CallContext(context.Background(), "SET", "k", "v"); e != nil || string(v.([]byte)) != "OK" {
+		t.Fatalf("%v %v", v, e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, e := c.CallContext(ctx, "BLPOP", "q", 0); e != context.Canceled {
+		t.Fatalf("got %v", e)
+	}
+	if time.Since(start) > time.Second || !c.Broken() {
+		t.Fatalf("took %v, broken %v", time.Since(start), c.Broken())
+	}
+
+	c = dialFake(t, s)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, e := c.CallContext(ctx, "BLPOP", "q", 0); e != context.DeadlineExceeded {
+		t.Fatalf("got %v", e)
+	}
+	if _, e := c.CallContext(ctx, "SET", "k", "v"); e != context.DeadlineExceeded {
+		t.Fatalf("expired ctx: %v", e)
+	}
+}
+
+func TestPoolCallContext(t *testing.T) {
+	s := newFakeServer(t, okHandler)
+	p := NewPool(s.Addr(), "")
+	if _, e := p.CallContext(context.Background(), "SET", "k", "v"); e != nil {
+		t.Fatal(e)
+	}
+	if p.Idles() != 1 || p.Actives() != 0 {
+		t.Fatalf("%d/%d", p.Idles(), p.Actives())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, e := p.CallContext(ctx, "SET", "k", "v"); e != context.Canceled {
+		t.Fatalf("got %v", e)
+	}
+	p.Shutdown(context.Background())
+	if _, e := p.PopContext(context.Background()); e != ErrNoConn {
+		t.Fatalf("got %v", e)
+	}
+}
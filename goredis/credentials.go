@@ -0,0 +1,125 @@
+package msgredis
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Credentials authenticate a new conn, Username is empty for the default
+// user (AUTH password)
+type Credentials struct {
+	Username string
+	Password string
+	// for short-lived tokens: conns authenticated with them are re-dialed
+	// once expired, zero never expires
+	ExpiresAt time.Time
+}
+
+// CredentialsProvider is asked for credentials on every new conn of a
+// pool, e.g. to fetch a cloud IAM token or read a rotated secret
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// CredentialsFunc adapts a function to a CredentialsProvider
+type CredentialsFunc func() (Credentials, error)
+
+func (f CredentialsFunc) Credentials() (Credentials, error) {
+	return f()
+}
+
+// fixed credentials, for options taking a Username besides Password
+func staticCredentials(username, password string) CredentialsProvider {
+	cr := Credentials{Username: username, Password: password}
+	return CredentialsFunc(func() (Credentials, error) { return cr, nil })
+}
+
+// AUTH [username] password
+func (c *Conn) auth(username, password string) error {
+	if username == "" {
+		_, e := c.AUTH(password)
+		return e
+	}
+	return c.okCall("AUTH", username, password)
+}
+
+// authenticate also keeps the credentials for RESET, which drops AUTH
+func (c *Conn) authenticate(cr Credentials) error {
+	c.username, c.password, c.credExpiry = cr.Username, cr.Password, cr.ExpiresAt
+	if cr.Password == "" {
+		return nil
+	}
+	return c.auth(cr.Username, cr.Password)
+}
+
+// the provider's credentials, or Password without one
+func (p *Pool) credentials() (Credentials, error) {
+	if p.Credentials == nil {
+		return Credentials{Password: p.Password}, nil
+	}
+	return p.Credentials.Credentials()
+}
+
+// lone dials the server of the pool for a conn of its own, e.g. a
+// subscriber or a probe, over TLS with the current credentials
+func (p *Pool) lone(ctx context.Context, readTimeout time.Duration) (*Conn, error) {
+	cr, e := p.credentials()
+	if e != nil {
+		return nil, e
+	}
+	return dialContext(ctx, p.address(), DialOptions{
+		Username:       cr.Username,
+		Password:       cr.Password,
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    readTimeout,
+		WriteTimeout:   WriteTimeout,
+		TLSConfig:      p.TLSConfig,
+	})
+}
+
+// dial opens a pool conn authenticated with the current credentials
+func (p *Pool) dial(ctx context.Context) (*Conn, error) {
+	gen := atomic.LoadUint64(&p.credGen)
+	cr, e := p.credentials()
+	if e != nil {
+		return nil, e
+	}
+	c, e := dialContext(ctx, p.address(), DialOptions{
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    ReadTimeout,
+		WriteTimeout:   WriteTimeout,
+		KeepAlive:      true,
+		TLSConfig:      p.TLSConfig,
+		Pool:           p,
+	})
+	if e != nil {
+		return nil, e
+	}
+	e = c.withContext(ctx, func() error {
+		return c.authenticate(cr)
+	})
+	if e != nil {
+		c.Close()
+		return nil, e
+	}
+	c.credGen = gen
+	c.dialedAt = time.Now()
+	return c, nil
+}
+
+// RotateCredentials tells the pool its provider has new credentials: idle
+// conns are closed on Pop and checked out ones on Push, so every conn is
+// re-dialed with them. Providers of expiring tokens need not call it.
+func (p *Pool) RotateCredentials() {
+	atomic.AddUint64(&p.credGen, 1)
+}
+
+// staleCredentials reports conns authenticated before the last rotation or
+// with an expired token
+func (p *Pool) staleCredentials(c *Conn) bool {
+	if c.credGen != atomic.LoadUint64(&p.credGen) {
+		return true
+	}
+	return !c.credExpiry.IsZero() && time.Now().After(c.credExpiry)
+}
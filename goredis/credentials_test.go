@@ -0,0 +1,66 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCredentialsProvider(t *testing.T) {
+	var mu sync.Mutex
+	var auths []string
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			mu.Lock()
+			auths = append(auths, strings.Join(args[1:], " "))
+			mu.Unlock()
+		}
+		return "+OK\r\n"
+	})
+	n := 0
+	ttl := time.Duration(0)
+	p := NewPool(s.Addr(), "")
+	p.Credentials = CredentialsFunc(func() (Credentials, error) {
+		n++
+		cr := Credentials{Username: "app", Password: "token-" + strconv.Itoa(n)}
+		if ttl > 0 {
+			cr.ExpiresAt = time.Now().Add(ttl)
+		}
+		return cr, nil
+	})
+	last := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return auths[len(auths)-1]
+	}
+
+	c := p.Pop()
+	if c == nil || last() != "app token-1" {
+		t.Fatalf("auths %v", auths)
+	}
+	p.Push(c)
+	if c = p.Pop(); last() != "app token-1" {
+		t.Fatalf("idle conn re-dialed: %v", auths)
+	}
+	p.RotateCredentials()
+	p.Push(c)
+	if p.Idles() != 0 || p.Actives() != 0 {
+		t.Fatalf("stale conn kept, %d idles", p.Idles())
+	}
+	p.Push(p.Pop())
+	if last() != "app token-2" {
+		t.Fatalf("auths %v", auths)
+	}
+
+	ttl = 20 * time.Millisecond
+	p.RotateCredentials()
+	c = p.Pop()
+	p.Push(c)
+	time.Sleep(2 * ttl)
+	p.Push(p.Pop())
+	if last() != "app token-4" || p.Idles() != 1 {
+		t.Fatalf("auths %v", auths)
+	}
+}
@@ -0,0 +1,71 @@
+package msgredis
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// how bulk strings of replies are decoded, they are []byte in every mode
+// so typed helpers work with all of them
+type DecodeMode int
+
+const (
+	// every bulk string is a copy of its own, safe to keep
+	DecodeCopy DecodeMode = iota
+	// bulk strings are slices of one buffer reused by the conn, they are
+	// only valid until the next command is sent: no allocation per value,
+	// for callers that parse or copy them at once, see CallBorrowed
+	DecodeZeroCopy
+	// copies with invalid UTF-8 sequences replaced by U+FFFD, for values
+	// passed on to JSON encoders, templates or databases expecting text
+	DecodeUTF8
+)
+
+// the zero-copy buffer grows to at least this size
+const minDecodeArena = 4096
+
+var utf8Replacement = []byte(string(utf8.RuneError))
+
+// SetDecodeMode sets how bulk strings are decoded, DecodeCopy by default.
+// Pools restore DecodeCopy on Push, as their helpers keep the values they
+// read after the conn is pushed back.
+func (c *Conn) SetDecodeMode(mode DecodeMode) {
+	c.decodeMode = mode
+}
+
+// readBulk reads a bulk string of n bytes and its CRLF
+func (c *Conn) readBulk(n int64) ([]byte, error) {
+	var buf []byte
+	if c.decodeMode == DecodeZeroCopy {
+		buf = c.arenaAlloc(int(n + 2))
+	} else {
+		buf = make([]byte, n+2)
+	}
+	if _, e := io.ReadFull(c.rb, buf); e != nil {
+		return buf[:n], e
+	}
+	buf = buf[:n:n]
+	if c.decodeMode == DecodeUTF8 && !utf8.Valid(buf) {
+		buf = bytes.ToValidUTF8(buf, utf8Replacement)
+	}
+	return buf, nil
+}
+
+// arenaAlloc cuts size bytes off the zero-copy buffer, a full buffer is
+// replaced rather than grown so the values handed out stay intact
+func (c *Conn) arenaAlloc(size int) []byte {
+	if cap(c.arena)-len(c.arena) < size {
+		n := 2 * cap(c.arena)
+		if n < minDecodeArena {
+			n = minDecodeArena
+		}
+		if n < size {
+			n = size
+		}
+		c.arena = make([]byte, 0, n)
+	}
+	start := len(c.arena)
+	c.arena = c.arena[:start+size]
+	return c.arena[start : start+size]
+}
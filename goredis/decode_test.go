@@ -0,0 +1,40 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestDecodeMode(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" && args[1] == "bad" {
+			return "$4\r\na\xffb\xfe\r\n"
+		}
+		return respArray(args[1], args[1]+"!")
+	})
+	c := dialFake(t, s)
+
+	c.SetDecodeMode(DecodeZeroCopy)
+	v, e := c.Call("MGET", "one")
+	if e != nil {
+		t.Fatal(e)
+	}
+	arr := v.([]interface{})
+	first, second := arr[0].([]byte), arr[1].([]byte)
+	if string(first) != "one" || string(second) != "one!" {
+		t.Fatalf("%q %q", first, second)
+	}
+	// the next command reuses the buffer
+	c.Call("MGET", "two")
+	if string(first) == "one" {
+		t.Fatalf("buffer not reused: %q", first)
+	}
+
+	c.SetDecodeMode(DecodeUTF8)
+	if v, e = c.Call("GET", "bad"); e != nil || string(v.([]byte)) != "a�b�" {
+		t.Fatalf("%q %v", v, e)
+	}
+	c.SetDecodeMode(DecodeCopy)
+	if v, e = c.Call("GET", "bad"); e != nil || string(v.([]byte)) != "a\xffb\xfe" {
+		t.Fatalf("%q %v", v, e)
+	}
+}
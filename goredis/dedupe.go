@@ -0,0 +1,126 @@
+package msgredis
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+)
+
+// redis bitmaps hold at most 2^32 bits
+var ErrBadBloomSize = errors.New(CommonErrPrefix + "bloom dedupe needs expected > 0, 0 < fpRate < 1 and at most 2^32 bits")
+
+// Dedupe lets at-least-once consumers skip messages already processed
+// within Window. The default mode keeps one SET NX PX key per message ID,
+// exact but a key per message; the bloom mode keeps two bitmaps, the
+// current and the previous window, at a fixed size and a small false
+// positive rate (a new message taken for a duplicate).
+type Dedupe struct {
+	Prefix string
+	Window time.Duration
+
+	p *Pool
+	// bloom mode, bitmap size and positions per ID
+	bits   uint64
+	hashes int
+}
+
+func NewDedupe(p *Pool, prefix string, window time.Duration) *Dedupe {
+	return &Dedupe{Prefix: prefix, Window: window, p: p}
+}
+
+// NewBloomDedupe sizes the bitmaps for expected IDs per window at a false
+// positive rate fpRate, e.g. 1e6 IDs at 0.001 take 1.8MB per window.
+func NewBloomDedupe(p *Pool, prefix string, window time.Duration, expected int, fpRate float64) (*Dedupe, error) {
+	if expected <= 0 || !(fpRate > 0 && fpRate < 1) {
+		return nil, ErrBadBloomSize
+	}
+	n := float64(expected)
+	m := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	if m > 1<<32 {
+		return nil, ErrBadBloomSize
+	}
+	k := int(math.Round(m / n * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Dedupe{Prefix: prefix, Window: window, p: p, bits: uint64(m), hashes: k}, nil
+}
+
+// Seen marks id as processed and reports whether it already was
+func (d *Dedupe) Seen(id string) (bool, error) {
+	c := d.p.Pop()
+	if c == nil {
+		return false, ErrNoConn
+	}
+	defer d.p.Push(c)
+	if d.bits == 0 {
+		v, e := c.Call("SET", d.Prefix+id, 1, "NX", "PX", d.Window)
+		if e != nil {
+			return false, e
+		}
+		// nil if the key existed
+		return v == nil, nil
+	}
+	return d.seenBloom(c, id)
+}
+
+// Forget removes id, e.g. when processing failed after Seen. Bloom mode
+// cannot remove IDs and returns ErrBadArgs.
+func (d *Dedupe) Forget(id string) error {
+	if d.bits != 0 {
+		return ErrBadArgs
+	}
+	c := d.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer d.p.Push(c)
+	_, e := c.Call("DEL", d.Prefix+id)
+	return e
+}
+
+// positions of id, double hashing h1 + i*h2
+func (d *Dedupe) positions(id string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	pos := make([]uint64, d.hashes)
+	for i := range pos {
+		pos[i] = (h1 + uint64(i)*h2) % d.bits
+	}
+	return pos
+}
+
+// SETBIT on the current window returns the old bits, the id was seen if
+// they were all set already or all bits are set in the previous window
+func (d *Dedupe) seenBloom(c *Conn, id string) (bool, error) {
+	gen := time.Now().UnixNano() / int64(d.Window)
+	cur := d.Prefix + strconv.FormatInt(gen, 10)
+	prev := d.Prefix + strconv.FormatInt(gen-1, 10)
+	pos := d.positions(id)
+	for _, p := range pos {
+		c.PipeSend("SETBIT", cur, p, 1)
+	}
+	for _, p := range pos {
+		c.PipeSend("GETBIT", prev, p)
+	}
+	// the current window is read as previous during the next one
+	c.PipeSend("PEXPIRE", cur, 2*d.Window)
+	ret, e := c.PipeExec()
+	if e != nil {
+		return false, e
+	}
+	inCur, inPrev := true, true
+	for i := range pos {
+		if n, ok := ret[i].(int64); !ok || n == 0 {
+			inCur = false
+		}
+		if n, ok := ret[len(pos)+i].(int64); !ok || n == 0 {
+			inPrev = false
+		}
+	}
+	return inCur || inPrev, nil
+}
@@ -0,0 +1,74 @@
+package msgredis
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]bool{}
+	bits := map[string]bool{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "SET":
+			if keys[args[1]] {
+				return "$-1\r\n"
+			}
+			keys[args[1]] = true
+			return "+OK\r\n"
+		case "DEL":
+			delete(keys, args[1])
+			return ":1\r\n"
+		case "SETBIT", "GETBIT":
+			k := args[1] + "/" + args[2]
+			old := bits[k]
+			if args[0] == "SETBIT" {
+				bits[k] = true
+			}
+			if old {
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	bloom, e := NewBloomDedupe(p, "bf:", time.Minute, 1000, 0.001)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, bad := range []struct {
+		expected int
+		fpRate   float64
+	}{{0, 0.01}, {1000, 0}, {1000, 1}, {1000, math.NaN()}, {1 << 40, 0.001}} {
+		if _, e := NewBloomDedupe(p, "bf:", time.Minute, bad.expected, bad.fpRate); e != ErrBadBloomSize {
+			t.Fatalf("%+v: %v", bad, e)
+		}
+	}
+
+	for _, d := range []*Dedupe{
+		NewDedupe(p, "dd:", time.Minute),
+		bloom,
+	} {
+		for i, want := range []bool{false, true} {
+			seen, e := d.Seen("msg-1")
+			if e != nil || seen != want {
+				t.Fatalf("%d: seen %v %v", i, seen, e)
+			}
+		}
+		if seen, _ := d.Seen("msg-2"); seen {
+			t.Fatal("msg-2 seen")
+		}
+	}
+
+	d := NewDedupe(p, "dd:", time.Minute)
+	d.Forget("msg-1")
+	if seen, _ := d.Seen("msg-1"); seen {
+		t.Fatal("msg-1 seen after Forget")
+	}
+}
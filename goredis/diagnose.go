@@ -0,0 +1,151 @@
+package msgredis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PINGs sent by Diagnose, the fastest one is the RTT
+const diagnosePings = 3
+
+// DiagnosticReport is the result of Pool.Diagnose
+type DiagnosticReport struct {
+	Address  string
+	DialTime time.Duration
+	// AUTH accepted, true without a password
+	Authenticated bool
+	// SELECT 0 accepted
+	Selected bool
+	RTT      time.Duration
+	Version  Version
+	Mode     string
+	// master or slave
+	Role string
+	// replicas: link to the master up; masters: replicas attached
+	MasterLinkUp      bool
+	ConnectedReplicas int
+	// loading the dataset from disk
+	Loading    bool
+	AOFEnabled bool
+	// the last RDB save and AOF write succeeded
+	PersistenceOK bool
+	// everything that would make the service misbehave
+	Problems []string
+}
+
+// Err returns the problems as one error, nil if the server looks usable
+func (r *DiagnosticReport) Err() error {
+	if len(r.Problems) == 0 {
+		return nil
+	}
+	return errors.New(CommonErrPrefix + r.Address + ": " + strings.Join(r.Problems, "; "))
+}
+
+func (r *DiagnosticReport) String() string {
+	s := r.Address + " version=" + r.Version.String() + " role=" + r.Role +
+		" dial=" + r.DialTime.String() + " rtt=" + r.RTT.String()
+	for _, p := range r.Problems {
+		s += " problem=" + strconv.Quote(p)
+	}
+	return s
+}
+
+func (r *DiagnosticReport) problem(msg string) {
+	r.Problems = append(r.Problems, msg)
+}
+
+// Diagnose checks the server the way the pool uses it, on a conn of its
+// own: dial, AUTH, SELECT, RTT, version against MinVersion, persistence
+// and replication status. Meant for service startup and health endpoints.
+// The error is the one that stopped the checks early, the report is
+// returned anyway; Err tells whether anything is wrong.
+func (p *Pool) Diagnose() (*DiagnosticReport, error) {
+	r := &DiagnosticReport{Address: p.address()}
+	start := time.Now()
+	// AUTH apart, to tell its failure from the dial's
+	c, e := dialContext(context.Background(), p.address(), DialOptions{
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    ReadTimeout,
+		WriteTimeout:   WriteTimeout,
+		TLSConfig:      p.TLSConfig,
+	})
+	if e != nil {
+		r.problem("dial: " + e.Error())
+		return r, e
+	}
+	defer c.Close()
+	r.DialTime = time.Since(start)
+
+	cr, e := p.credentials()
+	if e == nil {
+		e = c.authenticate(cr)
+	}
+	if e != nil {
+		r.problem("auth: " + e.Error())
+		return r, e
+	}
+	r.Authenticated = true
+	if _, e = c.SELECT(0); e != nil {
+		if !isReplyError(e) {
+			return r, e
+		}
+		r.problem("select: " + e.Error())
+	} else {
+		r.Selected = true
+	}
+
+	for i := 0; i < diagnosePings; i++ {
+		start = time.Now()
+		if _, e = c.Call("PING"); e != nil {
+			r.problem("ping: " + e.Error())
+			return r, e
+		}
+		if rtt := time.Since(start); i == 0 || rtt < r.RTT {
+			r.RTT = rtt
+		}
+	}
+
+	v, e := c.Call("INFO")
+	if e != nil {
+		r.problem("info: " + e.Error())
+		return r, e
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return r, ErrBadType
+	}
+	info := parseInfo(b)
+	r.Version = ParseVersion(info["redis_version"])
+	r.Mode = info["redis_mode"]
+	if r.Version.Less(p.MinVersion) {
+		r.problem("server " + r.Version.String() + " older than " + p.MinVersion.String())
+	}
+
+	r.Loading = info["loading"] == "1"
+	if r.Loading {
+		r.problem("loading the dataset")
+	}
+	r.AOFEnabled = info["aof_enabled"] == "1"
+	r.PersistenceOK = true
+	if s, ok := info["rdb_last_bgsave_status"]; ok && s != "ok" {
+		r.PersistenceOK = false
+		r.problem("last RDB save " + s)
+	}
+	if s, ok := info["aof_last_write_status"]; ok && r.AOFEnabled && s != "ok" {
+		r.PersistenceOK = false
+		r.problem("last AOF write " + s)
+	}
+
+	r.Role = info["role"]
+	r.ConnectedReplicas, _ = strconv.Atoi(info["connected_slaves"])
+	if r.Role == "slave" {
+		r.MasterLinkUp = info["master_link_status"] == "up"
+		if !r.MasterLinkUp {
+			r.problem("link to master " + info["master_host"] + ":" + info["master_port"] + " down")
+		}
+	}
+	return r, nil
+}
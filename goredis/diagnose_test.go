@@ -0,0 +1,57 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func infoReply(lines ...string) string {
+	info := strings.Join(lines, "\r\n") + "\r\n"
+	return "$" + strconv.Itoa(len(info)) + "\r\n" + info + "\r\n"
+}
+
+func TestDiagnose(t *testing.T) {
+	replica := false
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			if args[1] != "secret" {
+				return "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case "PING":
+			return "+PONG\r\n"
+		case "INFO":
+			if replica {
+				return infoReply("# Server", "redis_version:6.0.9", "redis_mode:standalone",
+					"# Persistence", "loading:0", "rdb_last_bgsave_status:err",
+					"# Replication", "role:slave", "master_host:10.0.0.1", "master_port:6379", "master_link_status:down")
+			}
+			return infoReply("# Server", "redis_version:7.2.4", "redis_mode:standalone",
+				"# Persistence", "loading:0", "rdb_last_bgsave_status:ok", "aof_enabled:1", "aof_last_write_status:ok",
+				"# Replication", "role:master", "connected_slaves:2")
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "secret")
+	p.MinVersion = Version{6, 2, 0}
+	r, e := p.Diagnose()
+	if e != nil || r.Err() != nil {
+		t.Fatalf("%v %v", e, r.Err())
+	}
+	if !r.Authenticated || !r.Selected || r.Version != (Version{7, 2, 4}) || r.Role != "master" ||
+		r.ConnectedReplicas != 2 || !r.AOFEnabled || !r.PersistenceOK || r.RTT <= 0 {
+		t.Fatalf("report %+v", r)
+	}
+
+	replica = true
+	r, _ = p.Diagnose()
+	if len(r.Problems) != 3 || r.PersistenceOK || r.MasterLinkUp {
+		t.Fatalf("problems %q", r.Problems)
+	}
+
+	p.Password = "wrong"
+	if r, e = p.Diagnose(); !isReplyError(e) || r.Authenticated {
+		t.Fatalf("got %v", e)
+	}
+}
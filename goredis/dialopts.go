@@ -0,0 +1,96 @@
+package msgredis
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+// DialOptions configures DialWith
+type DialOptions struct {
+	// AUTH [Username] Password, no AUTH without a password
+	Username string
+	Password string
+	// selected after AUTH, and again after a RESET
+	DB int
+	// zero takes ConnectTimeout, ReadTimeout and WriteTimeout
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	KeepAlive      bool
+	// dials TLS when set, see DialTLS
+	TLSConfig *tls.Config
+	// CLIENT SETNAME, set again after a RESET
+	ClientName string
+	// 3 switches to RESP3 with HELLO3, 0 or 2 keeps RESP2
+	Protocol int
+	// the pool the conn will be pushed to, nil for a lone conn
+	Pool *Pool
+}
+
+// DialWith dials address with opts, giving up when ctx is done. Dial and
+// DialTLS remain for the common cases.
+func DialWith(ctx context.Context, address string, opts DialOptions) (*Conn, error) {
+	if opts.DB < 0 || (opts.Protocol != 0 && opts.Protocol != 2 && opts.Protocol != 3) {
+		return nil, ErrBadArgs
+	}
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = ConnectTimeout
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = ReadTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = WriteTimeout
+	}
+	return dialContext(ctx, address, opts)
+}
+
+// dialOptions returns the options to dial a conn like c again, without
+// the session state it changed since
+func (c *Conn) dialOptions() DialOptions {
+	opts := DialOptions{
+		Username:       c.username,
+		Password:       c.password,
+		DB:             c.db,
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    c.readTimeout,
+		WriteTimeout:   c.writeTimeout,
+		KeepAlive:      c.keepAlive,
+		TLSConfig:      c.tlsConfig,
+		ClientName:     c.clientName,
+	}
+	if c.resp3 {
+		opts.Protocol = 3
+	}
+	return opts
+}
+
+// setup prepares the session of a new conn
+func (c *Conn) setup(opts DialOptions) error {
+	e := c.authenticate(Credentials{Username: opts.Username, Password: opts.Password})
+	if e != nil {
+		return e
+	}
+	if opts.Protocol == 3 {
+		if e = c.HELLO3(); e != nil {
+			return e
+		}
+		c.protocol = 3
+	}
+	if opts.DB != 0 {
+		if e = c.okCall("SELECT", opts.DB); e != nil {
+			return e
+		}
+		c.db = opts.DB
+		// the base db, not state to reset
+		c.state &^= stateSelect
+	}
+	if opts.ClientName != "" {
+		if e = c.okCall("CLIENT", "SETNAME", opts.ClientName); e != nil {
+			return e
+		}
+		c.clientName = opts.ClientName
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package msgredis
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDialWith(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		got = append(got, strings.Join(args, " "))
+		mu.Unlock()
+		switch args[0] {
+		case "HELLO":
+			return "*2\r\n" + bulk("proto") + ":3\r\n"
+		case "RESET":
+			return "+RESET\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c, e := DialWith(context.Background(), s.Addr(), DialOptions{
+		Username:   "app",
+		Password:   "secret",
+		DB:         2,
+		ClientName: "worker",
+		Protocol:   3,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+	if !c.resp3 || c.readTimeout != ReadTimeout || c.state != 0 {
+		t.Fatalf("resp3 %v, read timeout %v, state %b", c.resp3, c.readTimeout, c.state)
+	}
+	c.Call("SELECT", 5)
+	if c.state&stateSelect == 0 {
+		t.Fatal("SELECT of another db not tracked")
+	}
+	if e = c.RESET(); e != nil {
+		t.Fatal(e)
+	}
+	want := []string{
+		"AUTH app secret", "HELLO 3", "SELECT 2", "CLIENT SETNAME worker",
+		"SELECT 5", "RESET", "AUTH app secret", "HELLO 3", "SELECT 2", "CLIENT SETNAME worker",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, want) || !c.resp3 {
+		t.Fatalf("sent %q", got)
+	}
+
+	for _, opts := range []DialOptions{{DB: -1}, {Protocol: 1}} {
+		if _, e = DialWith(context.Background(), s.Addr(), opts); e != ErrBadArgs {
+			t.Fatalf("%+v: %v", opts, e)
+		}
+	}
+}
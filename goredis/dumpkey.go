@@ -0,0 +1,77 @@
+package msgredis
+
+import (
+	"errors"
+)
+
+var ErrUnknownType = errors.New(CommonErrPrefix + "unsupported key type")
+
+// KeyDump is a typed snapshot of one key. Value depends on Type:
+//
+//	string => string
+//	hash   => map[string]string
+//	list   => []string
+//	set    => []string
+//	zset   => []ZMember, lowest score first
+//	stream => []StreamEntry
+type KeyDump struct {
+	Key   string
+	Type  string
+	Value interface{}
+	// milliseconds, -1 if the key has no expire
+	PTTL int64
+}
+
+// DumpKey detects the type of key and reads its whole value in one call,
+// for debugging tools and data browsers. Big keys are read at once, so do
+// not use it on huge collections in production.
+func (c *Conn) DumpKey(key string) (*KeyDump, error) {
+	t, e := c.TYPE(key)
+	if e != nil {
+		return nil, e
+	}
+	d := &KeyDump{Key: key, Type: string(t)}
+
+	var v interface{}
+	switch d.Type {
+	case "none":
+		return nil, ErrKeyNotExist
+	case "string":
+		v, e = c.Call("GET", key)
+		if e == nil {
+			d.Value, e = replyString(v)
+		}
+	case "hash":
+		v, e = c.Call("HGETALL", key)
+		if e == nil {
+			d.Value, e = replyStringMap(v)
+		}
+	case "list":
+		v, e = c.Call("LRANGE", key, 0, -1)
+		if e == nil {
+			d.Value, e = replyStrings(v)
+		}
+	case "set":
+		v, e = c.Call("SMEMBERS", key)
+		if e == nil {
+			d.Value, e = replyStrings(v)
+		}
+	case "zset":
+		v, e = c.Call("ZRANGE", key, 0, -1, "WITHSCORES")
+		if e == nil {
+			d.Value, e = replyZMembers(v)
+		}
+	case "stream":
+		d.Value, e = c.XRANGE(key, "-", "+", 0)
+	default:
+		return nil, ErrUnknownType
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	if d.PTTL, e = c.PTTL(key); e != nil {
+		return nil, e
+	}
+	return d, nil
+}
@@ -0,0 +1,46 @@
+package msgredis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDumpKey(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		switch args[0] + " " + args[1] {
+		case "TYPE h":
+			return "+hash\r\n"
+		case "TYPE z":
+			return "+zset\r\n"
+		case "TYPE missing":
+			return "+none\r\n"
+		case "HGETALL h":
+			return "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$0\r\n\r\n"
+		case "ZRANGE z":
+			return "*4\r\n$1\r\nx\r\n$3\r\n1.5\r\n$1\r\ny\r\n$1\r\n2\r\n"
+		case "PTTL h", "PTTL z":
+			return ":-1\r\n"
+		}
+		return "-ERR unexpected\r\n"
+	}))
+
+	d, e := c.DumpKey("h")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if d.Type != "hash" || d.PTTL != -1 || !reflect.DeepEqual(d.Value, map[string]string{"a": "1", "b": ""}) {
+		t.Errorf("hash dump %+v", d)
+	}
+
+	d, e = c.DumpKey("z")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !reflect.DeepEqual(d.Value, []ZMember{{"x", 1.5}, {"y", 2}}) {
+		t.Errorf("zset dump %+v", d)
+	}
+
+	if _, e = c.DumpKey("missing"); e != ErrKeyNotExist {
+		t.Errorf("missing key err=%v", e)
+	}
+}
@@ -0,0 +1,131 @@
+package msgredis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultEjectErrorRate = 0.5
+	DefaultEjectMinCalls  = 20
+	DefaultEjectDuration  = 10 * time.Second
+)
+
+// shardHealth counts network errors of one MultiPool shard. It is installed
+// as a Hook on the shard's pool. Server replies like -ERR are not counted.
+type shardHealth struct {
+	mu           sync.Mutex
+	calls        int64
+	errors       int64
+	ejectedUntil time.Time
+	probing      bool
+}
+
+func (h *shardHealth) Before(ev *HookEvent) {}
+
+func (h *shardHealth) After(ev *HookEvent) {
+	h.mu.Lock()
+	h.calls++
+	if ev.Err != nil && !strings.Contains(ev.Err.Error(), CommonErrPrefix) {
+		h.errors++
+	}
+	h.mu.Unlock()
+}
+
+// AddrByKey returns the shard serving key. If the owner is ejected the key
+// moves to the next live shard, so only the ejected shard's keys move.
+func (mp *MultiPool) AddrByKey(key string) string {
+	n := len(mp.servers)
+	i := Sum(key) % n
+	for j := 0; j < n; j++ {
+		addr := mp.servers[(i+j)%n]
+		if !mp.CheckHealth(addr) {
+			return addr
+		}
+	}
+	// everything is ejected, fall back to the owner
+	return mp.servers[i]
+}
+
+// Ejected reports whether addr is temporarily removed from key routing
+func (mp *MultiPool) Ejected(addr string) bool {
+	h, ok := mp.health[addr]
+	if !ok {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.ejectedUntil.IsZero()
+}
+
+// CheckHealth ejects addr if its error rate since the last check crossed
+// EjectErrorRate, counting from zero again, and reports whether it is
+// ejected. AddrByKey checks the shards it routes to.
+func (mp *MultiPool) CheckHealth(addr string) bool {
+	h, ok := mp.health[addr]
+	if !ok {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.ejectedUntil.IsZero() {
+		return true
+	}
+	if h.calls < mp.EjectMinCalls {
+		return false
+	}
+	rate := float64(h.errors) / float64(h.calls)
+	h.calls, h.errors = 0, 0
+	if rate < mp.EjectErrorRate {
+		return false
+	}
+	mp.eject(addr, h)
+	return true
+}
+
+// dial failures never reach the hooks
+func (mp *MultiPool) reportFailure(addr string) {
+	h, ok := mp.health[addr]
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	h.calls++
+	h.errors++
+	h.mu.Unlock()
+}
+
+// h.mu must be held
+func (mp *MultiPool) eject(addr string, h *shardHealth) {
+	fmt.Println("[MultiPool] eject " + addr)
+	h.ejectedUntil = time.Now().Add(mp.EjectDuration)
+	if !h.probing {
+		h.probing = true
+		time.AfterFunc(mp.EjectDuration, func() { mp.probe(addr, h) })
+	}
+}
+
+// PING an ejected shard, re-admit it on success or keep it out for another
+// EjectDuration
+func (mp *MultiPool) probe(addr string, h *shardHealth) {
+	alive := false
+	if c, e := mp.pools[addr].lone(context.Background(), ReadTimeout); e == nil {
+		alive = c.IsAlive()
+		c.Close()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if alive {
+		fmt.Println("[MultiPool] re-admit " + addr)
+		h.ejectedUntil = time.Time{}
+		h.calls, h.errors = 0, 0
+		h.probing = false
+		return
+	}
+	h.ejectedUntil = time.Now().Add(mp.EjectDuration)
+	time.AfterFunc(mp.EjectDuration, func() { mp.probe(addr, h) })
+}
@@ -0,0 +1,157 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// an emulation receives the arguments of the missing command
+type emulation func(c *Conn, args []interface{}) (interface{}, error)
+
+var getdelScript = NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v`)
+
+// ARGV: expire command or PERSIST, and its argument
+var getexScript = NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v and ARGV[1] then
+	if ARGV[1] == 'PERSIST' then
+		redis.call('PERSIST', KEYS[1])
+	else
+		redis.call(ARGV[1], KEYS[1], ARGV[2])
+	end
+end
+return v`)
+
+// ARGV: replace flag
+var copyScript = NewScript(`
+local d = redis.call('DUMP', KEYS[1])
+if not d then
+	return 0
+end
+if ARGV[1] == '0' and redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = 0
+end
+if ARGV[1] == '1' then
+	redis.call('RESTORE', KEYS[2], ttl, d, 'REPLACE')
+else
+	redis.call('RESTORE', KEYS[2], ttl, d)
+end
+return 1`)
+
+// ARGV: limit, 0 for none
+var sintercardScript = NewScript(`
+local n = #redis.call('SINTER', unpack(KEYS))
+local limit = tonumber(ARGV[1])
+if limit > 0 and n > limit then
+	n = limit
+end
+return n`)
+
+// GETEX options => the expire command run by the script
+var getexOptions = map[string]string{
+	"EX": "EXPIRE", "PX": "PEXPIRE", "EXAT": "EXPIREAT", "PXAT": "PEXPIREAT",
+}
+
+// filled in init, the emulations call back into Conn.Call
+var emulations map[string]emulation
+
+func init() {
+	emulations = map[string]emulation{
+		"GETDEL": func(c *Conn, args []interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, ErrBadArgs
+			}
+			return c.RunScript(getdelScript, []string{argString(args[0])})
+		},
+		"GETEX": func(c *Conn, args []interface{}) (interface{}, error) {
+			if len(args) == 0 {
+				return nil, ErrBadArgs
+			}
+			keys := []string{argString(args[0])}
+			switch len(args) {
+			case 1:
+				return c.RunScript(getexScript, keys)
+			case 2:
+				if strings.ToUpper(argString(args[1])) == "PERSIST" {
+					return c.RunScript(getexScript, keys, "PERSIST")
+				}
+			case 3:
+				if cmd, ok := getexOptions[strings.ToUpper(argString(args[1]))]; ok {
+					return c.RunScript(getexScript, keys, cmd, optionValue("GETEX", args[1], args[2]))
+				}
+			}
+			return nil, ErrBadArgs
+		},
+		"COPY": func(c *Conn, args []interface{}) (interface{}, error) {
+			if len(args) < 2 {
+				return nil, ErrBadArgs
+			}
+			replace := "0"
+			for _, opt := range args[2:] {
+				if strings.ToUpper(argString(opt)) != "REPLACE" {
+					// DB needs SELECT inside the script, not emulated
+					return nil, ErrBadArgs
+				}
+				replace = "1"
+			}
+			return c.RunScript(copyScript, []string{argString(args[0]), argString(args[1])}, replace)
+		},
+		"SINTERCARD": func(c *Conn, args []interface{}) (interface{}, error) {
+			if len(args) < 2 {
+				return nil, ErrBadArgs
+			}
+			n, e := strconv.Atoi(argString(args[0]))
+			if e != nil || n <= 0 || len(args) < 1+n {
+				return nil, ErrBadArgs
+			}
+			keys := make([]string, n)
+			for i := range keys {
+				keys[i] = argString(args[1+i])
+			}
+			limit := "0"
+			if rest := args[1+n:]; len(rest) == 2 && strings.ToUpper(argString(rest[0])) == "LIMIT" {
+				limit = argString(rest[1])
+			} else if len(rest) != 0 {
+				return nil, ErrBadArgs
+			}
+			return c.RunScript(sintercardScript, keys, limit)
+		},
+	}
+}
+
+// SetEmulation runs missing commands (GETDEL, GETEX, COPY, SINTERCARD) as
+// equivalent scripts when the pool detected an older server, instead of
+// failing with UnsupportedError. Needs Pool.CheckCapabilities.
+func (c *Conn) SetEmulation(on bool) {
+	c.emulate = on
+}
+
+// emulated returns the emulation of command when e is an UnsupportedError
+func (c *Conn) emulated(command string, e error) emulation {
+	if _, ok := e.(*UnsupportedError); !ok || !c.emulate {
+		return nil
+	}
+	return emulations[strings.ToUpper(command)]
+}
+
+// optionValue encodes durations and times for the option of command, as
+// writeRequest would, before they are passed to a script
+func optionValue(command string, opt, v interface{}) string {
+	switch data := v.(type) {
+	case time.Duration:
+		return durationArg(command, opt, data)
+	case time.Time:
+		return timeArg(command, opt, data)
+	}
+	return argString(v)
+}
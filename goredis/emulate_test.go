@@ -0,0 +1,61 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmulation(t *testing.T) {
+	var got []string
+	caps := capsHandler("6.0.9")
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "EVALSHA":
+			got = args
+			return "$1\r\nv\r\n"
+		case "INFO", "MODULE":
+			return caps(args)
+		}
+		got = args
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	p.CheckCapabilities = true
+	p.EmulateCommands = true
+	c := p.Pop()
+	defer p.Push(c)
+
+	ret, e := c.Call("GETDEL", "k")
+	if e != nil || string(ret.([]byte)) != "v" {
+		t.Fatalf("got %v %v", ret, e)
+	}
+	if got[0] != "EVALSHA" || got[1] != getdelScript.SHA || got[2] != "1" || got[3] != "k" {
+		t.Fatalf("sent %v", got)
+	}
+
+	c.Call("GETEX", "k", "PX", 1500*time.Millisecond)
+	if len(got) != 6 || got[4] != "PEXPIRE" || got[5] != "1500" {
+		t.Fatalf("sent %v", got)
+	}
+	c.Call("SINTERCARD", 2, "a", "b", "LIMIT", 10)
+	if len(got) != 6 || got[1] != sintercardScript.SHA || got[2] != "2" || got[5] != "10" {
+		t.Fatalf("sent %v", got)
+	}
+	c.Call("COPY", "a", "b", "REPLACE")
+	if len(got) != 6 || got[5] != "1" {
+		t.Fatalf("sent %v", got)
+	}
+	if _, e = c.Call("COPY", "a", "b", "DB", 1); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+
+	// no emulation: the unsupported error
+	c.SetEmulation(false)
+	if _, e = c.Call("GETDEL", "k"); e == nil {
+		t.Fatal("GETDEL sent to an old server")
+	}
+	// a supported command goes out as is
+	if _, e = c.Call("SET", "k", "v"); e != nil || got[0] != "SET" {
+		t.Fatalf("sent %v %v", got, e)
+	}
+}
@@ -0,0 +1,198 @@
+package msgredis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explanation is a command as it would be sent, see Conn.Explain
+type Explanation struct {
+	Command string
+	// the arguments as written on the wire, command name first
+	Wire []string
+	Keys []string
+	// hash slot of the keys, -1 without keys or across slots
+	Slot int
+	RESP []byte
+	// arity, slot, argument type and client-side policy problems
+	Problems []string
+}
+
+// Err returns the problems as one error, nil if the command looks valid
+func (x *Explanation) Err() error {
+	if len(x.Problems) == 0 {
+		return nil
+	}
+	return errors.New(CommonErrPrefix + x.Command + ": " + strings.Join(x.Problems, "; "))
+}
+
+func (x *Explanation) String() string {
+	return strconv.Quote(string(x.RESP)) + x.notes()
+}
+
+// safeString is String with the arguments passed through r, for logs
+func (x *Explanation) safeString(r *Redactor) string {
+	if len(x.Wire) == 0 {
+		return x.Command + x.notes()
+	}
+	args := make([]interface{}, len(x.Wire)-1)
+	for i, arg := range x.Wire[1:] {
+		args[i] = arg
+	}
+	// x.Command, the wire name may be renamed
+	return strings.Join(append([]string{x.Wire[0]}, r.RedactCommand(x.Command, args)...), " ") + x.notes()
+}
+
+func (x *Explanation) notes() string {
+	s := ""
+	if x.Slot >= 0 {
+		s += " slot=" + strconv.Itoa(x.Slot)
+	}
+	for _, p := range x.Problems {
+		s += " problem=" + strconv.Quote(p)
+	}
+	return s
+}
+
+// Explain encodes and validates a command without sending it: the exact
+// RESP bytes after policies and rename-command, arity checked against the
+// registry, keys and their slot, and arguments whose type would only be
+// written with %v. Unknown commands are encoded but not checked.
+// ReaderArg arguments are never read, RESP holds a placeholder of their
+// length instead, so they can still be sent afterwards.
+func (c *Conn) Explain(command string, args ...interface{}) *Explanation {
+	x := &Explanation{Command: strings.ToUpper(command), Slot: -1}
+	command, args, e := c.applyPolicies(command, args)
+	if e != nil {
+		x.Problems = append(x.Problems, e.Error())
+	}
+	if c.disabled(command) {
+		x.Problems = append(x.Problems, ErrCommandDisabled.Error())
+	}
+	if e = c.checkReadOnly(command); e != nil {
+		x.Problems = append(x.Problems, e.Error())
+	}
+	if e = c.checkProxy(command); e != nil {
+		x.Problems = append(x.Problems, e.Error())
+	}
+	for i, arg := range args {
+		switch arg.(type) {
+		case time.Duration, time.Time, []byte, ReaderArg:
+			continue
+		}
+		switch k := reflect.ValueOf(arg).Kind(); k {
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Ptr,
+			reflect.Func, reflect.Chan, reflect.Interface:
+			x.Problems = append(x.Problems, fmt.Sprintf("argument %d is a %T, written as %q", i+1, arg, argString(arg)))
+		}
+	}
+	if ci := LookupCommand(command); ci != nil {
+		if n := len(args) + 1; (ci.Arity > 0 && n != ci.Arity) || (ci.Arity < 0 && n < -ci.Arity) {
+			x.Problems = append(x.Problems, fmt.Sprintf("wrong number of arguments: %d, arity %d", n, ci.Arity))
+		}
+		for _, pos := range ci.KeyPositions(args) {
+			x.Keys = append(x.Keys, argString(args[pos]))
+		}
+		if len(x.Keys) > 0 {
+			if slot, e := CheckSlot(x.Keys...); e == nil {
+				x.Slot = int(slot)
+			} else if c.clusterMode {
+				// a standalone server takes keys of any slots together
+				x.Problems = append(x.Problems, e.Error())
+			}
+		}
+	}
+
+	// never read readers, a copy of args holds placeholders
+	wire := args
+	copied := false
+	for i, arg := range args {
+		if _, ok := arg.(ReaderArg); ok {
+			if !copied {
+				wire, copied = append([]interface{}(nil), args...), true
+			}
+			wire[i] = argString(arg)
+		}
+	}
+	var buf bytes.Buffer
+	wb := c.wb
+	c.wb = bufio.NewWriter(&buf)
+	e = c.writeRequest(command, wire)
+	if e == nil {
+		e = c.wb.Flush()
+	}
+	c.wb = wb
+	if e != nil {
+		x.Problems = append(x.Problems, e.Error())
+	}
+	x.RESP = buf.Bytes()
+	x.Wire = decodeRequest(x.RESP)
+	return x
+}
+
+// DryRunError is the error of every Call in dry run, the command was not
+// sent and there is no reply
+type DryRunError struct {
+	Explanation *Explanation
+}
+
+func (e *DryRunError) Error() string {
+	if pe := e.Explanation.Err(); pe != nil {
+		return pe.Error()
+	}
+	return CommonErrPrefix + "dry run, not sent: " + e.Explanation.Command
+}
+
+// SetDryRun makes Call and PipeSend log the Explanation instead of sending.
+// Call returns a nil reply with a *DryRunError carrying the Explanation, so
+// the typed helpers return before reading a reply; PipeSend returns the
+// problems of the Explanation.
+func (c *Conn) SetDryRun(on bool) {
+	c.dryRun = on
+}
+
+// SetClusterMode tells the conn it talks to a cluster node, Explain then
+// flags keys of different slots. Pools of a Resharding set it.
+func (c *Conn) SetClusterMode(on bool) {
+	c.clusterMode = on
+}
+
+func (c *Conn) dryRunCall(command string, args []interface{}) (interface{}, error) {
+	x := c.Explain(command, args...)
+	r := c.redactor
+	if r == nil {
+		r = DefaultRedactor
+	}
+	fmt.Println("[DryRun] " + x.safeString(r))
+	return nil, &DryRunError{Explanation: x}
+}
+
+// decodeRequest splits an encoded request back into its arguments
+func decodeRequest(p []byte) []string {
+	r := bufio.NewReader(bytes.NewReader(p))
+	line, e := r.ReadString('\n')
+	if e != nil || len(line) < 3 {
+		return nil
+	}
+	n, _ := strconv.Atoi(line[1 : len(line)-2])
+	wire := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, e = r.ReadString('\n'); e != nil || len(line) < 3 {
+			return wire
+		}
+		size, _ := strconv.Atoi(line[1 : len(line)-2])
+		buf := make([]byte, size+2)
+		if _, e = io.ReadFull(r, buf); e != nil {
+			return wire
+		}
+		wire = append(wire, string(buf[:size]))
+	}
+	return wire
+}
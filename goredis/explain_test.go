@@ -0,0 +1,83 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		t.Errorf("%v sent in dry run", args)
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+
+	x := c.Explain("SET", "k", "v", "PX", 1500*time.Millisecond)
+	if string(x.RESP) != "*5\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n$2\r\nPX\r\n$4\r\n1500\r\n" {
+		t.Fatalf("resp %q", x.RESP)
+	}
+	if x.Err() != nil || x.Slot != int(KeySlot("k")) || len(x.Wire) != 5 || x.Wire[4] != "1500" {
+		t.Fatalf("%+v", x)
+	}
+
+	if x = c.Explain("GET", "a", "b"); x.Err() == nil {
+		t.Fatal("arity not checked")
+	}
+	// keys across slots are fine on a standalone server
+	if x = c.Explain("MGET", "a", "b"); x.Err() != nil {
+		t.Fatalf("cross slot outside cluster mode: %v", x.Err())
+	}
+	c.SetClusterMode(true)
+	if x = c.Explain("MGET", "a", "b"); x.Err() == nil || x.Slot != -1 {
+		t.Fatal("cross slot not reported")
+	}
+	if x = c.Explain("SADD", "s", []string{"a", "b"}); x.Err() == nil {
+		t.Fatal("slice argument not reported")
+	}
+
+	c.SetDryRun(true)
+	ret, e := c.Call("MGET", "{u}a", "{u}b")
+	dr, ok := e.(*DryRunError)
+	if ret != nil || !ok || dr.Explanation.Slot != int(KeySlot("u")) || dr.Explanation.Err() != nil {
+		t.Fatalf("got %v %v", ret, e)
+	}
+	// typed helpers see the error, not a reply of the wrong type
+	if ok, e := c.EXISTS("k"); ok || e == nil {
+		t.Fatalf("exists %v %v", ok, e)
+	}
+	if e = c.PipeSend("GET", "k"); e != nil {
+		t.Fatal(e)
+	}
+	if e = c.PipeSend("GET"); e == nil {
+		t.Fatal("arity not checked")
+	}
+}
+
+func TestExplainSecretsAndReaders(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	r := &Redactor{}
+	for _, args := range [][]interface{}{
+		{"AUTH", "app", "secret"},
+		{"CONFIG", "SET", "requirepass", "secret"},
+		{"HELLO", "3", "AUTH", "app", "secret"},
+	} {
+		x := c.Explain(args[0].(string), args[1:]...)
+		if log := x.safeString(r); strings.Contains(log, "secret") || !strings.Contains(log, "***") {
+			t.Fatalf("logged %q", log)
+		}
+	}
+	if log := c.Explain("SET", "k", "v").safeString(r); !strings.HasPrefix(log, "SET k v ") {
+		t.Fatalf("logged %q", log)
+	}
+
+	// the reader is left for the real call
+	body := strings.NewReader("hello")
+	x := c.Explain("SET", "k", ReaderArg{R: body, N: 5})
+	if x.Err() != nil || body.Len() != 5 {
+		t.Fatalf("%v, %d bytes left", x.Err(), body.Len())
+	}
+	if _, e := c.Call("SET", "k", ReaderArg{R: body, N: 5}); e != nil {
+		t.Fatal(e)
+	}
+}
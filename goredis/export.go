@@ -0,0 +1,319 @@
+package msgredis
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	// one JSON object per line: {"key":..,"type":..,"ttl":..,"value":..}
+	FormatJSON = "json"
+	// key,type,ttl,value with non string values JSON encoded
+	FormatCSV = "csv"
+
+	// the key and every string of the value are base64, set on records
+	// holding bytes that are not UTF-8, which JSON would garble
+	EncodingBase64 = "base64"
+)
+
+var ErrBadFormat = errors.New(CommonErrPrefix + "unknown export format")
+
+// one exported key, ttl in milliseconds, -1 if persistent
+type exportRecord struct {
+	Key      string          `json:"key"`
+	Type     string          `json:"type"`
+	TTL      int64           `json:"ttl"`
+	Value    json.RawMessage `json:"value"`
+	Encoding string          `json:"encoding,omitempty"`
+}
+
+// Export writes every key matching pattern to w, returns the number of keys.
+// Binary keys and values are exported base64 encoded, see EncodingBase64;
+// CSV rows get it as a fifth column.
+func Export(c *Conn, pattern string, w io.Writer, format string) (int, error) {
+	if format != FormatJSON && format != FormatCSV {
+		return 0, ErrBadFormat
+	}
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	enc := json.NewEncoder(bw)
+	n := 0
+	cursor := 0
+	for {
+		next, elements, e := c.SCAN(cursor, pattern != "", pattern, false, 0)
+		if e != nil {
+			return n, e
+		}
+		for _, el := range elements {
+			d, e := c.DumpKey(string(el.([]byte)))
+			if e == ErrKeyNotExist {
+				continue
+			}
+			if e != nil {
+				return n, e
+			}
+			key, encoding := d.Key, ""
+			if !utf8.ValidString(key) || !validStrings(d.Value) {
+				key, encoding = base64.StdEncoding.EncodeToString([]byte(key)), EncodingBase64
+				if d.Value, e = mapStrings(d.Value, encodeString); e != nil {
+					return n, e
+				}
+			}
+			value, e := json.Marshal(d.Value)
+			if e != nil {
+				return n, e
+			}
+			if format == FormatJSON {
+				e = enc.Encode(exportRecord{Key: key, Type: d.Type, TTL: d.PTTL, Value: value, Encoding: encoding})
+			} else {
+				if d.Type == "string" {
+					value = []byte(d.Value.(string))
+				}
+				row := []string{key, d.Type, strconv.FormatInt(d.PTTL, 10), string(value)}
+				if encoding != "" {
+					row = append(row, encoding)
+				}
+				e = cw.Write(row)
+			}
+			if e != nil {
+				return n, e
+			}
+			n++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	cw.Flush()
+	if e := cw.Error(); e != nil {
+		return n, e
+	}
+	return n, bw.Flush()
+}
+
+// Import reads records written by Export and recreates the keys, replacing
+// existing ones. Returns the number of keys imported.
+func Import(c *Conn, r io.Reader, format string) (int, error) {
+	var next func() (*exportRecord, error)
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		next = func() (*exportRecord, error) {
+			rec := &exportRecord{}
+			return rec, dec.Decode(rec)
+		}
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		// the encoding column is optional
+		cr.FieldsPerRecord = -1
+		next = func() (*exportRecord, error) {
+			row, e := cr.Read()
+			if e != nil {
+				return nil, e
+			}
+			if len(row) != 4 && len(row) != 5 {
+				return nil, ErrBadFormat
+			}
+			ttl, e := strconv.ParseInt(row[2], 10, 64)
+			if e != nil {
+				return nil, e
+			}
+			rec := &exportRecord{Key: row[0], Type: row[1], TTL: ttl, Value: json.RawMessage(row[3])}
+			if len(row) == 5 {
+				rec.Encoding = row[4]
+			}
+			if rec.Type == "string" {
+				rec.Value, _ = json.Marshal(row[3])
+			}
+			return rec, nil
+		}
+	default:
+		return 0, ErrBadFormat
+	}
+
+	n := 0
+	for {
+		rec, e := next()
+		if e == io.EOF {
+			return n, nil
+		}
+		if e != nil {
+			return n, e
+		}
+		if e = importRecord(c, rec); e != nil {
+			return n, e
+		}
+		n++
+	}
+}
+
+func importRecord(c *Conn, rec *exportRecord) error {
+	var v interface{}
+	var e error
+	switch rec.Type {
+	case "string":
+		var s string
+		e = json.Unmarshal(rec.Value, &s)
+		v = s
+	case "hash":
+		var m map[string]string
+		e = json.Unmarshal(rec.Value, &m)
+		v = m
+	case "list", "set":
+		var l []string
+		e = json.Unmarshal(rec.Value, &l)
+		v = l
+	case "zset":
+		var z []ZMember
+		e = json.Unmarshal(rec.Value, &z)
+		v = z
+	case "stream":
+		var entries []StreamEntry
+		e = json.Unmarshal(rec.Value, &entries)
+		v = entries
+	default:
+		return ErrUnknownType
+	}
+	if e != nil {
+		return e
+	}
+	key := rec.Key
+	switch rec.Encoding {
+	case "":
+	case EncodingBase64:
+		if key, e = decodeString(key); e != nil {
+			return e
+		}
+		if v, e = mapStrings(v, decodeString); e != nil {
+			return e
+		}
+	default:
+		return ErrBadFormat
+	}
+
+	cmds := [][]interface{}{{"DEL", key}}
+	args := []interface{}{key}
+	switch v := v.(type) {
+	case string:
+		cmds = append(cmds, []interface{}{"SET", key, v})
+	case map[string]string:
+		for f, val := range v {
+			args = append(args, f, val)
+		}
+		cmds = append(cmds, append([]interface{}{"HSET"}, args...))
+	case []string:
+		command := "RPUSH"
+		if rec.Type == "set" {
+			command = "SADD"
+		}
+		for _, val := range v {
+			args = append(args, val)
+		}
+		cmds = append(cmds, append([]interface{}{command}, args...))
+	case []ZMember:
+		for _, m := range v {
+			args = append(args, m.Score, m.Member)
+		}
+		cmds = append(cmds, append([]interface{}{"ZADD"}, args...))
+	case []StreamEntry:
+		for _, entry := range v {
+			xargs := []interface{}{"XADD", key, entry.ID}
+			for f, val := range entry.Fields {
+				xargs = append(xargs, f, val)
+			}
+			cmds = append(cmds, xargs)
+		}
+	}
+	// empty collections do not exist in redis
+	if last := cmds[len(cmds)-1]; len(last) == 2 && last[0] != "DEL" {
+		cmds = cmds[:len(cmds)-1]
+	}
+	if rec.TTL > 0 {
+		cmds = append(cmds, []interface{}{"PEXPIRE", key, rec.TTL})
+	}
+	for _, cmd := range cmds {
+		if e = c.PipeSend(cmd[0].(string), cmd[1:]...); e != nil {
+			break
+		}
+	}
+	// the commands queued are read even after a failed PipeSend
+	if _, pe := c.pipeExecAll(); e == nil {
+		e = pe
+	}
+	return e
+}
+
+func encodeString(s string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+func decodeString(s string) (string, error) {
+	b, e := base64.StdEncoding.DecodeString(s)
+	return string(b), e
+}
+
+func validStrings(v interface{}) bool {
+	_, e := mapStrings(v, func(s string) (string, error) {
+		if !utf8.ValidString(s) {
+			return "", ErrBadFormat
+		}
+		return s, nil
+	})
+	return e == nil
+}
+
+// mapStrings returns a KeyDump value with f applied to all of its strings
+func mapStrings(v interface{}, f func(string) (string, error)) (interface{}, error) {
+	var e error
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[string]string:
+		m := make(map[string]string, len(v))
+		for field, val := range v {
+			if field, e = f(field); e != nil {
+				return nil, e
+			}
+			if m[field], e = f(val); e != nil {
+				return nil, e
+			}
+		}
+		return m, nil
+	case []string:
+		l := make([]string, len(v))
+		for i, val := range v {
+			if l[i], e = f(val); e != nil {
+				return nil, e
+			}
+		}
+		return l, nil
+	case []ZMember:
+		z := make([]ZMember, len(v))
+		for i, m := range v {
+			z[i].Score = m.Score
+			if z[i].Member, e = f(m.Member); e != nil {
+				return nil, e
+			}
+		}
+		return z, nil
+	case []StreamEntry:
+		entries := make([]StreamEntry, len(v))
+		for i, entry := range v {
+			entries[i].ID = entry.ID
+			fields, e := mapStrings(entry.Fields, f)
+			if e != nil {
+				return nil, e
+			}
+			entries[i].Fields = fields.(map[string]string)
+		}
+		return entries, nil
+	}
+	return nil, ErrUnknownType
+}
@@ -0,0 +1,178 @@
+package msgredis
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		got = append(got, strings.Join(args, " "))
+		mu.Unlock()
+		return ":1\r\n"
+	}))
+
+	in := `{"key":"s","type":"string","ttl":-1,"value":"hello"}
+{"key":"z","type":"zset","ttl":5000,"value":[{"member":"a","score":1.5}]}
+`
+	if n, e := Import(c, strings.NewReader(in), FormatJSON); e != nil || n != 2 {
+		t.Fatalf("json import n=%d e=%v", n, e)
+	}
+	in = "l,list,-1,\"[\"\"x\"\",\"\"y\"\"]\"\ns,string,-1,\"a,b\"\n"
+	if n, e := Import(c, strings.NewReader(in), FormatCSV); e != nil || n != 2 {
+		t.Fatalf("csv import n=%d e=%v", n, e)
+	}
+
+	want := []string{
+		"DEL s", "SET s hello",
+		"DEL z", "ZADD z 1.5 a", "PEXPIRE z 5000",
+		"DEL l", "RPUSH l x y",
+		"DEL s", "SET s a,b",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// exportStore keeps strings, hashes, lists and zsets for Export and Import
+type exportStore struct {
+	mu   sync.Mutex
+	data map[string]interface{}
+	ttl  map[string]string
+}
+
+func (s *exportStore) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	switch args[0] {
+	case "SCAN":
+		keys := make([]string, 0, len(s.data))
+		for k := range s.data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "*2\r\n$1\r\n0\r\n" + respArray(keys...)
+	case "TYPE":
+		switch s.data[key].(type) {
+		case string:
+			return "+string\r\n"
+		case map[string]string:
+			return "+hash\r\n"
+		case []string:
+			return "+list\r\n"
+		case []ZMember:
+			return "+zset\r\n"
+		}
+		return "+none\r\n"
+	case "PTTL":
+		if ttl, ok := s.ttl[key]; ok {
+			return ":" + ttl + "\r\n"
+		}
+		return ":-1\r\n"
+	case "GET":
+		return respArray(s.data[key].(string))[4:]
+	case "HGETALL":
+		var fields []string
+		for f, v := range s.data[key].(map[string]string) {
+			fields = append(fields, f, v)
+		}
+		return respArray(fields...)
+	case "LRANGE":
+		return respArray(s.data[key].([]string)...)
+	case "ZRANGE":
+		var members []string
+		for _, m := range s.data[key].([]ZMember) {
+			members = append(members, m.Member, strconv.FormatFloat(m.Score, 'g', -1, 64))
+		}
+		return respArray(members...)
+	case "DEL":
+		delete(s.data, key)
+		delete(s.ttl, key)
+		return ":1\r\n"
+	case "SET":
+		s.data[key] = args[2]
+	case "HSET":
+		h := map[string]string{}
+		for i := 2; i < len(args); i += 2 {
+			h[args[i]] = args[i+1]
+		}
+		s.data[key] = h
+	case "RPUSH":
+		s.data[key] = append([]string(nil), args[2:]...)
+	case "ZADD":
+		var z []ZMember
+		for i := 2; i < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			z = append(z, ZMember{Member: args[i+1], Score: score})
+		}
+		s.data[key] = z
+	case "PEXPIRE":
+		s.ttl[key] = args[2]
+	default:
+		return "-ERR unknown command\r\n"
+	}
+	return ":1\r\n"
+}
+
+func TestExportImport(t *testing.T) {
+	data := map[string]interface{}{
+		"s":        "hello",
+		"bin\xff":  "\x00\xfe\xffraw",
+		"h":        map[string]string{"f": "v", "b\xc3": "\xff"},
+		"l":        []string{"x", "\x80y"},
+		"z":        []ZMember{{Member: "a", Score: 1.5}, {Member: "\xfe", Score: 2}},
+		"plain:h":  map[string]string{"a": "1"},
+		"plain:zz": []ZMember{{Member: "m", Score: -1}},
+	}
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		src := &exportStore{data: map[string]interface{}{}, ttl: map[string]string{"s": "5000"}}
+		for k, v := range data {
+			src.data[k] = v
+		}
+		var buf bytes.Buffer
+		if n, e := Export(dialFake(t, newFakeServer(t, src.handle)), "", &buf, format); e != nil || n != len(data) {
+			t.Fatalf("%s export: %d %v", format, n, e)
+		}
+		if format == FormatJSON && strings.Contains(buf.String(), "\ufffd") {
+			t.Fatalf("garbled export %s", buf.String())
+		}
+		dst := &exportStore{data: map[string]interface{}{}, ttl: map[string]string{}}
+		if n, e := Import(dialFake(t, newFakeServer(t, dst.handle)), &buf, format); e != nil || n != len(data) {
+			t.Fatalf("%s import: %d %v", format, n, e)
+		}
+		if !reflect.DeepEqual(dst.data, src.data) || !reflect.DeepEqual(dst.ttl, src.ttl) {
+			t.Fatalf("%s round trip:\n%q %v\nwant\n%q %v", format, dst.data, dst.ttl, src.data, src.ttl)
+		}
+	}
+}
+
+func TestImportReplyErrors(t *testing.T) {
+	// the DEL succeeds, the write after it does not
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		if args[0] == "HSET" {
+			return "-OOM command not allowed when used memory > 'maxmemory'\r\n"
+		}
+		return ":1\r\n"
+	}))
+	in := `{"key":"h","type":"hash","ttl":-1,"value":{"f":"v"}}` + "\n"
+	if n, e := Import(c, strings.NewReader(in), FormatJSON); n != 0 || !isReplyError(e) {
+		t.Fatalf("import %d %v", n, e)
+	}
+	// an unknown encoding is refused
+	in = `{"key":"s","type":"string","ttl":-1,"value":"x","encoding":"rot13"}` + "\n"
+	if _, e := Import(c, strings.NewReader(in), FormatJSON); e != ErrBadFormat {
+		t.Fatalf("got %v", e)
+	}
+}
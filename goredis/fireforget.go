@@ -0,0 +1,172 @@
+package msgredis
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrReplyOff = errors.New(CommonErrPrefix + "replies are off, see SetReplyOff")
+
+// FireAndForgetError is returned for a command that cannot be sent without
+// its reply, it was not sent
+type FireAndForgetError struct {
+	Command string
+}
+
+func (e *FireAndForgetError) Error() string {
+	return CommonErrPrefix + e.Command + " cannot be fired and forgotten"
+}
+
+// commands whose reply is part of the session protocol, or which change
+// state the conn tracks from the replies
+var noFireAndForget = map[string]bool{
+	"MULTI":        true,
+	"EXEC":         true,
+	"DISCARD":      true,
+	"WATCH":        true,
+	"UNWATCH":      true,
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"SSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"SUNSUBSCRIBE": true,
+	"CLIENT":       true,
+	"HELLO":        true,
+	"AUTH":         true,
+	"SELECT":       true,
+	"RESET":        true,
+	"MONITOR":      true,
+	"QUIT":         true,
+}
+
+// clientReply returns the mode of a CLIENT REPLY command, "" for others
+func clientReply(command string, args []interface{}) string {
+	if len(args) != 2 || !strings.EqualFold(command, "CLIENT") || !strings.EqualFold(argString(args[0]), "REPLY") {
+		return ""
+	}
+	return strings.ToUpper(argString(args[1]))
+}
+
+// checkReplyMode refuses commands whose reply would never come: anything
+// but CLIENT REPLY ON and RESET while replies are off, and CLIENT REPLY
+// OFF or SKIP sent as a regular command, see FireAndForget
+func (c *Conn) checkReplyMode(command string, args []interface{}) error {
+	mode := clientReply(command, args)
+	if mode == "OFF" || mode == "SKIP" {
+		return &FireAndForgetError{Command: "CLIENT REPLY " + mode}
+	}
+	if c.state&stateReplyOff != 0 && mode != "ON" && !strings.EqualFold(command, "RESET") {
+		return ErrReplyOff
+	}
+	return nil
+}
+
+// FireAndForget sends a command without waiting for its reply, preceded
+// by CLIENT REPLY SKIP so the server sends none, e.g. for write-heavy
+// telemetry. Errors of the command are lost too. Commands changing the
+// session state are refused with FireAndForgetError, as is a conn in a
+// transaction or subscribed. See SetReplyOff to skip the CLIENT REPLY SKIP
+// of every command.
+func (c *Conn) FireAndForget(command string, args ...interface{}) error {
+	if e := c.enter(command); e != nil {
+		return e
+	}
+	defer c.leave()
+	if c.dryRun {
+		_, e := c.dryRunCall(command, args)
+		return e.(*DryRunError).Explanation.Err()
+	}
+	command, args, e := c.applyPolicies(command, args)
+	if e != nil {
+		return e
+	}
+	name := strings.ToUpper(command)
+	if noFireAndForget[name] || c.state&(stateMulti|stateSubscribed) != 0 {
+		return &FireAndForgetError{Command: name}
+	}
+	if c.disabled(command) {
+		return ErrCommandDisabled
+	}
+	if e = c.checkReadOnly(command); e != nil {
+		return e
+	}
+	if e = c.checkProxy("CLIENT"); e != nil {
+		return e
+	}
+	if args, e = c.encryptArgs(command, args); e != nil {
+		return e
+	}
+	if len(c.hooks) == 0 && c.trace == nil {
+		e = c.fire(command, args)
+	} else {
+		ev := c.newEvent(command, args)
+		c.before(ev)
+		ev.Err = c.fire(command, args)
+		c.after(ev)
+		e = ev.Err
+	}
+	if e == nil {
+		c.microUpdate(command, args, nil, nil)
+	}
+	return e
+}
+
+// fire writes the command, after CLIENT REPLY SKIP unless replies are off
+func (c *Conn) fire(command string, args []interface{}) error {
+	if c.broken {
+		return ErrBrokenConn
+	}
+	c.lastActiveTime = time.Now().Unix()
+	var e error
+	if c.writeTimeout > 0 {
+		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+			return e
+		}
+	}
+	if c.state&stateReplyOff == 0 {
+		e = c.writeRequest("CLIENT", []interface{}{"REPLY", "SKIP"})
+	}
+	if e == nil {
+		e = c.writeRequest(command, args)
+	}
+	if e == nil {
+		e = c.wb.Flush()
+	}
+	if e != nil {
+		// the server may have the SKIP without its command
+		c.broken = true
+	}
+	return e
+}
+
+// SetReplyOff turns the replies of the conn off (CLIENT REPLY OFF) or back
+// on. While off, FireAndForget sends bare commands and every other command
+// fails with ErrReplyOff instead of waiting for a reply forever. Pools turn
+// replies back on when the conn is pushed.
+func (c *Conn) SetReplyOff(off bool) error {
+	if !off {
+		if c.state&stateReplyOff == 0 {
+			return nil
+		}
+		return c.okCall("CLIENT", "REPLY", "ON")
+	}
+	if c.state&stateReplyOff != 0 {
+		return nil
+	}
+	if e := c.enter("CLIENT"); e != nil {
+		return e
+	}
+	defer c.leave()
+	// queued commands would wait for their replies
+	if c.state&(stateMulti|stateSubscribed) != 0 || c.pipeCount > 0 {
+		return &FireAndForgetError{Command: "CLIENT REPLY OFF"}
+	}
+	if e := c.checkProxy("CLIENT"); e != nil {
+		return e
+	}
+	// no reply to read, and none for what follows
+	c.state |= stateReplyOff
+	return c.fire("CLIENT", []interface{}{"REPLY", "OFF"})
+}
@@ -0,0 +1,94 @@
+package msgredis
+
+import (
+	"sync"
+	"testing"
+)
+
+// a server honoring CLIENT REPLY for its one client at a time
+func replyModeServer(t *testing.T) *fakeServer {
+	var mu sync.Mutex
+	values := map[string]string{}
+	off, skip := false, false
+	return newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		reply := "+OK\r\n"
+		switch args[0] {
+		case "CLIENT":
+			switch args[2] {
+			case "SKIP":
+				skip = true
+				return ""
+			case "OFF":
+				off = true
+				return ""
+			}
+			off = false
+		case "RESET":
+			off = false
+			reply = "+RESET\r\n"
+		case "SET":
+			values[args[1]] = args[2]
+		case "GET":
+			reply = bulk(values[args[1]])
+		}
+		if skip || off {
+			skip = false
+			return ""
+		}
+		return reply
+	})
+}
+
+func TestFireAndForget(t *testing.T) {
+	s := replyModeServer(t)
+	c := dialFake(t, s)
+	defer c.Close()
+	for _, v := range []string{"1", "2", "3"} {
+		if e := c.FireAndForget("SET", "a", v); e != nil {
+			t.Fatal(e)
+		}
+	}
+	// no reply was left to desynchronize the next one
+	if v, e := c.Call("GET", "a"); e != nil || string(v.([]byte)) != "3" {
+		t.Fatalf("get: %v %v", v, e)
+	}
+	if _, ok := c.FireAndForget("MULTI").(*FireAndForgetError); !ok {
+		t.Fatal("MULTI fired")
+	}
+	if _, e := c.Call("CLIENT", "REPLY", "SKIP"); e == nil {
+		t.Fatal("CLIENT REPLY SKIP sent as a regular command")
+	}
+
+	if e := c.SetReplyOff(true); e != nil {
+		t.Fatal(e)
+	}
+	c.FireAndForget("SET", "b", "x")
+	if _, e := c.Call("GET", "b"); e != ErrReplyOff {
+		t.Fatalf("call with replies off: %v", e)
+	}
+	if e := c.PipeSend("GET", "b"); e != ErrReplyOff {
+		t.Fatalf("pipeline with replies off: %v", e)
+	}
+	if e := c.SetReplyOff(false); e != nil {
+		t.Fatal(e)
+	}
+	if v, e := c.Call("GET", "b"); e != nil || string(v.([]byte)) != "x" {
+		t.Fatalf("get: %v %v", v, e)
+	}
+}
+
+func TestReplyOffPooled(t *testing.T) {
+	p := NewPool(replyModeServer(t).Addr(), "")
+	c := p.Pop()
+	c.SetReplyOff(true)
+	c.FireAndForget("SET", "k", "v")
+	p.Push(c)
+	// RESET turned the replies back on
+	c = p.Pop()
+	defer p.Push(c)
+	if v, e := c.Call("GET", "k"); e != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("get: %v %v", v, e)
+	}
+}
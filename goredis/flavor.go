@@ -0,0 +1,72 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// server implementations speaking RESP, see Capabilities.Flavor
+const (
+	FlavorRedis     = "redis"
+	FlavorValkey    = "valkey"
+	FlavorKeyDB     = "keydb"
+	FlavorDragonfly = "dragonfly"
+)
+
+// conns per server thread suggested by SuggestedConns
+const connsPerThread = 8
+
+// commands a flavor lacks whatever redis_version it reports
+var flavorMissing = map[string]map[string]bool{
+	FlavorDragonfly: {
+		"FUNCTION": true,
+		"FCALL":    true,
+		"FCALL_RO": true,
+		"MODULE":   true,
+		"WAITAOF":  true,
+	},
+}
+
+// detectFlavor tells the server implementation from INFO server. The
+// forks keep reporting a compatible redis_version, their own version is
+// in FlavorVersion.
+func (caps *Capabilities) detectFlavor(info map[string]string) {
+	caps.Flavor, caps.Threads = FlavorRedis, 1
+	switch {
+	case info["dragonfly_version"] != "":
+		caps.Flavor = FlavorDragonfly
+		caps.FlavorVersion = ParseVersion(strings.TrimPrefix(info["dragonfly_version"], "df-v"))
+		caps.Threads, _ = strconv.Atoi(info["thread_count"])
+	case info["valkey_version"] != "" || info["server_name"] == FlavorValkey:
+		caps.Flavor = FlavorValkey
+		caps.FlavorVersion = ParseVersion(info["valkey_version"])
+	case strings.Contains(info["executable"], "keydb") || info["server_threads"] != "":
+		caps.Flavor = FlavorKeyDB
+		caps.FlavorVersion = caps.Version
+		caps.Threads, _ = strconv.Atoi(info["server_threads"])
+	default:
+		caps.FlavorVersion = caps.Version
+	}
+	if caps.Threads < 1 {
+		caps.Threads = 1
+	}
+}
+
+// SuggestedConns is a pool size hint: multi-threaded servers (KeyDB,
+// Dragonfly) serve more conns in parallel than single-threaded redis
+func (caps *Capabilities) SuggestedConns() int {
+	n := connsPerThread * caps.Threads
+	if n > MaxConnNum {
+		n = MaxConnNum
+	}
+	return n
+}
+
+// Flavor returns the server implementation of the pool, detected once
+func (p *Pool) Flavor() (string, error) {
+	caps, e := p.Capabilities()
+	if e != nil {
+		return "", e
+	}
+	return caps.Flavor, nil
+}
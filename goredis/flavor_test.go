@@ -0,0 +1,48 @@
+package msgredis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func flavorHandler(info string) func([]string) string {
+	return func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			return "$" + strconv.Itoa(len(info)) + "\r\n" + info + "\r\n"
+		case "MODULE":
+			return "-ERR unexpected MODULE\r\n"
+		}
+		return "+OK\r\n"
+	}
+}
+
+func TestFlavorDetection(t *testing.T) {
+	df := newFakeServer(t, flavorHandler("# Server\r\nredis_version:7.2.0\r\ndragonfly_version:df-v1.14.1\r\nthread_count:4\r\n"))
+	p := NewPool(df.Addr(), "")
+	p.CheckCapabilities = true
+	c := p.Pop()
+	defer p.Push(c)
+	caps, e := p.Capabilities()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if caps.Flavor != FlavorDragonfly || caps.FlavorVersion != (Version{1, 14, 1}) || caps.Threads != 4 ||
+		caps.SuggestedConns() != 32 {
+		t.Fatalf("caps %+v", caps)
+	}
+	_, e = c.Call("FCALL", "f", 0)
+	if ue, ok := e.(*UnsupportedError); !ok || ue.Flavor != FlavorDragonfly {
+		t.Fatalf("got %v", e)
+	}
+
+	vk := newFakeServer(t, flavorHandler("# Server\r\nredis_version:7.2.4\r\nserver_name:valkey\r\nvalkey_version:8.0.1\r\n"))
+	if flavor, e := NewPool(vk.Addr(), "").Flavor(); e != nil || flavor != FlavorValkey {
+		t.Fatalf("got %q %v", flavor, e)
+	}
+	rd := newFakeServer(t, flavorHandler("# Server\r\nredis_version:7.2.4\r\n"))
+	caps, _ = NewPool(rd.Addr(), "").Capabilities()
+	if caps.Flavor != FlavorRedis || caps.Threads != 1 || caps.SuggestedConns() != connsPerThread {
+		t.Fatalf("caps %+v", caps)
+	}
+}
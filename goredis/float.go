@@ -0,0 +1,73 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+)
+
+var ErrBadFloatFormat = errors.New(CommonErrPrefix + "float format must be one of 'f', 'e', 'g'")
+
+// SetFloatFormat sets how float64 and float32 arguments are encoded, format
+// and prec are passed to strconv.FormatFloat. format 0 restores the default,
+// the shortest 'g' representation that parses back to the same value.
+// A fixed 'f' format avoids exponents some modules do not accept.
+func (c *Conn) SetFloatFormat(format byte, prec int) error {
+	switch format {
+	case 0:
+		prec = -1
+	case 'f', 'e', 'g':
+	default:
+		return ErrBadFloatFormat
+	}
+	c.floatFmt = format
+	c.floatPrec = prec
+	return nil
+}
+
+// RESP3 double, ,1.23 ,inf ,-inf ,nan
+func parseDouble(p []byte) (float64, error) {
+	f, e := strconv.ParseFloat(string(p), 64)
+	if e != nil {
+		return 0, errors.New(CommonErrPrefix + e.Error())
+	}
+	return f, nil
+}
+
+// a float reply may be a bulk string (RESP2) or a double (RESP3)
+func replyFloatBytes(v interface{}) []byte {
+	if f, ok := v.(float64); ok {
+		return strconv.AppendFloat(nil, f, 'g', -1, 64)
+	}
+	return v.([]byte)
+}
+
+/******************* float replies *******************/
+// ZSCOREF is ZSCORE decoded to float64
+func (c *Conn) ZSCOREF(key, member string) (float64, error) {
+	v, e := c.Call("ZSCORE", key, member)
+	if e != nil {
+		return 0, e
+	}
+	if v == nil {
+		return 0, ErrKeyNotExist
+	}
+	return replyFloat(v)
+}
+
+// INCRBYFLOATF is INCRBYFLOAT decoded to float64
+func (c *Conn) INCRBYFLOATF(key string, f float64) (float64, error) {
+	v, e := c.Call("INCRBYFLOAT", key, f)
+	if e != nil {
+		return 0, e
+	}
+	return replyFloat(v)
+}
+
+// HINCRBYFLOATF is HINCRBYFLOAT decoded to float64
+func (c *Conn) HINCRBYFLOATF(key string, field string, increment float64) (float64, error) {
+	v, e := c.Call("HINCRBYFLOAT", key, field, increment)
+	if e != nil {
+		return 0, e
+	}
+	return replyFloat(v)
+}
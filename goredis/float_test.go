@@ -0,0 +1,69 @@
+package msgredis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloatFormat(t *testing.T) {
+	var got string
+	s := newFakeServer(t, func(args []string) string {
+		got = args[2]
+		return ",1.5\r\n"
+	})
+	c := dialFake(t, s)
+
+	if _, e := c.INCRBYFLOATF("k", 1e21); e != nil {
+		t.Fatal(e)
+	}
+	if got != "1e+21" {
+		t.Fatalf("default format: %q", got)
+	}
+	if e := c.SetFloatFormat('f', 2); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := c.Call("INCRBYFLOAT", "k", 0.125); e != nil {
+		t.Fatal(e)
+	}
+	if got != "0.12" {
+		t.Fatalf("'f' 2: %q", got)
+	}
+	if e := c.SetFloatFormat('x', 2); e != ErrBadFloatFormat {
+		t.Fatalf("bad format accepted: %v", e)
+	}
+}
+
+func TestDoubleReply(t *testing.T) {
+	replies := []string{",3.25\r\n", ",inf\r\n", ",-inf\r\n", "$4\r\n2.75\r\n"}
+	i := 0
+	s := newFakeServer(t, func(args []string) string {
+		r := replies[i]
+		i++
+		return r
+	})
+	c := dialFake(t, s)
+
+	f, e := c.ZSCOREF("z", "m")
+	if e != nil || f != 3.25 {
+		t.Fatalf("double: %v %v", f, e)
+	}
+	if f, e = c.ZSCOREF("z", "m"); e != nil || !math.IsInf(f, 1) {
+		t.Fatalf("inf: %v %v", f, e)
+	}
+	if f, e = c.ZSCOREF("z", "m"); e != nil || !math.IsInf(f, -1) {
+		t.Fatalf("-inf: %v %v", f, e)
+	}
+	// RESP2 bulk string still works
+	if f, e = c.ZSCOREF("z", "m"); e != nil || f != 2.75 {
+		t.Fatalf("bulk: %v %v", f, e)
+	}
+}
+
+func TestDoubleReplyBytes(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string { return ",10.5\r\n" })
+	c := dialFake(t, s)
+	b, e := c.ZSCORE("z", "m")
+	if e != nil || string(b) != "10.5" {
+		t.Fatalf("%q %v", b, e)
+	}
+}
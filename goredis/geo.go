@@ -0,0 +1,241 @@
+package msgredis
+
+import (
+	"sort"
+	"sync"
+)
+
+// a member position, longitude first as in GEOADD
+type GeoPos struct {
+	Lon float64
+	Lat float64
+}
+
+/******************* geo commands *******************/
+func (c *Conn) GEOADD(key string, lon, lat float64, member string) (int64, error) {
+	n, e := c.Call("GEOADD", key, lon, lat, member)
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+// GEOPOS, nil for missing members
+func (c *Conn) GEOPOS(key string, members ...string) ([]*GeoPos, error) {
+	v, e := c.Call("GEOPOS", Args{key}.Add(members)...)
+	if e != nil {
+		return nil, e
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	ret := make([]*GeoPos, len(arr))
+	for i, x := range arr {
+		pos, ok := x.([]interface{})
+		if !ok || len(pos) != 2 {
+			// nil array of a missing member
+			continue
+		}
+		lon, e1 := replyFloat(pos[0])
+		lat, e2 := replyFloat(pos[1])
+		if e1 != nil || e2 != nil {
+			return nil, ErrBadType
+		}
+		ret[i] = &GeoPos{Lon: lon, Lat: lat}
+	}
+	return ret, nil
+}
+
+// GEODIST in meters, ErrKeyNotExist if a member is missing
+func (c *Conn) GEODIST(key, member1, member2 string) (float64, error) {
+	v, e := c.Call("GEODIST", key, member1, member2, "m")
+	if e != nil {
+		return 0, e
+	}
+	if v == nil {
+		return 0, ErrKeyNotExist
+	}
+	return replyFloat(v)
+}
+
+// GEOSEARCH key FROMLONLAT lon lat BYRADIUS radius m
+func (c *Conn) GEOSEARCHRADIUS(key string, lon, lat, radius float64) ([]string, error) {
+	v, e := c.Call("GEOSEARCH", key, "FROMLONLAT", lon, lat, "BYRADIUS", radius, "m")
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+// GEOSEARCH key FROMLONLAT lon lat BYBOX width height m
+func (c *Conn) GEOSEARCHBOX(key string, lon, lat, width, height float64) ([]string, error) {
+	v, e := c.Call("GEOSEARCH", key, "FROMLONLAT", lon, lat, "BYBOX", width, height, "m")
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+/******************* geo fencing *******************/
+// GeoFence is a circle if Radius > 0, else a Width x Height box, meters
+// around the center
+type GeoFence struct {
+	Name   string
+	Lon    float64
+	Lat    float64
+	Radius float64
+	Width  float64
+	Height float64
+}
+
+type FenceEvent struct {
+	Fence  string
+	Member string
+	// false on exit
+	Enter bool
+}
+
+// GeoTracker keeps member positions in a geo set and reports members
+// entering or leaving registered fences. Membership is evaluated by
+// Check, one GEOSEARCH per fence, and compared with the previous Check.
+type GeoTracker struct {
+	Key string
+	// called for every event found by Check, may be nil
+	OnEvent func(FenceEvent)
+
+	p      *Pool
+	mu     sync.Mutex
+	fences map[string]GeoFence
+	inside map[string]map[string]bool
+}
+
+func NewGeoTracker(p *Pool, key string, onEvent func(FenceEvent)) *GeoTracker {
+	return &GeoTracker{
+		Key:     key,
+		OnEvent: onEvent,
+		p:       p,
+		fences:  make(map[string]GeoFence),
+		inside:  make(map[string]map[string]bool),
+	}
+}
+
+// AddFence registers or replaces a fence, members already inside are
+// reported as entering on the next Check
+func (g *GeoTracker) AddFence(f GeoFence) {
+	g.mu.Lock()
+	g.fences[f.Name] = f
+	g.inside[f.Name] = map[string]bool{}
+	g.mu.Unlock()
+}
+
+// RemoveFence forgets a fence without exit events
+func (g *GeoTracker) RemoveFence(name string) {
+	g.mu.Lock()
+	delete(g.fences, name)
+	delete(g.inside, name)
+	g.mu.Unlock()
+}
+
+// Update moves member, events follow on the next Check
+func (g *GeoTracker) Update(member string, lon, lat float64) error {
+	c := g.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer g.p.Push(c)
+	_, e := c.GEOADD(g.Key, lon, lat, member)
+	return e
+}
+
+// Check searches every fence and returns the enter and exit events since
+// the previous Check, also passing them to OnEvent. A failed search leaves
+// every fence as it was, its events come with the next Check.
+func (g *GeoTracker) Check() ([]FenceEvent, error) {
+	events, e := g.check()
+	if e != nil {
+		return nil, e
+	}
+	// outside the lock, OnEvent may add or remove fences
+	if g.OnEvent != nil {
+		for _, ev := range events {
+			g.OnEvent(ev)
+		}
+	}
+	return events, nil
+}
+
+func (g *GeoTracker) check() ([]FenceEvent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.fences))
+	for name := range g.fences {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := g.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	var e error
+	for _, name := range names {
+		f := g.fences[name]
+		if f.Radius > 0 {
+			e = c.PipeSend("GEOSEARCH", g.Key, "FROMLONLAT", f.Lon, f.Lat, "BYRADIUS", f.Radius, "m")
+		} else {
+			e = c.PipeSend("GEOSEARCH", g.Key, "FROMLONLAT", f.Lon, f.Lat, "BYBOX", f.Width, f.Height, "m")
+		}
+		if e != nil {
+			break
+		}
+	}
+	// the searches queued are read even after a failed PipeSend
+	ret, pe := c.pipeExecAll()
+	g.p.Push(c)
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	// every fence is searched before any is updated
+	found := make([]map[string]bool, len(names))
+	for i := range names {
+		members, e := replyStrings(ret[i])
+		if e != nil {
+			return nil, e
+		}
+		found[i] = make(map[string]bool, len(members))
+		for _, m := range members {
+			found[i][m] = true
+		}
+	}
+
+	events := []FenceEvent{}
+	for i, name := range names {
+		entered := []string{}
+		for m := range found[i] {
+			if !g.inside[name][m] {
+				entered = append(entered, m)
+			}
+		}
+		sort.Strings(entered)
+		for _, m := range entered {
+			events = append(events, FenceEvent{Fence: name, Member: m, Enter: true})
+		}
+		left := []string{}
+		for m := range g.inside[name] {
+			if !found[i][m] {
+				left = append(left, m)
+			}
+		}
+		sort.Strings(left)
+		for _, m := range left {
+			events = append(events, FenceEvent{Fence: name, Member: m})
+		}
+		g.inside[name] = found[i]
+	}
+	return events, nil
+}
@@ -0,0 +1,114 @@
+package msgredis
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGeoTracker(t *testing.T) {
+	var mu sync.Mutex
+	pos := map[string][2]float64{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "GEOADD":
+			lon, _ := strconv.ParseFloat(args[2], 64)
+			lat, _ := strconv.ParseFloat(args[3], 64)
+			pos[args[4]] = [2]float64{lon, lat}
+			return ":1\r\n"
+		case "GEOSEARCH":
+			// flat earth is fine for a few hundred meters
+			lon, _ := strconv.ParseFloat(args[3], 64)
+			lat, _ := strconv.ParseFloat(args[4], 64)
+			r, _ := strconv.ParseFloat(args[6], 64)
+			members := []string{}
+			for m, p := range pos {
+				dx := (p[0] - lon) * 111320 * math.Cos(lat*math.Pi/180)
+				dy := (p[1] - lat) * 110540
+				if math.Hypot(dx, dy) <= r {
+					members = append(members, m)
+				}
+			}
+			return respArray(members...)
+		}
+		return "-ERR unknown\r\n"
+	})
+
+	var got []FenceEvent
+	g := NewGeoTracker(NewPool(s.Addr(), ""), "trucks", func(ev FenceEvent) { got = append(got, ev) })
+	g.AddFence(GeoFence{Name: "depot", Lon: 13.4, Lat: 52.5, Radius: 500})
+
+	g.Update("t1", 13.4, 52.5)
+	g.Update("t2", 13.5, 52.5)
+	if _, e := g.Check(); e != nil {
+		t.Fatal(e)
+	}
+	g.Update("t1", 13.5, 52.6)
+	g.Update("t2", 13.401, 52.5)
+	if _, e := g.Check(); e != nil {
+		t.Fatal(e)
+	}
+	want := []FenceEvent{
+		{Fence: "depot", Member: "t1", Enter: true},
+		{Fence: "depot", Member: "t2", Enter: true},
+		{Fence: "depot", Member: "t1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestGeoTrackerFailedCheck(t *testing.T) {
+	var mu sync.Mutex
+	fail := true
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if args[0] == "GEOSEARCH" && args[3] == "2" && fail {
+			return "-ERR search failed\r\n"
+		}
+		return respArray("t1")
+	})
+	var got []FenceEvent
+	var g *GeoTracker
+	g = NewGeoTracker(NewPool(s.Addr(), ""), "trucks", func(ev FenceEvent) {
+		got = append(got, ev)
+		// callbacks may change the fences
+		g.RemoveFence("b")
+	})
+	g.AddFence(GeoFence{Name: "a", Lon: 1, Lat: 1, Radius: 100})
+	g.AddFence(GeoFence{Name: "b", Lon: 2, Lat: 2, Radius: 100})
+	if _, e := g.Check(); e == nil || !isReplyError(e) {
+		t.Fatalf("got %v", e)
+	}
+	if len(got) != 0 {
+		t.Fatalf("events of a failed check %+v", got)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		_, e := g.Check()
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		if e != nil {
+			t.Fatal(e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnEvent deadlocked")
+	}
+	// the entry into a was not lost by the failed check
+	want := []FenceEvent{{Fence: "a", Member: "t1", Enter: true}, {Fence: "b", Member: "t1", Enter: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v", got)
+	}
+}
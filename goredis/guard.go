@@ -0,0 +1,62 @@
+package msgredis
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ConcurrentUseError is returned in concurrency check mode when a Conn is
+// used by a second goroutine while a call or an open pipeline owns it.
+type ConcurrentUseError struct {
+	Command   string
+	Goroutine int64
+	Owner     int64
+}
+
+func (e *ConcurrentUseError) Error() string {
+	return CommonErrPrefix + "concurrent use of Conn: " + e.Command +
+		" from goroutine " + strconv.FormatInt(e.Goroutine, 10) +
+		" while goroutine " + strconv.FormatInt(e.Owner, 10) + " owns it"
+}
+
+// SetConcurrencyCheck enables ownership tracking. It is meant for tests and
+// debugging: every call pays for a runtime.Stack to identify the goroutine.
+func (c *Conn) SetConcurrencyCheck(on bool) {
+	c.checkOwner = on
+}
+
+// goroutine id parsed from "goroutine 18 [running]:"
+func goid() int64 {
+	var buf [64]byte
+	s := string(buf[:runtime.Stack(buf[:], false)])
+	s = strings.TrimPrefix(s, "goroutine ")
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
+
+// enter takes ownership for the calling goroutine, the owner stays set
+// while a pipeline is open so PipeSend/PipeExec from others are refused
+func (c *Conn) enter(command string) error {
+	if !c.checkOwner {
+		return nil
+	}
+	id := goid()
+	if atomic.CompareAndSwapInt64(&c.owner, 0, id) {
+		return nil
+	}
+	if owner := atomic.LoadInt64(&c.owner); owner != id {
+		return &ConcurrentUseError{Command: command, Goroutine: id, Owner: owner}
+	}
+	return nil
+}
+
+func (c *Conn) leave() {
+	if c.checkOwner && c.pipeCount == 0 {
+		atomic.StoreInt64(&c.owner, 0)
+	}
+}
@@ -0,0 +1,38 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestConcurrencyCheck(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	c.SetConcurrencyCheck(true)
+
+	if _, e := c.Call("PING"); e != nil {
+		t.Fatal(e)
+	}
+	if e := c.PipeSend("SET", "a", "1"); e != nil {
+		t.Fatal(e)
+	}
+
+	// another goroutine while the pipeline is open
+	done := make(chan error)
+	go func() {
+		_, e := c.Call("GET", "a")
+		done <- e
+	}()
+	if _, ok := (<-done).(*ConcurrentUseError); !ok {
+		t.Fatal("concurrent Call not detected")
+	}
+
+	if _, e := c.PipeExec(); e != nil {
+		t.Fatal(e)
+	}
+	go func() {
+		_, e := c.Call("GET", "a")
+		done <- e
+	}()
+	if e := <-done; e != nil {
+		t.Fatalf("Call after PipeExec from another goroutine: %v", e)
+	}
+}
@@ -0,0 +1,94 @@
+package msgredis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// PING timeout when the context has no deadline
+	DefaultCheckTimeout = time.Second
+	// share of MaxConnNum checked out before the pool counts as saturated
+	DefaultMaxSaturation = 0.9
+)
+
+var ErrPoolSaturated = errors.New(CommonErrPrefix + "pool saturated")
+
+// Checker is a readiness/liveness probe for a pool: Check fails when the
+// pool is saturated, when PING fails or when it takes longer than the
+// context allows. It never waits for a free conn, a probe stuck behind a
+// full pool would time out anyway.
+type Checker struct {
+	Pool    *Pool
+	Timeout time.Duration
+	// Actives/MaxConnNum above this fails the check, 0 uses the default
+	MaxSaturation float64
+	// commands in flight above this fail the check, 0 for no limit
+	MaxInFlight int64
+	// consecutive failed checks, reset by a successful one
+	failures int64
+}
+
+func NewChecker(p *Pool) *Checker {
+	return &Checker{Pool: p, Timeout: DefaultCheckTimeout, MaxSaturation: DefaultMaxSaturation}
+}
+
+// Check returns nil when the pool can serve commands
+func (ch *Checker) Check(ctx context.Context) error {
+	e := ch.check(ctx)
+	if e != nil {
+		atomic.AddInt64(&ch.failures, 1)
+		return e
+	}
+	atomic.StoreInt64(&ch.failures, 0)
+	return nil
+}
+
+// Failures counts the checks failed in a row, e.g. for liveness probes
+// that should only restart after several
+func (ch *Checker) Failures() int64 {
+	return atomic.LoadInt64(&ch.failures)
+}
+
+func (ch *Checker) check(ctx context.Context) error {
+	max := ch.MaxSaturation
+	if max <= 0 {
+		max = DefaultMaxSaturation
+	}
+	p := ch.Pool
+	if float64(p.Actives()) >= max*MaxConnNum && p.Idles() == 0 {
+		return ErrPoolSaturated
+	}
+	if ch.MaxInFlight > 0 && p.InFlight() > ch.MaxInFlight {
+		return ErrPoolSaturated
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := ch.Timeout
+		if timeout <= 0 {
+			timeout = DefaultCheckTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		c := p.Pop()
+		if c == nil {
+			done <- ErrNoConn
+			return
+		}
+		// a late reply still returns the conn
+		defer p.Push(c)
+		_, e := c.Call("PING")
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		return e
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
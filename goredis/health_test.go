@@ -0,0 +1,35 @@
+package msgredis
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	var stall int32
+	s := newFakeServer(t, func(args []string) string {
+		if atomic.LoadInt32(&stall) == 1 {
+			return ""
+		}
+		return "+PONG\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	ch := NewChecker(p)
+	if e := ch.Check(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+
+	atomic.StoreInt32(&stall, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if e := ch.Check(ctx); e != context.DeadlineExceeded || ch.Failures() != 1 {
+		t.Fatalf("got %v, %d failures", e, ch.Failures())
+	}
+
+	atomic.StoreInt64(&p.ActiveNum, MaxConnNum)
+	if e := ch.Check(context.Background()); e != ErrPoolSaturated || ch.Failures() != 2 {
+		t.Fatalf("got %v", e)
+	}
+}
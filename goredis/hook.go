@@ -0,0 +1,98 @@
+package msgredis
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Hook observes every command sent through a Conn. Before is called right
+// before the request is written and After once its reply has been read.
+// Both receive the same *HookEvent, so the ID pairs the two calls.
+type Hook interface {
+	Before(ev *HookEvent)
+	After(ev *HookEvent)
+}
+
+type HookEvent struct {
+	// unique and monotonically increasing within the process
+	ID uint64
+	// pipelined commands share a non zero BatchID, Index is the position in the batch
+	BatchID uint64
+	Index   int
+
+	Addr    string
+	Command string
+	Args    []interface{}
+	Start   time.Time
+	// commands of the pool (or the lone conn) waiting for a reply when
+	// this one was issued
+	InFlight int64
+
+	// set before After is called
+	Reply    interface{}
+	Err      error
+	Duration time.Duration
+
+	redactor *Redactor
+}
+
+var (
+	cmdSeq   uint64
+	batchSeq uint64
+)
+
+func nextCmdID() uint64 {
+	return atomic.AddUint64(&cmdSeq, 1)
+}
+
+func nextBatchID() uint64 {
+	return atomic.AddUint64(&batchSeq, 1)
+}
+
+func (c *Conn) AddHook(h Hook) {
+	c.hooks = append(c.hooks, h)
+}
+
+func (c *Conn) newEvent(command string, args []interface{}) *HookEvent {
+	return &HookEvent{
+		ID:      nextCmdID(),
+		Addr:    c.addr,
+		Command: command,
+		Args:    args,
+		Start:   time.Now(),
+
+		InFlight: c.inflightNow(),
+		redactor: c.redactor,
+	}
+}
+
+func (c *Conn) before(ev *HookEvent) {
+	for _, h := range c.hooks {
+		h.Before(ev)
+	}
+}
+
+func (c *Conn) after(ev *HookEvent) {
+	ev.Duration = time.Since(ev.Start)
+	for _, h := range c.hooks {
+		h.After(ev)
+	}
+	if c.trace != nil {
+		c.trace.record(ev)
+	}
+}
+
+// SlowLogHook prints commands slower than Threshold with redacted arguments.
+type SlowLogHook struct {
+	Threshold time.Duration
+}
+
+func (h *SlowLogHook) Before(ev *HookEvent) {}
+
+func (h *SlowLogHook) After(ev *HookEvent) {
+	if ev.Duration < h.Threshold {
+		return
+	}
+	fmt.Println("[SlowLog]", ev.ID, ev.Addr, ev.Duration.String(), ev.Command, ev.SafeArgs())
+}
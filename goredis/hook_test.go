@@ -0,0 +1,60 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+type recordHook struct {
+	before []HookEvent
+	after  []HookEvent
+}
+
+func (h *recordHook) Before(ev *HookEvent) { h.before = append(h.before, *ev) }
+func (h *recordHook) After(ev *HookEvent)  { h.after = append(h.after, *ev) }
+
+func TestHookIDs(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	h := &recordHook{}
+	c.AddHook(h)
+
+	c.Call("SET", "a", "1")
+	c.PipeSend("SET", "b", "2")
+	c.PipeSend("SET", "c", "3")
+	if _, e := c.PipeExec(); e != nil {
+		t.Fatal(e)
+	}
+
+	if len(h.before) != 3 || len(h.after) != 3 {
+		t.Fatalf("got %d before and %d after events", len(h.before), len(h.after))
+	}
+	for i := range h.before {
+		if h.before[i].ID != h.after[i].ID {
+			t.Errorf("event %d: before ID %d != after ID %d", i, h.before[i].ID, h.after[i].ID)
+		}
+		if i > 0 && h.before[i].ID <= h.before[i-1].ID {
+			t.Errorf("event %d: ID %d not increasing", i, h.before[i].ID)
+		}
+	}
+	if h.after[0].BatchID != 0 {
+		t.Errorf("plain call has BatchID %d", h.after[0].BatchID)
+	}
+	if h.after[1].BatchID == 0 || h.after[1].BatchID != h.after[2].BatchID {
+		t.Errorf("pipeline batch IDs %d, %d", h.after[1].BatchID, h.after[2].BatchID)
+	}
+	if h.after[1].Index != 0 || h.after[2].Index != 1 {
+		t.Errorf("pipeline indexes %d, %d", h.after[1].Index, h.after[2].Index)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	args := []interface{}{"user:1", "secret-password", 42}
+	got := DefaultRedactor.Redact(args)
+	if got[0] != "user:1" || got[1] != "***" || got[2] != "***" {
+		t.Errorf("DefaultRedactor: %v", got)
+	}
+	r := &Redactor{MaxLen: 4}
+	got = r.Redact(args)
+	if got[0] != "user...(6 bytes)" || got[2] != "42" {
+		t.Errorf("truncating Redactor: %v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package msgredis
+
+import (
+	"strconv"
+)
+
+// IndexSet keeps a record hash, key Prefix+id, together with its secondary
+// indexes: a sorted set per score field, Prefix+"score:"+field, and a set
+// of ids per value of a tag field, Prefix+"tag:"+field+":"+value. Records
+// and indexes change in one Lua script, so readers never see a record
+// without its index entries. Index keys are derived inside the script; in
+// a cluster Prefix must carry a hash tag, e.g. "{user}:".
+type IndexSet struct {
+	Prefix      string
+	ScoreFields []string
+	TagFields   []string
+}
+
+// KEYS[1] record, ARGV prefix, id, #scores, #tags, score fields, tag fields,
+// then the field value pairs of the record (none to remove it)
+var indexSaveScript = NewScript(`
+local prefix, id = ARGV[1], ARGV[2]
+local ns, nt = tonumber(ARGV[3]), tonumber(ARGV[4])
+local scores, tags = {}, {}
+for j = 1, ns do scores[j] = ARGV[4 + j] end
+for j = 1, nt do tags[j] = ARGV[4 + ns + j] end
+for _, f in ipairs(tags) do
+	local old = redis.call('HGET', KEYS[1], f)
+	if old then redis.call('SREM', prefix .. 'tag:' .. f .. ':' .. old, id) end
+end
+redis.call('DEL', KEYS[1])
+if #ARGV > 4 + ns + nt then
+	redis.call('HSET', KEYS[1], unpack(ARGV, 5 + ns + nt))
+end
+for _, f in ipairs(scores) do
+	local v = redis.call('HGET', KEYS[1], f)
+	if v then
+		redis.call('ZADD', prefix .. 'score:' .. f, v, id)
+	else
+		redis.call('ZREM', prefix .. 'score:' .. f, id)
+	end
+end
+for _, f in ipairs(tags) do
+	local v = redis.call('HGET', KEYS[1], f)
+	if v then redis.call('SADD', prefix .. 'tag:' .. f .. ':' .. v, id) end
+end
+return 1
+`)
+
+func (ix *IndexSet) run(c *Conn, id string, fields map[string]string) error {
+	args := Args{ix.Prefix, id, len(ix.ScoreFields), len(ix.TagFields)}.
+		Add(ix.ScoreFields, ix.TagFields).
+		AddFlat(fields)
+	_, e := c.RunScript(indexSaveScript, []string{ix.Prefix + id}, args...)
+	return e
+}
+
+// Save replaces the record and updates its index entries atomically, a
+// score field must hold a number
+func (ix *IndexSet) Save(c *Conn, id string, fields map[string]string) error {
+	if len(fields) == 0 {
+		return ErrBadArgs
+	}
+	return ix.run(c, id, fields)
+}
+
+// Remove deletes the record and its index entries
+func (ix *IndexSet) Remove(c *Conn, id string) error {
+	return ix.run(c, id, nil)
+}
+
+// RangeByScore pages through the ids with min <= field <= max, min and max
+// take the ZRANGEBYSCORE syntax ("-inf", "(10")
+func (ix *IndexSet) RangeByScore(c *Conn, field, min, max string, offset, count int) ([]string, error) {
+	v, e := c.Call("ZRANGEBYSCORE", ix.Prefix+"score:"+field, min, max, "LIMIT", offset, count)
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+// ByTag pages through the ids whose field is tag with SSCAN, start with
+// cursor 0 and stop when the returned cursor is 0
+func (ix *IndexSet) ByTag(c *Conn, field, tag string, cursor, count int) ([]string, int, error) {
+	v, e := c.Call("SSCAN", ix.Prefix+"tag:"+field+":"+tag, cursor, "COUNT", count)
+	if e != nil {
+		return nil, 0, e
+	}
+	r, ok := v.([]interface{})
+	if !ok || len(r) != 2 {
+		return nil, 0, ErrBadType
+	}
+	next, e := replyString(r[0])
+	if e != nil {
+		return nil, 0, e
+	}
+	ids, e := replyStrings(r[1])
+	if e != nil {
+		return nil, 0, e
+	}
+	n, _ := strconv.Atoi(next)
+	return ids, n, nil
+}
+
+// Load returns the records of ids in order, nil for vanished ones
+func (ix *IndexSet) Load(c *Conn, ids []string) ([]map[string]string, error) {
+	for _, id := range ids {
+		c.PipeSend("HGETALL", ix.Prefix+id)
+	}
+	replies, e := c.PipeExec()
+	if e != nil {
+		return nil, e
+	}
+	records := make([]map[string]string, len(ids))
+	for i, reply := range replies {
+		fields, e := replyStringMap(reply)
+		if e != nil {
+			return nil, e
+		}
+		if len(fields) > 0 {
+			records[i] = fields
+		}
+	}
+	return records, nil
+}
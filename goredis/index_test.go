@@ -0,0 +1,31 @@
+package msgredis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexSetSave(t *testing.T) {
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "EVALSHA" {
+			return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+		}
+		got = args
+		return ":1\r\n"
+	})
+	c := dialFake(t, s)
+
+	ix := &IndexSet{Prefix: "{user}:", ScoreFields: []string{"age"}, TagFields: []string{"city"}}
+	if e := ix.Save(c, "42", map[string]string{"name": "ann", "city": "oslo", "age": "31"}); e != nil {
+		t.Fatal(e)
+	}
+	want := []string{"EVAL", indexSaveScript.Src, "1", "{user}:42",
+		"{user}:", "42", "1", "1", "age", "city", "age", "31", "city", "oslo", "name", "ann"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q", got[2:])
+	}
+	if e := ix.Save(c, "42", nil); e != ErrBadArgs {
+		t.Fatalf("empty save: %v", e)
+	}
+}
@@ -0,0 +1,51 @@
+package msgredis
+
+import (
+	"sync/atomic"
+)
+
+// addInflight counts commands written and waiting for their reply
+func (c *Conn) addInflight(n int) {
+	atomic.AddInt32(&c.inflight, int32(n))
+	if c.pool != nil {
+		atomic.AddInt64(&c.pool.inflight, int64(n))
+	}
+}
+
+// addQueued counts commands queued by PipeSend and not executed yet
+func (c *Conn) addQueued(n int) {
+	atomic.AddInt32(&c.queued, int32(n))
+	if c.pool != nil {
+		atomic.AddInt64(&c.pool.queued, int64(n))
+	}
+}
+
+// InFlight is the number of commands sent on the conn whose reply was not
+// read yet. It is safe to call from other goroutines.
+func (c *Conn) InFlight() int {
+	return int(atomic.LoadInt32(&c.inflight))
+}
+
+// Queued is the pipeline depth: commands queued by PipeSend before PipeExec
+func (c *Conn) Queued() int {
+	return int(atomic.LoadInt32(&c.queued))
+}
+
+// InFlight is the number of commands of all pool conns waiting for their
+// reply, a backpressure signal to shed load before the pool saturates
+func (p *Pool) InFlight() int64 {
+	return atomic.LoadInt64(&p.inflight)
+}
+
+// Queued is the total pipeline depth of the pool conns
+func (p *Pool) Queued() int64 {
+	return atomic.LoadInt64(&p.queued)
+}
+
+// in flight commands seen by a hook, of the pool or of a lone conn
+func (c *Conn) inflightNow() int64 {
+	if c.pool != nil {
+		return c.pool.InFlight()
+	}
+	return int64(c.InFlight())
+}
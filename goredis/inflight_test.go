@@ -0,0 +1,60 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+type inflightHook struct {
+	seen []int64
+}
+
+func (h *inflightHook) Before(ev *HookEvent) { h.seen = append(h.seen, ev.InFlight) }
+func (h *inflightHook) After(ev *HookEvent)  {}
+
+func TestInFlight(t *testing.T) {
+	release := make(chan struct{})
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "SLOW" {
+			<-release
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	h := &inflightHook{}
+	p.Hooks = []Hook{h}
+
+	slow := p.Pop()
+	done := make(chan struct{})
+	go func() {
+		slow.Call("SLOW")
+		close(done)
+	}()
+	for p.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if slow.InFlight() != 1 {
+		t.Fatalf("conn in flight %d", slow.InFlight())
+	}
+
+	c := p.Pop()
+	c.PipeSend("SET", "a", 1)
+	c.PipeSend("SET", "b", 2)
+	if c.Queued() != 2 || p.Stats().Queued != 2 {
+		t.Fatalf("queued %d %d", c.Queued(), p.Stats().Queued)
+	}
+	if _, e := c.PipeExec(); e != nil {
+		t.Fatal(e)
+	}
+	p.Push(c)
+	if h.seen[1] != 1 || p.Stats().InFlight != 1 || p.Queued() != 0 {
+		t.Fatalf("seen %v stats %+v", h.seen, p.Stats())
+	}
+
+	close(release)
+	<-done
+	p.Push(slow)
+	if p.InFlight() != 0 {
+		t.Fatalf("in flight %d", p.InFlight())
+	}
+}
@@ -0,0 +1,81 @@
+package msgredis
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// DefaultXFetchBeta > 1 favors earlier recomputation, < 1 later
+const DefaultXFetchBeta = 1.0
+
+// Jitter returns ttl randomized within ±percent, e.g. Jitter(time.Hour, 10)
+// is between 54 and 66 minutes, so keys written together do not expire
+// together. The result is never below one millisecond.
+func Jitter(ttl time.Duration, percent float64) time.Duration {
+	if percent <= 0 {
+		return ttl
+	}
+	band := float64(ttl) * percent / 100
+	d := ttl + time.Duration((rand.Float64()*2-1)*band)
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d
+}
+
+// SET key value PX <ttl ± percent>
+func (c *Conn) SetWithJitteredTTL(key string, value interface{}, ttl time.Duration, percent float64) error {
+	return c.okCall("SET", key, value, "PX", Jitter(ttl, percent))
+}
+
+// PEXPIRE key <ttl ± percent>, false if the key does not exist
+func (c *Conn) TouchWithJitter(key string, ttl time.Duration, percent float64) (bool, error) {
+	n, e := c.Call("PEXPIRE", key, Jitter(ttl, percent))
+	if e != nil {
+		return false, e
+	}
+	return n.(int64) == 1, nil
+}
+
+// XFetch reads key, a hash of the cached value and the time its last
+// computation took, and recomputes it before it expires with a probability
+// growing as the expiry approaches (probabilistic early expiration, Vattani
+// et al.). Only a few callers recompute a hot key early instead of all of
+// them at once when it expires. recompute returns the new value and its ttl.
+func (c *Conn) XFetch(key string, beta float64, recompute func() (string, time.Duration, error)) (string, error) {
+	if beta <= 0 {
+		beta = DefaultXFetchBeta
+	}
+	c.PipeSend("HMGET", key, "value", "delta")
+	c.PipeSend("PTTL", key)
+	ret, e := c.PipeExec()
+	if e != nil {
+		return "", e
+	}
+	if fields, ok := ret[0].([]interface{}); ok && len(fields) == 2 && fields[0] != nil {
+		value, _ := replyString(fields[0])
+		delta, _ := replyString(fields[1])
+		ms, _ := strconv.ParseFloat(delta, 64)
+		pttl, _ := ret[1].(int64)
+		// -delta * beta * ln(rand) has a long tail, rand in (0, 1]
+		early := -ms * beta * math.Log(1-rand.Float64())
+		if pttl < 0 || early < float64(pttl) {
+			return value, nil
+		}
+	}
+
+	start := time.Now()
+	value, ttl, e := recompute()
+	if e != nil {
+		return "", e
+	}
+	delta := strconv.FormatInt(int64(time.Since(start)/time.Millisecond), 10)
+	c.PipeSend("HSET", key, "value", value, "delta", delta)
+	c.PipeSend("PEXPIRE", key, ttl)
+	if _, e = c.PipeExec(); e != nil {
+		return "", e
+	}
+	return value, nil
+}
@@ -0,0 +1,55 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		d := Jitter(time.Hour, 10)
+		if d < 54*time.Minute || d > 66*time.Minute {
+			t.Fatalf("out of band: %v", d)
+		}
+	}
+	if d := Jitter(time.Second, 0); d != time.Second {
+		t.Fatalf("no jitter: %v", d)
+	}
+}
+
+func TestXFetch(t *testing.T) {
+	hash := map[string]string{}
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HMGET":
+			if len(hash) == 0 {
+				return "*2\r\n$-1\r\n$-1\r\n"
+			}
+			return respArray(hash["value"], hash["delta"])
+		case "PTTL":
+			return ":3600000\r\n"
+		case "HSET":
+			hash[args[2]] = args[3]
+			hash[args[4]] = args[5]
+			return ":2\r\n"
+		}
+		return ":1\r\n"
+	})
+	c := dialFake(t, s)
+
+	calls := 0
+	recompute := func() (string, time.Duration, error) {
+		calls++
+		return "v", time.Hour, nil
+	}
+	for i := 0; i < 3; i++ {
+		v, e := c.XFetch("k", 0, recompute)
+		if e != nil || v != "v" {
+			t.Fatalf("%q %v", v, e)
+		}
+	}
+	// an hour left and a recompute of ~0ms is never early
+	if calls != 1 {
+		t.Fatalf("recomputed %d times", calls)
+	}
+}
@@ -0,0 +1,116 @@
+package msgredis
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+const (
+	// values below histSub microseconds get one bucket each, above that
+	// every power of two is split into histSub buckets (~6% error)
+	histSub     = 16
+	histBuckets = histSub + 48*histSub
+)
+
+// LatencyHistogram is a streaming HDR style histogram of durations with
+// microsecond resolution, safe for concurrent use.
+type LatencyHistogram struct {
+	mu     sync.Mutex
+	counts [histBuckets]uint64
+	total  uint64
+	max    time.Duration
+}
+
+func histIndex(us uint64) int {
+	if us < histSub {
+		return int(us)
+	}
+	shift := uint(bits.Len64(us) - 5)
+	i := histSub + int(shift)*histSub + int(us>>shift) - histSub
+	if i >= histBuckets {
+		i = histBuckets - 1
+	}
+	return i
+}
+
+// upper bound of bucket i in microseconds
+func histValue(i int) uint64 {
+	if i < histSub {
+		return uint64(i)
+	}
+	shift := uint((i - histSub) / histSub)
+	sub := uint64((i-histSub)%histSub + histSub)
+	return (sub+1)<<shift - 1
+}
+
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := histIndex(uint64(d / time.Microsecond))
+	h.mu.Lock()
+	h.counts[i]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+	h.mu.Unlock()
+}
+
+// Quantile returns the latency below which q (0..1) of the samples fall.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantile(q)
+}
+
+func (h *LatencyHistogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			d := time.Duration(histValue(i)) * time.Microsecond
+			if d > h.max {
+				d = h.max
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+type LatencySnapshot struct {
+	Count uint64
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (h *LatencyHistogram) Snapshot() LatencySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return LatencySnapshot{
+		Count: h.total,
+		P50:   h.quantile(0.50),
+		P95:   h.quantile(0.95),
+		P99:   h.quantile(0.99),
+		Max:   h.max,
+	}
+}
+
+func (h *LatencyHistogram) Reset() {
+	h.mu.Lock()
+	h.counts = [histBuckets]uint64{}
+	h.total = 0
+	h.max = 0
+	h.mu.Unlock()
+}
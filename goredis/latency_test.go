@@ -0,0 +1,30 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := &LatencyHistogram{}
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	s := h.Snapshot()
+	if s.Count != 1000 || s.Max != time.Second {
+		t.Fatalf("count=%d max=%v", s.Count, s.Max)
+	}
+	within := func(name string, got, want time.Duration) {
+		if got < want*94/100 || got > want*106/100 {
+			t.Errorf("%s=%v, want ~%v", name, got, want)
+		}
+	}
+	within("p50", s.P50, 500*time.Millisecond)
+	within("p95", s.P95, 950*time.Millisecond)
+	within("p99", s.P99, 990*time.Millisecond)
+
+	h.Reset()
+	if h.Quantile(0.5) != 0 {
+		t.Error("Reset did not clear the histogram")
+	}
+}
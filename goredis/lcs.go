@@ -0,0 +1,122 @@
+package msgredis
+
+// inclusive offsets of a match in one of the strings
+type LCSRange struct {
+	Start int64
+	End   int64
+}
+
+type LCSMatch struct {
+	Key1 LCSRange
+	Key2 LCSRange
+	// only set with WITHMATCHLEN
+	Len int64
+}
+
+type LCSResult struct {
+	// longest match first, as returned by the server
+	Matches []LCSMatch
+	Len     int64
+}
+
+/******************* lcs commands *******************/
+// longest common subsequence of two string keys
+func (c *Conn) LCS(key1, key2 string) ([]byte, error) {
+	v, e := c.Call("LCS", key1, key2)
+	if e != nil {
+		return nil, e
+	}
+	return v.([]byte), nil
+}
+
+func (c *Conn) LCSLEN(key1, key2 string) (int64, error) {
+	n, e := c.Call("LCS", key1, key2, "LEN")
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+// LCSIDX returns the match ranges, minMatchLen 0 keeps every match
+func (c *Conn) LCSIDX(key1, key2 string, minMatchLen int, withMatchLen bool) (*LCSResult, error) {
+	args := []interface{}{key1, key2, "IDX"}
+	if minMatchLen > 0 {
+		args = append(args, "MINMATCHLEN", minMatchLen)
+	}
+	if withMatchLen {
+		args = append(args, "WITHMATCHLEN")
+	}
+	v, e := c.Call("LCS", args...)
+	if e != nil {
+		return nil, e
+	}
+	return parseLCS(v)
+}
+
+// ["matches", [[[s1, e1], [s2, e2], len], ...], "len", n], a map in RESP3
+func parseLCS(v interface{}) (*LCSResult, error) {
+	fields, ok := v.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, ErrBadType
+	}
+	r := &LCSResult{}
+	for i := 0; i < len(fields); i += 2 {
+		name, ok := fields[i].([]byte)
+		if !ok {
+			return nil, ErrBadType
+		}
+		switch string(name) {
+		case "len":
+			if r.Len, ok = fields[i+1].(int64); !ok {
+				return nil, ErrBadType
+			}
+		case "matches":
+			matches, ok := fields[i+1].([]interface{})
+			if !ok {
+				return nil, ErrBadType
+			}
+			for _, m := range matches {
+				match, e := parseLCSMatch(m)
+				if e != nil {
+					return nil, e
+				}
+				r.Matches = append(r.Matches, match)
+			}
+		}
+	}
+	return r, nil
+}
+
+func parseLCSMatch(v interface{}) (LCSMatch, error) {
+	var m LCSMatch
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 2 {
+		return m, ErrBadType
+	}
+	var e error
+	if m.Key1, e = parseLCSRange(arr[0]); e != nil {
+		return m, e
+	}
+	if m.Key2, e = parseLCSRange(arr[1]); e != nil {
+		return m, e
+	}
+	if len(arr) > 2 {
+		if m.Len, ok = arr[2].(int64); !ok {
+			return m, ErrBadType
+		}
+	}
+	return m, nil
+}
+
+func parseLCSRange(v interface{}) (LCSRange, error) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 2 {
+		return LCSRange{}, ErrBadType
+	}
+	start, ok1 := arr[0].(int64)
+	end, ok2 := arr[1].(int64)
+	if !ok1 || !ok2 {
+		return LCSRange{}, ErrBadType
+	}
+	return LCSRange{Start: start, End: end}, nil
+}
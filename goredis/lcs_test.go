@@ -0,0 +1,32 @@
+package msgredis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLCSIDX(t *testing.T) {
+	var got string
+	s := newFakeServer(t, func(args []string) string {
+		got = strings.Join(args, " ")
+		// LCS key1 key2 IDX MINMATCHLEN 4 WITHMATCHLEN for ohmytext / mynewtext
+		return "*4\r\n$7\r\nmatches\r\n*1\r\n*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n$3\r\nlen\r\n:6\r\n"
+	})
+	c := dialFake(t, s)
+
+	r, e := c.LCSIDX("key1", "key2", 4, true)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got != "LCS key1 key2 IDX MINMATCHLEN 4 WITHMATCHLEN" {
+		t.Fatalf("request: %q", got)
+	}
+	want := &LCSResult{
+		Matches: []LCSMatch{{Key1: LCSRange{4, 7}, Key2: LCSRange{5, 8}, Len: 4}},
+		Len:     6,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("got %+v, want %+v", r, want)
+	}
+}
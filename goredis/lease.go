@@ -0,0 +1,140 @@
+package msgredis
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	ErrConnNotOwned  = errors.New(CommonErrPrefix + "conn was pushed back to the pool")
+	ErrLeaseReleased = errors.New(CommonErrPrefix + "lease already released")
+	ErrNotLeaseable  = errors.New(CommonErrPrefix + "command not allowed on a lease")
+	ErrNoConn        = errors.New("no conn available")
+)
+
+// commands a Lease accepts outside of MULTI
+var leaseCommands = map[string]bool{
+	"WATCH": true, "UNWATCH": true, "MULTI": true, "EXEC": true, "DISCARD": true,
+	"BLPOP": true, "BRPOP": true, "BRPOPLPUSH": true, "BLMOVE": true, "BLMPOP": true,
+	"BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true, "XREAD": true, "XREADGROUP": true,
+	"WAIT": true, "SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+}
+
+// Lease is an exclusive conn for transactions, blocking commands and
+// subscriptions. Nothing else uses the conn until Release, and Release
+// leaves it clean: an open MULTI is discarded and WATCHed keys unwatched.
+type Lease struct {
+	c        *Conn
+	p        *Pool
+	inMulti  bool
+	watching bool
+	released bool
+}
+
+func (p *Pool) AcquireExclusive() (*Lease, error) {
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	return &Lease{c: c, p: p}, nil
+}
+
+// Call accepts the commands of leaseCommands, and anything once MULTI is open
+func (l *Lease) Call(command string, args ...interface{}) (interface{}, error) {
+	if l.released {
+		return nil, ErrLeaseReleased
+	}
+	name := strings.ToUpper(command)
+	if !l.inMulti && !leaseCommands[name] {
+		return nil, ErrNotLeaseable
+	}
+	ret, e := l.c.Call(command, args...)
+	switch name {
+	case "MULTI":
+		l.inMulti = l.inMulti || e == nil
+	case "EXEC", "DISCARD":
+		l.inMulti = false
+		l.watching = false
+	case "WATCH":
+		l.watching = l.watching || e == nil
+	case "UNWATCH":
+		l.watching = false
+	}
+	return ret, e
+}
+
+func (l *Lease) Watch(keys ...string) error {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	_, e := l.Call("WATCH", args...)
+	return e
+}
+
+func (l *Lease) MULTI() error {
+	_, e := l.Call("MULTI")
+	return e
+}
+
+func (l *Lease) TransSend(command string, args ...interface{}) error {
+	if !l.inMulti {
+		return ErrNotLeaseable
+	}
+	_, e := l.Call(command, args...)
+	return e
+}
+
+// nil reply (aborted by WATCH) is reported as ErrNil
+func (l *Lease) TransExec() ([]interface{}, error) {
+	ret, e := l.Call("EXEC")
+	if e != nil {
+		return nil, e
+	}
+	// *-1 decodes to a nil []interface{}, not a nil interface
+	arr, _ := ret.([]interface{})
+	if arr == nil {
+		return nil, ErrNil
+	}
+	return arr, nil
+}
+
+func (l *Lease) Discard() error {
+	_, e := l.Call("DISCARD")
+	return e
+}
+
+// Release returns the conn to the pool. A conn left in an unknown state is
+// closed instead.
+func (l *Lease) Release() {
+	if l.released {
+		return
+	}
+	l.released = true
+	var e error
+	if l.inMulti {
+		_, e = l.c.Call("DISCARD")
+	} else if l.watching {
+		_, e = l.c.Call("UNWATCH")
+	}
+	if e != nil {
+		l.c.Close()
+		atomic.AddInt64(&l.p.ActiveNum, -1)
+		return
+	}
+	l.p.Push(l.c)
+}
+
+// Conn.idle is set while the conn sits in a pool
+func (c *Conn) setIdle(idle bool) {
+	var v int32
+	if idle {
+		v = 1
+	}
+	atomic.StoreInt32(&c.idle, v)
+}
+
+func (c *Conn) isIdle() bool {
+	return atomic.LoadInt32(&c.idle) == 1
+}
@@ -0,0 +1,52 @@
+package msgredis
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLease(t *testing.T) {
+	var mu sync.Mutex
+	var discards int
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SET":
+			return "+QUEUED\r\n"
+		case "DISCARD":
+			mu.Lock()
+			discards++
+			mu.Unlock()
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+
+	l, e := p.AcquireExclusive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = l.Call("GET", "a"); e != ErrNotLeaseable {
+		t.Errorf("GET on lease err=%v", e)
+	}
+	if e = l.MULTI(); e != nil {
+		t.Fatal(e)
+	}
+	if e = l.TransSend("SET", "a", "1"); e != nil {
+		t.Fatal(e)
+	}
+	l.Release()
+	if _, e = l.Call("MULTI"); e != ErrLeaseReleased {
+		t.Errorf("call after release err=%v", e)
+	}
+	mu.Lock()
+	if discards != 1 {
+		t.Errorf("open MULTI not discarded on release, discards=%d", discards)
+	}
+	mu.Unlock()
+
+	c := p.Pop()
+	p.Push(c)
+	if e = c.MULTI(); e != ErrConnNotOwned {
+		t.Errorf("MULTI on pooled conn err=%v", e)
+	}
+}
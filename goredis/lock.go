@@ -0,0 +1,98 @@
+package msgredis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrLockHeld = errors.New(CommonErrPrefix + "lock held by another owner")
+	ErrLockLost = errors.New(CommonErrPrefix + "lock expired or taken over")
+)
+
+// Lock is a single instance lock, SET key token NX PX ttl. Only the owner
+// token may extend or release it, an expired lock can be taken by others.
+type Lock struct {
+	Key string
+	TTL time.Duration
+
+	p     *Pool
+	token string
+}
+
+// delete/extend only while key still holds our token
+var (
+	unlockScript = NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+	extendScript = NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// TryLock takes key for ttl, ErrLockHeld if someone else holds it
+func (p *Pool) TryLock(key string, ttl time.Duration) (*Lock, error) {
+	b := make([]byte, 16)
+	if _, e := rand.Read(b); e != nil {
+		return nil, e
+	}
+	l := &Lock{Key: key, TTL: ttl, p: p, token: hex.EncodeToString(b)}
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer p.Push(c)
+	v, e := c.Call("SET", key, l.token, "NX", "PX", ttl)
+	if e != nil {
+		return nil, e
+	}
+	if v == nil {
+		return nil, ErrLockHeld
+	}
+	return l, nil
+}
+
+// Lock retries TryLock every wait until timeout
+func (p *Pool) Lock(key string, ttl, wait, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, e := p.TryLock(key, ttl)
+		if e != ErrLockHeld || time.Now().Add(wait).After(deadline) {
+			return l, e
+		}
+		time.Sleep(wait)
+	}
+}
+
+func (l *Lock) owned(s *Script, args ...interface{}) error {
+	c := l.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer l.p.Push(c)
+	v, e := c.RunScript(s, []string{l.Key}, Args{l.token}.Add(args...)...)
+	if e != nil {
+		return e
+	}
+	if n, _ := v.(int64); n != 1 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Extend resets the ttl, ErrLockLost if it already expired
+func (l *Lock) Extend() error {
+	// a Duration would be sent in seconds, PEXPIRE takes milliseconds
+	return l.owned(extendScript, int64(l.TTL/time.Millisecond))
+}
+
+// Unlock releases the lock, ErrLockLost if it already expired
+func (l *Lock) Unlock() error {
+	return l.owned(unlockScript)
+}
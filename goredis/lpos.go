@@ -0,0 +1,81 @@
+package msgredis
+
+// LPosOptions of LPOS, zero values are left out. Rank n starts at the n-th
+// match, negative ranks search from the tail; MaxLen compares at most that
+// many elements.
+type LPosOptions struct {
+	Rank   int
+	MaxLen int
+}
+
+func (opt LPosOptions) args(args []interface{}) []interface{} {
+	if opt.Rank != 0 {
+		args = append(args, "RANK", opt.Rank)
+	}
+	if opt.MaxLen > 0 {
+		args = append(args, "MAXLEN", opt.MaxLen)
+	}
+	return args
+}
+
+/******************* lpos commands *******************/
+// index of the first match, ErrKeyNotExist if element is not in the list
+func (c *Conn) LPOS(key, element string, opt LPosOptions) (int64, error) {
+	v, e := c.Call("LPOS", opt.args([]interface{}{key, element})...)
+	if e != nil {
+		return -1, e
+	}
+	if v == nil {
+		return -1, ErrKeyNotExist
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return -1, ErrBadType
+	}
+	return n, nil
+}
+
+// indexes of up to count matches, count 0 returns all of them
+func (c *Conn) LPOSCOUNT(key, element string, count int, opt LPosOptions) ([]int64, error) {
+	args := opt.args([]interface{}{key, element})
+	v, e := c.Call("LPOS", append(args, "COUNT", count)...)
+	if e != nil {
+		return nil, e
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	ret := make([]int64, len(arr))
+	for i, x := range arr {
+		if ret[i], ok = x.(int64); !ok {
+			return nil, ErrBadType
+		}
+	}
+	return ret, nil
+}
+
+var removeFirstScript = NewScript(`
+local i = redis.call('LPOS', KEYS[1], ARGV[1])
+if i then
+	redis.call('LREM', KEYS[1], 1, ARGV[1])
+end
+return i`)
+
+// RemoveFirstOccurrence removes the first element equal to element and
+// returns where it was, e.g. to cancel a queued job. ok is false if it was
+// not in the list.
+func (c *Conn) RemoveFirstOccurrence(key, element string) (int64, bool, error) {
+	v, e := c.RunScript(removeFirstScript, []string{key}, element)
+	if e != nil {
+		return -1, false, e
+	}
+	if v == nil {
+		return -1, false, nil
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return -1, false, ErrBadType
+	}
+	return n, true, nil
+}
@@ -0,0 +1,47 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLPOS(t *testing.T) {
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		got = args
+		if args[0] == "EVALSHA" {
+			if args[4] == "missing" {
+				return "$-1\r\n"
+			}
+			return ":2\r\n"
+		}
+		if strings.Contains(strings.Join(args, " "), "COUNT") {
+			return "*2\r\n:1\r\n:4\r\n"
+		}
+		if args[2] == "missing" {
+			return "$-1\r\n"
+		}
+		return ":3\r\n"
+	})
+	c := dialFake(t, s)
+
+	n, e := c.LPOS("q", "a", LPosOptions{Rank: -1, MaxLen: 100})
+	if e != nil || n != 3 || strings.Join(got, " ") != "LPOS q a RANK -1 MAXLEN 100" {
+		t.Fatalf("got %d %v %v", n, e, got)
+	}
+	if _, e = c.LPOS("q", "missing", LPosOptions{}); e != ErrKeyNotExist {
+		t.Fatalf("got %v", e)
+	}
+	idx, e := c.LPOSCOUNT("q", "a", 0, LPosOptions{})
+	if e != nil || len(idx) != 2 || idx[1] != 4 || strings.Join(got, " ") != "LPOS q a COUNT 0" {
+		t.Fatalf("got %v %v %v", idx, e, got)
+	}
+
+	n, ok, e := c.RemoveFirstOccurrence("q", "job")
+	if e != nil || !ok || n != 2 || got[1] != removeFirstScript.SHA {
+		t.Fatalf("got %d %v %v %v", n, ok, e, got)
+	}
+	if _, ok, e = c.RemoveFirstOccurrence("q", "missing"); e != nil || ok {
+		t.Fatalf("got %v %v", ok, e)
+	}
+}
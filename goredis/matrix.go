@@ -0,0 +1,187 @@
+package msgredis
+
+import (
+	"strconv"
+)
+
+// Matrix packs a table of fixed-width integer cells into the single string
+// at Key, row after row, Cols cells of Width bits each. Cell (row, col) is
+// the BITFIELD index row*Cols+col, so a million users with 8 one-bit flags
+// take 1MB instead of a million hashes. Cells read as 0 until written.
+type Matrix struct {
+	Key  string
+	Cols int
+	// 1..63 bits unsigned, 1..64 signed
+	Width  int
+	Signed bool
+
+	p *Pool
+}
+
+func NewMatrix(p *Pool, key string, cols, width int, signed bool) (*Matrix, error) {
+	if cols <= 0 || width <= 0 || width > 64 || (width == 64 && !signed) {
+		return nil, ErrBadArgs
+	}
+	return &Matrix{Key: key, Cols: cols, Width: width, Signed: signed, p: p}, nil
+}
+
+// BITFIELD type, e.g. u4 or i16
+func (m *Matrix) fieldType() string {
+	if m.Signed {
+		return "i" + strconv.Itoa(m.Width)
+	}
+	return "u" + strconv.Itoa(m.Width)
+}
+
+func (m *Matrix) cell(row int64, col int) (string, error) {
+	if row < 0 || col < 0 || col >= m.Cols {
+		return "", ErrBadArgs
+	}
+	return "#" + strconv.FormatInt(row*int64(m.Cols)+int64(col), 10), nil
+}
+
+func (m *Matrix) bitfield(args ...interface{}) (int64, error) {
+	c := m.p.Pop()
+	if c == nil {
+		return 0, ErrNoConn
+	}
+	defer m.p.Push(c)
+	v, e := c.Call("BITFIELD", append([]interface{}{m.Key}, args...)...)
+	if e != nil {
+		return 0, e
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 1 {
+		return 0, ErrBadType
+	}
+	if arr[0] == nil {
+		// OVERFLOW FAIL
+		return 0, ErrNil
+	}
+	n, ok := arr[0].(int64)
+	if !ok {
+		return 0, ErrBadType
+	}
+	return n, nil
+}
+
+func (m *Matrix) Get(row int64, col int) (int64, error) {
+	off, e := m.cell(row, col)
+	if e != nil {
+		return 0, e
+	}
+	return m.bitfield("GET", m.fieldType(), off)
+}
+
+// Set writes a cell and returns its old value, v is truncated to Width bits
+func (m *Matrix) Set(row int64, col int, v int64) (int64, error) {
+	off, e := m.cell(row, col)
+	if e != nil {
+		return 0, e
+	}
+	return m.bitfield("SET", m.fieldType(), off, v)
+}
+
+// Incr adds delta to a cell, saturating at the bounds of Width, and returns
+// the new value. Counters in a time-bucketed row never wrap around.
+func (m *Matrix) Incr(row int64, col int, delta int64) (int64, error) {
+	off, e := m.cell(row, col)
+	if e != nil {
+		return 0, e
+	}
+	return m.bitfield("OVERFLOW", "SAT", "INCRBY", m.fieldType(), off, delta)
+}
+
+// rowBytes is the byte size of a row, 0 if rows are not byte aligned
+func (m *Matrix) rowBytes() int64 {
+	bits := int64(m.Cols) * int64(m.Width)
+	if bits%8 != 0 {
+		return 0
+	}
+	return bits / 8
+}
+
+// Row reads all cells of a row with one GETRANGE
+func (m *Matrix) Row(row int64) ([]int64, error) {
+	if row < 0 {
+		return nil, ErrBadArgs
+	}
+	bits := int64(m.Cols) * int64(m.Width)
+	first := row * bits
+	start, end := first/8, (first+bits-1)/8
+	c := m.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer m.p.Push(c)
+	v, e := c.Call("GETRANGE", m.Key, start, end)
+	if e != nil {
+		return nil, e
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, ErrBadType
+	}
+	cells := make([]int64, m.Cols)
+	for i := range cells {
+		cells[i] = m.decode(b, first-start*8+int64(i*m.Width))
+	}
+	return cells, nil
+}
+
+// decode reads the cell at bit offset off of b, most significant bit first
+// as BITFIELD stores it; bytes past the end of the string are 0
+func (m *Matrix) decode(b []byte, off int64) int64 {
+	var u uint64
+	for i := int64(0); i < int64(m.Width); i++ {
+		bit := off + i
+		u <<= 1
+		if idx := bit / 8; idx < int64(len(b)) && b[idx]&(0x80>>uint(bit%8)) != 0 {
+			u |= 1
+		}
+	}
+	if m.Signed && m.Width < 64 && u&(1<<uint(m.Width-1)) != 0 {
+		u |= ^uint64(0) << uint(m.Width)
+	}
+	return int64(u)
+}
+
+// SetRecord overwrites a whole row with its raw bytes, rows must be byte
+// aligned (Cols*Width a multiple of 8)
+func (m *Matrix) SetRecord(row int64, rec []byte) error {
+	size := m.rowBytes()
+	if size == 0 || row < 0 || int64(len(rec)) != size {
+		return ErrBadArgs
+	}
+	c := m.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer m.p.Push(c)
+	_, e := c.Call("SETRANGE", m.Key, row*size, rec)
+	return e
+}
+
+// Record returns the raw bytes of a row, zero filled past the end
+func (m *Matrix) Record(row int64) ([]byte, error) {
+	size := m.rowBytes()
+	if size == 0 || row < 0 {
+		return nil, ErrBadArgs
+	}
+	c := m.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer m.p.Push(c)
+	v, e := c.Call("GETRANGE", m.Key, row*size, (row+1)*size-1)
+	if e != nil {
+		return nil, e
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, ErrBadType
+	}
+	rec := make([]byte, size)
+	copy(rec, b)
+	return rec, nil
+}
@@ -0,0 +1,143 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// strHandler keeps one string value and serves the bit and range commands
+func strHandler() func([]string) string {
+	var mu sync.Mutex
+	var val []byte
+	grow := func(n int) {
+		for len(val) < n {
+			val = append(val, 0)
+		}
+	}
+	getBits := func(off, width int) uint64 {
+		var u uint64
+		for i := 0; i < width; i++ {
+			u <<= 1
+			if b := (off + i) / 8; b < len(val) && val[b]&(0x80>>uint((off+i)%8)) != 0 {
+				u |= 1
+			}
+		}
+		return u
+	}
+	setBits := func(off, width int, u uint64) {
+		grow((off + width + 7) / 8)
+		for i := width - 1; i >= 0; i-- {
+			b, mask := (off+i)/8, byte(0x80>>uint((off+i)%8))
+			if u&1 != 0 {
+				val[b] |= mask
+			} else {
+				val[b] &^= mask
+			}
+			u >>= 1
+		}
+	}
+	return func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "GETRANGE":
+			start, _ := strconv.Atoi(args[2])
+			end, _ := strconv.Atoi(args[3])
+			if start >= len(val) {
+				return "$0\r\n\r\n"
+			}
+			if end >= len(val) {
+				end = len(val) - 1
+			}
+			return "$" + strconv.Itoa(end-start+1) + "\r\n" + string(val[start:end+1]) + "\r\n"
+		case "SETRANGE":
+			off, _ := strconv.Atoi(args[2])
+			grow(off + len(args[3]))
+			copy(val[off:], args[3])
+			return ":" + strconv.Itoa(len(val)) + "\r\n"
+		case "BITFIELD":
+			i := 2
+			sat := false
+			if args[i] == "OVERFLOW" {
+				sat, i = args[i+1] == "SAT", i+2
+			}
+			width, _ := strconv.Atoi(args[i+1][1:])
+			idx, _ := strconv.Atoi(args[i+2][1:])
+			off := idx * width
+			old := int64(getBits(off, width))
+			signed := args[i+1][0] == 'i'
+			if signed && old&(1<<uint(width-1)) != 0 {
+				old -= 1 << uint(width)
+			}
+			switch args[i] {
+			case "GET":
+				return "*1\r\n:" + strconv.FormatInt(old, 10) + "\r\n"
+			case "SET":
+				v, _ := strconv.ParseInt(args[i+3], 10, 64)
+				setBits(off, width, uint64(v))
+				return "*1\r\n:" + strconv.FormatInt(old, 10) + "\r\n"
+			case "INCRBY":
+				d, _ := strconv.ParseInt(args[i+3], 10, 64)
+				v := old + d
+				if max := int64(1)<<uint(width) - 1; sat && !signed && v > max {
+					v = max
+				}
+				setBits(off, width, uint64(v))
+				return "*1\r\n:" + strconv.FormatInt(v, 10) + "\r\n"
+			}
+		}
+		return "-ERR unknown\r\n"
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	s := newFakeServer(t, strHandler())
+	p := NewPool(s.Addr(), "")
+	if _, e := NewMatrix(p, "m", 4, 64, false); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+	m, _ := NewMatrix(p, "flags", 5, 3, false)
+
+	if old, e := m.Set(2, 4, 5); e != nil || old != 0 {
+		t.Fatalf("got %d %v", old, e)
+	}
+	m.Set(2, 0, 7)
+	m.Set(1, 4, 1)
+	if v, e := m.Get(2, 4); e != nil || v != 5 {
+		t.Fatalf("got %d %v", v, e)
+	}
+	if v, _ := m.Incr(2, 4, 10); v != 7 {
+		t.Fatalf("not saturated: %d", v)
+	}
+	row, e := m.Row(2)
+	if e != nil || len(row) != 5 || row[0] != 7 || row[1] != 0 || row[4] != 7 {
+		t.Fatalf("row %v %v", row, e)
+	}
+	if row, _ = m.Row(9); row[0] != 0 {
+		t.Fatalf("row past the end %v", row)
+	}
+	if _, e = m.Get(0, 5); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+	if e = m.SetRecord(0, []byte{1}); e != ErrBadArgs {
+		t.Fatal("unaligned rows accepted")
+	}
+
+	s = newFakeServer(t, strHandler())
+	p = NewPool(s.Addr(), "")
+	r, _ := NewMatrix(p, "rec", 2, 8, true)
+	if e = r.SetRecord(1, []byte{0x7f, 0xfe}); e != nil {
+		t.Fatal(e)
+	}
+	if row, _ = r.Row(1); row[0] != 127 || row[1] != -2 {
+		t.Fatalf("row %v", row)
+	}
+	rec, e := r.Record(3)
+	if e != nil || len(rec) != 2 || rec[0] != 0 {
+		t.Fatalf("record %v %v", rec, e)
+	}
+	if v, _ := r.Get(1, 1); v != -2 {
+		t.Fatalf("got %d", v)
+	}
+}
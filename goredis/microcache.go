@@ -0,0 +1,164 @@
+package msgredis
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// entries kept before the micro cache starts over
+const microMaxEntries = 100000
+
+// integer metadata reads on one key the micro cache may answer
+var microCommands = map[string]bool{
+	"EXISTS": true,
+	"TTL":    true,
+	"PTTL":   true,
+	"STRLEN": true,
+	"HLEN":   true,
+	"LLEN":   true,
+	"SCARD":  true,
+	"ZCARD":  true,
+	"XLEN":   true,
+}
+
+type microRule struct {
+	pattern  string
+	commands map[string]bool
+}
+
+type microEntry struct {
+	value   int64
+	expires time.Time
+}
+
+// MicroCache keeps the integer replies of hot metadata reads (EXISTS,
+// TTL, HLEN...) for a sub-second TTL, for workloads polling the same keys
+// thousands of times per second. Replies are shared by the conns of a
+// pool. Writes sent through those conns drop the entries of their keys,
+// writes of other clients show after at most TTL; TTL replies are as old
+// as the entry.
+type MicroCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	rules   []microRule
+	entries map[string]microEntry
+}
+
+func NewMicroCache(ttl time.Duration) *MicroCache {
+	return &MicroCache{TTL: ttl, entries: make(map[string]microEntry)}
+}
+
+// Add caches commands on keys matching pattern, a path.Match glob such as
+// "session:*". Commands other than single key integer reads are ignored.
+func (mc *MicroCache) Add(pattern string, commands ...string) error {
+	if _, e := path.Match(pattern, ""); e != nil {
+		return ErrBadArgs
+	}
+	rule := microRule{pattern: pattern, commands: make(map[string]bool)}
+	for _, command := range commands {
+		if name := strings.ToUpper(command); microCommands[name] {
+			rule.commands[name] = true
+		}
+	}
+	mc.mu.Lock()
+	mc.rules = append(mc.rules, rule)
+	mc.mu.Unlock()
+	return nil
+}
+
+// entryKey returns the cache key of a cacheable call, "" otherwise
+func (mc *MicroCache) entryKey(command string, args []interface{}) string {
+	if len(args) != 1 {
+		return ""
+	}
+	key := argString(args[0])
+	for _, rule := range mc.rules {
+		if !rule.commands[command] {
+			continue
+		}
+		if ok, _ := path.Match(rule.pattern, key); ok {
+			return command + " " + key
+		}
+	}
+	return ""
+}
+
+func (mc *MicroCache) get(command string, args []interface{}) (int64, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	k := mc.entryKey(command, args)
+	if k == "" {
+		return 0, false
+	}
+	entry, ok := mc.entries[k]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, false
+	}
+	return entry.value, true
+}
+
+func (mc *MicroCache) set(command string, args []interface{}, v int64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	k := mc.entryKey(command, args)
+	if k == "" {
+		return
+	}
+	if len(mc.entries) >= microMaxEntries {
+		mc.entries = make(map[string]microEntry)
+	}
+	mc.entries[k] = microEntry{value: v, expires: time.Now().Add(mc.TTL)}
+}
+
+// invalidate drops every entry of keys
+func (mc *MicroCache) invalidate(keys []string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, key := range keys {
+		for command := range microCommands {
+			delete(mc.entries, command+" "+key)
+		}
+	}
+}
+
+// SetMicroCache answers cacheable calls from mc, see MicroCache
+func (c *Conn) SetMicroCache(mc *MicroCache) {
+	c.microCache = mc
+}
+
+// in MULTI the server answers QUEUED, subscribed it takes no reads
+func (c *Conn) microLookup(command string, args []interface{}) (interface{}, bool) {
+	if c.microCache == nil || c.state&(stateMulti|stateSubscribed) != 0 {
+		return nil, false
+	}
+	v, ok := c.microCache.get(strings.ToUpper(command), args)
+	if !ok {
+		return nil, false
+	}
+	return v, true
+}
+
+// microUpdate caches the reply of a read, or drops the keys of a write
+func (c *Conn) microUpdate(command string, args []interface{}, ret interface{}, e error) {
+	if c.microCache == nil || e != nil {
+		return
+	}
+	name := strings.ToUpper(command)
+	if n, ok := ret.(int64); ok && microCommands[name] {
+		c.microCache.set(name, args, n)
+		return
+	}
+	ci := LookupCommand(name)
+	if ci == nil || !ci.Is(FlagWrite) {
+		return
+	}
+	positions := ci.KeyPositions(args)
+	keys := make([]string, len(positions))
+	for i, pos := range positions {
+		keys[i] = argString(args[pos])
+	}
+	c.microCache.invalidate(keys)
+}
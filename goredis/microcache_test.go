@@ -0,0 +1,82 @@
+package msgredis
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMicroCache(t *testing.T) {
+	var calls int32
+	s := newFakeServer(t, func(args []string) string {
+		atomic.AddInt32(&calls, 1)
+		if args[0] == "DEL" {
+			return ":1\r\n"
+		}
+		return ":7\r\n"
+	})
+	mc := NewMicroCache(50 * time.Millisecond)
+	if e := mc.Add("hot:*", "exists", "TTL", "GET"); e != nil {
+		t.Fatal(e)
+	}
+	c := dialFake(t, s)
+	c.SetMicroCache(mc)
+
+	for i := 0; i < 10; i++ {
+		if v, e := c.Call("EXISTS", "hot:a"); e != nil || v != int64(7) {
+			t.Fatalf("%v %v", v, e)
+		}
+	}
+	c.Call("TTL", "hot:a")
+	c.Call("EXISTS", "cold:a")
+	c.Call("EXISTS", "cold:a")
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("%d calls, want 4", n)
+	}
+	// a write drops the key, expiry refetches
+	c.Call("DEL", "hot:a")
+	c.Call("EXISTS", "hot:a")
+	c.Call("TTL", "hot:a")
+	if n := atomic.LoadInt32(&calls); n != 7 {
+		t.Fatalf("%d calls after DEL, want 7", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	c.Call("EXISTS", "hot:a")
+	if n := atomic.LoadInt32(&calls); n != 8 {
+		t.Fatalf("%d calls after expiry, want 8", n)
+	}
+}
+
+func TestMicroCacheMulti(t *testing.T) {
+	multi := false
+	s := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "MULTI":
+			multi = true
+			return "+OK\r\n"
+		case args[0] == "EXEC":
+			multi = false
+			return "*1\r\n:0\r\n"
+		case multi:
+			return "+QUEUED\r\n"
+		}
+		return ":1\r\n"
+	})
+	mc := NewMicroCache(time.Minute)
+	mc.Add("hot:*", "EXISTS")
+	c := dialFake(t, s)
+	c.SetMicroCache(mc)
+	if v, e := c.Call("EXISTS", "hot:a"); e != nil || v != int64(1) {
+		t.Fatalf("%v %v", v, e)
+	}
+	// the warm entry must not answer for the server inside MULTI
+	if e := c.MULTI(); e != nil {
+		t.Fatal(e)
+	}
+	if e := c.TransSend("EXISTS", "hot:a"); e != nil {
+		t.Fatalf("queue: %v", e)
+	}
+	if ret, e := c.TransExec(); e != nil || len(ret) != 1 {
+		t.Fatalf("exec: %v %v", ret, e)
+	}
+}
@@ -0,0 +1,159 @@
+package msgredis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMigrationLockTTL  = time.Minute
+	DefaultMigrationLockWait = 5 * time.Minute
+)
+
+type Migration struct {
+	ID string
+	Up func(p *Pool) error
+}
+
+// Migrations applies registered data migrations once: applied IDs are
+// recorded in the hash Key and Run holds the lock Key+":lock", so
+// concurrently started instances apply each migration exactly once.
+// The lock is extended every LockTTL/3 while a migration runs; a migration
+// is only recorded as applied while the lock is still held.
+type Migrations struct {
+	Key      string
+	LockTTL  time.Duration
+	LockWait time.Duration
+
+	p    *Pool
+	list []Migration
+}
+
+func NewMigrations(p *Pool, key string) *Migrations {
+	return &Migrations{Key: key, LockTTL: DefaultMigrationLockTTL, LockWait: DefaultMigrationLockWait, p: p}
+}
+
+// Register appends a migration, they run in registration order
+func (m *Migrations) Register(id string, up func(p *Pool) error) {
+	m.list = append(m.list, Migration{ID: id, Up: up})
+}
+
+// Applied returns the applied IDs and when they were applied
+func (m *Migrations) Applied() (map[string]time.Time, error) {
+	c := m.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer m.p.Push(c)
+	v, e := c.Call("HGETALL", m.Key)
+	if e != nil {
+		return nil, e
+	}
+	fields, e := replyStringMap(v)
+	if e != nil {
+		return nil, e
+	}
+	applied := make(map[string]time.Time, len(fields))
+	for id, ts := range fields {
+		sec, _ := strconv.ParseInt(ts, 10, 64)
+		applied[id] = time.Unix(sec, 0)
+	}
+	return applied, nil
+}
+
+// records a migration only while the lock still holds our token
+var markAppliedScript = NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('HSET', KEYS[2], ARGV[2], ARGV[3])
+end
+return -1`)
+
+// Run applies the pending migrations and returns their IDs. It stops at
+// the first failing migration, later runs retry it. ErrLockLost means the
+// lock expired while a migration ran, which was then not recorded.
+func (m *Migrations) Run() (done []string, e error) {
+	lock, e := m.p.Lock(m.Key+":lock", m.LockTTL, 100*time.Millisecond, m.LockWait)
+	if e != nil {
+		return nil, e
+	}
+	defer func() {
+		if ue := lock.Unlock(); e == nil {
+			e = ue
+		}
+	}()
+
+	// read under the lock, another instance may just have finished
+	applied, e := m.Applied()
+	if e != nil {
+		return nil, e
+	}
+	done = []string{}
+	for _, mig := range m.list {
+		if _, ok := applied[mig.ID]; ok {
+			continue
+		}
+		if e = lock.Extend(); e != nil {
+			return done, e
+		}
+		fmt.Println("[Migrations] applying " + mig.ID)
+		stop := make(chan struct{})
+		lost := m.keepLock(lock, stop)
+		e = mig.Up(m.p)
+		close(stop)
+		if le := <-lost; e == nil {
+			e = le
+		}
+		if e != nil {
+			return done, e
+		}
+		if e = m.markApplied(lock, mig.ID); e != nil {
+			return done, e
+		}
+		done = append(done, mig.ID)
+	}
+	return done, nil
+}
+
+// keepLock extends lock every LockTTL/3 until stop is closed. The channel
+// gets the error that ended it, nil once stopped.
+func (m *Migrations) keepLock(lock *Lock, stop <-chan struct{}) <-chan error {
+	interval := m.LockTTL / 3
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	lost := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				lost <- nil
+				return
+			case <-ticker.C:
+				if e := lock.Extend(); e != nil {
+					lost <- e
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
+
+func (m *Migrations) markApplied(lock *Lock, id string) error {
+	c := m.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer m.p.Push(c)
+	v, e := c.RunScript(markAppliedScript, []string{lock.Key, m.Key}, lock.token, id, time.Now().Unix())
+	if e != nil {
+		return e
+	}
+	if n, _ := v.(int64); n < 0 {
+		return ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// strings with expiry, hashes and the lock scripts
+func lockHandler() func(args []string) string {
+	var mu sync.Mutex
+	kv := map[string]string{}
+	expires := map[string]time.Time{}
+	hash := map[string]string{}
+	get := func(key string) string {
+		if at, ok := expires[key]; ok && time.Now().After(at) {
+			delete(kv, key)
+			delete(expires, key)
+		}
+		return kv[key]
+	}
+	return func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "SET":
+			if get(args[1]) != "" {
+				return "$-1\r\n"
+			}
+			kv[args[1]] = args[2]
+			if len(args) == 6 {
+				ms, _ := strconv.Atoi(args[5])
+				expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			return "+OK\r\n"
+		case "DEL":
+			delete(kv, args[1])
+			return ":1\r\n"
+		case "EVALSHA":
+			if args[1] == markAppliedScript.SHA {
+				// KEYS = lock, hash; ARGV = token, id, time
+				if get(args[3]) != args[5] {
+					return ":-1\r\n"
+				}
+				hash[args[6]] = args[7]
+				return ":1\r\n"
+			}
+			// KEYS[1] = args[3], token = args[4]
+			if get(args[3]) != args[4] {
+				return ":0\r\n"
+			}
+			switch args[1] {
+			case unlockScript.SHA:
+				delete(kv, args[3])
+			case extendScript.SHA:
+				ms, _ := strconv.Atoi(args[5])
+				expires[args[3]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			return ":1\r\n"
+		case "HGETALL":
+			fields := []string{}
+			for k, v := range hash {
+				fields = append(fields, k, v)
+			}
+			return respArray(fields...)
+		}
+		return "-ERR unknown\r\n"
+	}
+}
+
+func TestLock(t *testing.T) {
+	p := NewPool(newFakeServer(t, lockHandler()).Addr(), "")
+	l, e := p.TryLock("lk", time.Second)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = p.TryLock("lk", time.Second); e != ErrLockHeld {
+		t.Fatalf("second lock: %v", e)
+	}
+	if e = l.Extend(); e != nil {
+		t.Fatal(e)
+	}
+	if e = l.Unlock(); e != nil {
+		t.Fatal(e)
+	}
+	if e = l.Unlock(); e != ErrLockLost {
+		t.Fatalf("double unlock: %v", e)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	p := NewPool(newFakeServer(t, lockHandler()).Addr(), "")
+	var runs [2]int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := NewMigrations(p, "migrations")
+			m.Register("001-rename", func(*Pool) error {
+				atomic.AddInt32(&runs[0], 1)
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			})
+			m.Register("002-backfill", func(*Pool) error {
+				atomic.AddInt32(&runs[1], 1)
+				return nil
+			})
+			if _, e := m.Run(); e != nil {
+				t.Error(e)
+			}
+		}()
+	}
+	wg.Wait()
+	if runs[0] != 1 || runs[1] != 1 {
+		t.Fatalf("runs %v", runs)
+	}
+	applied, _ := NewMigrations(p, "migrations").Applied()
+	if len(applied) != 2 {
+		t.Fatalf("applied %v", applied)
+	}
+}
+
+func TestMigrationsLockRenewal(t *testing.T) {
+	p := NewPool(newFakeServer(t, lockHandler()).Addr(), "")
+	m := NewMigrations(p, "migrations")
+	m.LockTTL = 60 * time.Millisecond
+	m.Register("001-slow", func(p *Pool) error {
+		// well past LockTTL, the lock must still be held
+		for i := 0; i < 4; i++ {
+			time.Sleep(50 * time.Millisecond)
+			if _, e := p.TryLock("migrations:lock", time.Second); e != ErrLockHeld {
+				return e
+			}
+		}
+		return nil
+	})
+	if done, e := m.Run(); e != nil || len(done) != 1 {
+		t.Fatalf("run %v %v", done, e)
+	}
+
+	// taken over while running: not recorded, the next run retries it
+	m = NewMigrations(p, "migrations")
+	m.Register("002-lost", func(p *Pool) error {
+		c := p.Pop()
+		defer p.Push(c)
+		c.Call("DEL", "migrations:lock")
+		_, e := p.TryLock("migrations:lock", time.Minute)
+		return e
+	})
+	if _, e := m.Run(); e != ErrLockLost {
+		t.Fatalf("got %v", e)
+	}
+	applied, _ := m.Applied()
+	if _, ok := applied["002-lost"]; ok || len(applied) != 1 {
+		t.Fatalf("applied %v", applied)
+	}
+}
@@ -3,7 +3,7 @@ package msgredis
 import (
 	// "fmt"
 	"runtime"
-	// "strconv"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -36,3 +36,72 @@ func TestMultiPool(t *testing.T) {
 	}
 	g.Wait()
 }
+
+func TestMultiPoolEject(t *testing.T) {
+	live := newFakeServer(t, okHandler)
+	dead := newFakeServer(t, okHandler)
+	deadAddr := dead.Addr()
+	dead.ln.Close()
+
+	mp := NewMultiPool([]string{live.Addr(), deadAddr})
+	mp.EjectMinCalls = 2
+	mp.EjectDuration = time.Minute
+	for i := 0; i < 50; i++ {
+		key := "key" + strconv.Itoa(i)
+		if c := mp.PopByKey(key); c != nil {
+			mp.PushByKey(key, c)
+		}
+	}
+	if !mp.Ejected(deadAddr) {
+		t.Fatal("dead shard not ejected")
+	}
+	for i := 0; i < 50; i++ {
+		if addr := mp.AddrByKey("key" + strconv.Itoa(i)); addr != live.Addr() {
+			t.Fatalf("key routed to %s", addr)
+		}
+	}
+}
+
+func TestMultiPoolProbe(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			if args[1] != "secret" {
+				return "-WRONGPASS invalid username-password pair\r\n"
+			}
+			return "+OK\r\n"
+		case "PING":
+			return "+PONG\r\n"
+		}
+		return "+OK\r\n"
+	})
+	mp := NewMultiPool([]string{s.Addr()})
+	mp.pools[s.Addr()].Credentials = staticCredentials("", "secret")
+	mp.EjectMinCalls = 2
+	mp.EjectDuration = 20 * time.Millisecond
+	h := mp.health[s.Addr()]
+	h.mu.Lock()
+	mp.eject(s.Addr(), h)
+	h.mu.Unlock()
+	if !mp.Ejected(s.Addr()) {
+		t.Fatal("not ejected")
+	}
+	// the probe authenticates like the pool
+	deadline := time.Now().Add(time.Second)
+	for mp.Ejected(s.Addr()) {
+		if time.Now().After(deadline) {
+			t.Fatal("not re-admitted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// reading the state ejects nothing
+	h.mu.Lock()
+	h.calls, h.errors = 10, 10
+	h.mu.Unlock()
+	if mp.Ejected(s.Addr()) {
+		t.Fatal("Ejected ejected")
+	}
+	if !mp.CheckHealth(s.Addr()) {
+		t.Fatal("error rate not checked")
+	}
+}
@@ -0,0 +1,88 @@
+package msgredis
+
+// (value, ok, err) accessors: ok is false when the key or field does not
+// exist, so a missing key is not confused with an empty string and no
+// ErrKeyNotExist check is needed. They are named after the command with an
+// OPT suffix.
+
+// nil bulk string => ok false
+func optBytes(v interface{}, e error) ([]byte, bool, error) {
+	if e != nil || v == nil {
+		return nil, false, e
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false, ErrBadType
+	}
+	return b, true, nil
+}
+
+func optString(v interface{}, e error) (string, bool, error) {
+	b, ok, e := optBytes(v, e)
+	return string(b), ok, e
+}
+
+// array with nil elements, e.g. MGET, HMGET
+func optStrings(v interface{}, e error) ([]string, []bool, error) {
+	if e != nil {
+		return nil, nil, e
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, nil, ErrBadType
+	}
+	values := make([]string, len(arr))
+	exists := make([]bool, len(arr))
+	for i, elem := range arr {
+		if values[i], exists[i], e = optString(elem, nil); e != nil {
+			return nil, nil, e
+		}
+	}
+	return values, exists, nil
+}
+
+func (c *Conn) GETBYTESOPT(key string) ([]byte, bool, error) {
+	return optBytes(c.Call("GET", key))
+}
+
+func (c *Conn) GETOPT(key string) (string, bool, error) {
+	return optString(c.Call("GET", key))
+}
+
+func (c *Conn) GETDELOPT(key string) (string, bool, error) {
+	return optString(c.Call("GETDEL", key))
+}
+
+// the old value, ok is false if the key did not exist
+func (c *Conn) GETSETOPT(key, value string) (string, bool, error) {
+	return optString(c.Call("GETSET", key, value))
+}
+
+// MGETOPT returns the values and whether each key exists
+func (c *Conn) MGETOPT(keys ...string) ([]string, []bool, error) {
+	return optStrings(c.Call("MGET", Args{}.Add(keys)...))
+}
+
+func (c *Conn) HGETOPT(key, field string) (string, bool, error) {
+	return optString(c.Call("HGET", key, field))
+}
+
+func (c *Conn) HMGETOPT(key string, fields ...string) ([]string, []bool, error) {
+	return optStrings(c.Call("HMGET", Args{key}.Add(fields)...))
+}
+
+func (c *Conn) LINDEXOPT(key string, index int) (string, bool, error) {
+	return optString(c.Call("LINDEX", key, index))
+}
+
+func (c *Conn) LPOPOPT(key string) (string, bool, error) {
+	return optString(c.Call("LPOP", key))
+}
+
+func (c *Conn) RPOPOPT(key string) (string, bool, error) {
+	return optString(c.Call("RPOP", key))
+}
+
+func (c *Conn) SPOPOPT(key string) (string, bool, error) {
+	return optString(c.Call("SPOP", key))
+}
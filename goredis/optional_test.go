@@ -0,0 +1,36 @@
+package msgredis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptionalAccessors(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "MGET":
+			return "*3\r\n$1\r\na\r\n$-1\r\n$0\r\n\r\n"
+		}
+		if args[1] == "empty" {
+			return "$0\r\n\r\n"
+		}
+		return "$-1\r\n"
+	})
+	c := dialFake(t, s)
+
+	v, ok, e := c.GETOPT("missing")
+	if e != nil || ok || v != "" {
+		t.Fatalf("missing: %q %v %v", v, ok, e)
+	}
+	v, ok, e = c.GETOPT("empty")
+	if e != nil || !ok || v != "" {
+		t.Fatalf("empty: %q %v %v", v, ok, e)
+	}
+	values, exists, e := c.MGETOPT("a", "b", "c")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !reflect.DeepEqual(values, []string{"a", "", ""}) || !reflect.DeepEqual(exists, []bool{true, false, true}) {
+		t.Fatalf("MGET: %q %v", values, exists)
+	}
+}
@@ -0,0 +1,23 @@
+package msgredis
+
+import (
+	"strconv"
+)
+
+// PartialError is returned by PipeExec when the replies stop part way,
+// e.g. on a read timeout. The first Completed replies are valid, the conn
+// is broken and must not be reused.
+type PartialError struct {
+	Completed int
+	Total     int
+	Err       error
+}
+
+func (e *PartialError) Error() string {
+	return CommonErrPrefix + "pipeline interrupted after " + strconv.Itoa(e.Completed) +
+		" of " + strconv.Itoa(e.Total) + " replies: " + e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,37 @@
+package msgredis
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPipeExecPartial(t *testing.T) {
+	n := 0
+	s := newFakeServer(t, func(args []string) string {
+		n++
+		if n >= 3 {
+			// the server stalls after two replies
+			return ""
+		}
+		return ":" + args[1] + "\r\n"
+	})
+	c := dialFake(t, s)
+	for i := 1; i <= 4; i++ {
+		c.PipeSend("ECHO", i)
+	}
+	ret, e := c.PipeExecDeadline(time.Now().Add(50 * time.Millisecond))
+	pe, ok := e.(*PartialError)
+	if !ok || pe.Completed != 2 || pe.Total != 4 {
+		t.Fatalf("got %v", e)
+	}
+	if ne, ok := pe.Unwrap().(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("cause %v", pe.Err)
+	}
+	if ret[0].(int64) != 1 || ret[1].(int64) != 2 || ret[2] != nil {
+		t.Fatalf("replies %v", ret)
+	}
+	if !c.Broken() {
+		t.Fatal("conn not broken")
+	}
+}
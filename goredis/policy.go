@@ -0,0 +1,144 @@
+package msgredis
+
+import (
+	"strings"
+)
+
+// PolicyError is returned when a Policy rejects a command, it was not sent
+type PolicyError struct {
+	Command string
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return CommonErrPrefix + "policy denied " + e.Command + ": " + e.Reason
+}
+
+// PolicyCall is the command a Policy inspects. A policy may rewrite it in
+// place: change Command or Args, or replace keys with SetKey.
+type PolicyCall struct {
+	Command string
+	Args    []interface{}
+	// keys as found by the registry, empty for unknown commands
+	Keys []string
+	// the registry knows where the keys of Command are. False for unknown
+	// commands and for keyless ones such as PING or KEYS.
+	KeysResolved bool
+	keyPos       []int
+}
+
+// SetKey replaces the i-th key in Keys and Args, e.g. to add a tenant prefix
+func (pc *PolicyCall) SetKey(i int, key string) {
+	pc.Keys[i] = key
+	pc.Args[pc.keyPos[i]] = key
+}
+
+// Policy is invoked before each command. A non nil error rejects it, use
+// PolicyError for the reason.
+type Policy func(pc *PolicyCall) error
+
+// AddPolicy appends policies run in order before every Call and PipeSend
+func (c *Conn) AddPolicy(policies ...Policy) {
+	c.policies = append(c.policies, policies...)
+}
+
+// applyPolicies returns the command as rewritten by the policies. Args are
+// copied before the first policy so callers' slices are never modified.
+func (c *Conn) applyPolicies(command string, args []interface{}) (string, []interface{}, error) {
+	if len(c.policies) == 0 {
+		return command, args, nil
+	}
+	pc := &PolicyCall{Command: command, Args: append([]interface{}(nil), args...)}
+	if ci := LookupCommand(command); ci != nil && ci.hasKeys() {
+		pc.KeysResolved = true
+		pc.keyPos = ci.KeyPositions(pc.Args)
+		pc.Keys = make([]string, len(pc.keyPos))
+		for i, pos := range pc.keyPos {
+			pc.Keys[i] = argString(pc.Args[pos])
+		}
+	}
+	for _, policy := range c.policies {
+		if e := policy(pc); e != nil {
+			return command, args, e
+		}
+	}
+	return pc.Command, pc.Args, nil
+}
+
+// DenyCommands rejects the given commands, e.g. KEYS and FLUSHALL in production
+func DenyCommands(commands ...string) Policy {
+	deny := make(map[string]bool, len(commands))
+	for _, name := range commands {
+		deny[strings.ToUpper(name)] = true
+	}
+	return func(pc *PolicyCall) error {
+		if name := strings.ToUpper(pc.Command); deny[name] {
+			return &PolicyError{Command: name, Reason: "command denied"}
+		}
+		return nil
+	}
+}
+
+// keyless commands touching no data, always let through by the key policies
+var keylessAllowed = []string{
+	"PING", "ECHO", "AUTH", "HELLO", "CLIENT", "SELECT", "RESET", "QUIT",
+	"MULTI", "EXEC", "DISCARD", "UNWATCH", "ASKING", "READONLY", "READWRITE",
+	"TIME", "WAIT",
+}
+
+// checkResolved fails closed: a command whose keys the registry cannot find
+// may touch any key, it passes only if allowed
+func checkResolved(allow map[string]bool) Policy {
+	return func(pc *PolicyCall) error {
+		name := strings.ToUpper(pc.Command)
+		if !pc.KeysResolved && !allow[name] {
+			return &PolicyError{Command: name, Reason: "keys cannot be resolved"}
+		}
+		return nil
+	}
+}
+
+func keylessAllowlist(allow []string) map[string]bool {
+	m := make(map[string]bool, len(keylessAllowed)+len(allow))
+	for _, name := range keylessAllowed {
+		m[name] = true
+	}
+	for _, name := range allow {
+		m[strings.ToUpper(name)] = true
+	}
+	return m
+}
+
+// RequireKeyPrefix rejects commands touching keys outside prefix. Commands
+// whose keys the registry cannot find (unknown ones, KEYS, SCAN, FLUSHALL...)
+// are rejected too, except session commands such as PING or AUTH and the
+// allow list; register module commands with RegisterCommand.
+func RequireKeyPrefix(prefix string, allow ...string) Policy {
+	resolved := checkResolved(keylessAllowlist(allow))
+	return func(pc *PolicyCall) error {
+		if e := resolved(pc); e != nil {
+			return e
+		}
+		for _, key := range pc.Keys {
+			if !strings.HasPrefix(key, prefix) {
+				return &PolicyError{Command: strings.ToUpper(pc.Command), Reason: "key " + key + " outside " + prefix}
+			}
+		}
+		return nil
+	}
+}
+
+// TenantPrefix rewrites every key to prefix+key. Like RequireKeyPrefix it
+// rejects commands whose keys cannot be found, except the allow list.
+func TenantPrefix(prefix string, allow ...string) Policy {
+	resolved := checkResolved(keylessAllowlist(allow))
+	return func(pc *PolicyCall) error {
+		if e := resolved(pc); e != nil {
+			return e
+		}
+		for i, key := range pc.Keys {
+			pc.SetKey(i, prefix+key)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestKeyPositions(t *testing.T) {
+	cases := []struct {
+		command string
+		args    []interface{}
+		want    []int
+	}{
+		{"GET", []interface{}{"k"}, []int{0}},
+		{"MSET", []interface{}{"a", 1, "b", 2}, []int{0, 2}},
+		{"DEL", []interface{}{"a", "b", "c"}, []int{0, 1, 2}},
+		{"EVAL", []interface{}{"return 1", 2, "a", "b", "x"}, []int{2, 3}},
+		{"PING", nil, nil},
+		{"XREAD", []interface{}{"COUNT", 1, "STREAMS", "a", "b", "0", "0"}, []int{3, 4}},
+		// a group named streams is not the keyword
+		{"XREADGROUP", []interface{}{"GROUP", "streams", "c", "STREAMS", "a", ">"}, []int{4}},
+	}
+	for _, tc := range cases {
+		got := LookupCommand(tc.command).KeyPositions(tc.args)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: %v", tc.command, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: %v", tc.command, got)
+			}
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	var sent []string
+	s := newFakeServer(t, func(args []string) string {
+		sent = args
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	c.AddPolicy(DenyCommands("keys", "FLUSHALL"), TenantPrefix("t1:"), RequireKeyPrefix("t1:"))
+
+	args := []interface{}{"a", "1", "b", "2"}
+	if _, e := c.Call("MSET", args...); e != nil {
+		t.Fatal(e)
+	}
+	if sent[1] != "t1:a" || sent[2] != "1" || sent[3] != "t1:b" {
+		t.Fatalf("sent %v", sent)
+	}
+	if args[0] != "a" {
+		t.Fatal("caller args modified")
+	}
+	if _, e := c.Call("KEYS", "*"); e == nil {
+		t.Fatal("KEYS allowed")
+	} else if pe, ok := e.(*PolicyError); !ok || pe.Command != "KEYS" {
+		t.Fatalf("got %v", e)
+	}
+
+	c = dialFake(t, s)
+	c.AddPolicy(RequireKeyPrefix("t1:"))
+	if e := c.PipeSend("GET", "t2:x"); e == nil {
+		t.Fatal("foreign key allowed")
+	}
+	if _, e := c.Call("GET", "t1:x"); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestPolicyUnresolvedKeys(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	c.AddPolicy(TenantPrefix("t1:", "dbsize"))
+	for _, args := range [][]interface{}{
+		{"MYMODULE.GET", "t2:k"},
+		{"KEYS", "*"},
+		{"FLUSHALL"},
+	} {
+		_, e := c.Call(args[0].(string), args[1:]...)
+		if pe, ok := e.(*PolicyError); !ok || pe.Reason != "keys cannot be resolved" {
+			t.Fatalf("%v: %v", args, e)
+		}
+	}
+	for _, command := range []string{"PING", "DBSIZE", "MULTI"} {
+		if _, e := c.Call(command); e != nil {
+			t.Fatalf("%s: %v", command, e)
+		}
+	}
+	c = dialFake(t, newFakeServer(t, okHandler))
+	c.AddPolicy(RequireKeyPrefix("t1:"))
+	// the streams of XREAD are keys too
+	_, e := c.Call("XREAD", "COUNT", "1", "STREAMS", "t1:s", "t2:s", "0", "0")
+	if pe, ok := e.(*PolicyError); !ok || pe.Reason != "key t2:s outside t1:" {
+		t.Fatalf("xread: %v", e)
+	}
+	if _, e := c.Call("SCAN", "0"); e == nil {
+		t.Fatal("SCAN allowed")
+	}
+}
@@ -1,10 +1,14 @@
 package msgredis
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,28 +18,45 @@ const ()
 type MultiPool struct {
 	pools   map[string]*Pool
 	servers []string
+
+	// shard ejection, see eject.go
+	EjectErrorRate float64
+	EjectMinCalls  int64
+	EjectDuration  time.Duration
+	healthMu       sync.Mutex
+	health         map[string]*shardHealth
 }
 
 //
 func NewMultiPool(addresses []string) *MultiPool {
-	pools := make(map[string]*Pool, len(addresses))
+	mp := &MultiPool{
+		pools:          make(map[string]*Pool, len(addresses)),
+		servers:        make([]string, 0, len(addresses)),
+		EjectErrorRate: DefaultEjectErrorRate,
+		EjectMinCalls:  DefaultEjectMinCalls,
+		EjectDuration:  DefaultEjectDuration,
+		health:         make(map[string]*shardHealth, len(addresses)),
+	}
 	for _, addr := range addresses {
 		addrPass := strings.Split(addr, "@")
+		var p *Pool
 		if len(addrPass) == 2 {
 			// redis need auth
-			pools[addrPass[0]] = NewPool(addrPass[0], addrPass[1])
+			p = NewPool(addrPass[0], addrPass[1])
 		} else if len(addrPass) == 1 {
 			// redis do not need auth
-			pools[addr] = NewPool(addrPass[0], "")
+			p = NewPool(addrPass[0], "")
 		} else {
 			fmt.Println("invalid address format:should 1.1.1.1:1100 or 1.1.1.1:1100@123")
+			continue
 		}
+		h := &shardHealth{}
+		p.Hooks = append(p.Hooks, h)
+		mp.pools[addrPass[0]] = p
+		mp.servers = append(mp.servers, addrPass[0])
+		mp.health[addrPass[0]] = h
 	}
-
-	return &MultiPool{
-		pools:   pools,
-		servers: addresses,
-	}
+	return mp
 }
 
 // get conn by address directly
@@ -55,23 +76,32 @@ func (mp *MultiPool) PushByAddr(addr string, c *Conn) {
 	mp.pools[addr].Push(c)
 }
 
-// sum(key)/len(pools)
+// sum(key)%len(servers), ejected shards are skipped
 func (mp *MultiPool) PopByKey(key string) *Conn {
-	addr := mp.servers[Sum(key)/len(mp.pools)]
-	if _, ok := mp.pools[addr]; !ok {
-		fmt.Println("[PopByKey] invalid address:" + addr)
+	if len(mp.servers) == 0 {
+		fmt.Println("[PopByKey] no servers")
 		return nil
 	}
-	return mp.pools[addr].Pop()
+	addr := mp.AddrByKey(key)
+	c := mp.pools[addr].Pop()
+	if c == nil {
+		mp.reportFailure(addr)
+	}
+	return c
 }
 
+// conns go back to the pool they were popped from, even if the shard of
+// key has been ejected or re-admitted in between
 func (mp *MultiPool) PushByKey(key string, c *Conn) {
-	addr := mp.servers[Sum(key)/len(mp.pools)]
-	if _, ok := mp.pools[addr]; !ok {
-		fmt.Println("[PushByKey] invalid address:" + addr)
+	if c != nil && c.pool != nil {
+		c.pool.Push(c)
 		return
 	}
-	mp.pools[addr].Push(c)
+	if len(mp.servers) == 0 {
+		fmt.Println("[PushByKey] no servers")
+		return
+	}
+	mp.pools[mp.AddrByKey(key)].Push(c)
 }
 
 const (
@@ -81,100 +111,225 @@ const (
 	MaxIdleSeconds = 28
 )
 
-// connection pool of only one redis server
+// connection pool of only one redis server. Idle conns are kept in one
+// free list per GOMAXPROCS, Pop and Push start at the next list round robin
+// and steal from the others, so checkouts do not queue on a single lock.
+// The single ClientPool channel is gone with it, use Pop and Push.
 type Pool struct {
-	Address    string
-	Password   string
-	IdleNum    int
-	ActiveNum  int
-	ClientPool chan *Conn
-	mu         sync.RWMutex
+	Address  string
+	Password string
+	// updated atomically, read them with Idles and Actives
+	IdleNum   int64
+	ActiveNum int64
+	shards    []chan *Conn
+	next      uint32
 
 	CallNum int64
 	callMu  sync.RWMutex
 
 	CallConsume map[string]int
+	latency     LatencyHistogram
+	cmdStats    commandStats
+
+	// installed on every new conn
+	Hooks    []Hook
+	Redactor *Redactor
+	// see Conn.SetConcurrencyCheck
+	ConcurrencyCheck bool
+	// see Conn.SetFloatFormat, zero keeps the default
+	FloatFormat    byte
+	FloatPrecision int
+	// see Conn.SetBusyScriptKill
+	KillBusyScripts bool
+	// see Conn.SetStrict
+	Strict bool
+	// see Conn.SetMaxReplySize
+	MaxReplySize int64
+	// see Conn.SetAutoChunk, opt-in as split commands are not atomic
+	AutoChunk int
+	// see Conn.SetRenameCommands
+	RenameCommands map[string]string
+	// see Conn.SetReadOnly
+	ReadOnly bool
+	// see Conn.AddPolicy
+	Policies []Policy
+	// detect the server capabilities on the first dial and refuse commands
+	// the server is too old for with UnsupportedError
+	CheckCapabilities bool
+	// see Conn.SetEmulation
+	EmulateCommands bool
+	caps            poolCaps
+	// ask new conns for their CLIENT ID, see KillOtherSessions; a conn
+	// that cannot tell fails Pop
+	TrackClientIDs bool
+	clients        clientIDs
+	// Diagnose reports older servers
+	MinVersion Version
+	// see Conn.SetWatchdog
+	Watchdog        time.Duration
+	WatchdogActions WatchdogAction
+	// see InFlight and Queued
+	inflight int64
+	queued   int64
+	// set by Shutdown
+	closing int32
+	// consulted on every new conn instead of Password, see RotateCredentials
+	Credentials CredentialsProvider
+	credGen     uint64
+	// see Conn.SetValueTransformer
+	ValueTransformer  ValueTransformer
+	TransformPrefixes []string
+	// see Conn.SetProxyCompat
+	ProxyCompat bool
+	// see Conn.SetClusterMode
+	ClusterMode bool
+	// shared by the conns, see Conn.SetMicroCache
+	MicroCache *MicroCache
+	// unix nanoseconds, see Rotate
+	lastRotation int64
+	// guards Address, see Repoint
+	addrMu sync.RWMutex
+	// new conns dial TLS with it, see DialTLS
+	TLSConfig *tls.Config
 }
 
 func NewPool(address, password string) *Pool {
-	return &Pool{
-		Address:    address,
-		Password:   password,
-		IdleNum:    0,
-		ActiveNum:  0,
-		ClientPool: make(chan *Conn, MaxConnNum),
+	n := runtime.GOMAXPROCS(0)
+	if n > MaxConnNum {
+		n = MaxConnNum
+	}
+	p := &Pool{
+		Address:  address,
+		Password: password,
+		shards:   make([]chan *Conn, n),
+	}
+	for i := range p.shards {
+		p.shards[i] = make(chan *Conn, (MaxConnNum+n-1)/n)
+	}
+	return p
+}
+
+// take returns an idle conn, nil if all free lists are empty
+func (p *Pool) take() *Conn {
+	n := uint32(len(p.shards))
+	start := atomic.AddUint32(&p.next, 1)
+	for i := uint32(0); i < n; i++ {
+		select {
+		case c := <-p.shards[(start+i)%n]:
+			return c
+		default:
+		}
 	}
+	return nil
+}
+
+// put returns false if all free lists are full
+func (p *Pool) put(c *Conn) bool {
+	n := uint32(len(p.shards))
+	start := atomic.AddUint32(&p.next, 1)
+	for i := uint32(0); i < n; i++ {
+		select {
+		case p.shards[(start+i)%n] <- c:
+			return true
+		default:
+		}
+	}
+	return false
 }
 
 // TODO: add timeout
 func (p *Pool) Pop() *Conn {
+	c, _ := p.pop(context.Background())
+	return c
+}
+
+func (p *Pool) pop(ctx context.Context) (*Conn, error) {
 	var waitSeconds = 5
-	var c *Conn
-PopLoop:
 	for {
-		select {
-		case c = <-p.ClientPool:
-			// fmt.Println("[Pop] in case")
-			if time.Now().Unix()-c.lastActiveTime > MaxIdleSeconds {
-				if c.IsAlive() {
-					p.mu.Lock()
-					p.IdleNum--
-					p.ActiveNum++
-					p.mu.Unlock()
-					break PopLoop
-				}
+		if p.closed() {
+			fmt.Println("[Pop] pool shut down")
+			return nil, ErrNoConn
+		}
+		if c := p.take(); c != nil {
+			c.setIdle(false)
+			atomic.AddInt64(&p.IdleNum, -1)
+			if time.Now().Unix()-c.lastActiveTime > MaxIdleSeconds && !c.IsAlive() {
 				c.Close()
-				p.mu.Lock()
-				p.IdleNum--
-				p.mu.Unlock()
 				fmt.Println("[Pop] lastActiveTime exceed 30s")
-				break
+				continue
 			}
-			// if !c.IsAlive() {
-			// 	p.mu.Lock()
-			// 	p.IdleNum--
-			// 	p.mu.Unlock()
-			// 	c.Close()
-			// 	fmt.Println("[Pop] not alive")
-			// 	break
-			// }
-			p.mu.Lock()
-			p.IdleNum--
-			p.ActiveNum++
-			p.mu.Unlock()
-			break PopLoop
-		default:
-			// fmt.Println("[Pop] in default")
-			p.mu.RLock()
-			if p.IdleNum+p.ActiveNum >= MaxConnNum {
-				p.mu.RUnlock()
-				// fmt.Println("waiting................")
-				if waitSeconds <= 0 {
-					break PopLoop
-				}
-				waitSeconds--
-				fmt.Println("[Pop] max wait 1s")
-				time.Sleep(1e9)
-				break
+			if p.staleCredentials(c) {
+				c.Close()
+				continue
+			}
+			atomic.AddInt64(&p.ActiveNum, 1)
+			return c, nil
+		}
+		// reserve a slot before dialing
+		if atomic.AddInt64(&p.ActiveNum, 1)+atomic.LoadInt64(&p.IdleNum) > MaxConnNum {
+			atomic.AddInt64(&p.ActiveNum, -1)
+			if waitSeconds <= 0 {
+				return nil, ErrNoConn
 			}
-			p.mu.RUnlock()
-
-			p.mu.Lock()
-			p.ActiveNum++
-			p.mu.Unlock()
-			c, e := Dial(p.Address, p.Password, ConnectTimeout, ReadTimeout, WriteTimeout, true, p)
-			if e != nil {
-				p.mu.Lock()
-				p.ActiveNum--
-				p.mu.Unlock()
-				fmt.Println(e.Error())
-				break PopLoop
+			waitSeconds--
+			fmt.Println("[Pop] max wait 1s")
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
 			}
+			continue
+		}
+		c, e := p.dial(ctx)
+		if e != nil {
+			atomic.AddInt64(&p.ActiveNum, -1)
+			fmt.Println(e.Error())
+			return nil, e
+		}
+		if e = p.configure(c); e != nil {
+			c.Close()
+			atomic.AddInt64(&p.ActiveNum, -1)
+			fmt.Println("[Pop] " + e.Error())
+			return nil, e
+		}
+		return c, nil
+	}
+}
 
-			p.Push(c)
+// configure applies the pool options to one of its conns, it fails only
+// if the conn cannot be tracked
+func (p *Pool) configure(c *Conn) error {
+	c.policies = nil
+	c.hooks = p.Hooks
+	c.redactor = p.Redactor
+	c.checkOwner = p.ConcurrencyCheck
+	c.SetFloatFormat(p.FloatFormat, p.FloatPrecision)
+	c.killBusy = p.KillBusyScripts
+	c.strict = p.Strict
+	c.maxReply = p.MaxReplySize
+	c.chunkArgs = p.AutoChunk
+	c.SetRenameCommands(p.RenameCommands)
+	c.readOnly = p.ReadOnly
+	c.AddPolicy(p.Policies...)
+	c.emulate = p.EmulateCommands
+	c.SetValueTransformer(p.ValueTransformer, p.TransformPrefixes...)
+	c.proxyCompat = p.ProxyCompat
+	c.clusterMode = p.ClusterMode
+	c.microCache = p.MicroCache
+	if p.TrackClientIDs {
+		if e := p.registerClient(c); e != nil {
+			return e
 		}
 	}
-	return c
+	if e := c.SetWatchdog(p.Watchdog, p.WatchdogActions); e != nil {
+		fmt.Println("[Pop] watchdog: " + e.Error())
+	}
+	if p.CheckCapabilities && p.cachedCaps() == nil {
+		if _, e := p.detect(c); e != nil {
+			fmt.Println("[Pop] capabilities: " + e.Error())
+		}
+	}
+	return nil
 }
 
 func (p *Pool) Push(c *Conn) {
@@ -182,51 +337,54 @@ func (p *Pool) Push(c *Conn) {
 		fmt.Println("[Push] c == nil")
 		return
 	}
-	// if !c.IsAlive() {
-	// 	p.mu.Lock()
-	// 	p.ActiveNum--
-	// 	p.mu.Unlock()
-	// 	c.Close()
-	// 	fmt.Println("[Push] not alive")
-	// 	return
-	// }
-	select {
-	case p.ClientPool <- c:
-		p.mu.Lock()
-		p.IdleNum++
-		p.ActiveNum--
-		p.mu.Unlock()
-		// fmt.Println("[Push] success")
-	default:
+	if p.closed() {
+		p.drain(c)
+		return
+	}
+	if p.staleCredentials(c) {
+		c.Close()
+		atomic.AddInt64(&p.ActiveNum, -1)
+		return
+	}
+	if !c.broken && c.recoverState() != nil {
+		c.broken = true
+	}
+	if c.broken {
 		c.Close()
-		fmt.Println("[Push] discard")
-		// discard
+		atomic.AddInt64(&p.ActiveNum, -1)
+		fmt.Println("[Push] discard broken conn")
+		return
 	}
+	// the helpers of the next borrower may keep what they read
+	c.decodeMode = DecodeCopy
+	c.resetArena()
+	c.trace = nil
+	c.setIdle(true)
+	atomic.AddInt64(&p.IdleNum, 1)
+	if p.put(c) {
+		atomic.AddInt64(&p.ActiveNum, -1)
+		// fmt.Println("[Push] success")
+		return
+	}
+	atomic.AddInt64(&p.IdleNum, -1)
+	c.setIdle(false)
+	c.Close()
+	fmt.Println("[Push] discard")
+	// discard
 }
 
 func (p *Pool) Actives() int {
-	var n int
-	p.mu.RLock()
-	n = p.ActiveNum
-	p.mu.RUnlock()
-	return n
+	return int(atomic.LoadInt64(&p.ActiveNum))
 }
 
 func (p *Pool) Idles() int {
-	var n int
-	p.mu.RLock()
-	n = p.IdleNum
-	p.mu.RUnlock()
-	return n
+	return int(atomic.LoadInt64(&p.IdleNum))
 }
 
 // 返回string，根据需要可能会修改返回值类型，如果info包含其他信息
 func (p *Pool) PoolInfo() string {
-	var IdleN, ActiveN int
-	p.mu.RLock()
-	IdleN = p.IdleNum
-	ActiveN = p.IdleNum
-	p.mu.RUnlock()
+	IdleN := p.Idles()
+	ActiveN := p.Idles()
 	return "ActiveNum=" + strconv.Itoa(ActiveN) + "\n IdleNum=" + strconv.Itoa(IdleN) + " \n"
 }
 
@@ -262,6 +420,38 @@ func (p *Pool) QPSAvg() int64 {
 	return qps[3]
 }
 
+type PoolStats struct {
+	Addr     string
+	Actives  int
+	Idles    int
+	Calls    int64
+	InFlight int64
+	Queued   int64
+	Latency  LatencySnapshot
+}
+
+// snapshot of the pool counters and the command latency percentiles
+func (p *Pool) Stats() PoolStats {
+	s := PoolStats{Addr: p.address(), Latency: p.latency.Snapshot()}
+	s.Actives = p.Actives()
+	s.Idles = p.Idles()
+	s.InFlight = p.InFlight()
+	s.Queued = p.Queued()
+	p.callMu.RLock()
+	s.Calls = p.CallNum
+	p.callMu.RUnlock()
+	return s
+}
+
+// per address stats
+func (mp *MultiPool) Stats() map[string]PoolStats {
+	stats := make(map[string]PoolStats, len(mp.pools))
+	for addr, p := range mp.pools {
+		stats[addr] = p.Stats()
+	}
+	return stats
+}
+
 // 哈希算法
 func Sum(key string) int {
 	var hash uint32 = 0
@@ -0,0 +1,46 @@
+package msgredis
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPoolShards(t *testing.T) {
+	s := newFakeServer(t, okHandler)
+	p := NewPool(s.Addr(), "")
+
+	// a conn pushed to any free list is found again
+	for i := 0; i < 10; i++ {
+		c := p.Pop()
+		if c == nil {
+			t.Fatal("no conn")
+		}
+		p.Push(c)
+	}
+	if p.Idles() != 1 || p.Actives() != 0 {
+		t.Fatalf("idles=%d actives=%d", p.Idles(), p.Actives())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				c := p.Pop()
+				if c == nil {
+					t.Error("no conn")
+					return
+				}
+				if _, e := c.Call("SET", "k", "v"); e != nil {
+					t.Error(e)
+				}
+				p.Push(c)
+			}
+		}()
+	}
+	wg.Wait()
+	if p.Actives() != 0 || p.Idles() < 1 || p.Idles() > 20 {
+		t.Fatalf("idles=%d actives=%d", p.Idles(), p.Actives())
+	}
+}
@@ -0,0 +1,173 @@
+package msgredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// heartbeats every TTL/3 at millisecond scores
+var ErrBadPresenceTTL = errors.New(CommonErrPrefix + "presence ttl must be at least 3ms")
+
+// Presence is a liveness registry: members heartbeat their last-seen time
+// into a sorted set and count as live until TTL passes without one, so
+// crashed instances drop out on their own.
+type Presence struct {
+	Key string
+	TTL time.Duration
+
+	p *Pool
+}
+
+func NewPresence(p *Pool, key string, ttl time.Duration) *Presence {
+	return &Presence{Key: key, TTL: ttl, p: p}
+}
+
+// Registration refreshes a member until Leave
+type Registration struct {
+	pr       *Presence
+	id       string
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (pr *Presence) heartbeat(id string) error {
+	c := pr.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer pr.p.Push(c)
+	_, e := c.Call("ZADD", pr.Key, nowMillis(), id)
+	return e
+}
+
+// Register adds id and refreshes it every TTL/3 in the background
+func (pr *Presence) Register(id string) (*Registration, error) {
+	if pr.TTL < 3*time.Millisecond {
+		return nil, ErrBadPresenceTTL
+	}
+	if e := pr.heartbeat(id); e != nil {
+		return nil, e
+	}
+	r := &Registration{pr: pr, id: id, stop: make(chan struct{}), done: make(chan struct{})}
+	go r.refresh()
+	return r, nil
+}
+
+func (r *Registration) refresh() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.pr.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			// a missed beat is retried on the next tick, two more fit in TTL
+			if e := r.pr.heartbeat(r.id); e != nil {
+				fmt.Println("[Presence] heartbeat failed:" + e.Error())
+			}
+		}
+	}
+}
+
+// Leave stops the heartbeat and removes the member at once
+func (r *Registration) Leave() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	c := r.pr.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer r.pr.p.Push(c)
+	_, e := c.Call("ZREM", r.pr.Key, r.id)
+	return e
+}
+
+// Members returns the live members sorted, expired ones are removed
+func (pr *Presence) Members() ([]string, error) {
+	c := pr.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer pr.p.Push(c)
+	deadline := strconv.FormatInt(nowMillis()-int64(pr.TTL/time.Millisecond), 10)
+	e := c.PipeSend("ZREMRANGEBYSCORE", pr.Key, "-inf", "("+deadline)
+	if e == nil {
+		e = c.PipeSend("ZRANGEBYSCORE", pr.Key, deadline, "+inf")
+	}
+	// read what was queued even after a failed PipeSend
+	ret, pe := c.pipeExecAll()
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, e
+	}
+	members, e := replyStrings(ret[1])
+	if e != nil {
+		return nil, e
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+type PresenceEvent struct {
+	Member string
+	// false when the member left or timed out
+	Joined bool
+}
+
+// Watch polls Members every interval and sends the joins and leaves since
+// the previous poll, members live at the first poll are reported as joins.
+// The channel is closed when ctx is done.
+func (pr *Presence) Watch(ctx context.Context, interval time.Duration) <-chan PresenceEvent {
+	ch := make(chan PresenceEvent)
+	go func() {
+		defer close(ch)
+		live := map[string]bool{}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			members, e := pr.Members()
+			if e == nil {
+				events := []PresenceEvent{}
+				now := make(map[string]bool, len(members))
+				for _, m := range members {
+					now[m] = true
+					if !live[m] {
+						events = append(events, PresenceEvent{Member: m, Joined: true})
+					}
+				}
+				for m := range live {
+					if !now[m] {
+						events = append(events, PresenceEvent{Member: m})
+					}
+				}
+				live = now
+				for _, ev := range events {
+					select {
+					case ch <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,104 @@
+package msgredis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sorted set of one key, enough for ZADD/ZREM/ZREMRANGEBYSCORE/ZRANGEBYSCORE
+func zsetHandler() func(args []string) string {
+	var mu sync.Mutex
+	zset := map[string]int64{}
+	return func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		bound := func(s string) int64 {
+			n, _ := strconv.ParseInt(strings.TrimPrefix(s, "("), 10, 64)
+			return n
+		}
+		switch args[0] {
+		case "ZADD":
+			n, _ := strconv.ParseInt(args[2], 10, 64)
+			zset[args[3]] = n
+			return ":1\r\n"
+		case "ZREM":
+			delete(zset, args[2])
+			return ":1\r\n"
+		case "ZREMRANGEBYSCORE":
+			max := bound(args[3])
+			for m, score := range zset {
+				if score < max {
+					delete(zset, m)
+				}
+			}
+			return ":0\r\n"
+		case "ZRANGEBYSCORE":
+			min := bound(args[2])
+			members := []string{}
+			for m, score := range zset {
+				if score >= min {
+					members = append(members, m)
+				}
+			}
+			return respArray(members...)
+		}
+		return "-ERR unknown\r\n"
+	}
+}
+
+func TestPresence(t *testing.T) {
+	s := newFakeServer(t, zsetHandler())
+	pr := NewPresence(NewPool(s.Addr(), ""), "svc", 60*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := pr.Watch(ctx, 10*time.Millisecond)
+
+	a, e := pr.Register("a")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ev := <-events; ev != (PresenceEvent{Member: "a", Joined: true}) {
+		t.Fatalf("got %+v", ev)
+	}
+	// b never refreshes and times out
+	if e = pr.heartbeat("b"); e != nil {
+		t.Fatal(e)
+	}
+	if ev := <-events; ev != (PresenceEvent{Member: "b", Joined: true}) {
+		t.Fatalf("got %+v", ev)
+	}
+	if ev := <-events; ev != (PresenceEvent{Member: "b"}) {
+		t.Fatalf("got %+v", ev)
+	}
+	// a outlived several TTLs through its heartbeat
+	if members, _ := pr.Members(); len(members) != 1 || members[0] != "a" {
+		t.Fatalf("members %v", members)
+	}
+	if e = a.Leave(); e != nil {
+		t.Fatal(e)
+	}
+	if ev := <-events; ev != (PresenceEvent{Member: "a"}) {
+		t.Fatalf("got %+v", ev)
+	}
+}
+
+func TestPresenceErrors(t *testing.T) {
+	p := NewPool(newFakeServer(t, func(args []string) string {
+		if args[0] == "ZREMRANGEBYSCORE" {
+			return "-READONLY You can't write against a read only replica.\r\n"
+		}
+		return "*0\r\n"
+	}).Addr(), "")
+	if _, e := NewPresence(p, "live", time.Nanosecond).Register("a"); e != ErrBadPresenceTTL {
+		t.Fatalf("register: %v", e)
+	}
+	// the failed cleanup is reported even though the read succeeded
+	if m, e := NewPresence(p, "live", time.Second).Members(); e == nil || !isReplyError(e) {
+		t.Fatalf("members %v %v", m, e)
+	}
+}
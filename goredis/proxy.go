@@ -0,0 +1,108 @@
+package msgredis
+
+import (
+	"sort"
+	"strings"
+)
+
+// ProxyError is returned in proxy-compat mode for a command sharding
+// proxies such as twemproxy or Envoy do not forward, it was not sent
+type ProxyError struct {
+	Command string
+}
+
+func (e *ProxyError) Error() string {
+	return CommonErrPrefix + e.Command + " is not supported behind a RESP proxy"
+}
+
+// commands that need one server session or see the whole keyspace
+var proxyUnsupported = map[string]bool{
+	// a db per session
+	"SELECT": true,
+	"SWAPDB": true,
+	"MOVE":   true,
+	// transactions span shards
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+	"UNWATCH": true,
+	// pub/sub and session protocol
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"SSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"SUNSUBSCRIBE": true,
+	"PUBLISH":      true,
+	"HELLO":        true,
+	"RESET":        true,
+	"CLIENT":       true,
+	"MONITOR":      true,
+	"WAIT":         true,
+	// keyspace and server wide commands
+	"KEYS":         true,
+	"SCAN":         true,
+	"RANDOMKEY":    true,
+	"DBSIZE":       true,
+	"FLUSHDB":      true,
+	"FLUSHALL":     true,
+	"CONFIG":       true,
+	"SCRIPT":       true,
+	"FUNCTION":     true,
+	"BGSAVE":       true,
+	"BGREWRITEAOF": true,
+	"SAVE":         true,
+	"LASTSAVE":     true,
+	"SHUTDOWN":     true,
+	"CLUSTER":      true,
+	"ASKING":       true,
+	"MODULE":       true,
+	"ACL":          true,
+	"SLOWLOG":      true,
+	"LATENCY":      true,
+	"MEMORY":       true,
+	"OBJECT":       true,
+	// blocking reads hold a proxy conn shared by many clients
+	"BLPOP":      true,
+	"BRPOP":      true,
+	"BLMOVE":     true,
+	"BRPOPLPUSH": true,
+	"BZPOPMIN":   true,
+	"BZPOPMAX":   true,
+	"BLMPOP":     true,
+	"BZMPOP":     true,
+	"XREAD":      true,
+	"XREADGROUP": true,
+}
+
+// ProxySupports reports whether command can be sent in proxy-compat mode
+func ProxySupports(command string) bool {
+	return !proxyUnsupported[strings.ToUpper(command)]
+}
+
+// ProxyUnsupportedCommands lists the commands proxy-compat mode refuses
+func ProxyUnsupportedCommands() []string {
+	names := make([]string, 0, len(proxyUnsupported))
+	for name := range proxyUnsupported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProxyCompat prepares the conn for a sharding RESP proxy: commands in
+// ProxyUnsupportedCommands fail with ProxyError before being sent, CallN
+// no longer retries on a fresh pool conn, as the proxy may route the
+// retry to another shard, and pools do not RESET conns, as no session
+// state can be left behind. Pipelines and EVAL with keys still work.
+func (c *Conn) SetProxyCompat(on bool) {
+	c.proxyCompat = on
+}
+
+func (c *Conn) checkProxy(command string) error {
+	if c.proxyCompat && !ProxySupports(command) {
+		return &ProxyError{Command: strings.ToUpper(command)}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package msgredis
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestProxyCompat(t *testing.T) {
+	var sent int32
+	s := newFakeServer(t, func(args []string) string {
+		atomic.AddInt32(&sent, 1)
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	p.ProxyCompat = true
+	c := p.Pop()
+	defer p.Push(c)
+
+	for _, cmd := range []string{"select", "MULTI", "KEYS", "blpop"} {
+		if _, e := c.Call(cmd, "x"); e == nil {
+			t.Fatalf("%s sent", cmd)
+		} else if pe, ok := e.(*ProxyError); !ok {
+			t.Fatalf("%s: %v", cmd, e)
+		} else if ProxySupports(pe.Command) {
+			t.Fatalf("%s reported supported", pe.Command)
+		}
+	}
+	if e := c.PipeSend("FLUSHALL"); e == nil {
+		t.Fatal("FLUSHALL pipelined")
+	}
+	if _, e := c.Call("SET", "k", "v"); e != nil {
+		t.Fatal(e)
+	}
+	if sent != 1 {
+		t.Fatalf("%d commands reached the proxy", sent)
+	}
+	if x := c.Explain("SUBSCRIBE", "ch"); x.Err() == nil {
+		t.Fatal("explain found no problem")
+	}
+	if len(ProxyUnsupportedCommands()) == 0 || !ProxySupports("GET") {
+		t.Fatal("bad command list")
+	}
+}
@@ -0,0 +1,469 @@
+package msgredis
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPubSubBuffer is the capacity of the PubSubConn channel
+const DefaultPubSubBuffer = 100
+
+// Message is a message published to a subscribed channel
+type Message struct {
+	Channel string
+	// set when the message matched a PSUBSCRIBE pattern
+	Pattern string
+	Payload []byte
+}
+
+// Subscription confirms a (p|s)(un)subscribe, Count is the number of
+// channels and patterns the conn is still subscribed to, or of shard
+// channels for ssubscribe and sunsubscribe
+type Subscription struct {
+	// subscribe, unsubscribe, psubscribe, punsubscribe, ssubscribe,
+	// sunsubscribe
+	Kind    string
+	Channel string
+	Count   int64
+}
+
+// Pong answers PubSubConn.Ping
+type Pong struct {
+	Data string
+}
+
+// ReconnectEvent reports a PubSubConn that lost its conn and subscribed
+// again on a new one. Messages published in between are lost.
+type ReconnectEvent struct {
+	// the error that dropped the old conn
+	Err error
+	// dials it took, failed ones included
+	Attempts int
+	Channels []string
+	Patterns []string
+	// SSUBSCRIBE channels
+	ShardChannels []string
+}
+
+// waits between the redials of a PubSubConn, doubled up to the max
+const (
+	pubSubRedialMin = 100 * time.Millisecond
+	pubSubRedialMax = RetryWaitSeconds
+)
+
+// PubSubConn is a conn dedicated to pub/sub: a goroutine reads what the
+// server pushes and delivers it on Messages as Message, Subscription or
+// Pong values, and as *ReplyError for a refused command. Subscribe and the
+// other methods only send, their confirmations arrive on Messages. A
+// consumer falling behind fills the channel and then stops the reads, the
+// server buffers the rest up to its client-output-buffer-limit.
+//
+// When the conn drops, the PubSubConn redials until Close and subscribes
+// again to the channels, patterns and shard channels it had, see
+// OnReconnect.
+type PubSubConn struct {
+	// the current conn, replaced on reconnect; mu serializes the writes
+	// and the swap, the reader goroutine owns the reads
+	mu       sync.Mutex
+	c        *Conn
+	dial     func() (*Conn, error)
+	channels map[string]bool
+	patterns map[string]bool
+	shards   map[string]bool
+	// see OnReconnect
+	onReconnect func(ReconnectEvent)
+
+	// see Err
+	err error
+	// see Stats
+	stats pubSubStats
+	// see AddHook
+	hooks []Hook
+
+	messages chan interface{}
+	done     chan struct{}
+	closing  chan struct{}
+	once     sync.Once
+}
+
+// NewPubSubConn takes over c, which must not be used or pushed to a pool
+// anymore. buffer <= 0 takes DefaultPubSubBuffer. Reconnects dial the
+// address of c with its credentials, TLS, DB, client name and protocol.
+func NewPubSubConn(c *Conn, buffer int) *PubSubConn {
+	addr, opts := c.addr, c.dialOptions()
+	return newPubSubConn(c, buffer, func() (*Conn, error) {
+		return dialContext(context.Background(), addr, opts)
+	})
+}
+
+func newPubSubConn(c *Conn, buffer int, dial func() (*Conn, error)) *PubSubConn {
+	if buffer <= 0 {
+		buffer = DefaultPubSubBuffer
+	}
+	// values outlive the next read
+	c.decodeMode = DecodeCopy
+	ps := &PubSubConn{
+		c:        c,
+		dial:     dial,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+		shards:   make(map[string]bool),
+		messages: make(chan interface{}, buffer),
+		done:     make(chan struct{}),
+		closing:  make(chan struct{}),
+	}
+	go ps.receive()
+	return ps
+}
+
+func dialPubSub(address string, tlsConfig *tls.Config, cr Credentials) (*Conn, error) {
+	c, e := dialContext(context.Background(), address, DialOptions{
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    ReadTimeout,
+		WriteTimeout:   WriteTimeout,
+		KeepAlive:      true,
+		TLSConfig:      tlsConfig,
+	})
+	if e != nil {
+		return nil, e
+	}
+	if e = c.authenticate(cr); e != nil {
+		c.Close()
+		return nil, e
+	}
+	return c, nil
+}
+
+// PubSub dials a PubSubConn to the pool server with the pool credentials,
+// reconnects ask the pool for fresh ones. The conn is not counted by the
+// pool.
+func (p *Pool) PubSub(buffer int) (*PubSubConn, error) {
+	dial := func() (*Conn, error) {
+		cr, e := p.credentials()
+		if e != nil {
+			return nil, e
+		}
+		return dialPubSub(p.address(), p.TLSConfig, cr)
+	}
+	c, e := dial()
+	if e != nil {
+		return nil, e
+	}
+	return newPubSubConn(c, buffer, dial), nil
+}
+
+// OnReconnect sets a callback run on the reader goroutine after each
+// reconnect, once the subscriptions are sent again
+func (ps *PubSubConn) OnReconnect(fn func(ReconnectEvent)) {
+	ps.mu.Lock()
+	ps.onReconnect = fn
+	ps.mu.Unlock()
+}
+
+// Messages is closed by Close
+func (ps *PubSubConn) Messages() <-chan interface{} {
+	return ps.messages
+}
+
+// Err returns the error that dropped the last conn, nil if none did
+func (ps *PubSubConn) Err() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.err
+}
+
+func (ps *PubSubConn) Subscribe(channels ...string) error {
+	return ps.send("SUBSCRIBE", channels)
+}
+
+func (ps *PubSubConn) PSubscribe(patterns ...string) error {
+	return ps.send("PSUBSCRIBE", patterns)
+}
+
+// SSubscribe subscribes to shard channels (Redis 7), all of one slot on a
+// cluster
+func (ps *PubSubConn) SSubscribe(channels ...string) error {
+	return ps.send("SSUBSCRIBE", channels)
+}
+
+// SUnsubscribe without channels unsubscribes from all shard channels
+func (ps *PubSubConn) SUnsubscribe(channels ...string) error {
+	return ps.send("SUNSUBSCRIBE", channels)
+}
+
+// Unsubscribe without channels unsubscribes from all of them
+func (ps *PubSubConn) Unsubscribe(channels ...string) error {
+	return ps.send("UNSUBSCRIBE", channels)
+}
+
+// PUnsubscribe without patterns unsubscribes from all of them
+func (ps *PubSubConn) PUnsubscribe(patterns ...string) error {
+	return ps.send("PUNSUBSCRIBE", patterns)
+}
+
+// Ping checks the conn is alive, a Pong with data follows on Messages.
+// Long idle subscriptions should ping, NAT and proxies drop silent conns.
+func (ps *PubSubConn) Ping(data string) error {
+	if data == "" {
+		return ps.send("PING", nil)
+	}
+	return ps.send("PING", []string{data})
+}
+
+func (ps *PubSubConn) send(command string, args []string) error {
+	if strings.HasSuffix(command, "SUBSCRIBE") && !strings.HasSuffix(command, "UNSUBSCRIBE") && len(args) == 0 {
+		return ErrBadArgs
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if e := ps.c.checkProxy(command); e != nil {
+		return e
+	}
+	// tracked even if the write fails, the reconnect sends them
+	ps.track(command, args)
+	return ps.write(command, args)
+}
+
+// track updates the subscriptions to restore on reconnect
+func (ps *PubSubConn) track(command string, args []string) {
+	set := ps.channels
+	switch command {
+	case "PSUBSCRIBE", "PUNSUBSCRIBE":
+		set = ps.patterns
+	case "SSUBSCRIBE", "SUNSUBSCRIBE":
+		set = ps.shards
+	}
+	switch command {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		for _, name := range args {
+			set[name] = true
+		}
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE":
+		if len(args) == 0 {
+			for name := range set {
+				delete(set, name)
+			}
+		}
+		for _, name := range args {
+			delete(set, name)
+		}
+	}
+}
+
+// write sends a command on the current conn, mu held
+func (ps *PubSubConn) write(command string, args []string) error {
+	c := ps.c
+	if c.broken {
+		return ErrBrokenConn
+	}
+	var e error
+	if c.writeTimeout > 0 {
+		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+			return e
+		}
+	}
+	if e = c.writeRequest(command, Args{}.Add(args)); e == nil {
+		e = c.wb.Flush()
+	}
+	if e != nil {
+		c.broken = true
+	}
+	return e
+}
+
+// Close stops the reads and closes the conn, Messages is closed once the
+// reader goroutine is gone
+func (ps *PubSubConn) Close() error {
+	ps.once.Do(func() {
+		ps.mu.Lock()
+		close(ps.closing)
+		ps.c.Close()
+		ps.mu.Unlock()
+	})
+	<-ps.done
+	return nil
+}
+
+func (ps *PubSubConn) closed() bool {
+	select {
+	case <-ps.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ps *PubSubConn) receive() {
+	defer close(ps.done)
+	defer close(ps.messages)
+	ps.mu.Lock()
+	c := ps.c
+	ps.mu.Unlock()
+	for {
+		e := ps.read(c)
+		if ps.closed() {
+			return
+		}
+		if c = ps.reconnect(e); c == nil {
+			return
+		}
+	}
+}
+
+// read delivers what c receives until it fails
+func (ps *PubSubConn) read(c *Conn) error {
+	// a subscription waits for messages as long as it takes
+	if e := c.conn.SetReadDeadline(time.Time{}); e != nil {
+		return e
+	}
+	for {
+		v, e := c.readResponse()
+		var m interface{}
+		switch {
+		case isReplyError(e):
+			m = e
+		case e != nil:
+			return e
+		default:
+			if m = parsePubSub(v); m == nil {
+				continue
+			}
+		}
+		if !ps.deliver(m) {
+			return nil
+		}
+	}
+}
+
+// reconnect redials after cause until it succeeds or Close, and sends the
+// subscriptions again. It returns the new conn, nil when closed.
+func (ps *PubSubConn) reconnect(cause error) *Conn {
+	ps.mu.Lock()
+	ps.err = cause
+	ps.c.Close()
+	ps.mu.Unlock()
+	wait := pubSubRedialMin
+	for attempts := 1; ; attempts++ {
+		c, e := ps.dial()
+		if e == nil {
+			c.decodeMode = DecodeCopy
+			if ev := ps.resubscribe(c, cause, attempts); ev != nil {
+				ps.mu.Lock()
+				fn := ps.onReconnect
+				ps.mu.Unlock()
+				if fn != nil {
+					fn(*ev)
+				}
+				return c
+			}
+			c.Close()
+		} else {
+			fmt.Println("[PubSub] redial: " + e.Error())
+		}
+		select {
+		case <-time.After(wait):
+		case <-ps.closing:
+			return nil
+		}
+		if wait *= 2; wait > pubSubRedialMax {
+			wait = pubSubRedialMax
+		}
+	}
+}
+
+// resubscribe swaps c in and sends the tracked subscriptions on it, nil if
+// that failed or the PubSubConn was closed meanwhile
+func (ps *PubSubConn) resubscribe(c *Conn, cause error, attempts int) *ReconnectEvent {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed() {
+		return nil
+	}
+	ev := &ReconnectEvent{Err: cause, Attempts: attempts}
+	for name := range ps.channels {
+		ev.Channels = append(ev.Channels, name)
+	}
+	for name := range ps.patterns {
+		ev.Patterns = append(ev.Patterns, name)
+	}
+	for name := range ps.shards {
+		ev.ShardChannels = append(ev.ShardChannels, name)
+	}
+	sort.Strings(ev.Channels)
+	sort.Strings(ev.Patterns)
+	sort.Strings(ev.ShardChannels)
+	ps.c = c
+	if len(ev.Channels) > 0 && ps.write("SUBSCRIBE", ev.Channels) != nil {
+		return nil
+	}
+	if len(ev.Patterns) > 0 && ps.write("PSUBSCRIBE", ev.Patterns) != nil {
+		return nil
+	}
+	// one SSUBSCRIBE per slot, a cluster refuses CROSSSLOT ones
+	for _, channels := range bySlot(ev.ShardChannels) {
+		if ps.write("SSUBSCRIBE", channels) != nil {
+			return nil
+		}
+	}
+	return ev
+}
+
+// parsePubSub turns a RESP2 pub/sub frame into a Message, Subscription or
+// Pong, nil for anything else
+func parsePubSub(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok && string(b) == "PONG" {
+		// PING before the first subscribe
+		return Pong{}
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 2 {
+		return nil
+	}
+	kind, _ := replyString(arr[0])
+	switch kind = strings.ToLower(kind); {
+	case (kind == "message" || kind == "smessage") && len(arr) == 3:
+		m := Message{}
+		m.Channel, _ = replyString(arr[1])
+		m.Payload, _ = arr[2].([]byte)
+		return m
+	case kind == "pmessage" && len(arr) == 4:
+		m := Message{}
+		m.Pattern, _ = replyString(arr[1])
+		m.Channel, _ = replyString(arr[2])
+		m.Payload, _ = arr[3].([]byte)
+		return m
+	case subscribeKinds[strings.ToUpper(kind)] != "" && len(arr) == 3:
+		s := Subscription{Kind: kind}
+		s.Channel, _ = replyString(arr[1])
+		s.Count, _ = arr[2].(int64)
+		return s
+	case kind == "pong":
+		data, _ := replyString(arr[1])
+		return Pong{Data: data}
+	}
+	return nil
+}
+
+// bySlot groups channels by hash slot, in slot order
+func bySlot(channels []string) [][]string {
+	groups := make(map[uint16][]string)
+	var slots []int
+	for _, ch := range channels {
+		slot := KeySlot(ch)
+		if _, ok := groups[slot]; !ok {
+			slots = append(slots, int(slot))
+		}
+		groups[slot] = append(groups[slot], ch)
+	}
+	sort.Ints(slots)
+	out := make([][]string, len(slots))
+	for i, slot := range slots {
+		out[i] = groups[uint16(slot)]
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package msgredis
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPubSubConn(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SUBSCRIBE", "PSUBSCRIBE":
+			kind := strings.ToLower(args[0])
+			reply := ""
+			for i, ch := range args[1:] {
+				reply += "*3\r\n" + bulk(kind) + bulk(ch) + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			if kind == "psubscribe" {
+				return reply + "*4\r\n" + bulk("pmessage") + bulk(args[1]) + bulk("news.eu") + bulk("hello")
+			}
+			return reply + "*3\r\n" + bulk("message") + bulk(args[1]) + bulk("hi")
+		case "PING":
+			return "*2\r\n" + bulk("pong") + bulk(args[1])
+		}
+		return "-ERR only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT allowed\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	ps, e := p.PubSub(0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ps.Subscribe("a", "b")
+	ps.PSubscribe("news.*")
+	ps.Ping("alive")
+	ps.send("GET", []string{"k"})
+
+	want := []interface{}{
+		Subscription{Kind: "subscribe", Channel: "a", Count: 1},
+		Subscription{Kind: "subscribe", Channel: "b", Count: 2},
+		Message{Channel: "a", Payload: []byte("hi")},
+		Subscription{Kind: "psubscribe", Channel: "news.*", Count: 1},
+		Message{Channel: "news.eu", Pattern: "news.*", Payload: []byte("hello")},
+		Pong{Data: "alive"},
+	}
+	for i, w := range want {
+		if got := <-ps.Messages(); !reflect.DeepEqual(got, w) {
+			t.Fatalf("%d: got %#v, want %#v", i, got, w)
+		}
+	}
+	if _, ok := (<-ps.Messages()).(*ReplyError); !ok {
+		t.Fatal("reply error not delivered")
+	}
+	if e = ps.Subscribe(); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+	ps.Close()
+	if _, open := <-ps.Messages(); open || ps.Err() != nil {
+		t.Fatalf("open %v, err %v", open, ps.Err())
+	}
+}
+
+func TestPubSubReconnect(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		reply := ""
+		for i, ch := range args[1:] {
+			reply += "*3\r\n" + bulk(strings.ToLower(args[0])) + bulk(ch) + ":" + strconv.Itoa(i+1) + "\r\n"
+		}
+		return reply
+	})
+	c, e := Dial(s.Addr(), "", ConnectTimeout, ReadTimeout, WriteTimeout, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ps := NewPubSubConn(c, 0)
+	defer ps.Close()
+	events := make(chan ReconnectEvent, 1)
+	ps.OnReconnect(func(ev ReconnectEvent) {
+		events <- ev
+	})
+	ps.Subscribe("a", "b", "c")
+	ps.Unsubscribe("b")
+	ps.PSubscribe("p.*")
+	for i := 0; i < 5; i++ {
+		<-ps.Messages()
+	}
+
+	// drop the socket under the reader
+	c.conn.Close()
+	ev := <-events
+	if ev.Err == nil || ev.Attempts != 1 || !reflect.DeepEqual(ev.Channels, []string{"a", "c"}) || !reflect.DeepEqual(ev.Patterns, []string{"p.*"}) {
+		t.Fatalf("%+v", ev)
+	}
+	want := []Subscription{{"subscribe", "a", 1}, {"subscribe", "c", 2}, {"psubscribe", "p.*", 1}}
+	for i, w := range want {
+		if got := <-ps.Messages(); got != w {
+			t.Fatalf("%d: got %#v", i, got)
+		}
+	}
+	if ps.Err() != ev.Err {
+		t.Fatalf("err %v", ps.Err())
+	}
+}
+
+func TestPubSubRedialOptions(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		got = append(got, strings.Join(args, " "))
+		mu.Unlock()
+		switch args[0] {
+		case "HELLO":
+			return "*2\r\n" + bulk("proto") + ":3\r\n"
+		case "SUBSCRIBE":
+			return "*3\r\n" + bulk("subscribe") + bulk(args[1]) + ":1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c, e := DialWith(context.Background(), s.Addr(), DialOptions{
+		Username: "app", Password: "secret", DB: 2, ClientName: "listener", Protocol: 3,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	ps := NewPubSubConn(c, 0)
+	defer ps.Close()
+	events := make(chan ReconnectEvent, 1)
+	ps.OnReconnect(func(ev ReconnectEvent) {
+		events <- ev
+	})
+	ps.Subscribe("a")
+	<-ps.Messages()
+	c.conn.Close()
+	<-events
+	<-ps.Messages()
+
+	setup := "AUTH app secret,HELLO 3,SELECT 2,CLIENT SETNAME listener"
+	mu.Lock()
+	defer mu.Unlock()
+	if want := setup + ",SUBSCRIBE a," + setup + ",SUBSCRIBE a"; strings.Join(got, ",") != want {
+		t.Fatalf("sent %q", got)
+	}
+}
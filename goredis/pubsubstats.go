@@ -0,0 +1,174 @@
+package msgredis
+
+import (
+	"sync"
+	"time"
+)
+
+// ChannelStats counts the messages of one channel, pattern matches under
+// the channel they were published to
+type ChannelStats struct {
+	Delivered int64
+	// passed to the Handle function, and the time it took
+	Handled     int64
+	HandlerTime time.Duration
+}
+
+// PubSubStats is a snapshot of how a PubSubConn consumer keeps up
+type PubSubStats struct {
+	Channels map[string]ChannelStats
+	// values waiting in Messages, of Capacity
+	Buffered int
+	Capacity int
+	// time the reader waited for room in Messages, growing while the
+	// consumer is behind and the server buffers the rest
+	Blocked time.Duration
+	// durations of the Handle function
+	Handler LatencySnapshot
+	// Lag estimates how long a message read now waits for its handler:
+	// Buffered times the mean handler time, 0 without Handle
+	Lag time.Duration
+}
+
+type pubSubStats struct {
+	mu       sync.Mutex
+	channels map[string]*ChannelStats
+	blocked  time.Duration
+	// values handled and their total time
+	count   int64
+	total   time.Duration
+	handler LatencyHistogram
+}
+
+func (s *pubSubStats) channel(name string) *ChannelStats {
+	cs, ok := s.channels[name]
+	if !ok {
+		if s.channels == nil {
+			s.channels = make(map[string]*ChannelStats)
+		}
+		cs = &ChannelStats{}
+		s.channels[name] = cs
+	}
+	return cs
+}
+
+func (s *pubSubStats) delivered(m interface{}, blocked time.Duration) {
+	s.mu.Lock()
+	if msg, ok := m.(Message); ok {
+		s.channel(msg.Channel).Delivered++
+	}
+	s.blocked += blocked
+	s.mu.Unlock()
+}
+
+func (s *pubSubStats) handled(m interface{}, d time.Duration) {
+	s.handler.Record(d)
+	s.mu.Lock()
+	if msg, ok := m.(Message); ok {
+		cs := s.channel(msg.Channel)
+		cs.Handled++
+		cs.HandlerTime += d
+	}
+	s.count++
+	s.total += d
+	s.mu.Unlock()
+}
+
+// deliver hands m to the consumer, it returns false when closed first
+func (ps *PubSubConn) deliver(m interface{}) bool {
+	select {
+	case ps.messages <- m:
+		ps.stats.delivered(m, 0)
+		return true
+	default:
+	}
+	// the buffer is full
+	start := time.Now()
+	select {
+	case ps.messages <- m:
+		ps.stats.delivered(m, time.Since(start))
+		return true
+	case <-ps.closing:
+		return false
+	}
+}
+
+// AddHook passes the messages Handle takes to h, e.g. to export the
+// handler latency with the metrics hooks of the commands. The events name
+// the kind of message as Command, "message" or "pmessage", with the
+// pattern, channel and payload as Args, Duration the time of the handler
+// and InFlight the values still buffered. Add hooks before Handle.
+func (ps *PubSubConn) AddHook(h Hook) {
+	ps.hooks = append(ps.hooks, h)
+}
+
+// Handle calls fn with every value of Messages until the PubSubConn is
+// closed, timing it for Stats and the hooks. It takes the place of reading
+// Messages.
+func (ps *PubSubConn) Handle(fn func(interface{})) {
+	for m := range ps.messages {
+		ev := ps.messageEvent(m)
+		if ev != nil {
+			for _, h := range ps.hooks {
+				h.Before(ev)
+			}
+		}
+		start := time.Now()
+		fn(m)
+		d := time.Since(start)
+		ps.stats.handled(m, d)
+		if ev != nil {
+			ev.Duration = d
+			for _, h := range ps.hooks {
+				h.After(ev)
+			}
+		}
+	}
+}
+
+// messageEvent is the hook event of m, nil without hooks or for values
+// other than messages
+func (ps *PubSubConn) messageEvent(m interface{}) *HookEvent {
+	msg, ok := m.(Message)
+	if !ok || len(ps.hooks) == 0 {
+		return nil
+	}
+	ps.mu.Lock()
+	addr := ps.c.addr
+	ps.mu.Unlock()
+	ev := &HookEvent{
+		ID:       nextCmdID(),
+		Addr:     addr,
+		Command:  "message",
+		Args:     []interface{}{msg.Channel, msg.Payload},
+		Start:    time.Now(),
+		InFlight: int64(len(ps.messages)),
+	}
+	if msg.Pattern != "" {
+		ev.Command = "pmessage"
+		ev.Args = []interface{}{msg.Pattern, msg.Channel, msg.Payload}
+	}
+	return ev
+}
+
+// Stats returns the delivery counts per channel, the buffer occupancy and
+// the estimated lag, e.g. to alert when a consumer falls behind
+func (ps *PubSubConn) Stats() PubSubStats {
+	s := &ps.stats
+	st := PubSubStats{
+		Buffered: len(ps.messages),
+		Capacity: cap(ps.messages),
+		Handler:  s.handler.Snapshot(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.Channels = make(map[string]ChannelStats, len(s.channels))
+	for name, cs := range s.channels {
+		st.Channels[name] = *cs
+	}
+	st.Blocked = s.blocked
+	if s.count > 0 {
+		st.Lag = time.Duration(st.Buffered) * (s.total / time.Duration(s.count))
+	}
+	return st
+}
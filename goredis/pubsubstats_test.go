@@ -0,0 +1,101 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPubSubStats(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		reply := ""
+		for i, ch := range args[1:] {
+			reply += "*3\r\n" + bulk("subscribe") + bulk(ch) + ":" + strconv.Itoa(i+1) + "\r\n"
+		}
+		for i := 0; i < 3; i++ {
+			reply += "*3\r\n" + bulk("message") + bulk("a") + bulk("x")
+		}
+		return reply + "*3\r\n" + bulk("message") + bulk("b") + bulk("y")
+	})
+	ps, e := NewPool(s.Addr(), "").PubSub(2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer ps.Close()
+	ps.Subscribe("a", "b")
+
+	// nobody reads: the buffer fills and the reader waits
+	time.Sleep(50 * time.Millisecond)
+	if st := ps.Stats(); st.Buffered != 2 || st.Capacity != 2 || st.Lag != 0 {
+		t.Fatalf("full buffer: %+v", st)
+	}
+
+	done := make(chan struct{})
+	handled := 0
+	go ps.Handle(func(m interface{}) {
+		time.Sleep(time.Millisecond)
+		if handled++; handled == 6 {
+			close(done)
+		}
+	})
+	<-done
+	st := ps.Stats()
+	a := st.Channels["a"]
+	if a.Delivered != 3 || a.Handled != 3 || a.HandlerTime < 3*time.Millisecond || st.Channels["b"].Delivered != 1 {
+		t.Fatalf("channels: %+v", st.Channels)
+	}
+	if st.Blocked <= 0 || st.Handler.Count != 6 {
+		t.Fatalf("stats: %+v", st)
+	}
+
+}
+
+type handlerHook struct {
+	mu     sync.Mutex
+	events []HookEvent
+}
+
+func (h *handlerHook) Before(ev *HookEvent) {}
+
+func (h *handlerHook) After(ev *HookEvent) {
+	h.mu.Lock()
+	h.events = append(h.events, *ev)
+	h.mu.Unlock()
+}
+
+func TestPubSubHooks(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		return "*3\r\n" + bulk("psubscribe") + bulk(args[1]) + ":1\r\n" +
+			"*4\r\n" + bulk("pmessage") + bulk(args[1]) + bulk("news.1") + bulk("hi")
+	})
+	ps, e := NewPool(s.Addr(), "").PubSub(0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	h := &handlerHook{}
+	ps.AddHook(h)
+	ps.PSubscribe("news.*")
+	done := make(chan struct{})
+	go func() {
+		ps.Handle(func(m interface{}) {
+			if _, ok := m.(Message); ok {
+				time.Sleep(time.Millisecond)
+				ps.Close()
+			}
+		})
+		close(done)
+	}()
+	<-done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// the subscription confirmation is no message
+	if len(h.events) != 1 {
+		t.Fatalf("events %+v", h.events)
+	}
+	ev := h.events[0]
+	if ev.Command != "pmessage" || ev.Addr != s.Addr() || ev.Duration < time.Millisecond ||
+		argString(ev.Args[0]) != "news.*" || argString(ev.Args[1]) != "news.1" || argString(ev.Args[2]) != "hi" {
+		t.Fatalf("event %+v", ev)
+	}
+}
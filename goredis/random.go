@@ -0,0 +1,47 @@
+package msgredis
+
+// random sampling, a positive count returns distinct elements, a negative
+// count may repeat elements and always returns -count of them
+
+/******************* random sampling commands *******************/
+func (c *Conn) HRANDFIELD(key string, count int) ([]string, error) {
+	v, e := c.Call("HRANDFIELD", key, count)
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+func (c *Conn) HRANDFIELDWITHVALUES(key string, count int) ([]HashField, error) {
+	v, e := c.Call("HRANDFIELD", key, count, "WITHVALUES")
+	if e != nil {
+		return nil, e
+	}
+	return replyHashFields(v)
+}
+
+func (c *Conn) ZRANDMEMBER(key string, count int) ([]string, error) {
+	v, e := c.Call("ZRANDMEMBER", key, count)
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
+
+func (c *Conn) ZRANDMEMBERWITHSCORES(key string, count int) ([]ZMember, error) {
+	v, e := c.Call("ZRANDMEMBER", key, count, "WITHSCORES")
+	if e != nil {
+		return nil, e
+	}
+	return replyZMembers(v)
+}
+
+// SRANDMEMBERS is SRANDMEMBER with count decoded to strings, unlike
+// SRANDMEMBER a count of 0 returns no members
+func (c *Conn) SRANDMEMBERS(key string, count int) ([]string, error) {
+	v, e := c.Call("SRANDMEMBER", key, count)
+	if e != nil {
+		return nil, e
+	}
+	return replyStrings(v)
+}
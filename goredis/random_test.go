@@ -0,0 +1,32 @@
+package msgredis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRandomSampling(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "HRANDFIELD" {
+			return respArray("f1", "v1", "f1", "v1")
+		}
+		// RESP3 WITHSCORES pairs
+		return "*2\r\n*2\r\n$1\r\na\r\n,1.5\r\n*2\r\n$1\r\nb\r\n,2\r\n"
+	})
+	c := dialFake(t, s)
+
+	fields, e := c.HRANDFIELDWITHVALUES("h", -2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if want := []HashField{{"f1", "v1"}, {"f1", "v1"}}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("got %v", fields)
+	}
+	members, e := c.ZRANDMEMBERWITHSCORES("z", 2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if want := []ZMember{{"a", 1.5}, {"b", 2}}; !reflect.DeepEqual(members, want) {
+		t.Fatalf("got %v", members)
+	}
+}
@@ -0,0 +1,144 @@
+package msgredis
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RawCall writes rawRESP to the socket as is and reads one reply. It is an
+// escape hatch for servers that speak RESP but not redis commands and for
+// protocol edge case tests: policies, renames and hooks are bypassed. The
+// request is still parsed for read-only mode and state tracking; one that
+// does not parse is refused by read-only conns and makes the pool reset the
+// conn before reuse. rawRESP must hold exactly one request, anything else
+// desynchronizes the conn.
+func (c *Conn) RawCall(rawRESP []byte) (interface{}, error) {
+	if e := c.enter("RawCall"); e != nil {
+		return nil, e
+	}
+	defer c.leave()
+	if e := c.checkRaw(rawRESP); e != nil {
+		return nil, e
+	}
+	return c.rawCall(rawRESP)
+}
+
+// InlineCall sends a space separated inline command, as typed in telnet,
+// e.g. "PING" or "SET k v". Arguments cannot be quoted or hold CR/LF.
+func (c *Conn) InlineCall(line string) (interface{}, error) {
+	if line == "" || strings.ContainsAny(line, "\r\n") {
+		return nil, ErrBadArgs
+	}
+	if e := c.enter("InlineCall"); e != nil {
+		return nil, e
+	}
+	defer c.leave()
+	req := []byte(line + "\r\n")
+	if e := c.checkRaw(req); e != nil {
+		return nil, e
+	}
+	return c.rawCall(req)
+}
+
+// checkRaw applies read-only mode and state tracking to a raw request
+func (c *Conn) checkRaw(req []byte) error {
+	command, args, ok := parseRequest(req)
+	if !ok {
+		if c.readOnly {
+			return &ReadOnlyError{Command: "RawCall"}
+		}
+		c.state |= stateUnknown
+		return nil
+	}
+	if e := c.checkReadOnly(command); e != nil {
+		return e
+	}
+	c.trackState(command, args)
+	return nil
+}
+
+// parseRequest splits one request, a RESP array of bulk strings or an
+// inline command, ok is false for anything else
+func parseRequest(req []byte) (command string, args []interface{}, ok bool) {
+	if len(req) == 0 || req[0] != '*' {
+		line := string(req)
+		if !strings.HasSuffix(line, "\r\n") || strings.ContainsAny(line[:len(line)-2], "\r\n") {
+			return "", nil, false
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return "", nil, false
+		}
+		for _, f := range fields[1:] {
+			args = append(args, f)
+		}
+		return fields[0], args, true
+	}
+	rb := bufio.NewReader(bytes.NewReader(req))
+	readInt := func(prefix byte) (int, bool) {
+		line, e := rb.ReadString('\n')
+		if e != nil || len(line) < 3 || line[0] != prefix || !strings.HasSuffix(line, "\r\n") {
+			return 0, false
+		}
+		n, e := strconv.Atoi(line[1 : len(line)-2])
+		return n, e == nil && n >= 0
+	}
+	n, ok := readInt('*')
+	if !ok || n == 0 {
+		return "", nil, false
+	}
+	for i := 0; i < n; i++ {
+		size, ok := readInt('$')
+		if !ok {
+			return "", nil, false
+		}
+		arg := make([]byte, size+2)
+		if _, e := io.ReadFull(rb, arg); e != nil || string(arg[size:]) != "\r\n" {
+			return "", nil, false
+		}
+		if i == 0 {
+			command = string(arg[:size])
+		} else {
+			args = append(args, arg[:size])
+		}
+	}
+	if rb.Buffered() > 0 {
+		return "", nil, false
+	}
+	return command, args, true
+}
+
+func (c *Conn) rawCall(req []byte) (interface{}, error) {
+	if c.broken {
+		return nil, ErrBrokenConn
+	}
+	c.lastActiveTime = time.Now().Unix()
+	var e error
+	if c.writeTimeout > 0 {
+		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+			return nil, e
+		}
+	}
+	if _, e = c.wb.Write(req); e == nil {
+		e = c.wb.Flush()
+	}
+	if e != nil {
+		c.broken = true
+		return nil, e
+	}
+	c.addInflight(1)
+	defer c.addInflight(-1)
+	if c.readTimeout > 0 {
+		if e = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); e != nil {
+			return nil, e
+		}
+	}
+	c.replySize = 0
+	v, e := c.readResponse()
+	c.checkBroken(e)
+	return v, e
+}
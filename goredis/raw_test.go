@@ -0,0 +1,105 @@
+package msgredis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRawAndInlineCall(t *testing.T) {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer ln.Close()
+	go func() {
+		conn, e := ln.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, e := r.ReadString('\n')
+			if e != nil {
+				return
+			}
+			// echo the first line of each request back
+			line = strings.TrimSuffix(line, "\r\n")
+			if strings.HasPrefix(line, "*") {
+				for i := 0; i < 2; i++ {
+					r.ReadString('\n')
+				}
+			}
+			io.WriteString(conn, "+"+line+"\r\n")
+		}
+	}()
+	c, e := Dial(ln.Addr().String(), "", ConnectTimeout, ReadTimeout, WriteTimeout, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+
+	if v, e := c.InlineCall("SET k v"); e != nil || string(v.([]byte)) != "SET k v" {
+		t.Fatalf("got %v %v", v, e)
+	}
+	if v, e := c.RawCall([]byte("*1\r\n$4\r\nPING\r\n")); e != nil || string(v.([]byte)) != "*1" {
+		t.Fatalf("got %v %v", v, e)
+	}
+	if _, e := c.InlineCall("GET a\r\nFLUSHALL"); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+}
+
+func TestRawCallChecks(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	c.SetReadOnly(true)
+	for _, req := range []string{"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n", "FLUSHALL\r\n", "*1\r\n$4\r\nGE"} {
+		if _, e := c.RawCall([]byte(req)); e == nil {
+			t.Fatalf("%q sent by a read-only conn", req)
+		} else if _, ok := e.(*ReadOnlyError); !ok {
+			t.Fatalf("%q: %v", req, e)
+		}
+	}
+	if _, e := c.InlineCall("DEL k"); e == nil {
+		t.Fatal("inline DEL sent by a read-only conn")
+	}
+	if _, e := c.RawCall([]byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")); e != nil {
+		t.Fatal(e)
+	}
+
+	// the state is tracked for the pool to reset
+	c.SetReadOnly(false)
+	if _, e := c.RawCall([]byte("*1\r\n$5\r\nMULTI\r\n")); e != nil || c.state&stateMulti == 0 {
+		t.Fatalf("MULTI: state %b %v", c.state, e)
+	}
+	c.state = 0
+	if _, e := c.RawCall([]byte("*2\r\n$6\r\nSELECT\r\n$1\r\n3\r\n")); e != nil || c.state&stateSelect == 0 {
+		t.Fatalf("SELECT: state %b %v", c.state, e)
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	cases := []struct {
+		req     string
+		command string
+		args    int
+		ok      bool
+	}{
+		{"*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", "GET", 1, true},
+		{"SET k v\r\n", "SET", 2, true},
+		{"*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPING\r\n", "", 0, false},
+		{"*1\r\n$9\r\nPING\r\n", "", 0, false},
+		{"*1\r\n:1\r\n", "", 0, false},
+		{"GET k", "", 0, false},
+		{"\r\n", "", 0, false},
+	}
+	for _, tc := range cases {
+		command, args, ok := parseRequest([]byte(tc.req))
+		if ok != tc.ok || command != tc.command || len(args) != tc.args {
+			t.Fatalf("%q: %s %v %v", tc.req, command, args, ok)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package msgredis
+
+import (
+	"strings"
+)
+
+// ReadOnlyError is returned for a command rejected by a read-only conn,
+// it never reached the server
+type ReadOnlyError struct {
+	Command string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return CommonErrPrefix + "read-only client refuses " + e.Command
+}
+
+// SetReadOnly makes the conn reject, before sending, every command the
+// registry does not flag as read-only: writes, admin commands and commands
+// it does not know. Register module reads with RegisterCommand.
+func (c *Conn) SetReadOnly(on bool) {
+	c.readOnly = on
+}
+
+func (c *Conn) checkReadOnly(command string) error {
+	if !c.readOnly {
+		return nil
+	}
+	ci := LookupCommand(command)
+	if ci == nil || !ci.Is(FlagReadOnly) || ci.Is(FlagWrite|FlagAdmin) {
+		return &ReadOnlyError{Command: strings.ToUpper(command)}
+	}
+	return nil
+}
+
+// ReadOnlyClient hands out read-only conns of a pool, for analytics and
+// debug tools that must not change data whatever they are fed
+type ReadOnlyClient struct {
+	p *Pool
+}
+
+func NewReadOnlyClient(p *Pool) *ReadOnlyClient {
+	return &ReadOnlyClient{p: p}
+}
+
+// Pop returns a read-only conn, give it back with Push
+func (r *ReadOnlyClient) Pop() *Conn {
+	c := r.p.Pop()
+	if c != nil {
+		c.readOnly = true
+	}
+	return c
+}
+
+func (r *ReadOnlyClient) Push(c *Conn) {
+	if c == nil {
+		return
+	}
+	c.readOnly = r.p.ReadOnly
+	r.p.Push(c)
+}
+
+func (r *ReadOnlyClient) Call(command string, args ...interface{}) (interface{}, error) {
+	c := r.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer r.Push(c)
+	return c.Call(command, args...)
+}
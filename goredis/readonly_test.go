@@ -0,0 +1,40 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestReadOnlyClient(t *testing.T) {
+	var sent []string
+	s := newFakeServer(t, func(args []string) string {
+		sent = append(sent, args[0])
+		return "$1\r\nv\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	r := NewReadOnlyClient(p)
+
+	if _, e := r.Call("GET", "k"); e != nil {
+		t.Fatal(e)
+	}
+	for _, cmd := range []string{"set", "FLUSHALL", "CONFIG", "EVAL", "NOSUCHCMD"} {
+		_, e := r.Call(cmd, "k", "v")
+		if ro, ok := e.(*ReadOnlyError); !ok || ro.Command == "" {
+			t.Fatalf("%s: %v", cmd, e)
+		}
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %v", sent)
+	}
+
+	c := r.Pop()
+	if e := c.PipeSend("DEL", "k"); e == nil {
+		t.Fatal("DEL queued")
+	}
+	r.Push(c)
+	// the pool conns are writable again
+	c = p.Pop()
+	defer p.Push(c)
+	if _, e := c.Call("SET", "k", "v"); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,173 @@
+package msgredis
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// one recorded command, a JSON line in the recording
+type Record struct {
+	ID       uint64        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Addr     string        `json:"addr"`
+	Command  string        `json:"command"`
+	Args     [][]byte      `json:"args"`
+	Reply    interface{}   `json:"reply,omitempty"`
+	Err      string        `json:"err,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// credentials in Args were replaced by "***", Replay skips the record
+	Masked bool `json:"masked,omitempty"`
+}
+
+// Recorder is a Hook writing every command and its reply to w as JSON lines.
+// Install it on a Conn with AddHook or on a Pool through Pool.Hooks.
+// The session setup (AUTH, HELLO, CLIENT SETNAME), which pools re-issue on
+// RESET, is left out, the conn replaying dials with its own; other
+// credentials are masked, see Redactor.RedactCommand.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	e   error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) Before(ev *HookEvent) {}
+
+func (r *Recorder) After(ev *HookEvent) {
+	if sessionSetup(ev.Command, ev.Args) {
+		return
+	}
+	rec := Record{
+		ID:       ev.ID,
+		Time:     ev.Start,
+		Addr:     ev.Addr,
+		Command:  ev.Command,
+		Args:     make([][]byte, len(ev.Args)),
+		Reply:    recordReply(ev.Reply),
+		Duration: ev.Duration,
+	}
+	secret := secretFrom(ev.Command, ev.Args)
+	for i, arg := range ev.Args {
+		if i >= secret {
+			rec.Args[i] = []byte("***")
+			rec.Masked = true
+			continue
+		}
+		rec.Args[i] = []byte(argString(arg))
+	}
+	if ev.Err != nil {
+		rec.Err = ev.Err.Error()
+	}
+	r.mu.Lock()
+	if r.e == nil {
+		r.e = r.enc.Encode(&rec)
+	}
+	r.mu.Unlock()
+}
+
+func sessionSetup(command string, args []interface{}) bool {
+	switch strings.ToUpper(command) {
+	case "AUTH", "HELLO":
+		return true
+	case "CLIENT":
+		return len(args) > 0 && strings.EqualFold(argString(args[0]), "SETNAME")
+	}
+	return false
+}
+
+// first write error, the recorder stops recording after it
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.e
+}
+
+// replies as JSON friendly values: bulk strings become strings
+func recordReply(v interface{}) interface{} {
+	switch data := v.(type) {
+	case []byte:
+		return string(data)
+	case []interface{}:
+		arr := make([]interface{}, len(data))
+		for i, x := range data {
+			arr[i] = recordReply(x)
+		}
+		return arr
+	case error:
+		return map[string]string{"error": data.Error()}
+	}
+	return v
+}
+
+type ReplayOptions struct {
+	// sleep between commands as long as in the recording, reproducing load patterns
+	KeepTiming bool
+	// called when the new reply differs from the recorded one
+	OnMismatch func(rec *Record, reply interface{}, e error)
+}
+
+type ReplayReport struct {
+	Commands   int
+	Mismatches int
+	// records with masked credentials, not sent
+	Skipped int
+}
+
+// Replay re-issues a recording made by Recorder on c, comparing replies.
+// Records with masked credentials are skipped: sent as recorded they would
+// set or check the password "***".
+func Replay(r io.Reader, c *Conn, opt ReplayOptions) (*ReplayReport, error) {
+	dec := json.NewDecoder(r)
+	report := &ReplayReport{}
+	var last time.Time
+	for {
+		var rec Record
+		if e := dec.Decode(&rec); e == io.EOF {
+			return report, nil
+		} else if e != nil {
+			return report, e
+		}
+		if opt.KeepTiming && !last.IsZero() && rec.Time.After(last) {
+			time.Sleep(rec.Time.Sub(last))
+		}
+		last = rec.Time
+
+		args := make([]interface{}, len(rec.Args))
+		for i, arg := range rec.Args {
+			args[i] = arg
+		}
+		// older recordings have no Masked flag
+		if rec.Masked || secretFrom(rec.Command, args) < len(args) {
+			report.Skipped++
+			continue
+		}
+		reply, e := c.Call(rec.Command, args...)
+		report.Commands++
+		if e != nil && !isReplyError(e) {
+			return report, e
+		}
+
+		errText := ""
+		if e != nil {
+			errText = e.Error()
+		}
+		// round trip through JSON so both sides have the same types
+		var got interface{}
+		if b, je := json.Marshal(recordReply(reply)); je == nil {
+			json.Unmarshal(b, &got)
+		}
+		if errText != rec.Err || !reflect.DeepEqual(got, rec.Reply) {
+			report.Mismatches++
+			if opt.OnMismatch != nil {
+				opt.OnMismatch(&rec, reply, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package msgredis
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$5\r\nhello\r\n"
+		case "MGET":
+			return "*2\r\n$1\r\na\r\n$-1\r\n"
+		}
+		return "-ERR unknown\r\n"
+	}))
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	c.AddHook(rec)
+	c.Call("GET", "k")
+	c.Call("MGET", "a", "b")
+	c.Call("NOPE")
+	if rec.Err() != nil || strings.Count(buf.String(), "\n") != 3 {
+		t.Fatalf("recording %q %v", buf.String(), rec.Err())
+	}
+
+	replayed := dialFake(t, newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "$5\r\nworld\r\n"
+		}
+		return "*2\r\n$1\r\na\r\n$-1\r\n"
+	}))
+	var mismatched []string
+	report, e := Replay(&buf, replayed, ReplayOptions{
+		OnMismatch: func(r *Record, reply interface{}, e error) {
+			mismatched = append(mismatched, r.Command)
+		},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if report.Commands != 3 || report.Mismatches != 2 || strings.Join(mismatched, ",") != "GET,NOPE" {
+		t.Fatalf("report %+v mismatched %v", report, mismatched)
+	}
+}
+
+func TestRecorderCredentials(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	c.AddHook(rec)
+	c.Call("AUTH", "app", "secret")
+	c.Call("HELLO", "2", "AUTH", "app", "secret")
+	c.Call("CLIENT", "SETNAME", "worker")
+	c.Call("CONFIG", "SET", "requirepass", "secret")
+	c.Call("SET", "k", "v")
+	var got []string
+	dec := json.NewDecoder(&buf)
+	for {
+		var r Record
+		if e := dec.Decode(&r); e != nil {
+			break
+		}
+		line := r.Command
+		for _, arg := range r.Args {
+			line += " " + string(arg)
+		}
+		got = append(got, line)
+	}
+	if strings.Join(got, ",") != "CONFIG SET requirepass ***,SET k v" {
+		t.Fatalf("recording %q", got)
+	}
+}
+
+func TestReplayMasked(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, okHandler))
+	var buf bytes.Buffer
+	c.AddHook(NewRecorder(&buf))
+	c.Call("CONFIG", "SET", "requirepass", "secret")
+	c.Call("SET", "k", "v")
+	// a recording predating the Masked flag
+	buf.WriteString(`{"command":"AUTH","args":["Kioq"]}` + "\n")
+
+	var mu sync.Mutex
+	var got []string
+	replayed := dialFake(t, newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, strings.Join(args, " "))
+		return "+OK\r\n"
+	}))
+	report, e := Replay(&buf, replayed, ReplayOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if report.Commands != 1 || report.Skipped != 2 || strings.Join(got, ",") != "SET k v" {
+		t.Fatalf("report %+v sent %q", report, got)
+	}
+}
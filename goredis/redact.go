@@ -0,0 +1,114 @@
+package msgredis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Redactor turns command arguments into strings that are safe to log.
+// The first KeepArgs arguments (usually the key) are kept, the rest are
+// replaced by Mask. Kept arguments longer than MaxLen bytes are truncated.
+type Redactor struct {
+	KeepArgs int
+	MaxLen   int
+	// empty Mask keeps every argument, only truncating them
+	Mask string
+}
+
+// show the key, hide values
+var DefaultRedactor = &Redactor{KeepArgs: 1, MaxLen: 64, Mask: "***"}
+
+func (r *Redactor) Redact(args []interface{}) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if r.Mask != "" && i >= r.KeepArgs {
+			out[i] = r.Mask
+			continue
+		}
+		s := argString(arg)
+		if r.MaxLen > 0 && len(s) > r.MaxLen {
+			s = s[:r.MaxLen] + "...(" + strconv.Itoa(len(s)) + " bytes)"
+		}
+		out[i] = s
+	}
+	return out
+}
+
+// RedactCommand is Redact with the credentials of command masked whatever
+// KeepArgs and Mask say: every argument of AUTH, and those after the AUTH
+// of HELLO and MIGRATE (AUTH2 too), after the user of ACL SETUSER and after
+// requirepass or masterauth in CONFIG SET
+func (r *Redactor) RedactCommand(command string, args []interface{}) []string {
+	out := r.Redact(args)
+	for i := secretFrom(command, args); i < len(out); i++ {
+		out[i] = "***"
+		if r.Mask != "" {
+			out[i] = r.Mask
+		}
+	}
+	return out
+}
+
+// secretFrom returns the index of the first argument of command carrying
+// credentials, len(args) for none
+func secretFrom(command string, args []interface{}) int {
+	after := func(from int, tokens ...string) int {
+		for i := from; i < len(args); i++ {
+			for _, token := range tokens {
+				if strings.EqualFold(argString(args[i]), token) {
+					return i + 1
+				}
+			}
+		}
+		return len(args)
+	}
+	switch strings.ToUpper(command) {
+	case "AUTH":
+		return 0
+	case "HELLO":
+		return after(0, "AUTH")
+	case "MIGRATE":
+		// the key may be named AUTH, it is the third argument
+		return after(3, "AUTH", "AUTH2")
+	case "ACL":
+		if len(args) > 0 && strings.EqualFold(argString(args[0]), "SETUSER") {
+			return 2
+		}
+	case "CONFIG":
+		if len(args) > 0 && strings.EqualFold(argString(args[0]), "SET") {
+			return after(1, "requirepass", "masterauth")
+		}
+	}
+	return len(args)
+}
+
+func argString(arg interface{}) string {
+	switch data := arg.(type) {
+	case string:
+		return data
+	case []byte:
+		return string(data)
+	case nil:
+		return ""
+	case ReaderArg:
+		// never read it here, it can be read once
+		return "(" + strconv.FormatInt(data.N, 10) + " bytes from a reader)"
+	default:
+		return fmt.Sprintf("%v", data)
+	}
+}
+
+func (c *Conn) SetRedactor(r *Redactor) {
+	c.redactor = r
+}
+
+// SafeArgs returns the event arguments passed through the conn's Redactor,
+// or DefaultRedactor if none is set. Hooks should log these, never Args.
+func (ev *HookEvent) SafeArgs() []string {
+	r := ev.redactor
+	if r == nil {
+		r = DefaultRedactor
+	}
+	return r.RedactCommand(ev.Command, ev.Args)
+}
@@ -0,0 +1,41 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactCommand(t *testing.T) {
+	cases := []struct {
+		command string
+		args    []interface{}
+		want    string
+	}{
+		{"AUTH", []interface{}{"secret"}, "***"},
+		{"AUTH", []interface{}{"app", "secret"}, "*** ***"},
+		{"HELLO", []interface{}{"3", "AUTH", "app", "secret", "SETNAME", "w"}, "3 AUTH *** *** *** ***"},
+		{"HELLO", []interface{}{"3"}, "3"},
+		{"CONFIG", []interface{}{"SET", "requirepass", "secret"}, "SET requirepass ***"},
+		{"CONFIG", []interface{}{"SET", "maxmemory", "1gb"}, "SET maxmemory 1gb"},
+		{"CONFIG", []interface{}{"SET", "maxmemory", "1gb", "masterauth", "secret"}, "SET maxmemory 1gb masterauth ***"},
+		{"MIGRATE", []interface{}{"h", "6379", "AUTH", "0", "5000", "AUTH", "secret"}, "h 6379 AUTH 0 5000 AUTH ***"},
+		{"MIGRATE", []interface{}{"h", "6379", "", "0", "5000", "AUTH2", "app", "secret", "KEYS", "a"}, "h 6379  0 5000 AUTH2 *** *** *** ***"},
+		{"ACL", []interface{}{"SETUSER", "app", "on", ">secret"}, "SETUSER app *** ***"},
+		{"GET", []interface{}{"k"}, "k"},
+	}
+	// keeps every argument but credentials
+	r := &Redactor{}
+	for _, c := range cases {
+		if got := strings.Join(r.RedactCommand(c.command, c.args), " "); got != c.want {
+			t.Errorf("%s: %q, want %q", c.command, got, c.want)
+		}
+	}
+	// DefaultRedactor keeps the first argument, never a password
+	if got := DefaultRedactor.RedactCommand("AUTH", []interface{}{"secret"}); got[0] != "***" {
+		t.Errorf("DefaultRedactor AUTH: %v", got)
+	}
+	ev := &HookEvent{Command: "auth", Args: []interface{}{"app", "secret"}}
+	if got := strings.Join(ev.SafeArgs(), " "); strings.Contains(got, "secret") || strings.Contains(got, "app") {
+		t.Errorf("SafeArgs: %s", got)
+	}
+}
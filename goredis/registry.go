@@ -0,0 +1,374 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	FlagReadOnly = 1 << iota
+	FlagWrite
+	// running it twice leaves the same data as running it once
+	FlagIdempotent
+	FlagBlocking
+	FlagAdmin
+	FlagPubSub
+)
+
+// CommandInfo describes a command like COMMAND INFO does. Arity counts the
+// command name, negative means at least -Arity arguments. Keys are the
+// arguments FirstKey, FirstKey+Step... up to LastKey, negative LastKey
+// counts from the end. KeyNum > 0 is the position of a numkeys argument
+// followed by the keys, as in EVAL. With KeyToken set the keys follow that
+// argument, searched from FirstKey on, and fill half of the arguments
+// after it, as in XREAD ... STREAMS key [key ...] id [id ...].
+type CommandInfo struct {
+	Name     string
+	Arity    int
+	Flags    int
+	FirstKey int
+	LastKey  int
+	Step     int
+	KeyNum   int
+	KeyToken string
+}
+
+func (ci *CommandInfo) Is(flag int) bool {
+	return ci.Flags&flag != 0
+}
+
+// guards commandTable against RegisterCommand
+var commandMu sync.RWMutex
+
+var commandTable = map[string]*CommandInfo{
+	// keys
+	"DEL":        {Arity: -2, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+	"UNLINK":     {Arity: -2, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+	"DUMP":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"EXISTS":     {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"EXPIRE":     {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"EXPIREAT":   {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"PEXPIRE":    {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"PEXPIREAT":  {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"EXPIRETIME": {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"PERSIST":    {Arity: 2, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"TTL":        {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"PTTL":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"KEYS":       {Arity: 2, Flags: FlagReadOnly},
+	"MIGRATE":    {Arity: -6, Flags: FlagWrite, FirstKey: 3, LastKey: 3, Step: 1},
+	"MOVE":       {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"OBJECT":     {Arity: -2, Flags: FlagReadOnly, FirstKey: 2, LastKey: 2, Step: 1},
+	"RANDOMKEY":  {Arity: 1, Flags: FlagReadOnly},
+	"RENAME":     {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"RENAMENX":   {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"RESTORE":    {Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"SORT":       {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"SORT_RO":    {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"TYPE":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SCAN":       {Arity: -2, Flags: FlagReadOnly},
+	"COPY":       {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"TOUCH":      {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+
+	// strings
+	"APPEND":      {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITCOUNT":    {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITOP":       {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 2, LastKey: -1, Step: 1},
+	"BITPOS":      {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITFIELD":    {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITFIELD_RO": {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"DECR":        {Arity: 2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"DECRBY":      {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCR":        {Arity: 2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCRBY":      {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCRBYFLOAT": {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"GET":         {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETBIT":      {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETRANGE":    {Arity: 4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETSET":      {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETDEL":      {Arity: 2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETEX":       {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"MGET":        {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"MSET":        {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 2},
+	"MSETNX":      {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 2},
+	"PSETEX":      {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SET":         {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETBIT":      {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETEX":       {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETNX":       {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETRANGE":    {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"STRLEN":      {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SUBSTR":      {Arity: 4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"LCS":         {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 2, Step: 1},
+
+	// hashes
+	"HDEL":         {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"HEXISTS":      {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HGET":         {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HGETALL":      {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HINCRBY":      {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"HINCRBYFLOAT": {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"HKEYS":        {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HLEN":         {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HMGET":        {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HMSET":        {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSET":         {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSETNX":       {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSTRLEN":      {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HVALS":        {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSCAN":        {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"HRANDFIELD":   {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// lists
+	"BLPOP":      {Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1},
+	"BRPOP":      {Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1},
+	"BRPOPLPUSH": {Arity: 4, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: 2, Step: 1},
+	"BLMOVE":     {Arity: 6, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: 2, Step: 1},
+	"LINDEX":     {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"LINSERT":    {Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"LLEN":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"LMOVE":      {Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"LPOP":       {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPOS":       {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPUSH":      {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPUSHX":     {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"LRANGE":     {Arity: 4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"LREM":       {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"LSET":       {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"LTRIM":      {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPOP":       {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPOPLPUSH":  {Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"RPUSH":      {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPUSHX":     {Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// sets
+	"SADD":        {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SCARD":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SDIFF":       {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"SDIFFSTORE":  {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+	"SINTER":      {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"SINTERCARD":  {Arity: -3, Flags: FlagReadOnly, KeyNum: 1},
+	"SINTERSTORE": {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+	"SISMEMBER":   {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SMISMEMBER":  {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SMEMBERS":    {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SMOVE":       {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1},
+	"SPOP":        {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"SRANDMEMBER": {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"SREM":        {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"SUNION":      {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"SUNIONSTORE": {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+	"SSCAN":       {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// sorted sets
+	"ZADD":             {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZCARD":            {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZCOUNT":           {Arity: 4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZINCRBY":          {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZINTERSTORE":      {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1, KeyNum: 2},
+	"ZUNIONSTORE":      {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1, KeyNum: 2},
+	"ZLEXCOUNT":        {Arity: 4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANGE":           {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANGEBYLEX":      {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREVRANGEBYLEX":   {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANGEBYSCORE":    {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANK":            {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREM":             {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREMRANGEBYLEX":   {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREMRANGEBYRANK":  {Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREMRANGEBYSCORE": {Arity: 4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREVRANGE":        {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREVRANGEBYSCORE": {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREVRANK":         {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZSCORE":           {Arity: 3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZMSCORE":          {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZSCAN":            {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANDMEMBER":      {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZPOPMIN":          {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZPOPMAX":          {Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"BZPOPMIN":         {Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1},
+	"BZPOPMAX":         {Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1},
+
+	// hyperloglog
+	"PFADD":   {Arity: -2, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"PFCOUNT": {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"PFMERGE": {Arity: -2, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: -1, Step: 1},
+
+	// geo
+	"GEOADD":         {Arity: -5, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"GEODIST":        {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GEOHASH":        {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GEOPOS":         {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GEOSEARCH":      {Arity: -7, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"GEOSEARCHSTORE": {Arity: -8, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 2, Step: 1},
+
+	// streams
+	"XACK":       {Arity: -4, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"XADD":       {Arity: -5, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"XAUTOCLAIM": {Arity: -6, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"XCLAIM":     {Arity: -6, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+	"XDEL":       {Arity: -3, Flags: FlagWrite | FlagIdempotent, FirstKey: 1, LastKey: 1, Step: 1},
+	"XGROUP":     {Arity: -2, Flags: FlagWrite, FirstKey: 2, LastKey: 2, Step: 1},
+	"XINFO":      {Arity: -2, Flags: FlagReadOnly, FirstKey: 2, LastKey: 2, Step: 1},
+	"XLEN":       {Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"XPENDING":   {Arity: -3, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"XRANGE":     {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"XREVRANGE":  {Arity: -4, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1},
+	"XREAD":      {Arity: -4, Flags: FlagReadOnly | FlagBlocking, FirstKey: 1, KeyToken: "STREAMS"},
+	"XREADGROUP": {Arity: -7, Flags: FlagWrite | FlagBlocking, FirstKey: 4, KeyToken: "STREAMS"},
+	"XTRIM":      {Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// scripting
+	"EVAL":       {Arity: -3, Flags: FlagWrite, KeyNum: 2},
+	"EVALSHA":    {Arity: -3, Flags: FlagWrite, KeyNum: 2},
+	"EVAL_RO":    {Arity: -3, Flags: FlagReadOnly, KeyNum: 2},
+	"EVALSHA_RO": {Arity: -3, Flags: FlagReadOnly, KeyNum: 2},
+	"FCALL":      {Arity: -3, Flags: FlagWrite, KeyNum: 2},
+	"FCALL_RO":   {Arity: -3, Flags: FlagReadOnly, KeyNum: 2},
+	"SCRIPT":     {Arity: -2, Flags: FlagAdmin},
+	"FUNCTION":   {Arity: -2, Flags: FlagAdmin},
+
+	// pubsub
+	"PUBLISH":      {Arity: 3, Flags: FlagPubSub},
+	"SPUBLISH":     {Arity: 3, Flags: FlagPubSub, FirstKey: 1, LastKey: 1, Step: 1},
+	"SUBSCRIBE":    {Arity: -2, Flags: FlagPubSub},
+	"PSUBSCRIBE":   {Arity: -2, Flags: FlagPubSub},
+	"SSUBSCRIBE":   {Arity: -2, Flags: FlagPubSub, FirstKey: 1, LastKey: -1, Step: 1},
+	"UNSUBSCRIBE":  {Arity: -1, Flags: FlagPubSub},
+	"PUNSUBSCRIBE": {Arity: -1, Flags: FlagPubSub},
+	"SUNSUBSCRIBE": {Arity: -1, Flags: FlagPubSub, FirstKey: 1, LastKey: -1, Step: 1},
+	"PUBSUB":       {Arity: -2, Flags: FlagReadOnly},
+
+	// connection
+	"AUTH":   {Arity: -2, Flags: 0},
+	"ECHO":   {Arity: 2, Flags: FlagReadOnly},
+	"HELLO":  {Arity: -1, Flags: 0},
+	"PING":   {Arity: -1, Flags: FlagReadOnly},
+	"QUIT":   {Arity: 1, Flags: 0},
+	"RESET":  {Arity: 1, Flags: 0},
+	"SELECT": {Arity: 2, Flags: 0},
+	"CLIENT": {Arity: -2, Flags: FlagAdmin},
+
+	// transactions
+	"MULTI":   {Arity: 1, Flags: 0},
+	"EXEC":    {Arity: 1, Flags: 0},
+	"DISCARD": {Arity: 1, Flags: 0},
+	"WATCH":   {Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, Step: 1},
+	"UNWATCH": {Arity: 1, Flags: 0},
+
+	// server
+	"ACL":          {Arity: -2, Flags: FlagAdmin},
+	"ASKING":       {Arity: 1, Flags: 0},
+	"BGREWRITEAOF": {Arity: 1, Flags: FlagAdmin},
+	"BGSAVE":       {Arity: -1, Flags: FlagAdmin},
+	"CLUSTER":      {Arity: -2, Flags: FlagAdmin},
+	"COMMAND":      {Arity: -1, Flags: FlagReadOnly},
+	"CONFIG":       {Arity: -2, Flags: FlagAdmin},
+	"DBSIZE":       {Arity: 1, Flags: FlagReadOnly},
+	"DEBUG":        {Arity: -2, Flags: FlagAdmin},
+	"FAILOVER":     {Arity: -1, Flags: FlagAdmin},
+	"FLUSHALL":     {Arity: -1, Flags: FlagWrite | FlagAdmin},
+	"FLUSHDB":      {Arity: -1, Flags: FlagWrite | FlagAdmin},
+	"INFO":         {Arity: -1, Flags: FlagReadOnly},
+	"LASTSAVE":     {Arity: 1, Flags: FlagReadOnly},
+	"LATENCY":      {Arity: -2, Flags: FlagAdmin},
+	"MEMORY":       {Arity: -2, Flags: FlagReadOnly},
+	"MODULE":       {Arity: -2, Flags: FlagAdmin},
+	"MONITOR":      {Arity: 1, Flags: FlagAdmin},
+	"READONLY":     {Arity: 1, Flags: 0},
+	"READWRITE":    {Arity: 1, Flags: 0},
+	"REPLICAOF":    {Arity: 3, Flags: FlagAdmin},
+	"SAVE":         {Arity: 1, Flags: FlagAdmin},
+	"SHUTDOWN":     {Arity: -1, Flags: FlagAdmin},
+	"SLAVEOF":      {Arity: 3, Flags: FlagAdmin},
+	"SLOWLOG":      {Arity: -2, Flags: FlagAdmin},
+	"SWAPDB":       {Arity: 3, Flags: FlagWrite | FlagAdmin},
+	"TIME":         {Arity: 1, Flags: FlagReadOnly},
+	"WAIT":         {Arity: 3, Flags: 0},
+}
+
+func init() {
+	for name, ci := range commandTable {
+		ci.Name = name
+		// reads never change data
+		if ci.Is(FlagReadOnly) {
+			ci.Flags |= FlagIdempotent
+		}
+	}
+}
+
+// LookupCommand returns the registry entry of command, nil if unknown
+func LookupCommand(command string) *CommandInfo {
+	commandMu.RLock()
+	defer commandMu.RUnlock()
+	return commandTable[strings.ToUpper(command)]
+}
+
+// RegisterCommand adds or replaces a registry entry, e.g. for module
+// commands. Entries are never changed in place, so it is safe to call
+// while other goroutines run commands.
+func RegisterCommand(ci CommandInfo) {
+	ci.Name = strings.ToUpper(ci.Name)
+	if ci.Is(FlagReadOnly) {
+		ci.Flags |= FlagIdempotent
+	}
+	commandMu.Lock()
+	commandTable[ci.Name] = &ci
+	commandMu.Unlock()
+}
+
+// unknown commands are not idempotent
+func IsIdempotent(command string) bool {
+	ci := LookupCommand(command)
+	return ci != nil && ci.Is(FlagIdempotent)
+}
+
+// hasKeys reports whether KeyPositions can find the keys of the command
+func (ci *CommandInfo) hasKeys() bool {
+	return ci.KeyNum > 0 || ci.FirstKey > 0
+}
+
+// KeyPositions returns the indexes in args (the arguments after the command
+// name) holding keys
+func (ci *CommandInfo) KeyPositions(args []interface{}) []int {
+	if ci.KeyToken != "" {
+		for i := ci.FirstKey - 1; i >= 0 && i < len(args); i++ {
+			if !strings.EqualFold(argString(args[i]), ci.KeyToken) {
+				continue
+			}
+			n := (len(args) - i - 1) / 2
+			pos := make([]int, 0, n)
+			for j := i + 1; j <= i+n; j++ {
+				pos = append(pos, j)
+			}
+			return pos
+		}
+		return nil
+	}
+	if ci.KeyNum > 0 {
+		if ci.KeyNum-1 >= len(args) {
+			return nil
+		}
+		n, e := strconv.Atoi(argString(args[ci.KeyNum-1]))
+		if e != nil || n <= 0 {
+			return nil
+		}
+		pos := make([]int, 0, n)
+		for i := ci.KeyNum; i < ci.KeyNum+n && i < len(args); i++ {
+			pos = append(pos, i)
+		}
+		return pos
+	}
+	if ci.FirstKey <= 0 {
+		return nil
+	}
+	last := ci.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	var pos []int
+	for i := ci.FirstKey; i <= last && i <= len(args); i += ci.Step {
+		pos = append(pos, i-1)
+	}
+	return pos
+}
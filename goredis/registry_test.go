@@ -0,0 +1,44 @@
+package msgredis
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	for _, cmd := range []string{"GET", "get", "SET", "DEL", "HSET", "ZRANGE", "PING"} {
+		if !IsIdempotent(cmd) {
+			t.Errorf("%s not idempotent", cmd)
+		}
+	}
+	for _, cmd := range []string{"INCR", "LPUSH", "XADD", "EVAL", "NOSUCHCOMMAND"} {
+		if IsIdempotent(cmd) {
+			t.Errorf("%s idempotent", cmd)
+		}
+	}
+	RegisterCommand(CommandInfo{Name: "json.get", Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1})
+	if !IsIdempotent("JSON.GET") {
+		t.Error("registered read command not idempotent")
+	}
+}
+
+func TestRegisterCommandConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				RegisterCommand(CommandInfo{Name: "mod.get", Arity: 2, Flags: FlagReadOnly, FirstKey: 1, LastKey: 1, Step: 1})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				LookupCommand("GET")
+				IsIdempotent("MOD.GET")
+			}
+		}()
+	}
+	wg.Wait()
+}
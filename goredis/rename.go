@@ -0,0 +1,38 @@
+package msgredis
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrCommandDisabled = errors.New(CommonErrPrefix + "command disabled by rename-command")
+
+// SetRenameCommands mirrors the rename-command lines of a hardened server,
+// e.g. {"CONFIG": "B4C0N", "FLUSHALL": ""}. Callers keep using the original
+// names, the new name is only written on the wire. An empty name means the
+// command is disabled and fails with ErrCommandDisabled without a round trip.
+func (c *Conn) SetRenameCommands(renames map[string]string) {
+	if len(renames) == 0 {
+		c.renames = nil
+		return
+	}
+	c.renames = make(map[string]string, len(renames))
+	for name, to := range renames {
+		c.renames[strings.ToUpper(name)] = to
+	}
+}
+
+// wireName is the name sent for command
+func (c *Conn) wireName(command string) string {
+	if c.renames == nil {
+		return command
+	}
+	if to, ok := c.renames[strings.ToUpper(command)]; ok {
+		return to
+	}
+	return command
+}
+
+func (c *Conn) disabled(command string) bool {
+	return c.renames != nil && c.wireName(command) == ""
+}
@@ -0,0 +1,34 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestRenameCommands(t *testing.T) {
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		got = args
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	c.SetRenameCommands(map[string]string{"config": "B4C0N", "FLUSHALL": ""})
+
+	if _, e := c.Call("CONFIG", "SET", "maxmemory", "1gb"); e != nil {
+		t.Fatal(e)
+	}
+	if got[0] != "B4C0N" || got[1] != "SET" {
+		t.Fatalf("sent %v", got)
+	}
+	if _, e := c.Call("flushall"); e != ErrCommandDisabled {
+		t.Fatalf("got %v", e)
+	}
+	if e := c.PipeSend("FLUSHALL"); e != ErrCommandDisabled {
+		t.Fatalf("got %v", e)
+	}
+	if _, e := c.Call("GET", "k"); e != nil || got[0] != "GET" {
+		t.Fatalf("sent %v %v", got, e)
+	}
+	if c.Broken() {
+		t.Fatal("conn broken")
+	}
+}
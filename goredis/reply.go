@@ -0,0 +1,137 @@
+package msgredis
+
+import (
+	"strconv"
+)
+
+// helpers decoding raw replies into go types
+
+// bulk or simple string
+func replyString(v interface{}) (string, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return "", ErrBadType
+	}
+	return string(b), nil
+}
+
+func replyStrings(v interface{}) ([]string, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	ret := make([]string, len(arr))
+	for i, x := range arr {
+		s, e := replyString(x)
+		if e != nil {
+			return nil, e
+		}
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+// [k1, v1, k2, v2...]
+func replyStringMap(v interface{}) (map[string]string, error) {
+	arr, e := replyStrings(v)
+	if e != nil {
+		return nil, e
+	}
+	if len(arr)%2 != 0 {
+		return nil, ErrBadType
+	}
+	ret := make(map[string]string, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		ret[arr[i]] = arr[i+1]
+	}
+	return ret, nil
+}
+
+func replyFloat(v interface{}) (float64, error) {
+	switch data := v.(type) {
+	case []byte:
+		return strconv.ParseFloat(string(data), 64)
+	case int64:
+		return float64(data), nil
+	case float64:
+		return data, nil
+	}
+	return 0, ErrBadType
+}
+
+// member and score of a sorted set
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// [[k1, v1], [k2, v2]...] as RESP3 sends WITHSCORES/WITHVALUES => [k1, v1, k2, v2...]
+func flattenPairs(v interface{}) (interface{}, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return v, ok
+	}
+	if _, nested := arr[0].([]interface{}); !nested {
+		return v, true
+	}
+	flat := make([]interface{}, 0, 2*len(arr))
+	for _, elem := range arr {
+		pair, ok := elem.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, false
+		}
+		flat = append(flat, pair[0], pair[1])
+	}
+	return flat, true
+}
+
+// [m1, s1, m2, s2...]
+func replyZMembers(v interface{}) ([]ZMember, error) {
+	v, ok := flattenPairs(v)
+	if !ok {
+		return nil, ErrBadType
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr)%2 != 0 {
+		return nil, ErrBadType
+	}
+	ret := make([]ZMember, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		m, e := replyString(arr[i])
+		if e != nil {
+			return nil, e
+		}
+		score, e := replyFloat(arr[i+1])
+		if e != nil {
+			return nil, ErrBadType
+		}
+		ret[i/2] = ZMember{Member: m, Score: score}
+	}
+	return ret, nil
+}
+
+// field and value of a hash
+type HashField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// [f1, v1, f2, v2...]
+func replyHashFields(v interface{}) ([]HashField, error) {
+	v, ok := flattenPairs(v)
+	if !ok {
+		return nil, ErrBadType
+	}
+	arr, e := replyStrings(v)
+	if e != nil {
+		return nil, e
+	}
+	if len(arr)%2 != 0 {
+		return nil, ErrBadType
+	}
+	ret := make([]HashField, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		ret[i/2] = HashField{Field: arr[i], Value: arr[i+1]}
+	}
+	return ret, nil
+}
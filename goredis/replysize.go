@@ -0,0 +1,56 @@
+package msgredis
+
+import (
+	"strconv"
+)
+
+// bytes charged per array element on top of the bulk string payloads
+const replyElemCost = 8
+
+// TooLargeError is returned when a reply exceeds the reply size limit. The
+// rest of the reply is left unread, so the conn is marked broken and the
+// pool closes it.
+type TooLargeError struct {
+	Command string
+	Limit   int64
+	// bytes announced when the limit was hit, a lower bound of the reply
+	Size int64
+}
+
+func (e *TooLargeError) Error() string {
+	return CommonErrPrefix + "reply to " + e.Command + " exceeds " +
+		strconv.FormatInt(e.Limit, 10) + " bytes (" + strconv.FormatInt(e.Size, 10) + " so far)"
+}
+
+// SetMaxReplySize refuses to materialize replies larger than limit bytes,
+// counting bulk string payloads and array elements. 0 disables the limit.
+func (c *Conn) SetMaxReplySize(limit int64) {
+	c.maxReply = limit
+}
+
+// CallLimit is Call with its own reply size limit, e.g. around an LRANGE
+// whose bounds come from user input
+func (c *Conn) CallLimit(limit int64, command string, args ...interface{}) (interface{}, error) {
+	saved := c.maxReply
+	c.maxReply = limit
+	defer func() { c.maxReply = saved }()
+	v, e := c.Call(command, args...)
+	if tl, ok := e.(*TooLargeError); ok {
+		tl.Command = command
+	}
+	return v, e
+}
+
+// chargeReply counts n more bytes of the reply being read, the size is
+// checked before the payload is allocated
+func (c *Conn) chargeReply(n int64) error {
+	if c.maxReply <= 0 {
+		return nil
+	}
+	c.replySize += n
+	if c.replySize > c.maxReply {
+		c.broken = true
+		return &TooLargeError{Limit: c.maxReply, Size: c.replySize}
+	}
+	return nil
+}
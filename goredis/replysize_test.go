@@ -0,0 +1,47 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxReplySize(t *testing.T) {
+	big := strings.Repeat("x", 1000)
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "LRANGE" {
+			return respArray(big, big, big)
+		}
+		return "$2\r\nok\r\n"
+	})
+	c := dialFake(t, s)
+
+	if _, e := c.CallLimit(10000, "LRANGE", "l", 0, -1); e != nil {
+		t.Fatal(e)
+	}
+	_, e := c.CallLimit(1500, "LRANGE", "l", 0, -1)
+	tl, ok := e.(*TooLargeError)
+	if !ok || tl.Command != "LRANGE" || tl.Limit != 1500 || tl.Size <= 1500 {
+		t.Fatalf("got %#v", e)
+	}
+	if !c.Broken() {
+		t.Fatal("conn not broken")
+	}
+
+	c = dialFake(t, s)
+	c.SetMaxReplySize(1500)
+	c.PipeSend("GET", "k")
+	c.PipeSend("LRANGE", "l", 0, -1)
+	c.PipeSend("GET", "k")
+	ret, e := c.PipeExec()
+	if string(ret[0].([]byte)) != "ok" {
+		t.Fatalf("first reply %v", ret[0])
+	}
+	// the third reply is behind the unread payload
+	pe, ok := e.(*PartialError)
+	if arr, _ := ret[1].([]interface{}); arr != nil || ret[2] != nil || !ok || pe.Completed != 1 || !c.Broken() {
+		t.Fatalf("got %v %v", ret, e)
+	}
+	if _, ok = pe.Err.(*TooLargeError); !ok {
+		t.Fatalf("got %v", pe.Err)
+	}
+}
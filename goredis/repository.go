@@ -0,0 +1,530 @@
+package msgredis
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrVersionConflict = errors.New(CommonErrPrefix + "entity was modified concurrently")
+
+const (
+	versionField = "_version"
+	jsonField    = "_json"
+
+	DefaultBulkBatch       = 100
+	DefaultBulkConcurrency = 4
+)
+
+// BulkError lists the IDs LoadMany or SaveMany failed on, the other
+// entities are still returned
+type BulkError struct {
+	Errs map[string]error
+}
+
+func (e *BulkError) Error() string {
+	ids := make([]string, 0, len(e.Errs))
+	for id := range e.Errs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = id + ": " + e.Errs[id].Error()
+	}
+	return CommonErrPrefix + "bulk failed on " + strings.Join(msgs, ", ")
+}
+
+// Repository stores entities of type T in hashes, key Prefix+ID, with a
+// version field for optimistic concurrency. By default the entity is kept
+// as JSON in one field; with AsHash every struct field is a hash field,
+// named by its `redis` tag as in Args.AddFlat. Secondary indexes are sets
+// of IDs per indexed value, maintained in the same transaction as the
+// entity.
+type Repository[T any] struct {
+	Prefix string
+	AsHash bool
+	// LoadMany and SaveMany pipeline BulkBatch entities per round trip on
+	// at most BulkConcurrency conns at once, 0 takes the defaults
+	BulkBatch       int
+	BulkConcurrency int
+
+	p       *Pool
+	id      func(*T) string
+	indexes map[string]func(*T) string
+}
+
+func NewRepository[T any](p *Pool, prefix string, id func(*T) string) *Repository[T] {
+	return &Repository[T]{Prefix: prefix, p: p, id: id, indexes: make(map[string]func(*T) string)}
+}
+
+// AddIndex maintains a set of IDs per value of fn, queried by ListBy.
+// Entities saved before the index was added are indexed on their next Save.
+func (r *Repository[T]) AddIndex(name string, fn func(*T) string) {
+	r.indexes[name] = fn
+}
+
+func (r *Repository[T]) key(id string) string {
+	return r.Prefix + id
+}
+
+func (r *Repository[T]) indexKey(name, value string) string {
+	return r.Prefix + "idx:" + name + ":" + value
+}
+
+func (r *Repository[T]) encode(v *T, version int64) (Args, error) {
+	args := Args{versionField, version}
+	if r.AsHash {
+		return args.AddFlat(v), nil
+	}
+	b, e := json.Marshal(v)
+	if e != nil {
+		return nil, e
+	}
+	return append(args, jsonField, b), nil
+}
+
+func (r *Repository[T]) decode(fields map[string]string) (*T, int64, error) {
+	version, _ := strconv.ParseInt(fields[versionField], 10, 64)
+	v := new(T)
+	if r.AsHash {
+		return v, version, scanStruct(fields, reflect.ValueOf(v).Elem())
+	}
+	if e := json.Unmarshal([]byte(fields[jsonField]), v); e != nil {
+		return nil, 0, e
+	}
+	return v, version, nil
+}
+
+// Get returns the entity and its version, ErrKeyNotExist if missing
+func (r *Repository[T]) Get(id string) (*T, int64, error) {
+	c := r.p.Pop()
+	if c == nil {
+		return nil, 0, ErrNoConn
+	}
+	defer r.p.Push(c)
+	return r.get(c.Call, id)
+}
+
+func (r *Repository[T]) get(call func(string, ...interface{}) (interface{}, error), id string) (*T, int64, error) {
+	v, e := call("HGETALL", r.key(id))
+	if e != nil {
+		return nil, 0, e
+	}
+	fields, e := replyStringMap(v)
+	if e != nil {
+		return nil, 0, e
+	}
+	if len(fields) == 0 {
+		return nil, 0, ErrKeyNotExist
+	}
+	return r.decode(fields)
+}
+
+// Save stores v if the stored version still is version, 0 for a new
+// entity, and returns the new version. ErrVersionConflict means another
+// writer saved in between: Get again and retry.
+func (r *Repository[T]) Save(v *T, version int64) (int64, error) {
+	id := r.id(v)
+	l, e := r.p.AcquireExclusive()
+	if e != nil {
+		return 0, e
+	}
+	defer l.Release()
+	if e = l.Watch(r.key(id)); e != nil {
+		return 0, e
+	}
+	old, current, e := r.get(l.c.Call, id)
+	if e != nil && e != ErrKeyNotExist {
+		return 0, e
+	}
+	if current != version {
+		return 0, ErrVersionConflict
+	}
+
+	args, e := r.encode(v, version+1)
+	if e != nil {
+		return 0, e
+	}
+	if e = l.MULTI(); e != nil {
+		return 0, e
+	}
+	l.TransSend("DEL", r.key(id))
+	l.TransSend("HSET", Args{r.key(id)}.Add(args...)...)
+	for _, name := range r.sortedIndexes() {
+		fn := r.indexes[name]
+		value := fn(v)
+		if old != nil && fn(old) != value {
+			l.TransSend("SREM", r.indexKey(name, fn(old)), id)
+		}
+		l.TransSend("SADD", r.indexKey(name, value), id)
+	}
+	if _, e = l.TransExec(); e == ErrNil {
+		return 0, ErrVersionConflict
+	}
+	if e != nil {
+		return 0, e
+	}
+	return version + 1, nil
+}
+
+// Delete removes the entity and its index entries
+func (r *Repository[T]) Delete(id string) error {
+	l, e := r.p.AcquireExclusive()
+	if e != nil {
+		return e
+	}
+	defer l.Release()
+	if e = l.Watch(r.key(id)); e != nil {
+		return e
+	}
+	old, _, e := r.get(l.c.Call, id)
+	if e == ErrKeyNotExist {
+		return nil
+	}
+	if e != nil {
+		return e
+	}
+	if e = l.MULTI(); e != nil {
+		return e
+	}
+	l.TransSend("DEL", r.key(id))
+	for _, name := range r.sortedIndexes() {
+		l.TransSend("SREM", r.indexKey(name, r.indexes[name](old)), id)
+	}
+	if _, e = l.TransExec(); e == ErrNil {
+		return ErrVersionConflict
+	}
+	return e
+}
+
+// ListBy returns the entities whose index name has value, sorted by ID.
+// IDs whose entity vanished meanwhile are skipped.
+func (r *Repository[T]) ListBy(name, value string) ([]*T, error) {
+	if _, ok := r.indexes[name]; !ok {
+		return nil, ErrBadArgs
+	}
+	c := r.p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer r.p.Push(c)
+	v, e := c.Call("SMEMBERS", r.indexKey(name, value))
+	if e != nil {
+		return nil, e
+	}
+	ids, e := replyStrings(v)
+	if e != nil || len(ids) == 0 {
+		return nil, e
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		c.PipeSend("HGETALL", r.key(id))
+	}
+	replies, e := c.PipeExec()
+	if e != nil {
+		return nil, e
+	}
+	list := make([]*T, 0, len(ids))
+	for _, reply := range replies {
+		fields, e := replyStringMap(reply)
+		if e != nil {
+			return nil, e
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		entity, _, e := r.decode(fields)
+		if e != nil {
+			return nil, e
+		}
+		list = append(list, entity)
+	}
+	return list, nil
+}
+
+// bulk runs fn on batches of n items, each on its own pool conn
+func (r *Repository[T]) bulk(n int, fn func(c *Conn, from, to int)) {
+	size, workers := r.BulkBatch, r.BulkConcurrency
+	if size <= 0 {
+		size = DefaultBulkBatch
+	}
+	if workers <= 0 {
+		workers = DefaultBulkConcurrency
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for from := 0; from < n; from += size {
+		to := from + size
+		if to > n {
+			to = n
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(from, to int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			c := r.p.Pop()
+			if c == nil {
+				fn(nil, from, to)
+				return
+			}
+			defer r.p.Push(c)
+			fn(c, from, to)
+		}(from, to)
+	}
+	wg.Wait()
+}
+
+// bulkResult collects the outcome of concurrent batches
+type bulkResult struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+func (b *bulkResult) fail(id string, e error) {
+	b.mu.Lock()
+	if b.errs == nil {
+		b.errs = make(map[string]error)
+	}
+	b.errs[id] = e
+	b.mu.Unlock()
+}
+
+func (b *bulkResult) err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return &BulkError{Errs: b.errs}
+}
+
+// LoadMany gets many entities with pipelined HGETALLs, e.g. to hydrate the
+// IDs of a page. The entities found are returned by ID; missing ones and
+// failed batches are listed in a *BulkError, ErrKeyNotExist for missing.
+func (r *Repository[T]) LoadMany(ids []string) (map[string]*T, error) {
+	entities, _, res := r.loadMany(ids)
+	return entities, res.err()
+}
+
+func (r *Repository[T]) loadMany(ids []string) (map[string]*T, map[string]int64, *bulkResult) {
+	entities := make(map[string]*T, len(ids))
+	versions := make(map[string]int64, len(ids))
+	res := &bulkResult{}
+	r.bulk(len(ids), func(c *Conn, from, to int) {
+		batch := ids[from:to]
+		replies, e := r.pipeline(c, batch, func(c *Conn, id string) {
+			c.PipeSend("HGETALL", r.key(id))
+		})
+		for i, id := range batch {
+			if e != nil {
+				res.fail(id, e)
+				continue
+			}
+			v, version, e := r.decodeReply(replies[i])
+			if e != nil {
+				res.fail(id, e)
+				continue
+			}
+			res.mu.Lock()
+			entities[id], versions[id] = v, version
+			res.mu.Unlock()
+		}
+	})
+	return entities, versions, res
+}
+
+// pipeline sends send for every id and reads the replies, e is set for
+// the whole batch. Reply errors stay in the replies.
+func (r *Repository[T]) pipeline(c *Conn, ids []string, send func(*Conn, string)) ([]interface{}, error) {
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	for _, id := range ids {
+		send(c, id)
+	}
+	ret, errs, e := c.pipeExec(time.Time{})
+	if e != nil {
+		return nil, e
+	}
+	for i, err := range errs {
+		if err != nil {
+			if !isReplyError(err) {
+				return nil, err
+			}
+			ret[i] = err
+		}
+	}
+	return ret, nil
+}
+
+func (r *Repository[T]) decodeReply(reply interface{}) (*T, int64, error) {
+	if e, ok := reply.(error); ok {
+		return nil, 0, e
+	}
+	fields, e := replyStringMap(reply)
+	if e != nil {
+		return nil, 0, e
+	}
+	if len(fields) == 0 {
+		return nil, 0, ErrKeyNotExist
+	}
+	return r.decode(fields)
+}
+
+// SaveMany stores many entities, each in a pipelined MULTI/EXEC with its
+// index entries, and returns their new versions by ID. Versions are not
+// checked: concurrent writers of the same entity are last writer wins,
+// Save is the optimistic path. Failures are listed in a *BulkError.
+func (r *Repository[T]) SaveMany(entities []*T) (map[string]int64, error) {
+	ids := make([]string, len(entities))
+	byID := make(map[string]*T, len(entities))
+	for i, v := range entities {
+		ids[i] = r.id(v)
+		byID[ids[i]] = v
+	}
+	// the old entities, for their index entries
+	old, versions, res := r.loadMany(ids)
+	saved := make(map[string]int64, len(ids))
+	var todo []string
+	for _, id := range ids {
+		if e, failed := res.errs[id]; failed && e != ErrKeyNotExist {
+			continue
+		}
+		delete(res.errs, id)
+		todo = append(todo, id)
+	}
+	r.bulk(len(todo), func(c *Conn, from, to int) {
+		batch := todo[from:to]
+		encodeErrs := make(map[string]error)
+		// index of the EXEC reply of each id
+		execs := make(map[string]int)
+		sent := 0
+		send := func(c *Conn, command string, args ...interface{}) {
+			c.PipeSend(command, args...)
+			sent++
+		}
+		replies, e := r.pipeline(c, batch, func(c *Conn, id string) {
+			args, e := r.encode(byID[id], versions[id]+1)
+			if e != nil {
+				encodeErrs[id] = e
+				return
+			}
+			send(c, "MULTI")
+			send(c, "DEL", r.key(id))
+			send(c, "HSET", Args{r.key(id)}.Add(args...)...)
+			for _, name := range r.sortedIndexes() {
+				fn := r.indexes[name]
+				value := fn(byID[id])
+				if o := old[id]; o != nil && fn(o) != value {
+					send(c, "SREM", r.indexKey(name, fn(o)), id)
+				}
+				send(c, "SADD", r.indexKey(name, value), id)
+			}
+			send(c, "EXEC")
+			execs[id] = sent - 1
+		})
+		for _, id := range batch {
+			switch {
+			case encodeErrs[id] != nil:
+				res.fail(id, encodeErrs[id])
+			case e != nil:
+				res.fail(id, e)
+			default:
+				if err := execError(replies[execs[id]]); err != nil {
+					res.fail(id, err)
+					continue
+				}
+				res.mu.Lock()
+				saved[id] = versions[id] + 1
+				res.mu.Unlock()
+			}
+		}
+	})
+	return saved, res.err()
+}
+
+// execError returns the error of an EXEC reply or of a command in it
+func execError(reply interface{}) error {
+	switch v := reply.(type) {
+	case error:
+		return v
+	case []interface{}:
+		for _, r := range v {
+			if e, ok := r.(error); ok {
+				return e
+			}
+		}
+		return nil
+	}
+	return ErrBadType
+}
+
+func (r *Repository[T]) sortedIndexes() []string {
+	names := make([]string, 0, len(r.indexes))
+	for name := range r.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scanStruct sets the fields of rv named as in Args.AddFlat from a hash,
+// strings, bools, ints, uints and floats are supported
+func scanStruct(fields map[string]string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("redis"); ok {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		s, ok := fields[name]
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(s)
+		case reflect.Bool:
+			fv.SetBool(s == "1" || s == "true")
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, e := strconv.ParseInt(s, 10, 64)
+			if e != nil {
+				return e
+			}
+			fv.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, e := strconv.ParseUint(s, 10, 64)
+			if e != nil {
+				return e
+			}
+			fv.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, e := strconv.ParseFloat(s, 64)
+			if e != nil {
+				return e
+			}
+			fv.SetFloat(n)
+		default:
+			return ErrBadType
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package msgredis
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// hashes, sets and MULTI/EXEC for one client at a time; abort makes the
+// next EXEC fail as if a WATCHed key changed
+type txStore struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+	queue  [][]string
+	multi  bool
+	abort  bool
+}
+
+func newTxStore() *txStore {
+	return &txStore{hashes: map[string]map[string]string{}, sets: map[string]map[string]bool{}}
+}
+
+func (s *txStore) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch args[0] {
+	case "MULTI":
+		s.multi = true
+		return "+OK\r\n"
+	case "EXEC":
+		s.multi = false
+		queue := s.queue
+		s.queue = nil
+		if s.abort {
+			s.abort = false
+			return "*-1\r\n"
+		}
+		r := "*" + strconv.Itoa(len(queue)) + "\r\n"
+		for _, cmd := range queue {
+			r += s.exec(cmd)
+		}
+		return r
+	case "DISCARD", "WATCH", "UNWATCH":
+		s.multi = false
+		s.queue = nil
+		return "+OK\r\n"
+	}
+	if s.multi {
+		s.queue = append(s.queue, args)
+		return "+QUEUED\r\n"
+	}
+	return s.exec(args)
+}
+
+func (s *txStore) exec(args []string) string {
+	switch args[0] {
+	case "DEL":
+		delete(s.hashes, args[1])
+		return ":1\r\n"
+	case "HSET":
+		h := map[string]string{}
+		for k, v := range s.hashes[args[1]] {
+			h[k] = v
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			h[args[i]] = args[i+1]
+		}
+		s.hashes[args[1]] = h
+		return ":1\r\n"
+	case "HGETALL":
+		kv := []string{}
+		for k, v := range s.hashes[args[1]] {
+			kv = append(kv, k, v)
+		}
+		return respArray(kv...)
+	case "SADD", "SREM":
+		if s.sets[args[1]] == nil {
+			s.sets[args[1]] = map[string]bool{}
+		}
+		if args[0] == "SADD" {
+			s.sets[args[1]][args[2]] = true
+		} else {
+			delete(s.sets[args[1]], args[2])
+		}
+		return ":1\r\n"
+	case "SMEMBERS":
+		members := []string{}
+		for m := range s.sets[args[1]] {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		return respArray(members...)
+	}
+	return "-ERR unknown " + args[0] + "\r\n"
+}
+
+type account struct {
+	ID    string `redis:"id"`
+	Owner string `redis:"owner"`
+	Plan  string `redis:"plan"`
+	Seats int    `redis:"seats"`
+}
+
+func TestRepository(t *testing.T) {
+	for _, asHash := range []bool{false, true} {
+		store := newTxStore()
+		s := newFakeServer(t, store.handle)
+		repo := NewRepository(NewPool(s.Addr(), ""), "acct:", func(a *account) string { return a.ID })
+		repo.AsHash = asHash
+		repo.AddIndex("plan", func(a *account) string { return a.Plan })
+
+		a := &account{ID: "1", Owner: "ann", Plan: "free", Seats: 1}
+		v, e := repo.Save(a, 0)
+		if e != nil || v != 1 {
+			t.Fatalf("save: %d %v", v, e)
+		}
+		repo.Save(&account{ID: "2", Owner: "bob", Plan: "free"}, 0)
+
+		got, version, e := repo.Get("1")
+		if e != nil || version != 1 || *got != *a {
+			t.Fatalf("get: %+v %d %v", got, version, e)
+		}
+
+		// stale version
+		if _, e = repo.Save(a, 0); e != ErrVersionConflict {
+			t.Fatalf("stale save: %v", e)
+		}
+		// concurrent write between WATCH and EXEC
+		store.abort = true
+		if _, e = repo.Save(a, 1); e != ErrVersionConflict {
+			t.Fatalf("aborted save: %v", e)
+		}
+
+		a.Plan = "pro"
+		if _, e = repo.Save(a, 1); e != nil {
+			t.Fatal(e)
+		}
+		free, _ := repo.ListBy("plan", "free")
+		pro, _ := repo.ListBy("plan", "pro")
+		if len(free) != 1 || free[0].ID != "2" || len(pro) != 1 || pro[0].Seats != 1 {
+			t.Fatalf("index: free %v pro %v", free, pro)
+		}
+
+		if e = repo.Delete("1"); e != nil {
+			t.Fatal(e)
+		}
+		if _, _, e = repo.Get("1"); e != ErrKeyNotExist {
+			t.Fatalf("deleted: %v", e)
+		}
+		if pro, _ = repo.ListBy("plan", "pro"); len(pro) != 0 {
+			t.Fatalf("index after delete: %v", pro)
+		}
+	}
+}
+
+func TestRepositoryBulk(t *testing.T) {
+	store := newTxStore()
+	s := newFakeServer(t, store.handle)
+	repo := NewRepository(NewPool(s.Addr(), ""), "acct:", func(a *account) string { return a.ID })
+	repo.AddIndex("plan", func(a *account) string { return a.Plan })
+	repo.BulkBatch = 10
+	repo.Save(&account{ID: "0", Plan: "free"}, 0)
+
+	// txStore queues MULTI of one client at a time
+	repo.BulkConcurrency = 1
+	var accounts []*account
+	ids := []string{}
+	for i := 0; i < 25; i++ {
+		id := strconv.Itoa(i)
+		accounts = append(accounts, &account{ID: id, Plan: "pro", Seats: i})
+		ids = append(ids, id)
+	}
+	versions, e := repo.SaveMany(accounts)
+	if e != nil || len(versions) != 25 || versions["0"] != 2 || versions["24"] != 1 {
+		t.Fatalf("save: %v %v", versions, e)
+	}
+	if free, _ := repo.ListBy("plan", "free"); len(free) != 0 {
+		t.Fatalf("old index entry kept: %v", free)
+	}
+
+	repo.BulkConcurrency = 3
+	got, e := repo.LoadMany(append(ids, "missing"))
+	bulkErr, ok := e.(*BulkError)
+	if !ok || len(bulkErr.Errs) != 1 || bulkErr.Errs["missing"] != ErrKeyNotExist {
+		t.Fatalf("load error: %v", e)
+	}
+	if len(got) != 25 || got["7"].Seats != 7 || got["0"].Plan != "pro" {
+		t.Fatalf("load: %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package msgredis
+
+import (
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxRedirects = 5
+	TryAgainWait        = 50 * time.Millisecond
+)
+
+var ErrTooManyRedirects = errors.New(CommonErrPrefix + "too many redirects")
+
+// Resharding keeps one pool per cluster node and follows redirects, so
+// keys of a slot that is being moved between nodes stay available:
+// keys already moved to the IMPORTING node are served there with ASKING,
+// keys not yet moved are served by the MIGRATING node.
+type Resharding struct {
+	Password     string
+	MaxRedirects int
+	// see Pool.TLSConfig and Pool.Credentials, set them before the first
+	// call
+	TLSConfig   *tls.Config
+	Credentials CredentialsProvider
+
+	mu    sync.Mutex
+	pools map[string]*Pool
+}
+
+func NewResharding(password string) *Resharding {
+	return &Resharding{
+		Password:     password,
+		MaxRedirects: DefaultMaxRedirects,
+		pools:        make(map[string]*Pool),
+	}
+}
+
+func (r *Resharding) pool(addr string) *Pool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pools[addr]
+	if !ok {
+		p = NewPool(addr, r.Password)
+		p.TLSConfig = r.TLSConfig
+		p.Credentials = r.Credentials
+		p.ClusterMode = true
+		r.pools[addr] = p
+	}
+	return p
+}
+
+// Call sends command to addr, the node believed to own the key, and follows
+// MOVED/ASK redirects. TRYAGAIN replies are retried after TryAgainWait.
+func (r *Resharding) Call(addr, command string, args ...interface{}) (interface{}, error) {
+	return r.call(addr, nil, command, args...)
+}
+
+// call is Call telling moved about every MOVED on the way
+func (r *Resharding) call(addr string, moved func(*RedirectError), command string, args ...interface{}) (interface{}, error) {
+	asking := false
+	for i := 0; i <= r.MaxRedirects; i++ {
+		p := r.pool(addr)
+		c := p.Pop()
+		if c == nil {
+			return nil, errors.New("[Resharding] no conn for " + addr)
+		}
+		var ret interface{}
+		var e error
+		if asking {
+			e = c.ASKING()
+		}
+		if e == nil {
+			ret, e = c.Call(command, args...)
+		}
+		p.Push(c)
+
+		if re, ok := ParseRedirect(e); ok {
+			if !re.Ask && moved != nil {
+				moved(re)
+			}
+			addr = re.Addr
+			asking = re.Ask
+			continue
+		}
+		if IsTryAgain(e) {
+			time.Sleep(TryAgainWait)
+			continue
+		}
+		return ret, e
+	}
+	return nil, ErrTooManyRedirects
+}
+
+func (r *Resharding) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for addr, p := range r.pools {
+		for p.Idles() > 0 {
+			c := p.Pop()
+			if c == nil {
+				break
+			}
+			c.Close()
+		}
+		delete(r.pools, addr)
+	}
+}
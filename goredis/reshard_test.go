@@ -0,0 +1,53 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReshardingAsk(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	record := func(node string, args []string) {
+		mu.Lock()
+		got = append(got, node+" "+strings.Join(args, " "))
+		mu.Unlock()
+	}
+	importing := newFakeServer(t, func(args []string) string {
+		record("importing", args)
+		if args[0] == "ASKING" {
+			return "+OK\r\n"
+		}
+		return bulk("imported")
+	})
+	migrating := newFakeServer(t, func(args []string) string {
+		record("migrating", args)
+		if args[1] == "moved" {
+			return "-ASK " + strconv.Itoa(int(KeySlot("moved"))) + " " + importing.Addr() + "\r\n"
+		}
+		return bulk("local")
+	})
+
+	r := NewResharding("")
+	defer r.Close()
+	var redirects []*RedirectError
+	v, e := r.call(migrating.Addr(), func(re *RedirectError) { redirects = append(redirects, re) }, "GET", "moved")
+	if e != nil || string(v.([]byte)) != "imported" {
+		t.Fatalf("ask: %v %v", v, e)
+	}
+	// ASK is a one off redirect, the slot still belongs to the migrating node
+	if len(redirects) != 0 {
+		t.Fatalf("slot map updated by ASK: %+v", redirects)
+	}
+	if v, e = r.Call(migrating.Addr(), "GET", "kept"); e != nil || string(v.([]byte)) != "local" {
+		t.Fatalf("not moved: %v %v", v, e)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := "migrating GET moved,importing ASKING,importing GET moved,migrating GET kept"
+	if s := strings.Join(got, ","); s != want {
+		t.Fatalf("sent %q", s)
+	}
+}
@@ -0,0 +1,267 @@
+package msgredis
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotRESP3 = errors.New(CommonErrPrefix + "conn is not in RESP3, call HELLO3 first")
+
+// a RESP3 push frame, never returned as a reply
+type pushFrame []interface{}
+
+// PushMessage is an out of band RESP3 frame: pub/sub messages, client side
+// caching invalidations, subscribe confirmations
+type PushMessage struct {
+	// message, pmessage, smessage, invalidate...
+	Kind    string
+	Channel string
+	// pmessage only
+	Pattern string
+	Payload []byte
+	Data    []interface{}
+}
+
+// HELLO3 switches the conn to RESP3. Maps are decoded as flat key value
+// arrays and booleans as 0/1 integers, so the RESP2 helpers keep working.
+// The pool switches conns back to RESP2 when they are pushed.
+func (c *Conn) HELLO3() error {
+	v, e := c.Call("HELLO", 3)
+	if e != nil {
+		return e
+	}
+	fields, ok := v.([]interface{})
+	if !ok {
+		return ErrBadType
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if k, _ := replyString(fields[i]); k == "proto" {
+			if n, _ := fields[i+1].(int64); n == 3 {
+				c.resp3 = true
+				return nil
+			}
+		}
+	}
+	return ErrBadType
+}
+
+// SetPushHandler receives the push frames read in front of command
+// replies, it runs on the goroutine using the conn. Without a handler push
+// frames are dropped.
+func (c *Conn) SetPushHandler(h func(*PushMessage)) {
+	c.pushHandler = h
+}
+
+func (c *Conn) dispatchPush(push pushFrame) {
+	if c.pushHandler == nil || len(push) == 0 {
+		return
+	}
+	m := &PushMessage{Data: push}
+	m.Kind, _ = replyString(push[0])
+	switch {
+	case (m.Kind == "message" || m.Kind == "smessage") && len(push) == 3:
+		m.Channel, _ = replyString(push[1])
+		m.Payload, _ = push[2].([]byte)
+	case m.Kind == "pmessage" && len(push) == 4:
+		m.Pattern, _ = replyString(push[1])
+		m.Channel, _ = replyString(push[2])
+		m.Payload, _ = push[3].([]byte)
+	}
+	c.pushHandler(m)
+}
+
+func (c *Conn) readResp3(resType byte, p []byte) (interface{}, error) {
+	switch resType {
+	case TypeNull:
+		return nil, nil
+	case TypeBool:
+		if string(p) == "t" {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case TypeBigNumber:
+		return p, nil
+	case TypeVerbatim:
+		v, e := c.parseBulkString(p)
+		// "txt:" or "mkd:" in front of the text
+		if b, ok := v.([]byte); ok && len(b) >= 4 {
+			return b[4:], e
+		}
+		return v, e
+	case TypeBlobError:
+		v, e := c.parseBulkString(p)
+		if e != nil {
+			return nil, e
+		}
+		b, _ := v.([]byte)
+		return nil, &ReplyError{Msg: string(b)}
+	case TypeSet:
+		return c.parseArray(p)
+	case TypePush:
+		arr, e := c.parseArray(p)
+		return pushFrame(arr), e
+	}
+	// maps and attributes are n pairs
+	n, e := strconv.ParseInt(string(p), 10, 64)
+	if e != nil {
+		return nil, errors.New(CommonErrPrefix + e.Error())
+	}
+	arr, e := c.parseArray([]byte(strconv.FormatInt(2*n, 10)))
+	if e != nil || resType == TypeMap {
+		return arr, e
+	}
+	// attributes describe the next reply, skipped
+	return c.readFrame()
+}
+
+// subscribe commands answered by push frames of the given kind
+var subscribeKinds = map[string]string{
+	"SUBSCRIBE":    "subscribe",
+	"PSUBSCRIBE":   "psubscribe",
+	"SSUBSCRIBE":   "ssubscribe",
+	"UNSUBSCRIBE":  "unsubscribe",
+	"PUNSUBSCRIBE": "punsubscribe",
+	"SUNSUBSCRIBE": "sunsubscribe",
+}
+
+// Subscribe shares the conn between commands and subscriptions, messages
+// go to the push handler whenever the conn reads. Low-volume notifications
+// need no dedicated conn; an idle conn is drained with PollPush.
+func (c *Conn) Subscribe(channels ...string) error {
+	return c.subscribe("SUBSCRIBE", channels)
+}
+
+func (c *Conn) PSubscribe(patterns ...string) error {
+	return c.subscribe("PSUBSCRIBE", patterns)
+}
+
+func (c *Conn) Unsubscribe(channels ...string) error {
+	return c.subscribe("UNSUBSCRIBE", channels)
+}
+
+func (c *Conn) PUnsubscribe(patterns ...string) error {
+	return c.subscribe("PUNSUBSCRIBE", patterns)
+}
+
+// subscribe sends a (un)subscribe command and waits for the confirmation of
+// every channel
+func (c *Conn) subscribe(command string, channels []string) error {
+	if !c.resp3 {
+		return ErrNotRESP3
+	}
+	if len(channels) == 0 {
+		return ErrBadArgs
+	}
+	if e := c.checkProxy(command); e != nil {
+		return e
+	}
+	if e := c.enter(command); e != nil {
+		return e
+	}
+	defer c.leave()
+	_, e := c.callPush(command, Args{}.Add(channels))
+	return e
+}
+
+// callPush is call for commands answered by push frames, one per argument
+func (c *Conn) callPush(command string, args []interface{}) (interface{}, error) {
+	if c.broken {
+		return nil, ErrBrokenConn
+	}
+	if len(args) == 0 {
+		// the number of confirmations would be unknown
+		return nil, ErrBadArgs
+	}
+	kind := subscribeKinds[strings.ToUpper(command)]
+	c.trackState(command, args)
+	var e error
+	if c.writeTimeout > 0 {
+		if e = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); e != nil {
+			return nil, e
+		}
+	}
+	if e = c.writeRequest(command, args); e == nil {
+		e = c.wb.Flush()
+	}
+	if e != nil {
+		c.broken = true
+		return nil, e
+	}
+	c.addInflight(1)
+	defer c.addInflight(-1)
+	if c.readTimeout > 0 {
+		if e = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); e != nil {
+			return nil, e
+		}
+	}
+	for pending := len(args); pending > 0; {
+		v, e := c.readFrame()
+		if e != nil {
+			c.checkBroken(e)
+			return nil, e
+		}
+		push, ok := v.(pushFrame)
+		if !ok {
+			c.broken = true
+			return nil, ErrBadType
+		}
+		if k, _ := replyString(push[0]); k != kind || len(push) != 3 {
+			c.dispatchPush(push)
+			continue
+		}
+		pending--
+		if n, _ := push[2].(int64); n == 0 {
+			c.state &^= stateSubscribed
+		}
+	}
+	return nil, nil
+}
+
+// PollPush waits up to timeout for push frames on an otherwise idle conn
+// and hands them to the push handler. It returns nil when nothing arrived.
+func (c *Conn) PollPush(timeout time.Duration) error {
+	if !c.resp3 {
+		return ErrNotRESP3
+	}
+	if e := c.enter("PollPush"); e != nil {
+		return e
+	}
+	defer c.leave()
+	if c.broken {
+		return ErrBrokenConn
+	}
+	if e := c.conn.SetReadDeadline(time.Now().Add(timeout)); e != nil {
+		return e
+	}
+	// peek consumes nothing, a timeout leaves the stream intact
+	if _, e := c.rb.Peek(1); e != nil {
+		if ne, ok := e.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		c.broken = true
+		return e
+	}
+	for c.rb.Buffered() > 0 {
+		if c.readTimeout > 0 {
+			if e := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); e != nil {
+				return e
+			}
+		}
+		v, e := c.readFrame()
+		if e != nil {
+			c.checkBroken(e)
+			return e
+		}
+		push, ok := v.(pushFrame)
+		if !ok {
+			// a reply nobody waits for
+			c.broken = true
+			return ErrBadType
+		}
+		c.dispatchPush(push)
+	}
+	return nil
+}
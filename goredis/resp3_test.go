@@ -0,0 +1,86 @@
+package msgredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRESP3Types(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%2\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n"
+		case "MAP":
+			return "%2\r\n+a\r\n#t\r\n+b\r\n_\r\n"
+		case "SET":
+			return "~2\r\n+x\r\n(12345678901234567890\r\n"
+		case "VERB":
+			return "|1\r\n+ttl\r\n:3\r\n=9\r\ntxt:hello\r\n"
+		}
+		return "!9\r\nERR blob!\r\n"
+	})
+	c := dialFake(t, s)
+	if e := c.HELLO3(); e != nil {
+		t.Fatal(e)
+	}
+	v, e := c.Call("MAP")
+	arr := v.([]interface{})
+	if e != nil || len(arr) != 4 || arr[1].(int64) != 1 || arr[3] != nil {
+		t.Fatalf("map %v %v", v, e)
+	}
+	v, _ = c.Call("SET")
+	if arr = v.([]interface{}); string(arr[1].([]byte)) != "12345678901234567890" {
+		t.Fatalf("set %v", v)
+	}
+	if v, _ = c.Call("VERB"); string(v.([]byte)) != "hello" {
+		t.Fatalf("verbatim %q", v)
+	}
+	if _, e = c.Call("OTHER"); !isReplyError(e) || c.Broken() {
+		t.Fatalf("blob error %v", e)
+	}
+}
+
+func TestRESP3Subscribe(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n$5\r\nproto\r\n:3\r\n"
+		case "SUBSCRIBE":
+			return ">3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n" +
+				">3\r\n$7\r\nmessage\r\n$1\r\na\r\n$5\r\nearly\r\n" +
+				">3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n"
+		case "UNSUBSCRIBE":
+			return ">3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n>3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:0\r\n"
+		case "GET":
+			return ">3\r\n$7\r\nmessage\r\n$1\r\nb\r\n$2\r\nhi\r\n$1\r\nv\r\n"
+		case "PUB":
+			return "" // nothing, the message follows out of band
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	if e := c.Subscribe("a"); e != ErrNotRESP3 {
+		t.Fatalf("got %v", e)
+	}
+	c.HELLO3()
+	var got []string
+	c.SetPushHandler(func(m *PushMessage) {
+		got = append(got, m.Kind+":"+m.Channel+":"+string(m.Payload))
+	})
+	if e := c.Subscribe("a", "b"); e != nil {
+		t.Fatal(e)
+	}
+	v, e := c.Call("GET", "k")
+	if e != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("got %v %v", v, e)
+	}
+	if len(got) != 2 || got[0] != "message:a:early" || got[1] != "message:b:hi" {
+		t.Fatalf("messages %v", got)
+	}
+	if e = c.PollPush(10 * time.Millisecond); e != nil || c.Broken() {
+		t.Fatalf("poll %v", e)
+	}
+	if _, e = c.Call("UNSUBSCRIBE", "a", "b"); e != nil || c.state&stateSubscribed != 0 {
+		t.Fatalf("unsubscribe %v state %d", e, c.state)
+	}
+}
@@ -0,0 +1,87 @@
+package msgredis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Rotate closes and re-dials a fraction of the pool conns every interval
+// until ctx is done, so a long-lived pool picks up DNS changes, new
+// certificates and rebalanced load balancers a few conns at a time
+// instead of in a burst of reconnects. fraction is in (0, 1], e.g. 0.1
+// re-dials every conn within about 10 intervals. Only idle conns are
+// rotated, each is kept off the free lists while its replacement dials.
+func (p *Pool) Rotate(ctx context.Context, interval time.Duration, fraction float64) error {
+	if interval <= 0 || fraction <= 0 || fraction > 1 {
+		return ErrBadArgs
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.rotate(ctx, fraction)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// rotate re-dials fraction of the conns, it returns how many were re-dialed
+func (p *Pool) rotate(ctx context.Context, fraction float64) int {
+	n := int(math.Ceil(fraction * float64(p.Idles()+p.Actives())))
+	// conns dialed since the previous round wait for the next one, so the
+	// older ones go first
+	start := time.Now().UnixNano()
+	since := atomic.SwapInt64(&p.lastRotation, start)
+	if since == 0 {
+		since = start
+	}
+	rotated := 0
+	// kept aside until the end so every idle conn is looked at once
+	var skipped []*Conn
+	for tries := p.Idles(); rotated < n && tries > 0 && !p.closed(); tries-- {
+		c := p.take()
+		if c == nil {
+			break
+		}
+		if !c.dialedAt.Before(time.Unix(0, since)) {
+			skipped = append(skipped, c)
+			continue
+		}
+		fresh, e := p.dial(ctx)
+		if e == nil {
+			if e = p.configure(fresh); e != nil {
+				fresh.Close()
+			}
+		}
+		if e != nil {
+			p.putBack(c)
+			fmt.Println("[Rotate] " + e.Error())
+			break
+		}
+		c.Close()
+		fresh.setIdle(true)
+		skipped = append(skipped, fresh)
+		rotated++
+	}
+	for _, c := range skipped {
+		p.putBack(c)
+	}
+	return rotated
+}
+
+// putBack returns a conn taken off the free lists, IdleNum still counts it
+func (p *Pool) putBack(c *Conn) {
+	if !p.put(c) {
+		c.setIdle(false)
+		c.Close()
+		atomic.AddInt64(&p.IdleNum, -1)
+	}
+}
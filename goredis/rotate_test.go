@@ -0,0 +1,48 @@
+package msgredis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolRotate(t *testing.T) {
+	s := newFakeServer(t, okHandler)
+	p := NewPool(s.Addr(), "")
+	old := map[*Conn]bool{}
+	conns := make([]*Conn, 4)
+	for i := range conns {
+		conns[i] = p.Pop()
+		old[conns[i]] = true
+	}
+	for _, c := range conns {
+		p.Push(c)
+	}
+
+	if n := p.rotate(context.Background(), 0.5); n != 2 {
+		t.Fatalf("rotated %d", n)
+	}
+	if p.Idles() != 4 || p.Actives() != 0 {
+		t.Fatalf("%d/%d", p.Idles(), p.Actives())
+	}
+	// the next round skips the conns dialed by the previous one
+	if n := p.rotate(context.Background(), 0.5); n != 2 {
+		t.Fatalf("rotated %d", n)
+	}
+	for i := range conns {
+		conns[i] = p.Pop()
+		if old[conns[i]] {
+			t.Fatal("conn not rotated")
+		}
+		if _, e := conns[i].Call("PING"); e != nil {
+			t.Fatal(e)
+		}
+	}
+	for _, c := range conns {
+		p.Push(c)
+	}
+
+	if e := p.Rotate(context.Background(), time.Second, 2); e != ErrBadArgs {
+		t.Fatalf("got %v", e)
+	}
+}
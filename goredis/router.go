@@ -0,0 +1,182 @@
+package msgredis
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrUnknownReplica = errors.New(CommonErrPrefix + "unknown replica")
+
+type routedReplica struct {
+	pool   *Pool
+	weight float64
+	zone   string
+}
+
+// ReadRouter sends reads to replicas and everything else to the master.
+// Replicas are picked at random in proportion to their weight, a replica
+// with twice the weight gets twice the reads. With latency weights on, the
+// static weight is also divided by the replica's median latency. With a
+// local zone set, replicas of that zone are preferred and the others only
+// serve reads when no local replica has weight.
+type ReadRouter struct {
+	master *Pool
+
+	mu             sync.RWMutex
+	replicas       []*routedReplica
+	latencyWeights bool
+	localZone      string
+}
+
+// NewReadRouter routes to replicas of weight 1, using the master password
+func NewReadRouter(master *Pool, replicas ...string) *ReadRouter {
+	r := &ReadRouter{master: master}
+	for _, addr := range replicas {
+		r.replicas = append(r.replicas, &routedReplica{pool: NewPool(addr, master.Password), weight: 1})
+	}
+	return r
+}
+
+// SetWeight changes the static weight of a replica at runtime, 0 takes it
+// out of rotation
+func (r *ReadRouter) SetWeight(addr string, weight float64) error {
+	if weight < 0 {
+		return ErrBadArgs
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rep := range r.replicas {
+		if rep.pool.Address == addr {
+			rep.weight = weight
+			return nil
+		}
+	}
+	return ErrUnknownReplica
+}
+
+// SetZone tags a replica with its availability zone
+func (r *ReadRouter) SetZone(addr, zone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rep := range r.replicas {
+		if rep.pool.Address == addr {
+			rep.zone = zone
+			return nil
+		}
+	}
+	return ErrUnknownReplica
+}
+
+// SetLocalZone is the zone of this client, "" disables the preference
+func (r *ReadRouter) SetLocalZone(zone string) {
+	r.mu.Lock()
+	r.localZone = zone
+	r.mu.Unlock()
+}
+
+// SetLatencyWeights scales weights by 1/p50 of the replica pools
+func (r *ReadRouter) SetLatencyWeights(on bool) {
+	r.mu.Lock()
+	r.latencyWeights = on
+	r.mu.Unlock()
+}
+
+// Weights returns the effective weight of every replica
+func (r *ReadRouter) Weights() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	weights := r.weights(r.replicas)
+	m := make(map[string]float64, len(weights))
+	for i, rep := range r.replicas {
+		m[rep.pool.Address] = weights[i]
+	}
+	return m
+}
+
+// weights of reps, replicas without latency samples are treated like the
+// fastest one so they get traffic to measure
+func (r *ReadRouter) weights(reps []*routedReplica) []float64 {
+	weights := make([]float64, len(reps))
+	if !r.latencyWeights {
+		for i, rep := range reps {
+			weights[i] = rep.weight
+		}
+		return weights
+	}
+	var fastest time.Duration
+	p50 := make([]time.Duration, len(reps))
+	for i, rep := range reps {
+		p50[i] = rep.pool.Stats().Latency.P50
+		if p50[i] > 0 && (fastest == 0 || p50[i] < fastest) {
+			fastest = p50[i]
+		}
+	}
+	for i, rep := range reps {
+		d := p50[i]
+		if d == 0 {
+			d = fastest
+		}
+		if d == 0 {
+			weights[i] = rep.weight
+			continue
+		}
+		weights[i] = rep.weight * float64(time.Millisecond) / float64(d)
+	}
+	return weights
+}
+
+// pick returns a replica pool by weight, the master if no replica has weight
+func (r *ReadRouter) pick(reps []*routedReplica) *Pool {
+	weights := r.weights(reps)
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return r.master
+	}
+	x := rand.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return reps[i].pool
+		}
+		x -= w
+	}
+	return reps[len(reps)-1].pool
+}
+
+// PoolFor returns the pool command goes to
+func (r *ReadRouter) PoolFor(command string) *Pool {
+	ci := LookupCommand(command)
+	if ci == nil || !ci.Is(FlagReadOnly) || ci.Is(FlagWrite) {
+		return r.master
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.localZone != "" {
+		local := make([]*routedReplica, 0, len(r.replicas))
+		for _, rep := range r.replicas {
+			if rep.zone == r.localZone && rep.weight > 0 {
+				local = append(local, rep)
+			}
+		}
+		if len(local) > 0 {
+			return r.pick(local)
+		}
+	}
+	return r.pick(r.replicas)
+}
+
+// Call runs reads on a replica, other commands on the master. Replicas
+// replicate asynchronously, so a read right after a write may be stale.
+func (r *ReadRouter) Call(command string, args ...interface{}) (interface{}, error) {
+	p := r.PoolFor(command)
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	defer p.Push(c)
+	return c.Call(command, args...)
+}
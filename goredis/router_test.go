@@ -0,0 +1,83 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestReadRouterWeights(t *testing.T) {
+	answer := func(name string) func([]string) string {
+		return func(args []string) string { return "$1\r\n" + name + "\r\n" }
+	}
+	master := newFakeServer(t, answer("m"))
+	a := newFakeServer(t, answer("a"))
+	b := newFakeServer(t, answer("b"))
+	r := NewReadRouter(NewPool(master.Addr(), ""), a.Addr(), b.Addr())
+	if e := r.SetWeight(a.Addr(), 3); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.SetWeight("127.0.0.1:1", 1); e != ErrUnknownReplica {
+		t.Fatalf("got %v", e)
+	}
+
+	count := map[string]int{}
+	for i := 0; i < 400; i++ {
+		ret, e := r.Call("GET", "k")
+		if e != nil {
+			t.Fatal(e)
+		}
+		count[string(ret.([]byte))]++
+	}
+	if count["m"] != 0 || count["a"] < 2*count["b"] {
+		t.Fatalf("reads %v", count)
+	}
+	if ret, _ := r.Call("SET", "k", "v"); string(ret.([]byte)) != "m" {
+		t.Fatal("write not sent to the master")
+	}
+
+	r.SetWeight(a.Addr(), 0)
+	r.SetWeight(b.Addr(), 0)
+	if ret, _ := r.Call("GET", "k"); string(ret.([]byte)) != "m" {
+		t.Fatal("no fallback to the master")
+	}
+
+	r.SetWeight(a.Addr(), 1)
+	r.SetWeight(b.Addr(), 1)
+	r.SetLatencyWeights(true)
+	if w := r.Weights(); w[a.Addr()] <= 0 || w[b.Addr()] <= 0 {
+		t.Fatalf("weights %v", w)
+	}
+}
+
+func TestReadRouterZones(t *testing.T) {
+	answer := func(name string) func([]string) string {
+		return func(args []string) string { return "$1\r\n" + name + "\r\n" }
+	}
+	master := newFakeServer(t, answer("m"))
+	a := newFakeServer(t, answer("a"))
+	b := newFakeServer(t, answer("b"))
+	r := NewReadRouter(NewPool(master.Addr(), ""), a.Addr(), b.Addr())
+	r.SetZone(a.Addr(), "eu-1a")
+	r.SetZone(b.Addr(), "eu-1b")
+	r.SetLocalZone("eu-1b")
+
+	for i := 0; i < 50; i++ {
+		if ret, _ := r.Call("GET", "k"); string(ret.([]byte)) != "b" {
+			t.Fatalf("read from %s", ret)
+		}
+	}
+	// cross zone once the local replica is out of rotation
+	r.SetWeight(b.Addr(), 0)
+	if ret, _ := r.Call("GET", "k"); string(ret.([]byte)) != "a" {
+		t.Fatalf("read from %s", ret)
+	}
+	r.SetLocalZone("us-1a")
+	r.SetWeight(b.Addr(), 1)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		ret, _ := r.Call("GET", "k")
+		seen[string(ret.([]byte))] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Fatalf("reads %v", seen)
+	}
+}
@@ -0,0 +1,108 @@
+package msgredis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrNoResponder = errors.New(CommonErrPrefix + "no subscriber on request channel")
+
+// RPCRequest is the message published on the request channel, the
+// responder publishes its answer to ReplyTo.
+type RPCRequest struct {
+	ReplyTo string `json:"reply_to"`
+	Body    []byte `json:"body"`
+}
+
+// ParseRPCRequest decodes a message received on a request channel
+func ParseRPCRequest(msg []byte) (*RPCRequest, error) {
+	req := &RPCRequest{}
+	if e := json.Unmarshal(msg, req); e != nil {
+		return nil, e
+	}
+	if req.ReplyTo == "" {
+		return nil, ErrBadArgs
+	}
+	return req, nil
+}
+
+// RPCReply publishes the response of req
+func RPCReply(c *Conn, req *RPCRequest, body []byte) error {
+	_, e := c.Call("PUBLISH", req.ReplyTo, body)
+	return e
+}
+
+func replyChannel() (string, error) {
+	b := make([]byte, 16)
+	if _, e := rand.Read(b); e != nil {
+		return "", e
+	}
+	return "rpc:reply:" + hex.EncodeToString(b), nil
+}
+
+// Request publishes body on channel with a unique reply channel and waits
+// up to timeout for the answer. The reply channel is subscribed on a
+// dedicated conn before publishing, so the answer cannot be missed, and
+// the conn is closed afterwards instead of going back to the pool.
+func (p *Pool) Request(channel string, body []byte, timeout time.Duration) ([]byte, error) {
+	replyTo, e := replyChannel()
+	if e != nil {
+		return nil, e
+	}
+	msg, e := json.Marshal(&RPCRequest{ReplyTo: replyTo, Body: body})
+	if e != nil {
+		return nil, e
+	}
+
+	deadline := time.Now().Add(timeout)
+	sub, e := p.lone(context.Background(), timeout)
+	if e != nil {
+		return nil, e
+	}
+	defer sub.Close()
+	// ["subscribe", channel, count]
+	if _, e = sub.Call("SUBSCRIBE", replyTo); e != nil {
+		return nil, e
+	}
+
+	c := p.Pop()
+	if c == nil {
+		return nil, ErrNoConn
+	}
+	n, e := c.Call("PUBLISH", channel, msg)
+	p.Push(c)
+	if e != nil {
+		return nil, e
+	}
+	if receivers, _ := n.(int64); receivers == 0 {
+		return nil, ErrNoResponder
+	}
+
+	for {
+		if e = sub.conn.SetReadDeadline(deadline); e != nil {
+			return nil, e
+		}
+		v, e := sub.readResponse()
+		if e != nil {
+			if time.Now().After(deadline) {
+				return nil, ErrTimeout
+			}
+			return nil, e
+		}
+		// ["message", channel, payload]
+		m, ok := v.([]interface{})
+		if !ok || len(m) != 3 {
+			continue
+		}
+		kind, _ := replyString(m[0])
+		ch, _ := replyString(m[1])
+		if kind == "message" && ch == replyTo {
+			payload, _ := m[2].([]byte)
+			return payload, nil
+		}
+	}
+}
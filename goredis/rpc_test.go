@@ -0,0 +1,77 @@
+package msgredis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// a fake server routing PUBLISH to SUBSCRIBEd conns, the responder
+// answers every request with "pong"
+func newPubSubServer(t *testing.T, respond bool) string {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	return servePubSub(t, ln, respond)
+}
+
+func servePubSub(t *testing.T, ln net.Listener, respond bool) string {
+	t.Cleanup(func() { ln.Close() })
+	subs := make(chan net.Conn, 1)
+	go func() {
+		for {
+			c, e := ln.Accept()
+			if e != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					args, e := readCommand(r)
+					if e != nil {
+						return
+					}
+					switch args[0] {
+					case "SUBSCRIBE":
+						io.WriteString(c, "*3\r\n$9\r\nsubscribe\r\n$"+strconv.Itoa(len(args[1]))+"\r\n"+args[1]+"\r\n:1\r\n")
+						subs <- c
+					case "PUBLISH":
+						if !respond {
+							io.WriteString(c, ":1\r\n")
+							continue
+						}
+						req, e := ParseRPCRequest([]byte(args[2]))
+						if e != nil {
+							io.WriteString(c, "-ERR bad request\r\n")
+							continue
+						}
+						io.WriteString(c, ":1\r\n")
+						sub := <-subs
+						io.WriteString(sub, "*3\r\n$7\r\nmessage\r\n$"+strconv.Itoa(len(req.ReplyTo))+"\r\n"+req.ReplyTo+"\r\n$4\r\npong\r\n")
+					default:
+						io.WriteString(c, "+OK\r\n")
+					}
+				}
+			}(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRequest(t *testing.T) {
+	p := NewPool(newPubSubServer(t, true), "")
+	v, e := p.Request("rpc:ping", []byte("ping"), time.Second)
+	if e != nil || string(v) != "pong" {
+		t.Fatalf("%q %v", v, e)
+	}
+
+	p = NewPool(newPubSubServer(t, false), "")
+	if _, e = p.Request("rpc:ping", []byte("ping"), 100*time.Millisecond); e != ErrTimeout {
+		t.Fatalf("timeout: %v", e)
+	}
+}
@@ -0,0 +1,77 @@
+package msgredis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// Script is a Lua script sent with EVALSHA, falling back to EVAL (which
+// also caches it on the server) when the node does not know it yet.
+type Script struct {
+	Src string
+	SHA string
+}
+
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{Src: src, SHA: hex.EncodeToString(sum[:])}
+}
+
+// -NOSCRIPT No matching script. Please use EVAL.
+func IsNoScript(e error) bool {
+	return e != nil && strings.HasPrefix(e.Error(), CommonErrPrefix+"NOSCRIPT")
+}
+
+// EVAL/EVALSHA <script> <numkeys> keys... args...
+func scriptArgs(script string, keys []string, args []interface{}) []interface{} {
+	a := make([]interface{}, 0, 2+len(keys)+len(args))
+	a = append(a, script, len(keys))
+	for _, key := range keys {
+		a = append(a, key)
+	}
+	return append(a, args...)
+}
+
+/******************* scripting commands *******************/
+func (c *Conn) EVAL(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return c.Call("EVAL", scriptArgs(script, keys, args)...)
+}
+
+func (c *Conn) EVALSHA(sha string, keys []string, args ...interface{}) (interface{}, error) {
+	return c.Call("EVALSHA", scriptArgs(sha, keys, args)...)
+}
+
+func (c *Conn) SCRIPTLOAD(script string) (string, error) {
+	v, e := c.Call("SCRIPT", "LOAD", script)
+	if e != nil {
+		return "", e
+	}
+	return replyString(v)
+}
+
+// RunScript runs s with EVALSHA and retries with EVAL on NOSCRIPT
+func (c *Conn) RunScript(s *Script, keys []string, args ...interface{}) (interface{}, error) {
+	v, e := c.EVALSHA(s.SHA, keys, args...)
+	if IsNoScript(e) {
+		return c.EVAL(s.Src, keys, args...)
+	}
+	return v, e
+}
+
+// RunScript runs s on the node owning keys, starting at addr. All keys
+// must hash to one slot, otherwise ErrCrossSlot is returned before anything
+// is sent. MOVED/ASK are followed as in Call, and a node that has not seen
+// the script yet (e.g. the new owner after a MOVED) gets it through EVAL.
+func (r *Resharding) RunScript(addr string, s *Script, keys []string, args ...interface{}) (interface{}, error) {
+	if len(keys) > 0 {
+		if _, e := CheckSlot(keys...); e != nil {
+			return nil, e
+		}
+	}
+	v, e := r.Call(addr, "EVALSHA", scriptArgs(s.SHA, keys, args)...)
+	if IsNoScript(e) {
+		return r.Call(addr, "EVAL", scriptArgs(s.Src, keys, args)...)
+	}
+	return v, e
+}
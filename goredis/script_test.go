@@ -0,0 +1,49 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestReshardingRunScript(t *testing.T) {
+	script := NewScript("return redis.call('GET', KEYS[1])")
+	// the old owner redirects, the new owner has not loaded the script yet
+	var newOwner *fakeServer
+	loaded := false
+	newOwner = newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "EVALSHA":
+			if !loaded {
+				return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+			}
+			return "$1\r\nv\r\n"
+		case "EVAL":
+			if args[1] != script.Src || args[2] != "2" || args[3] != "{u}a" {
+				return "-ERR bad eval\r\n"
+			}
+			loaded = true
+			return "$1\r\nv\r\n"
+		}
+		return "+OK\r\n"
+	})
+	oldOwner := newFakeServer(t, func(args []string) string {
+		return "-MOVED 3000 " + newOwner.Addr() + "\r\n"
+	})
+
+	r := NewResharding("")
+	defer r.Close()
+	for i := 0; i < 2; i++ {
+		v, e := r.RunScript(oldOwner.Addr(), script, []string{"{u}a", "{u}b"})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if string(v.([]byte)) != "v" {
+			t.Fatalf("got %q", v)
+		}
+	}
+	if !loaded {
+		t.Fatal("script not loaded on the new owner")
+	}
+	if _, e := r.RunScript(oldOwner.Addr(), script, []string{"a", "b"}); e != ErrCrossSlot {
+		t.Fatalf("cross slot: %v", e)
+	}
+}
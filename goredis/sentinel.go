@@ -0,0 +1,220 @@
+package msgredis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+const switchMasterChannel = "+switch-master"
+
+// the pool address, safe against Repoint
+func (p *Pool) address() string {
+	p.addrMu.RLock()
+	defer p.addrMu.RUnlock()
+	return p.Address
+}
+
+// Repoint moves the pool to another server, e.g. the new master after a
+// failover: conns to the old one are closed on Pop and Push, as after
+// RotateCredentials, and new conns dial address.
+func (p *Pool) Repoint(address string) {
+	p.addrMu.Lock()
+	p.Address = address
+	p.addrMu.Unlock()
+	// conns dialed before this have an older generation
+	p.RotateCredentials()
+}
+
+// SentinelPool is a Pool at the master of a group monitored by Redis
+// Sentinel. The master is resolved with SENTINEL GET-MASTER-ADDR-BY-NAME
+// on the first sentinel answering, and the pool follows it to the new
+// master on every +switch-master the sentinels publish. Switches missed
+// while the sentinel subscription was down are caught up on by resolving
+// again once it is back.
+type SentinelPool struct {
+	*Pool
+	MasterName string
+	Sentinels  []string
+
+	mu       sync.Mutex
+	onSwitch func(from, to string)
+	ps       *PubSubConn
+	done     chan struct{}
+	// TLS and AUTH of the sentinels
+	tlsConfig *tls.Config
+	sentinel  Credentials
+}
+
+// SentinelOptions configures the conns of a SentinelPool
+type SentinelOptions struct {
+	// AUTH [Username] Password on the master
+	Username string
+	Password string
+	// consulted instead of Username and Password, see Pool.Credentials
+	Credentials CredentialsProvider
+	// dials the master and the sentinels over TLS when set, see DialTLS
+	TLSConfig *tls.Config
+	// AUTH of the sentinels, none without a password
+	SentinelUsername string
+	SentinelPassword string
+}
+
+// NewSentinelPool resolves the master of masterName and subscribes to the
+// failovers. password is that of the master, sentinels take none.
+func NewSentinelPool(masterName, password string, sentinels ...string) (*SentinelPool, error) {
+	return NewSentinelPoolWith(masterName, SentinelOptions{Password: password}, sentinels...)
+}
+
+// NewSentinelPoolWith is NewSentinelPool with the credentials and TLS of
+// opts
+func NewSentinelPoolWith(masterName string, opts SentinelOptions, sentinels ...string) (*SentinelPool, error) {
+	if masterName == "" || len(sentinels) == 0 {
+		return nil, ErrBadArgs
+	}
+	sp := &SentinelPool{
+		MasterName: masterName,
+		Sentinels:  sentinels,
+		done:       make(chan struct{}),
+		tlsConfig:  opts.TLSConfig,
+		sentinel:   Credentials{Username: opts.SentinelUsername, Password: opts.SentinelPassword},
+	}
+	master, e := sp.MasterAddr()
+	if e != nil {
+		return nil, e
+	}
+	sp.Pool = NewPool(master, opts.Password)
+	sp.TLSConfig = opts.TLSConfig
+	sp.Credentials = opts.Credentials
+	if sp.Credentials == nil && opts.Username != "" {
+		sp.Credentials = staticCredentials(opts.Username, opts.Password)
+	}
+
+	c, e := sp.dialSentinel()
+	if e != nil {
+		return nil, e
+	}
+	sp.ps = newPubSubConn(c, 0, sp.dialSentinel)
+	sp.ps.OnReconnect(func(ReconnectEvent) {
+		sp.check()
+	})
+	if e = sp.ps.Subscribe(switchMasterChannel); e != nil {
+		sp.ps.Close()
+		return nil, e
+	}
+	go sp.watch()
+	return sp, nil
+}
+
+// OnSwitch sets a callback run after the pool moved to a new master
+func (sp *SentinelPool) OnSwitch(fn func(from, to string)) {
+	sp.mu.Lock()
+	sp.onSwitch = fn
+	sp.mu.Unlock()
+}
+
+// MasterAddr asks the sentinels in turn for the current master address
+func (sp *SentinelPool) MasterAddr() (string, error) {
+	var e error
+	for _, addr := range sp.Sentinels {
+		var master string
+		if master, e = sp.askMaster(addr); e == nil {
+			return master, nil
+		}
+		fmt.Println("[Sentinel] " + addr + ": " + e.Error())
+	}
+	return "", e
+}
+
+func (sp *SentinelPool) askMaster(addr string) (string, error) {
+	c, e := dialContext(context.Background(), addr, DialOptions{
+		Username:       sp.sentinel.Username,
+		Password:       sp.sentinel.Password,
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    ReadTimeout,
+		WriteTimeout:   WriteTimeout,
+		TLSConfig:      sp.tlsConfig,
+	})
+	if e != nil {
+		return "", e
+	}
+	defer c.Close()
+	v, e := c.Call("SENTINEL", "GET-MASTER-ADDR-BY-NAME", sp.MasterName)
+	if e != nil {
+		return "", e
+	}
+	if v == nil {
+		return "", errors.New(CommonErrPrefix + "sentinel does not know master " + sp.MasterName)
+	}
+	hostPort, e := replyStrings(v)
+	if e != nil || len(hostPort) != 2 {
+		return "", ErrBadType
+	}
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+// dialSentinel dials the first sentinel reachable
+func (sp *SentinelPool) dialSentinel() (*Conn, error) {
+	var e error
+	for _, addr := range sp.Sentinels {
+		var c *Conn
+		if c, e = dialPubSub(addr, sp.tlsConfig, sp.sentinel); e == nil {
+			return c, nil
+		}
+	}
+	return nil, e
+}
+
+// watch follows the +switch-master messages of the group
+func (sp *SentinelPool) watch() {
+	defer close(sp.done)
+	for m := range sp.ps.Messages() {
+		msg, ok := m.(Message)
+		if !ok || msg.Channel != switchMasterChannel {
+			continue
+		}
+		// <master name> <old ip> <old port> <new ip> <new port>
+		fields := strings.Fields(string(msg.Payload))
+		if len(fields) != 5 || fields[0] != sp.MasterName {
+			continue
+		}
+		sp.switchTo(net.JoinHostPort(fields[3], fields[4]))
+	}
+}
+
+// check resolves the master again, after switches may have been missed
+func (sp *SentinelPool) check() {
+	master, e := sp.MasterAddr()
+	if e != nil {
+		return
+	}
+	sp.switchTo(master)
+}
+
+func (sp *SentinelPool) switchTo(master string) {
+	sp.mu.Lock()
+	from := sp.address()
+	if from == master {
+		sp.mu.Unlock()
+		return
+	}
+	sp.Repoint(master)
+	fn := sp.onSwitch
+	sp.mu.Unlock()
+	fmt.Println("[Sentinel] " + sp.MasterName + " switched from " + from + " to " + master)
+	if fn != nil {
+		fn(from, master)
+	}
+}
+
+// Close stops following the failovers and shuts the pool down, see
+// Pool.Shutdown
+func (sp *SentinelPool) Close(ctx context.Context) error {
+	sp.ps.Close()
+	<-sp.done
+	return sp.Shutdown(ctx)
+}
@@ -0,0 +1,66 @@
+package msgredis
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSentinelPool(t *testing.T) {
+	name := func(n string) func([]string) string {
+		return func(args []string) string { return bulk(n) }
+	}
+	m1, m2 := newFakeServer(t, name("m1")), newFakeServer(t, name("m2"))
+	var master atomic.Value
+	master.Store(m1.Addr())
+	sentinel := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SENTINEL":
+			host, port, _ := net.SplitHostPort(master.Load().(string))
+			return respArray(host, port)
+		case "SUBSCRIBE":
+			return "*3\r\n" + bulk("subscribe") + bulk(args[1]) + ":1\r\n"
+		case "PING":
+			// the failover happens
+			oldHost, oldPort, _ := net.SplitHostPort(m1.Addr())
+			host, port, _ := net.SplitHostPort(m2.Addr())
+			master.Store(m2.Addr())
+			return "*3\r\n" + bulk("message") + bulk("+switch-master") +
+				bulk("mymaster "+oldHost+" "+oldPort+" "+host+" "+port)
+		}
+		return "-ERR unknown\r\n"
+	})
+	down := newFakeServer(t, okHandler)
+	down.ln.Close()
+
+	sp, e := NewSentinelPool("mymaster", "", down.Addr(), sentinel.Addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sp.Close(context.Background())
+	get := func() string {
+		c := sp.Pop()
+		defer sp.Push(c)
+		v, _ := c.Call("GET", "k")
+		return string(v.([]byte))
+	}
+	if got := get(); got != "m1" {
+		t.Fatalf("before failover: %s", got)
+	}
+
+	switched := make(chan [2]string, 1)
+	sp.OnSwitch(func(from, to string) { switched <- [2]string{from, to} })
+	sp.ps.Ping("")
+	if got := <-switched; got != [2]string{m1.Addr(), m2.Addr()} {
+		t.Fatalf("switch: %v", got)
+	}
+	// the idle conn to m1 is dropped
+	if got := get(); got != "m2" {
+		t.Fatalf("after failover: %s", got)
+	}
+
+	if _, e = NewSentinelPool("mymaster", ""); e != ErrBadArgs {
+		t.Fatalf("no sentinels: %v", e)
+	}
+}
@@ -0,0 +1,201 @@
+package msgredis
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTimeout      = errors.New(CommonErrPrefix + "timeout")
+	ErrBGSaveFailed = errors.New(CommonErrPrefix + "background save failed")
+)
+
+// INFO reply => field:value map, section headers are skipped
+func parseInfo(info []byte) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(info), "\r\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+	return fields
+}
+
+// INFO <section> parsed into fields
+func (c *Conn) InfoSection(section string) (map[string]string, error) {
+	v, e := c.Call("INFO", section)
+	if e != nil {
+		return nil, e
+	}
+	info, ok := v.([]byte)
+	if !ok {
+		return nil, ErrBadType
+	}
+	return parseInfo(info), nil
+}
+
+// CONFIG GET <pattern> as parameter => value
+func (c *Conn) CONFIGGET(pattern string) (map[string]string, error) {
+	v, e := c.Call("CONFIG", "GET", pattern)
+	if e != nil {
+		return nil, e
+	}
+	return replyStringMap(v)
+}
+
+/******************* persistence commands *******************/
+func (c *Conn) SAVE() error {
+	return c.okCall("SAVE")
+}
+
+// returns the status line, e.g. "Background saving started"
+func (c *Conn) BGSAVE() ([]byte, error) {
+	v, e := c.Call("BGSAVE")
+	if e != nil {
+		return nil, e
+	}
+	return v.([]byte), nil
+}
+
+func (c *Conn) BGREWRITEAOF() ([]byte, error) {
+	v, e := c.Call("BGREWRITEAOF")
+	if e != nil {
+		return nil, e
+	}
+	return v.([]byte), nil
+}
+
+// unix time of the last successful save
+func (c *Conn) LASTSAVE() (int64, error) {
+	n, e := c.Call("LASTSAVE")
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+// WaitForBGSave polls INFO persistence every interval until no background
+// save is in progress, then reports whether the last one succeeded.
+func (c *Conn) WaitForBGSave(timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, e := c.InfoSection("persistence")
+		if e != nil {
+			return e
+		}
+		if info["rdb_bgsave_in_progress"] == "0" {
+			if info["rdb_last_bgsave_status"] != "ok" {
+				return ErrBGSaveFailed
+			}
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(interval)
+	}
+}
+
+// commands answering +OK
+func (c *Conn) okCall(command string, args ...interface{}) error {
+	v, e := c.Call(command, args...)
+	if e != nil {
+		return e
+	}
+	r, ok := v.([]byte)
+	if !ok {
+		return ErrBadType
+	}
+	if len(r) == 2 && r[0] == 'O' && r[1] == 'K' {
+		return nil
+	}
+	return errors.New("invalid return:" + string(r))
+}
+
+/******************* shutdown and maintenance *******************/
+const (
+	ShutdownNoSave = "NOSAVE"
+	ShutdownSave   = "SAVE"
+	ShutdownNow    = "NOW"
+	ShutdownForce  = "FORCE"
+	ShutdownAbort  = "ABORT"
+)
+
+var ErrReplicasLagging = errors.New(CommonErrPrefix + "replicas did not acknowledge writes")
+
+// SHUTDOWN [NOSAVE|SAVE] [NOW] [FORCE] [ABORT]. On success the server
+// closes the connection, which is reported as nil.
+func (c *Conn) SHUTDOWN(modifiers ...string) error {
+	args := make([]interface{}, len(modifiers))
+	for i, m := range modifiers {
+		args[i] = m
+	}
+	_, e := c.Call("SHUTDOWN", args...)
+	if e == io.EOF {
+		c.Close()
+		return nil
+	}
+	return e
+}
+
+// writeOnly pauses only write commands (redis 6.2+)
+func (c *Conn) CLIENTPAUSE(timeout time.Duration, writeOnly bool) error {
+	args := []interface{}{"PAUSE", int64(timeout / time.Millisecond)}
+	if writeOnly {
+		args = append(args, "WRITE")
+	}
+	return c.okCall("CLIENT", args...)
+}
+
+func (c *Conn) CLIENTUNPAUSE() error {
+	return c.okCall("CLIENT", "UNPAUSE")
+}
+
+// returns the number of replicas that acknowledged the writes
+func (c *Conn) WAIT(numReplicas int, timeout time.Duration) (int64, error) {
+	n, e := c.Call("WAIT", numReplicas, int64(timeout/time.Millisecond))
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+type MaintainOptions struct {
+	// writes are paused for at most this long, it must cover the restart
+	PauseTimeout time.Duration
+	// replicas that must have caught up before the restart
+	Replicas    int
+	WaitTimeout time.Duration
+}
+
+// Maintain pauses writes on the server behind c, waits until opt.Replicas
+// replicas acknowledged every write and then runs restart (a failover, a
+// SHUTDOWN, a config reload...). Writes are unpaused afterwards, or as soon
+// as the replicas fail to catch up.
+func Maintain(c *Conn, opt MaintainOptions, restart func() error) error {
+	if e := c.CLIENTPAUSE(opt.PauseTimeout, true); e != nil {
+		return e
+	}
+	n, e := c.WAIT(opt.Replicas, opt.WaitTimeout)
+	if e == nil && n < int64(opt.Replicas) {
+		e = ErrReplicasLagging
+	}
+	if e == nil {
+		e = restart()
+	}
+	// the server may be gone after restart, the pause then ends with it
+	if ue := c.CLIENTUNPAUSE(); e == nil && ue != nil && ue != io.EOF && !isNetError(ue) {
+		e = ue
+	}
+	return e
+}
+
+// errors not coming from a server reply
+func isNetError(e error) bool {
+	return e != nil && !strings.Contains(e.Error(), CommonErrPrefix)
+}
@@ -0,0 +1,262 @@
+package msgredis
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a handler reply closing the connection instead of answering
+const closeConn = "\x00close"
+
+// fakeServer is a minimal RESP server for tests that must not depend on a
+// live redis. handler receives the decoded command and returns the raw reply.
+type fakeServer struct {
+	ln      net.Listener
+	handler func(args []string) string
+}
+
+func newFakeServer(t *testing.T, handler func(args []string) string) *fakeServer {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	s := &fakeServer{ln: ln, handler: handler}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, e := s.ln.Accept()
+		if e != nil {
+			return
+		}
+		go s.serveConn(c)
+	}
+}
+
+func (s *fakeServer) serveConn(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		args, e := readCommand(r)
+		if e != nil {
+			return
+		}
+		reply := s.handler(args)
+		if reply == closeConn {
+			return
+		}
+		if reply != "" {
+			if _, e = io.WriteString(c, reply); e != nil {
+				return
+			}
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, e := r.ReadString('\n')
+	if e != nil {
+		return nil, e
+	}
+	n, e := strconv.Atoi(line[1 : len(line)-2])
+	if e != nil {
+		return nil, e
+	}
+	args := make([]string, n)
+	for i := 0; i < n; i++ {
+		if line, e = r.ReadString('\n'); e != nil {
+			return nil, e
+		}
+		size, e := strconv.Atoi(line[1 : len(line)-2])
+		if e != nil {
+			return nil, e
+		}
+		buf := make([]byte, size+2)
+		if _, e = io.ReadFull(r, buf); e != nil {
+			return nil, e
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// replies OK to everything
+func okHandler(args []string) string {
+	return "+OK\r\n"
+}
+
+func dialFake(t *testing.T, s *fakeServer) *Conn {
+	c, e := Dial(s.Addr(), "", ConnectTimeout, ReadTimeout, WriteTimeout, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// RESP array of bulk strings
+func respArray(ss ...string) string {
+	r := "*" + strconv.Itoa(len(ss)) + "\r\n"
+	for _, s := range ss {
+		r += "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+	}
+	return r
+}
+
+func TestPersistence(t *testing.T) {
+	var mu sync.Mutex
+	// INFO persistence replies, the last one repeats
+	var infos []string
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "SAVE":
+			return "+OK\r\n"
+		case "BGSAVE":
+			return "+Background saving started\r\n"
+		case "BGREWRITEAOF":
+			return "+Background append only file rewriting started\r\n"
+		case "LASTSAVE":
+			return ":1700000000\r\n"
+		case "INFO":
+			info := infos[0]
+			if len(infos) > 1 {
+				infos = infos[1:]
+			}
+			return info
+		}
+		return "-ERR unexpected\r\n"
+	}))
+	if e := c.SAVE(); e != nil {
+		t.Fatal(e)
+	}
+	if v, e := c.BGSAVE(); e != nil || string(v) != "Background saving started" {
+		t.Fatalf("bgsave: %s %v", v, e)
+	}
+	if v, e := c.BGREWRITEAOF(); e != nil || !strings.HasPrefix(string(v), "Background append only") {
+		t.Fatalf("bgrewriteaof: %s %v", v, e)
+	}
+	if n, e := c.LASTSAVE(); e != nil || n != 1700000000 {
+		t.Fatalf("lastsave: %d %v", n, e)
+	}
+
+	saving := infoReply("# Persistence", "rdb_bgsave_in_progress:1", "rdb_last_bgsave_status:ok")
+	setInfos := func(replies ...string) {
+		mu.Lock()
+		infos = replies
+		mu.Unlock()
+	}
+	// polls until the save is over
+	setInfos(saving, saving, infoReply("# Persistence", "rdb_bgsave_in_progress:0", "rdb_last_bgsave_status:ok"))
+	if e := c.WaitForBGSave(time.Second, time.Millisecond); e != nil {
+		t.Fatal(e)
+	}
+	setInfos(saving, infoReply("# Persistence", "rdb_bgsave_in_progress:0", "rdb_last_bgsave_status:err"))
+	if e := c.WaitForBGSave(time.Second, time.Millisecond); e != ErrBGSaveFailed {
+		t.Fatalf("failed save: %v", e)
+	}
+	setInfos(saving)
+	start := time.Now()
+	if e := c.WaitForBGSave(30*time.Millisecond, 10*time.Millisecond); e != ErrTimeout || time.Since(start) > time.Second {
+		t.Fatalf("timeout: %v after %v", e, time.Since(start))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, strings.Join(args, " "))
+		if len(args) > 1 && args[1] == "ABORT" {
+			return "-ERR No shutdown in progress.\r\n"
+		}
+		return closeConn
+	})
+	c := dialFake(t, s)
+	if e := c.SHUTDOWN(ShutdownAbort); e == nil || !isReplyError(e) {
+		t.Fatalf("abort: %v", e)
+	}
+	// the server closing the conn is the success
+	if e := c.SHUTDOWN(ShutdownNoSave, ShutdownNow, ShutdownForce); e != nil {
+		t.Fatal(e)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(got, ",") != "SHUTDOWN ABORT,SHUTDOWN NOSAVE NOW FORCE" {
+		t.Fatalf("sent %q", got)
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	acks := ":1\r\n"
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, strings.Join(args, " "))
+		if args[0] == "WAIT" {
+			return acks
+		}
+		return "+OK\r\n"
+	}))
+	sent := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		s := strings.Join(got, ",")
+		got = nil
+		return s
+	}
+	opt := MaintainOptions{PauseTimeout: time.Second, Replicas: 1, WaitTimeout: 500 * time.Millisecond}
+	restarts := 0
+	restart := func() error {
+		restarts++
+		return nil
+	}
+	if e := Maintain(c, opt, restart); e != nil || restarts != 1 {
+		t.Fatalf("maintain: %v, %d restarts", e, restarts)
+	}
+	if s := sent(); s != "CLIENT PAUSE 1000 WRITE,WAIT 1 500,CLIENT UNPAUSE" {
+		t.Fatalf("sent %q", s)
+	}
+
+	// the replica lags: no restart, writes unpaused at once
+	mu.Lock()
+	acks = ":0\r\n"
+	mu.Unlock()
+	if e := Maintain(c, opt, restart); e != ErrReplicasLagging || restarts != 1 {
+		t.Fatalf("lagging: %v, %d restarts", e, restarts)
+	}
+	if s := sent(); s != "CLIENT PAUSE 1000 WRITE,WAIT 1 500,CLIENT UNPAUSE" {
+		t.Fatalf("sent %q", s)
+	}
+
+	// the restart error is returned, after the unpause
+	mu.Lock()
+	acks = ":1\r\n"
+	mu.Unlock()
+	failed := errors.New("failover refused")
+	if e := Maintain(c, opt, func() error { return failed }); e != failed {
+		t.Fatalf("restart error: %v", e)
+	}
+	if s := sent(); !strings.HasSuffix(s, "CLIENT UNPAUSE") {
+		t.Fatalf("sent %q", s)
+	}
+}
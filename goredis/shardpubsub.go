@@ -0,0 +1,279 @@
+package msgredis
+
+import (
+	"sync"
+)
+
+// Resubscribed is delivered by ShardedPubSub when shard channels moved to
+// another node with their slot and were subscribed again there
+type Resubscribed struct {
+	Channels []string
+	From     string
+	To       string
+}
+
+// ShardedPubSub subscribes to shard channels of a cluster (SSUBSCRIBE), on
+// one PubSubConn per node owning some of them. Messages of every node are
+// merged on Messages. When a slot moves, the server drops its channels
+// (sunsubscribe) or refuses them with MOVED; they are subscribed again on
+// the new owner and a Resubscribed is delivered instead.
+type ShardedPubSub struct {
+	r      *Resharding
+	seed   string
+	buffer int
+
+	mu    sync.Mutex
+	slots []SlotRange
+	nodes map[string]*PubSubConn
+	// node of each subscribed channel
+	owner map[string]string
+
+	messages chan interface{}
+	closing  chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// ShardedPubSub reads the slot map with CLUSTER SLOTS on seed, buffer is
+// that of Messages and of every node conn
+func (r *Resharding) ShardedPubSub(seed string, buffer int) (*ShardedPubSub, error) {
+	if buffer <= 0 {
+		buffer = DefaultPubSubBuffer
+	}
+	sp := &ShardedPubSub{
+		r:        r,
+		seed:     seed,
+		buffer:   buffer,
+		nodes:    make(map[string]*PubSubConn),
+		owner:    make(map[string]string),
+		messages: make(chan interface{}, buffer),
+		closing:  make(chan struct{}),
+	}
+	if e := sp.refresh(); e != nil {
+		return nil, e
+	}
+	return sp, nil
+}
+
+func (sp *ShardedPubSub) Messages() <-chan interface{} {
+	return sp.messages
+}
+
+// refresh reloads the slot map
+func (sp *ShardedPubSub) refresh() error {
+	p := sp.r.pool(sp.seed)
+	c := p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	ranges, e := c.CLUSTERSLOTS()
+	p.Push(c)
+	if e != nil {
+		return e
+	}
+	sp.mu.Lock()
+	sp.slots = ranges
+	sp.mu.Unlock()
+	return nil
+}
+
+// master of the slot of channel, mu held
+func (sp *ShardedPubSub) nodeOf(channel string) (string, error) {
+	slot := int(KeySlot(channel))
+	for _, sr := range sp.slots {
+		if slot >= sr.Start && slot <= sr.End {
+			return sr.Master, nil
+		}
+	}
+	return "", ErrNoConn
+}
+
+// node returns the conn to addr, dialing it on first use, mu held
+func (sp *ShardedPubSub) node(addr string) (*PubSubConn, error) {
+	if ps, ok := sp.nodes[addr]; ok {
+		return ps, nil
+	}
+	select {
+	case <-sp.closing:
+		return nil, ErrNoConn
+	default:
+	}
+	pool := sp.r.pool(addr)
+	dial := func() (*Conn, error) {
+		// asked on every dial, the credentials may have rotated
+		cr, e := pool.credentials()
+		if e != nil {
+			return nil, e
+		}
+		return dialPubSub(addr, sp.r.TLSConfig, cr)
+	}
+	c, e := dial()
+	if e != nil {
+		return nil, e
+	}
+	ps := newPubSubConn(c, sp.buffer, dial)
+	sp.nodes[addr] = ps
+	sp.wg.Add(1)
+	go sp.forward(addr, ps)
+	return ps, nil
+}
+
+// SSubscribe subscribes to channels on their owners, one SSUBSCRIBE per
+// slot
+func (sp *ShardedPubSub) SSubscribe(channels ...string) error {
+	if len(channels) == 0 {
+		return ErrBadArgs
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for _, group := range bySlot(channels) {
+		if e := sp.subscribe(group); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// subscribe sends one slot worth of channels to its owner, mu held
+func (sp *ShardedPubSub) subscribe(channels []string) error {
+	addr, e := sp.nodeOf(channels[0])
+	if e != nil {
+		return e
+	}
+	ps, e := sp.node(addr)
+	if e != nil {
+		return e
+	}
+	for _, ch := range channels {
+		sp.owner[ch] = addr
+	}
+	return ps.SSubscribe(channels...)
+}
+
+// SUnsubscribe drops channels, their sunsubscribe confirmations follow on
+// Messages
+func (sp *ShardedPubSub) SUnsubscribe(channels ...string) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	byNode := make(map[string][]string)
+	for _, ch := range channels {
+		if addr, ok := sp.owner[ch]; ok {
+			byNode[addr] = append(byNode[addr], ch)
+			delete(sp.owner, ch)
+		}
+	}
+	for addr, chs := range byNode {
+		for _, group := range bySlot(chs) {
+			if e := sp.nodes[addr].SUnsubscribe(group...); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// forward passes the messages of one node on, and moves the channels the
+// node gives up
+func (sp *ShardedPubSub) forward(addr string, ps *PubSubConn) {
+	defer sp.wg.Done()
+	for m := range ps.Messages() {
+		var moved []string
+		switch v := m.(type) {
+		case Subscription:
+			// not asked for: the slot left the node
+			if v.Kind == "sunsubscribe" && sp.owned(v.Channel, addr) {
+				moved = []string{v.Channel}
+			}
+		case *ReplyError:
+			if re, ok := ParseRedirect(v); ok && !re.Ask {
+				moved = sp.channelsOfSlot(addr, re.Slot)
+			}
+		}
+		if len(moved) > 0 {
+			m = sp.move(addr, moved)
+			if m == nil {
+				continue
+			}
+		}
+		select {
+		case sp.messages <- m:
+		case <-sp.closing:
+			return
+		}
+	}
+}
+
+func (sp *ShardedPubSub) owned(channel, addr string) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.owner[channel] == addr
+}
+
+func (sp *ShardedPubSub) channelsOfSlot(addr string, slot int) []string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	var channels []string
+	for ch, owner := range sp.owner {
+		if owner == addr && int(KeySlot(ch)) == slot {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// move subscribes channels again on their new owner, the old node conn
+// forgets them. It returns the Resubscribed to deliver, or the error of
+// the move, delivered instead.
+func (sp *ShardedPubSub) move(from string, channels []string) interface{} {
+	if e := sp.refresh(); e != nil {
+		return e
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if old, ok := sp.nodes[from]; ok {
+		old.mu.Lock()
+		old.track("SUNSUBSCRIBE", channels)
+		old.mu.Unlock()
+	}
+	ev := Resubscribed{From: from}
+	for _, group := range bySlot(channels) {
+		// a channel unsubscribed meanwhile stays so
+		var still []string
+		for _, ch := range group {
+			if sp.owner[ch] == from {
+				still = append(still, ch)
+			}
+		}
+		if len(still) == 0 {
+			continue
+		}
+		if e := sp.subscribe(still); e != nil {
+			return e
+		}
+		ev.To = sp.owner[still[0]]
+		ev.Channels = append(ev.Channels, still...)
+	}
+	if len(ev.Channels) == 0 {
+		return nil
+	}
+	return ev
+}
+
+// Close closes every node conn, then Messages
+func (sp *ShardedPubSub) Close() error {
+	sp.once.Do(func() {
+		sp.mu.Lock()
+		close(sp.closing)
+		nodes := make([]*PubSubConn, 0, len(sp.nodes))
+		for _, ps := range sp.nodes {
+			nodes = append(nodes, ps)
+		}
+		sp.mu.Unlock()
+		for _, ps := range nodes {
+			ps.Close()
+		}
+		sp.wg.Wait()
+		close(sp.messages)
+	})
+	return nil
+}
@@ -0,0 +1,76 @@
+package msgredis
+
+import (
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedPubSubMove(t *testing.T) {
+	ssubscribe := func(args []string) string {
+		reply := ""
+		for i, ch := range args[1:] {
+			reply += "*3\r\n" + bulk("ssubscribe") + bulk(ch) + ":" + strconv.Itoa(i+1) + "\r\n"
+		}
+		return reply
+	}
+	b := newFakeServer(t, ssubscribe)
+	var owner atomic.Value
+	a := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "PING":
+			// the slot of news left for b
+			owner.Store(b.Addr())
+			return "*3\r\n" + bulk("sunsubscribe") + bulk("news") + ":0\r\n"
+		case args[1] == "sports":
+			owner.Store(b.Addr())
+			return "-MOVED " + strconv.Itoa(int(KeySlot("sports"))) + " " + b.Addr() + "\r\n"
+		}
+		return ssubscribe(args)
+	})
+	owner.Store(a.Addr())
+	seed := newFakeServer(t, func(args []string) string {
+		return "*1\r\n*3\r\n:0\r\n:16383\r\n" + slotNode(t, owner.Load().(string))
+	})
+
+	r := NewResharding("")
+	defer r.Close()
+	sp, e := r.ShardedPubSub(seed.Addr(), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sp.Close()
+	if e = sp.SSubscribe("news"); e != nil {
+		t.Fatal(e)
+	}
+	if got := <-sp.Messages(); got != (Subscription{"ssubscribe", "news", 1}) {
+		t.Fatalf("got %#v", got)
+	}
+	sp.nodes[a.Addr()].Ping("")
+	expectMove(t, sp, "news", a.Addr(), b.Addr())
+
+	owner.Store(a.Addr())
+	sp.refresh()
+	sp.SSubscribe("sports")
+	expectMove(t, sp, "sports", a.Addr(), b.Addr())
+}
+
+// the Resubscribed and the confirmation of the new node, in any order
+func expectMove(t *testing.T, sp *ShardedPubSub, channel, from, to string) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		switch got := (<-sp.Messages()).(type) {
+		case Resubscribed:
+			if !reflect.DeepEqual(got, Resubscribed{Channels: []string{channel}, From: from, To: to}) {
+				t.Fatalf("got %#v", got)
+			}
+		case Subscription:
+			if got.Channel != channel || got.Kind != "ssubscribe" {
+				t.Fatalf("got %#v", got)
+			}
+		default:
+			t.Fatalf("got %#v", got)
+		}
+	}
+}
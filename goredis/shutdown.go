@@ -0,0 +1,107 @@
+package msgredis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// how often Shutdown looks for conns still checked out
+const shutdownPoll = 10 * time.Millisecond
+
+// Shutdown drains the pool for a graceful stop, e.g. on SIGTERM: Pop
+// returns nil from now on, idle conns are closed, and conns coming back
+// get their pending pipeline flushed and are closed. It waits for every
+// checked out conn up to the context deadline, conns still out then are
+// closed when they are pushed.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&p.closing, 1)
+	p.closeIdles()
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for p.Actives() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// a Push racing with the flag may still have parked one
+			p.closeIdles()
+		}
+	}
+	p.closeIdles()
+	return nil
+}
+
+func (p *Pool) closed() bool {
+	return atomic.LoadInt32(&p.closing) == 1
+}
+
+func (p *Pool) closeIdles() {
+	for c := p.take(); c != nil; c = p.take() {
+		atomic.AddInt64(&p.IdleNum, -1)
+		c.Close()
+	}
+}
+
+// drain flushes what a borrower queued on c before closing it
+func (p *Pool) drain(c *Conn) {
+	if c.pipeCount > 0 && !c.broken {
+		if _, e := c.PipeExec(); e != nil {
+			fmt.Println("[Shutdown] flush pipeline: " + e.Error())
+		}
+	}
+	c.Close()
+	atomic.AddInt64(&p.ActiveNum, -1)
+}
+
+// Shutdown drains every shard at once, the first error is returned
+func (mp *MultiPool) Shutdown(ctx context.Context) error {
+	pools := make([]*Pool, 0, len(mp.pools))
+	for _, p := range mp.pools {
+		pools = append(pools, p)
+	}
+	return shutdownAll(ctx, pools)
+}
+
+// Shutdown drains the pool of every node seen so far
+func (r *Resharding) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	pools := make([]*Pool, 0, len(r.pools))
+	for _, p := range r.pools {
+		pools = append(pools, p)
+	}
+	r.mu.Unlock()
+	return shutdownAll(ctx, pools)
+}
+
+// Shutdown drains the master and the replicas
+func (r *ReadRouter) Shutdown(ctx context.Context) error {
+	r.mu.RLock()
+	pools := []*Pool{r.master}
+	for _, rep := range r.replicas {
+		pools = append(pools, rep.pool)
+	}
+	r.mu.RUnlock()
+	return shutdownAll(ctx, pools)
+}
+
+func shutdownAll(ctx context.Context, pools []*Pool) error {
+	errs := make([]error, len(pools))
+	var wg sync.WaitGroup
+	for i, p := range pools {
+		wg.Add(1)
+		go func(i int, p *Pool) {
+			defer wg.Done()
+			errs[i] = p.Shutdown(ctx)
+		}(i, p)
+	}
+	wg.Wait()
+	for _, e := range errs {
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package msgredis
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolShutdown(t *testing.T) {
+	var sets int32
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "SET" {
+			atomic.AddInt32(&sets, 1)
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	c1, c2 := p.Pop(), p.Pop()
+	p.Push(c2)
+	c1.PipeSend("SET", "k", "v")
+
+	done := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- p.Shutdown(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if c := p.Pop(); c != nil {
+		t.Fatal("checkout after shutdown")
+	}
+	select {
+	case e := <-done:
+		t.Fatalf("returned %v with a conn out", e)
+	default:
+	}
+	p.Push(c1)
+	if e := <-done; e != nil {
+		t.Fatal(e)
+	}
+	if atomic.LoadInt32(&sets) != 1 || p.Actives() != 0 || p.Idles() != 0 {
+		t.Fatalf("%d sets, %d actives, %d idles", sets, p.Actives(), p.Idles())
+	}
+
+	p = NewPool(s.Addr(), "")
+	c := p.Pop()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if e := p.Shutdown(ctx); e != context.DeadlineExceeded {
+		t.Fatalf("got %v", e)
+	}
+	p.Push(c)
+	if p.Actives() != 0 || p.Idles() != 0 {
+		t.Fatalf("%d actives, %d idles", p.Actives(), p.Idles())
+	}
+}
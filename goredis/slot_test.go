@@ -0,0 +1,48 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestKeySlot(t *testing.T) {
+	cases := map[string]uint16{
+		"":          0,
+		"foo":       12182,
+		"bar":       5061,
+		"123456789": 12739,
+		"{foo}bar":  12182,
+	}
+	for key, slot := range cases {
+		if s := KeySlot(key); s != slot {
+			t.Errorf("KeySlot(%q)=%d, want %d", key, s, slot)
+		}
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	cases := map[string]string{
+		"user1000":             "user1000",
+		"{user1000}.following": "user1000",
+		"foo{}{bar}":           "foo{}{bar}",
+		"foo{{bar}}zap":        "{bar",
+		"foo{bar}{zap}":        "bar",
+		"foo{bar":              "foo{bar",
+	}
+	for key, tag := range cases {
+		if h := HashTag(key); h != tag {
+			t.Errorf("HashTag(%q)=%q, want %q", key, h, tag)
+		}
+	}
+}
+
+func TestCheckSlot(t *testing.T) {
+	if _, e := CheckSlot("{user1000}.following", "{user1000}.followers"); e != nil {
+		t.Error(e)
+	}
+	if _, e := CheckSlot("foo", "bar"); e != ErrCrossSlot {
+		t.Errorf("CheckSlot(foo, bar) err=%v, want ErrCrossSlot", e)
+	}
+	if _, e := CheckSlot(); e != ErrBadArgs {
+		t.Errorf("CheckSlot() err=%v, want ErrBadArgs", e)
+	}
+}
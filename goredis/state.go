@@ -0,0 +1,148 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// session state a borrower may leave on a conn
+const (
+	stateMulti = 1 << iota
+	stateWatch
+	stateSubscribed
+	stateTracking
+	stateSelect
+	stateReplyOff
+	// a raw request that could not be parsed, only RESET undoes it
+	stateUnknown
+)
+
+// pushed messages read past before the +RESET reply of a subscribed conn
+const maxResetSkip = 10000
+
+var ErrStateNotRecovered = errors.New(CommonErrPrefix + "conn state could not be reset")
+
+// trackState records commands that change the session state
+func (c *Conn) trackState(command string, args []interface{}) {
+	switch strings.ToUpper(command) {
+	case "MULTI":
+		c.state |= stateMulti
+	case "EXEC", "DISCARD":
+		c.state &^= stateMulti | stateWatch
+	case "WATCH":
+		c.state |= stateWatch
+	case "UNWATCH":
+		c.state &^= stateWatch
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		c.state |= stateSubscribed
+	case "SELECT":
+		if len(args) == 1 && argString(args[0]) != strconv.Itoa(c.db) {
+			c.state |= stateSelect
+		} else {
+			c.state &^= stateSelect
+		}
+	case "CLIENT":
+		if len(args) < 2 {
+			return
+		}
+		sub, opt := strings.ToUpper(argString(args[0])), strings.ToUpper(argString(args[1]))
+		switch {
+		case sub == "TRACKING" && opt == "ON":
+			c.state |= stateTracking
+		case sub == "TRACKING" && opt == "OFF":
+			c.state &^= stateTracking
+		case sub == "REPLY" && opt != "ON":
+			c.state |= stateReplyOff
+		case sub == "REPLY":
+			c.state &^= stateReplyOff
+		}
+	case "RESET":
+		c.state = 0
+	}
+}
+
+// RESET discards MULTI, unwatches, unsubscribes, turns off tracking,
+// selects db 0 and re-authenticates, as RESET drops AUTH (redis >= 6.2).
+// The protocol, DB and client name the conn was dialed with are set again.
+func (c *Conn) RESET() error {
+	state := c.state
+	v, e := c.Call("RESET")
+	for i := 0; e == nil && !isResetReply(v); i++ {
+		if i == maxResetSkip {
+			c.broken = true
+			return ErrStateNotRecovered
+		}
+		// messages pushed to a subscribed conn before the reply
+		v, e = c.readResponse()
+		c.checkBroken(e)
+	}
+	if e != nil {
+		c.state = state
+		return e
+	}
+	// RESET also switches back to RESP2
+	c.resp3 = false
+	if c.password != "" {
+		if e = c.auth(c.username, c.password); e != nil {
+			return e
+		}
+	}
+	if c.protocol == 3 {
+		if e = c.HELLO3(); e != nil {
+			return e
+		}
+	}
+	// RESET also selects db 0 and drops the client name
+	if c.db != 0 {
+		if e = c.okCall("SELECT", c.db); e != nil {
+			return e
+		}
+	}
+	if c.clientName != "" {
+		return c.okCall("CLIENT", "SETNAME", c.clientName)
+	}
+	return nil
+}
+
+func isResetReply(v interface{}) bool {
+	b, ok := v.([]byte)
+	return ok && string(b) == "RESET"
+}
+
+// recoverState leaves the conn as Dial made it before a pool hands it to
+// the next borrower. Servers without RESET get DISCARD/UNWATCH/SELECT 0,
+// state those cannot undo makes the conn unusable: ErrStateNotRecovered.
+func (c *Conn) recoverState() error {
+	if c.state == 0 || c.proxyCompat {
+		return nil
+	}
+	e := c.RESET()
+	if e == nil || !isReplyError(e) || !strings.Contains(strings.ToLower(e.Error()), "unknown command") {
+		return e
+	}
+	if c.state&(stateSubscribed|stateTracking|stateUnknown) != 0 {
+		return ErrStateNotRecovered
+	}
+	if c.state&stateReplyOff != 0 {
+		if e = c.SetReplyOff(false); e != nil {
+			return e
+		}
+	}
+	if c.state&stateMulti != 0 {
+		if e = c.Discard(); e != nil {
+			return e
+		}
+	}
+	if c.state&stateWatch != 0 {
+		if _, e = c.Call("UNWATCH"); e != nil {
+			return e
+		}
+	}
+	if c.state&stateSelect != 0 {
+		if _, e = c.Call("SELECT", c.db); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package msgredis
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecoverState(t *testing.T) {
+	for _, hasReset := range []bool{true, false} {
+		var mu sync.Mutex
+		var got []string
+		s := newFakeServer(t, func(args []string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, args[0])
+			switch args[0] {
+			case "RESET":
+				if !hasReset {
+					return "-ERR unknown command 'RESET'\r\n"
+				}
+				return "+RESET\r\n"
+			case "SUBSCRIBE":
+				return "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n"
+			case "MULTI", "DISCARD", "WATCH", "UNWATCH", "SELECT":
+				return "+OK\r\n"
+			}
+			return "$-1\r\n"
+		})
+		p := NewPool(s.Addr(), "")
+
+		c := p.Pop()
+		c.Call("WATCH", "k")
+		c.MULTI()
+		c.Call("SELECT", 3)
+		p.Push(c)
+
+		mu.Lock()
+		want := "WATCH MULTI SELECT RESET"
+		if !hasReset {
+			want += " DISCARD SELECT"
+		}
+		if s := strings.Join(got, " "); s != want {
+			t.Fatalf("reset %v: got %q, want %q", hasReset, s, want)
+		}
+		mu.Unlock()
+		if p.Idles() != 1 {
+			t.Fatalf("reset %v: conn not reused", hasReset)
+		}
+
+		// subscribe state cannot be undone without RESET
+		c = p.Pop()
+		c.Call("SUBSCRIBE", "ch")
+		p.Push(c)
+		if want := 0; !hasReset && p.Idles() != want {
+			t.Fatalf("subscribed conn was pooled")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package msgredis
+
+import (
+	"time"
+)
+
+type StreamEntry struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// [[id, [f1, v1...]], ...]
+func replyStreamEntries(v interface{}) ([]StreamEntry, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrBadType
+	}
+	entries := make([]StreamEntry, len(arr))
+	for i, x := range arr {
+		entry, ok := x.([]interface{})
+		if !ok || len(entry) != 2 {
+			return nil, ErrBadType
+		}
+		id, e := replyString(entry[0])
+		if e != nil {
+			return nil, e
+		}
+		entries[i] = StreamEntry{ID: id}
+		if entry[1] == nil {
+			// a pending entry deleted by XDEL or XTRIM
+			continue
+		}
+		if entries[i].Fields, e = replyStringMap(entry[1]); e != nil {
+			return nil, e
+		}
+	}
+	return entries, nil
+}
+
+/******************* streams commands *******************/
+// count <= 0 returns the whole range
+func (c *Conn) XRANGE(key, start, end string, count int) ([]StreamEntry, error) {
+	args := []interface{}{key, start, end}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	v, e := c.Call("XRANGE", args...)
+	if e != nil {
+		return nil, e
+	}
+	return replyStreamEntries(v)
+}
+
+// XREAD [COUNT count] [BLOCK ms] STREAMS key id for one stream, nil entries
+// if BLOCK timed out. block < 0 does not block.
+func (c *Conn) XREAD(count int, block time.Duration, key, id string) ([]StreamEntry, error) {
+	args := []interface{}{}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block >= 0 {
+		args = append(args, "BLOCK", block)
+	}
+	args = append(args, "STREAMS", key, id)
+	v, e := c.Call("XREAD", args...)
+	if e != nil {
+		return nil, e
+	}
+	return replyReadStream(v)
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK ms] STREAMS key id,
+// id ">" reads new entries, "0" the pending entries of consumer
+func (c *Conn) XREADGROUP(group, consumer string, count int, block time.Duration, key, id string) ([]StreamEntry, error) {
+	args := []interface{}{"GROUP", group, consumer}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block >= 0 {
+		args = append(args, "BLOCK", block)
+	}
+	args = append(args, "STREAMS", key, id)
+	v, e := c.Call("XREADGROUP", args...)
+	if e != nil {
+		return nil, e
+	}
+	return replyReadStream(v)
+}
+
+func (c *Conn) XACK(key, group string, ids ...string) (int64, error) {
+	n, e := c.Call("XACK", Args{key, group}.Add(ids)...)
+	if e != nil {
+		return -1, e
+	}
+	return n.(int64), nil
+}
+
+// [[key, entries]] of a single stream read, nil on timeout
+func replyReadStream(v interface{}) ([]StreamEntry, error) {
+	if v == nil {
+		return nil, nil
+	}
+	streams, ok := v.([]interface{})
+	if !ok || len(streams) > 1 {
+		return nil, ErrBadType
+	}
+	if len(streams) == 0 {
+		// *-1 decodes to a nil []interface{}
+		return nil, nil
+	}
+	stream, ok := streams[0].([]interface{})
+	if !ok || len(stream) != 2 {
+		return nil, ErrBadType
+	}
+	return replyStreamEntries(stream[1])
+}
@@ -0,0 +1,133 @@
+package msgredis
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// reply kinds as decoded by readResponse, simple and bulk strings are
+// both []byte and cannot be told apart
+const (
+	KindString = 1 << iota
+	KindInteger
+	KindArray
+	KindNil
+	KindDouble
+)
+
+var kindNames = []string{"string", "integer", "array", "nil", "double"}
+
+func kindString(kinds int) string {
+	names := []string{}
+	for i, name := range kindNames {
+		if kinds&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// expected reply kinds of the commands wrapped by typed methods
+var replyKinds = map[string]int{}
+
+func init() {
+	for kinds, commands := range map[int]string{
+		KindString | KindNil:              "GET GETSET GETDEL GETEX LINDEX LPOP RPOP SPOP RPOPLPUSH LMOVE HGET SET SRANDMEMBER",
+		KindString | KindNil | KindDouble: "ZSCORE ZINCRBY",
+		KindString | KindDouble:           "INCRBYFLOAT HINCRBYFLOAT",
+		KindString:                        "TYPE PING ECHO SETEX PSETEX MSET RENAME SELECT FLUSHDB FLUSHALL SAVE BGSAVE BGREWRITEAOF MULTI DISCARD WATCH UNWATCH AUTH LSET LTRIM PFMERGE RESET ASKING",
+		KindInteger: "INCR DECR INCRBY DECRBY APPEND STRLEN SETRANGE SETNX MSETNX DEL UNLINK EXISTS EXPIRE PEXPIRE EXPIREAT PEXPIREAT PERSIST TTL PTTL TOUCH " +
+			"HSET HSETNX HDEL HLEN HEXISTS HSTRLEN HINCRBY LLEN LPUSH RPUSH LPUSHX RPUSHX LINSERT LREM SADD SREM SCARD SISMEMBER SMOVE " +
+			"SDIFFSTORE SINTERSTORE SUNIONSTORE ZADD ZCARD ZCOUNT ZLEXCOUNT ZREM ZREMRANGEBYRANK ZREMRANGEBYSCORE ZREMRANGEBYLEX " +
+			"ZUNIONSTORE ZINTERSTORE PUBLISH GETBIT SETBIT BITCOUNT BITPOS PFADD PFCOUNT DBSIZE LASTSAVE WAIT XACK XLEN GEOADD",
+		KindInteger | KindNil: "ZRANK ZREVRANK",
+		KindArray: "MGET HMGET HKEYS HVALS HGETALL SMEMBERS SDIFF SINTER SUNION LRANGE ZRANGE ZREVRANGE ZRANGEBYSCORE " +
+			"ZREVRANGEBYSCORE ZRANGEBYLEX KEYS SCAN SSCAN HSCAN ZSCAN XRANGE XREVRANGE GEOPOS GEOSEARCH",
+		KindArray | KindNil: "BLPOP BRPOP EXEC XREAD XREADGROUP",
+	} {
+		for _, command := range strings.Fields(commands) {
+			replyKinds[command] = kinds
+		}
+	}
+}
+
+// UnexpectedReplyError is returned in strict mode instead of a reply of a
+// kind the command never answers with, rather than letting a typed method
+// panic on its type assertion.
+type UnexpectedReplyError struct {
+	Command  string
+	Expected string
+	Actual   string
+	// hex of the first bytes of a string reply, the value otherwise
+	Preview string
+}
+
+func (e *UnexpectedReplyError) Error() string {
+	return CommonErrPrefix + "unexpected reply to " + e.Command + ": expected " +
+		e.Expected + ", got " + e.Actual + " " + e.Preview
+}
+
+// SetStrict checks every reply of a known command against the kinds it
+// may return
+func (c *Conn) SetStrict(on bool) {
+	c.strict = on
+}
+
+func replyKind(v interface{}) int {
+	switch data := v.(type) {
+	case nil:
+		return KindNil
+	case []byte:
+		return KindString
+	case int64:
+		return KindInteger
+	case float64:
+		return KindDouble
+	case []interface{}:
+		if data == nil {
+			// *-1
+			return KindNil
+		}
+		return KindArray
+	}
+	return 0
+}
+
+const previewLen = 16
+
+func replyPreview(v interface{}) string {
+	switch data := v.(type) {
+	case []byte:
+		if len(data) > previewLen {
+			return hex.EncodeToString(data[:previewLen]) + "..."
+		}
+		return hex.EncodeToString(data)
+	case int64:
+		return strconv.FormatInt(data, 10)
+	case float64:
+		return strconv.FormatFloat(data, 'g', -1, 64)
+	case []interface{}:
+		return "len " + strconv.Itoa(len(data))
+	}
+	return ""
+}
+
+// checkReply validates a successful reply in strict mode
+func (c *Conn) checkReply(command string, v interface{}) error {
+	name := strings.ToUpper(command)
+	kinds, ok := replyKinds[name]
+	if !ok {
+		return nil
+	}
+	kind := replyKind(v)
+	if kinds&kind != 0 {
+		return nil
+	}
+	return &UnexpectedReplyError{
+		Command:  name,
+		Expected: kindString(kinds),
+		Actual:   kindString(kind),
+		Preview:  replyPreview(v),
+	}
+}
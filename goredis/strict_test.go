@@ -0,0 +1,38 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestStrictReplies(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			// a proxy answering with the wrong kind
+			return ":1\r\n"
+		case "INCR":
+			return "$5\r\nhello\r\n"
+		}
+		return "$-1\r\n"
+	})
+	c := dialFake(t, s)
+	c.SetStrict(true)
+
+	_, e := c.GET("k")
+	ue, ok := e.(*UnexpectedReplyError)
+	if !ok || ue.Command != "GET" || ue.Expected != "string|nil" || ue.Actual != "integer" || ue.Preview != "1" {
+		t.Fatalf("GET: %#v", e)
+	}
+	_, e = c.INCR("k")
+	if ue, ok = e.(*UnexpectedReplyError); !ok || ue.Preview != "68656c6c6f" {
+		t.Fatalf("INCR: %#v", e)
+	}
+	// nil is a valid GET reply
+	if _, e = c.Call("HGET", "h", "f"); e != nil {
+		t.Fatal(e)
+	}
+	// unknown commands are not checked
+	if _, e = c.Call("MODULE.CMD"); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,195 @@
+package msgredis
+
+import (
+	"bytes"
+	"time"
+)
+
+const (
+	SyncMissing = "missing"
+	SyncValue   = "value"
+	SyncTTL     = "ttl"
+	// the key could not be read, see SyncDiff.Err
+	SyncError = "error"
+)
+
+type SyncOptions struct {
+	// SCAN MATCH pattern, empty means every key
+	Match string
+	// SCAN COUNT hint
+	Count int
+	// report differences without writing to the target
+	DryRun bool
+	// ttl differences below this are ignored, the reads are not atomic
+	TTLTolerance time.Duration
+}
+
+type SyncDiff struct {
+	Key    string
+	Reason string
+	// the read or RESTORE of the key failed, Sync goes on with the others
+	Err error
+}
+
+type SyncReport struct {
+	Scanned int
+	Diffs   []SyncDiff
+	Applied int
+}
+
+// one key as read from one side
+type syncKey struct {
+	dump []byte
+	pttl int64
+	err  error
+}
+
+// Sync SCANs src and compares every key with dst by DUMP payload and TTL.
+// Keys missing or different on dst are copied with RESTORE ... REPLACE
+// unless DryRun is set. Keys only present on dst are left alone. Keys that
+// fail to read or RESTORE are reported with their Err, the others synced.
+func Sync(src, dst *Conn, opt SyncOptions) (*SyncReport, error) {
+	report := &SyncReport{}
+	cursor := 0
+	for {
+		next, elements, e := src.SCAN(cursor, opt.Match != "", opt.Match, opt.Count > 0, opt.Count)
+		if e != nil {
+			return report, e
+		}
+		keys := make([]string, len(elements))
+		for i, el := range elements {
+			keys[i] = string(el.([]byte))
+		}
+		if e = syncKeys(src, dst, keys, opt, report); e != nil {
+			return report, e
+		}
+		if next == 0 {
+			return report, nil
+		}
+		cursor = next
+	}
+}
+
+func syncKeys(src, dst *Conn, keys []string, opt SyncOptions, report *SyncReport) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	from, e := readSyncKeys(src, keys)
+	if e != nil {
+		return e
+	}
+	to, e := readSyncKeys(dst, keys)
+	if e != nil {
+		return e
+	}
+
+	// index in report.Diffs of every RESTORE sent
+	var applying []int
+	for i, key := range keys {
+		report.Scanned++
+		if re := from[i].err; re != nil || to[i].err != nil {
+			if re == nil {
+				re = to[i].err
+			}
+			report.Diffs = append(report.Diffs, SyncDiff{Key: key, Reason: SyncError, Err: re})
+			continue
+		}
+		if from[i].dump == nil {
+			// expired or deleted since SCAN
+			continue
+		}
+		reason := ""
+		if to[i].dump == nil {
+			reason = SyncMissing
+		} else if !bytes.Equal(dumpPayload(from[i].dump), dumpPayload(to[i].dump)) {
+			reason = SyncValue
+		} else if ttlDiffers(from[i].pttl, to[i].pttl, opt.TTLTolerance) {
+			reason = SyncTTL
+		}
+		if reason == "" {
+			continue
+		}
+		report.Diffs = append(report.Diffs, SyncDiff{Key: key, Reason: reason})
+		if opt.DryRun {
+			continue
+		}
+		ttl := from[i].pttl
+		if ttl < 0 {
+			ttl = 0
+		}
+		if e = dst.PipeSend("RESTORE", key, ttl, from[i].dump, "REPLACE"); e != nil {
+			// the RESTOREs queued are still read below
+			report.Diffs[len(report.Diffs)-1].Err = e
+			break
+		}
+		applying = append(applying, len(report.Diffs)-1)
+	}
+	if len(applying) == 0 {
+		return e
+	}
+	_, errs, pe := dst.pipeExec(time.Time{})
+	if pe != nil {
+		return pe
+	}
+	for i, d := range applying {
+		if errs[i] != nil {
+			report.Diffs[d].Err = errs[i]
+		} else {
+			report.Applied++
+		}
+	}
+	return e
+}
+
+// readSyncKeys reads the DUMP and PTTL of keys, the errors of one key are
+// in its syncKey
+func readSyncKeys(c *Conn, keys []string) ([]syncKey, error) {
+	var e error
+	for _, key := range keys {
+		if e = c.PipeSend("DUMP", key); e == nil {
+			e = c.PipeSend("PTTL", key)
+		}
+		if e != nil {
+			break
+		}
+	}
+	// what was queued is read even after a failed PipeSend, the replies
+	// would otherwise go to the next command
+	ret, errs, pe := c.pipeExec(time.Time{})
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, e
+	}
+	out := make([]syncKey, len(keys))
+	for i := range keys {
+		if out[i].err = errs[2*i]; out[i].err == nil {
+			out[i].err = errs[2*i+1]
+		}
+		out[i].dump, _ = ret[2*i].([]byte)
+		out[i].pttl, _ = ret[2*i+1].(int64)
+	}
+	return out, nil
+}
+
+// dumpPayload strips the 2 bytes RDB version and the CRC64 ending DUMP
+// payloads: the CRC covers the version, which differs between servers of
+// different versions holding the same value
+func dumpPayload(dump []byte) []byte {
+	if len(dump) < 10 {
+		return dump
+	}
+	return dump[:len(dump)-10]
+}
+
+func ttlDiffers(a, b int64, tolerance time.Duration) bool {
+	if (a < 0) != (b < 0) {
+		return true
+	}
+	d := time.Duration(a-b) * time.Millisecond
+	if d < 0 {
+		d = -d
+	}
+	return d > tolerance
+}
@@ -0,0 +1,146 @@
+package msgredis
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a DUMP payload: the value, the RDB version and a CRC64 covering both
+func dumpOf(value string, version byte) string {
+	return value + string([]byte{version, 0}) + strings.Repeat(string('a'+version), 8)
+}
+
+type syncStore struct {
+	mu   sync.Mutex
+	keys map[string]string
+	pttl map[string]int64
+	// replies to DUMP or RESTORE of a key
+	fail     map[string]string
+	restored []string
+}
+
+func newSyncServer(t *testing.T, st *syncStore) *fakeServer {
+	return newFakeServer(t, func(args []string) string {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if reply, ok := st.fail[args[0]+" "+args[len(args)-1]]; ok {
+			return reply
+		}
+		switch args[0] {
+		case "SCAN":
+			var keys []string
+			for k := range st.keys {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return "*2\r\n" + bulk("0") + respArray(keys...)
+		case "DUMP":
+			if v, ok := st.keys[args[1]]; ok {
+				return bulk(v)
+			}
+			return "$-1\r\n"
+		case "PTTL":
+			if _, ok := st.keys[args[1]]; !ok {
+				return ":-2\r\n"
+			}
+			return ":" + strconv.FormatInt(st.pttl[args[1]], 10) + "\r\n"
+		case "RESTORE":
+			if reply, ok := st.fail["RESTORE "+args[1]]; ok {
+				return reply
+			}
+			st.keys[args[1]] = args[3]
+			st.pttl[args[1]], _ = strconv.ParseInt(args[2], 10, 64)
+			st.restored = append(st.restored, args[1])
+			return "+OK\r\n"
+		}
+		return "-ERR unexpected " + args[0] + "\r\n"
+	})
+}
+
+func TestSync(t *testing.T) {
+	newStores := func() (*syncStore, *syncStore) {
+		src := &syncStore{
+			keys: map[string]string{
+				"missing": dumpOf("m", 9), "value": dumpOf("new", 9), "same": dumpOf("s", 11),
+				"ttl": dumpOf("t", 9), "unreadable": dumpOf("u", 9),
+			},
+			pttl: map[string]int64{"missing": -1, "value": -1, "same": 5000, "ttl": 60000, "unreadable": -1},
+		}
+		dst := &syncStore{
+			// same holds the same value written by an older server
+			keys: map[string]string{
+				"value": dumpOf("old", 9), "same": dumpOf("s", 9), "ttl": dumpOf("t", 9), "unreadable": dumpOf("u", 9),
+			},
+			pttl: map[string]int64{"value": -1, "same": 4990, "ttl": 1000, "unreadable": -1},
+			fail: map[string]string{"DUMP unreadable": "-ERR dump refused\r\n"},
+		}
+		return src, dst
+	}
+	reasons := func(r *SyncReport) map[string]string {
+		m := make(map[string]string)
+		for _, d := range r.Diffs {
+			m[d.Key] = d.Reason
+			if d.Err != nil {
+				m[d.Key] += " " + d.Err.Error()
+			}
+		}
+		return m
+	}
+
+	// dry run
+	srcStore, dstStore := newStores()
+	src, dst := dialFake(t, newSyncServer(t, srcStore)), dialFake(t, newSyncServer(t, dstStore))
+	r, e := Sync(src, dst, SyncOptions{DryRun: true, TTLTolerance: time.Second})
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := map[string]string{
+		"missing": SyncMissing, "value": SyncValue, "ttl": SyncTTL,
+		"unreadable": SyncError + " " + CommonErrPrefix + "ERR dump refused",
+	}
+	got := reasons(r)
+	if r.Scanned != 5 || r.Applied != 0 || len(got) != len(want) || len(dstStore.restored) != 0 {
+		t.Fatalf("dry run: %+v, restored %v", r, dstStore.restored)
+	}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("%s: %q, want %q", k, got[k], w)
+		}
+	}
+
+	// a failed RESTORE does not stop the others
+	srcStore, dstStore = newStores()
+	dstStore.fail["RESTORE value"] = "-BUSYKEY target key name already exists\r\n"
+	src, dst = dialFake(t, newSyncServer(t, srcStore)), dialFake(t, newSyncServer(t, dstStore))
+	if r, e = Sync(src, dst, SyncOptions{TTLTolerance: time.Second}); e != nil {
+		t.Fatal(e)
+	}
+	got = reasons(r)
+	if r.Applied != 2 || got["value"] != SyncValue+" "+CommonErrPrefix+"BUSYKEY target key name already exists" {
+		t.Fatalf("partial failure: %+v", got)
+	}
+	sort.Strings(dstStore.restored)
+	if len(dstStore.restored) != 2 || dstStore.restored[0] != "missing" || dstStore.restored[1] != "ttl" {
+		t.Fatalf("restored %v", dstStore.restored)
+	}
+	if dstStore.pttl["ttl"] != 60000 || dstStore.keys["missing"] != dumpOf("m", 9) {
+		t.Fatalf("dst %v %v", dstStore.keys, dstStore.pttl)
+	}
+	// the conns are still in step
+	if v, e := dst.Call("DUMP", "missing"); e != nil || string(v.([]byte)) != dumpOf("m", 9) {
+		t.Fatalf("after sync: %v %v", v, e)
+	}
+
+	// a refused PipeSend fails the batch, the DUMP queued before it is read
+	dst.SetRenameCommands(map[string]string{"PTTL": ""})
+	if _, e = Sync(src, dst, SyncOptions{}); e != ErrCommandDisabled {
+		t.Fatalf("refused PTTL: %v", e)
+	}
+	if v, e := dst.Call("DUMP", "ttl"); e != nil || string(v.([]byte)) != dumpOf("t", 9) {
+		t.Fatalf("after refused PTTL: %v %v", v, e)
+	}
+}
@@ -0,0 +1,66 @@
+package msgredis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commands whose bare time arguments are milliseconds
+var millisCommands = map[string]bool{
+	"PEXPIRE":    true,
+	"PEXPIREAT":  true,
+	"PSETEX":     true,
+	"WAIT":       true,
+	"XCLAIM":     true,
+	"XAUTOCLAIM": true,
+	"CLIENT":     true, // CLIENT PAUSE
+}
+
+// options naming the unit of the argument after them, e.g. SET k v PX 100
+var unitOptions = map[string]bool{
+	"EX":    false,
+	"EXAT":  false,
+	"PX":    true,
+	"PXAT":  true,
+	"BLOCK": true, // XREAD BLOCK
+}
+
+// millis reports whether the time argument following prev is in
+// milliseconds for command
+func millis(command string, prev interface{}) bool {
+	if opt, ok := prev.(string); ok {
+		if ms, ok := unitOptions[strings.ToUpper(opt)]; ok {
+			return ms
+		}
+	}
+	return millisCommands[strings.ToUpper(command)]
+}
+
+// durationArg encodes d in the unit command expects. Whole seconds are
+// rounded up, a TTL of 1500ms must not become 1s and 0 would expire the
+// key at once; blocking commands take fractional seconds instead.
+func durationArg(command string, prev interface{}, d time.Duration) string {
+	if millis(command, prev) {
+		return strconv.FormatInt(int64((d+time.Millisecond-1)/time.Millisecond), 10)
+	}
+	if ci := LookupCommand(command); ci != nil && ci.Is(FlagBlocking) {
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	}
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// timeArg encodes t as unix seconds or milliseconds, e.g. EXPIREAT/PEXPIREAT
+func timeArg(command string, prev interface{}, t time.Time) string {
+	if millis(command, prev) {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func prevArg(args []interface{}, i int) interface{} {
+	if i == 0 {
+		return nil
+	}
+	return args[i-1]
+}
@@ -0,0 +1,40 @@
+package msgredis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeArgs(t *testing.T) {
+	var got []string
+	s := newFakeServer(t, func(args []string) string {
+		got = args
+		return ":1\r\n"
+	})
+	c := dialFake(t, s)
+
+	at := time.Unix(1700000000, 250e6)
+	cases := []struct {
+		command string
+		args    []interface{}
+		want    string
+	}{
+		{"EXPIRE", []interface{}{"k", 90 * time.Second}, "EXPIRE k 90"},
+		{"EXPIRE", []interface{}{"k", 1500 * time.Millisecond}, "EXPIRE k 2"},
+		{"PEXPIRE", []interface{}{"k", 1500 * time.Millisecond}, "PEXPIRE k 1500"},
+		{"SET", []interface{}{"k", "v", "PX", 2 * time.Second}, "SET k v PX 2000"},
+		{"SET", []interface{}{"k", "v", "EX", 2 * time.Second}, "SET k v EX 2"},
+		{"BLPOP", []interface{}{"k", 500 * time.Millisecond}, "BLPOP k 0.5"},
+		{"EXPIREAT", []interface{}{"k", at}, "EXPIREAT k 1700000000"},
+		{"PEXPIREAT", []interface{}{"k", at}, "PEXPIREAT k 1700000000250"},
+	}
+	for _, tc := range cases {
+		if _, e := c.Call(tc.command, tc.args...); e != nil {
+			t.Fatal(e)
+		}
+		if s := strings.Join(got, " "); s != tc.want {
+			t.Errorf("got %q, want %q", s, tc.want)
+		}
+	}
+}
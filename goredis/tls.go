@@ -0,0 +1,28 @@
+package msgredis
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+// DialTLS is Dial over TLS, e.g. for managed services requiring encrypted
+// transport. tlsConfig holds the CAs trusted (RootCAs, the system ones if
+// nil), the client certificates for mutual auth (Certificates), and the
+// name verified against the server certificate (ServerName, the host of
+// address if empty). PubSubConn redials keep it; pools take it from
+// Pool.TLSConfig.
+func DialTLS(address, password string, tlsConfig *tls.Config, connectTimeout, readTimeout, writeTimeout time.Duration, keepAlive bool, pool *Pool) (*Conn, error) {
+	if tlsConfig == nil {
+		return nil, ErrBadArgs
+	}
+	return dialContext(context.Background(), address, DialOptions{
+		Password:       password,
+		ConnectTimeout: connectTimeout,
+		ReadTimeout:    readTimeout,
+		WriteTimeout:   writeTimeout,
+		KeepAlive:      keepAlive,
+		TLSConfig:      tlsConfig,
+		Pool:           pool,
+	})
+}
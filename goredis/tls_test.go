@@ -0,0 +1,155 @@
+package msgredis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a certificate for name and a pool trusting it
+func selfSigned(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestDialTLS(t *testing.T) {
+	serverCert, serverCAs := selfSigned(t, "redis.test")
+	clientCert, clientCAs := selfSigned(t, "app")
+	ln, e := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	s := &fakeServer{ln: ln, handler: okHandler}
+	go s.serve()
+	defer ln.Close()
+
+	cfg := &tls.Config{RootCAs: serverCAs, ServerName: "redis.test", Certificates: []tls.Certificate{clientCert}}
+	c, e := DialTLS(s.Addr(), "", cfg, time.Second, time.Second, time.Second, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v, e := c.Call("PING"); e != nil || string(v.([]byte)) != "OK" {
+		t.Fatalf("ping: %v %v", v, e)
+	}
+	c.Close()
+
+	p := NewPool(s.Addr(), "")
+	p.TLSConfig = cfg
+	if c = p.Pop(); c == nil {
+		t.Fatal("pool dial")
+	}
+	p.Push(c)
+
+	// the name does not match the certificate
+	wrongName := cfg.Clone()
+	wrongName.ServerName = "other.test"
+	if c, e = DialTLS(s.Addr(), "", wrongName, time.Second, time.Second, time.Second, false, nil); e == nil {
+		t.Fatal("wrong server name accepted")
+	}
+	// the server requires a client certificate: TLS 1.3 reports it on the
+	// first read
+	noCert := cfg.Clone()
+	noCert.Certificates = nil
+	if c, e = DialTLS(s.Addr(), "", noCert, time.Second, time.Second, time.Second, false, nil); e == nil {
+		_, e = c.Call("PING")
+		c.Close()
+	}
+	if e == nil {
+		t.Fatal("missing client certificate accepted")
+	}
+	if _, e = DialTLS(s.Addr(), "", nil, time.Second, time.Second, time.Second, false, nil); e != ErrBadArgs {
+		t.Fatalf("nil config: %v", e)
+	}
+}
+
+// newTLSFakeServer serves handler over TLS, returning the client config
+func newTLSFakeServer(t *testing.T, handler func(args []string) string) (*fakeServer, *tls.Config) {
+	cert, cas := selfSigned(t, "redis.test")
+	ln, e := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	s := &fakeServer{ln: ln, handler: handler}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s, &tls.Config{RootCAs: cas, ServerName: "redis.test"}
+}
+
+// the conns pools dial outside Pop take its TLS config and credentials
+func TestPoolTLSDials(t *testing.T) {
+	var mu sync.Mutex
+	var auths []string
+	s, cfg := newTLSFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			mu.Lock()
+			auths = append(auths, strings.Join(args[1:], " "))
+			mu.Unlock()
+		case "PING":
+			return "+PONG\r\n"
+		case "INFO":
+			return infoReply("# Server", "redis_version:7.2.4", "# Replication", "role:master")
+		}
+		return "+OK\r\n"
+	})
+	p := NewPool(s.Addr(), "")
+	p.TLSConfig = cfg
+	p.Credentials = staticCredentials("app", "secret")
+	r, e := p.Diagnose()
+	if e != nil || !r.Authenticated || r.Version != (Version{7, 2, 4}) {
+		t.Fatalf("diagnose: %+v %v", r, e)
+	}
+
+	serverCert, cas := selfSigned(t, "redis.test")
+	ln, e := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	rp := NewPool(servePubSub(t, ln, true), "")
+	rp.TLSConfig = &tls.Config{RootCAs: cas, ServerName: "redis.test"}
+	if v, e := rp.Request("rpc:ping", []byte("ping"), time.Second); e != nil || string(v) != "pong" {
+		t.Fatalf("request: %q %v", v, e)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(auths) == 0 || auths[0] != "app secret" {
+		t.Fatalf("auth: %v", auths)
+	}
+}
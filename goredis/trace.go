@@ -0,0 +1,169 @@
+package msgredis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NPlusOneThreshold is the number of separate round trips of one command
+// on keys of one shape from which Trace.Summary flags an N+1 pattern
+const NPlusOneThreshold = 5
+
+// TraceEntry is one command of a Trace
+type TraceEntry struct {
+	Addr    string
+	Command string
+	// the first key, "" for commands without keys
+	Key      string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	// non zero for pipelined commands, see HookEvent
+	BatchID uint64
+}
+
+// Trace records the commands issued for one application request, e.g. to
+// find the N+1 access patterns of a handler in development. Conns taken
+// with PopContext and commands sent with CallContext record into the
+// trace of their context, other conns with SetTrace.
+type Trace struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context carrying a new Trace
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// TraceFrom returns the Trace of ctx, nil if it has none
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// SetTrace records the commands of the conn into t until it is pushed back
+// to its pool, nil stops recording
+func (c *Conn) SetTrace(t *Trace) {
+	c.trace = t
+}
+
+func (t *Trace) record(ev *HookEvent) {
+	entry := TraceEntry{
+		Addr:     ev.Addr,
+		Command:  strings.ToUpper(ev.Command),
+		Start:    ev.Start,
+		Duration: ev.Duration,
+		Err:      ev.Err,
+		BatchID:  ev.BatchID,
+	}
+	if ci := LookupCommand(ev.Command); ci != nil {
+		if pos := ci.KeyPositions(ev.Args); len(pos) > 0 {
+			entry.Key = argString(ev.Args[pos[0]])
+		}
+	}
+	t.mu.Lock()
+	t.entries = append(t.entries, entry)
+	t.mu.Unlock()
+}
+
+// Entries returns the commands recorded so far, in the order of their
+// replies
+func (t *Trace) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEntry(nil), t.entries...)
+}
+
+// keyShape replaces the digit runs of key, user:42:cart => user:{n}:cart
+func keyShape(key string) string {
+	var b strings.Builder
+	digits := false
+	for i := 0; i < len(key); i++ {
+		if key[i] >= '0' && key[i] <= '9' {
+			if !digits {
+				b.WriteString("{n}")
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+type traceGroup struct {
+	command, shape string
+	count          int
+	// commands sent in a round trip of their own
+	single   int
+	failed   int
+	duration time.Duration
+}
+
+// Summary renders the commands grouped by name and key shape, most frequent
+// first, flagging the groups sent in NPlusOneThreshold or more separate
+// round trips, which a pipeline or a multi-key command would batch:
+//
+//	12 commands, 4 round trips, 3.1ms
+//	  9x GET user:{n} 2.4ms  N+1?
+//	  ...
+func (t *Trace) Summary() string {
+	entries := t.Entries()
+	groups := make(map[[2]string]*traceGroup)
+	var order []*traceGroup
+	batches := make(map[uint64]bool)
+	trips := 0
+	var total time.Duration
+	for _, entry := range entries {
+		shape := keyShape(entry.Key)
+		g, ok := groups[[2]string{entry.Command, shape}]
+		if !ok {
+			g = &traceGroup{command: entry.Command, shape: shape}
+			groups[[2]string{entry.Command, shape}] = g
+			order = append(order, g)
+		}
+		g.count++
+		g.duration += entry.Duration
+		if entry.Err != nil {
+			g.failed++
+		}
+		if entry.BatchID == 0 {
+			g.single++
+			trips++
+			total += entry.Duration
+		} else if !batches[entry.BatchID] {
+			// a pipeline is one round trip, its replies arrive together
+			batches[entry.BatchID] = true
+			trips++
+			total += entry.Duration
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].count > order[j].count })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d commands, %d round trips, %v\n", len(entries), trips, total)
+	for _, g := range order {
+		fmt.Fprintf(&b, "  %dx %s", g.count, g.command)
+		if g.shape != "" {
+			b.WriteString(" " + g.shape)
+		}
+		fmt.Fprintf(&b, " %v", g.duration)
+		if g.failed > 0 {
+			fmt.Fprintf(&b, " %d failed", g.failed)
+		}
+		if g.single >= NPlusOneThreshold {
+			b.WriteString("  N+1?")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package msgredis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "HGET" {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return bulk("v")
+	})
+	p := NewPool(s.Addr(), "")
+	ctx, tr := WithTrace(context.Background())
+	for i := 0; i < 6; i++ {
+		p.CallContext(ctx, "GET", "user:"+strconv.Itoa(i))
+	}
+	p.CallContext(ctx, "HGET", "cart:7", "total")
+	c, e := p.PopContext(ctx)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i := 6; i < 9; i++ {
+		c.PipeSend("GET", "user:"+strconv.Itoa(i))
+	}
+	c.PipeExec()
+	p.Push(c)
+	// not traced
+	c = p.Pop()
+	c.Call("GET", "other")
+	p.Push(c)
+
+	entries := tr.Entries()
+	if len(entries) != 10 || entries[0].Key != "user:0" || entries[6].Err == nil || entries[9].BatchID == 0 {
+		t.Fatalf("entries: %+v", entries)
+	}
+	sum := tr.Summary()
+	for _, want := range []string{"10 commands, 8 round trips", "9x GET user:{n}", "N+1?", "1x HGET cart:{n}", "1 failed"} {
+		if !strings.Contains(sum, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, sum)
+		}
+	}
+	if strings.Count(sum, "N+1?") != 1 {
+		t.Fatalf("summary:\n%s", sum)
+	}
+}
@@ -0,0 +1,76 @@
+package msgredis
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var ErrAddressMismatch = errors.New(CommonErrPrefix + "conn is connected to another address")
+
+// Detach takes a checked out conn away from the pool, which stops counting
+// it; Push no longer applies. The conn keeps its socket and buffers.
+func (p *Pool) Detach(c *Conn) {
+	if c.pool != p {
+		return
+	}
+	atomic.AddInt64(&p.ActiveNum, -1)
+	if c.clientID != 0 {
+		p.clients.remove(c.clientID)
+	}
+	c.pool = nil
+}
+
+// Attach makes c a checked out conn of p, detaching it from its pool
+// first, e.g. when a failover turns the node of a replica pool into the
+// master. The socket is reused instead of redialed; c gets p's options
+// and goes to p's free list on Push. c must be connected to p.Address
+// and have no session state left.
+func (p *Pool) Attach(c *Conn) error {
+	if c.broken {
+		return ErrBrokenConn
+	}
+	if c.addr != p.address() {
+		return ErrAddressMismatch
+	}
+	if e := c.recoverState(); e != nil {
+		return e
+	}
+	// an untracked conn is refused before it changes pools
+	if p.TrackClientIDs {
+		if _, e := c.ClientID(); e != nil {
+			return e
+		}
+	}
+	if c.pool != nil {
+		c.pool.Detach(c)
+	}
+	atomic.AddInt64(&p.ActiveNum, 1)
+	c.pool = p
+	c.credGen = atomic.LoadUint64(&p.credGen)
+	// the client id is known, it cannot fail
+	return p.configure(c)
+}
+
+// TransferIdle moves the idle conns of p connected to to.Address over to
+// to, it returns how many were moved
+func (p *Pool) TransferIdle(to *Pool) int {
+	n := atomic.LoadInt64(&p.IdleNum)
+	moved := 0
+	for i := int64(0); i < n; i++ {
+		c := p.take()
+		if c == nil {
+			break
+		}
+		atomic.AddInt64(&p.IdleNum, -1)
+		c.setIdle(false)
+		// counted as checked out until Attach moves it
+		atomic.AddInt64(&p.ActiveNum, 1)
+		if e := to.Attach(c); e != nil {
+			p.Push(c)
+			continue
+		}
+		to.Push(c)
+		moved++
+	}
+	return moved
+}
@@ -0,0 +1,44 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestPoolTransfer(t *testing.T) {
+	s := newFakeServer(t, okHandler)
+	replicas, master := NewPool(s.Addr(), ""), NewPool(s.Addr(), "")
+	master.ReadOnly = true
+	c1, c2 := replicas.Pop(), replicas.Pop()
+	replicas.Push(c1)
+	replicas.Push(c2)
+
+	if n := replicas.TransferIdle(master); n != 2 {
+		t.Fatalf("moved %d", n)
+	}
+	if replicas.Idles() != 0 || replicas.Actives() != 0 || master.Idles() != 2 || master.Actives() != 0 {
+		t.Fatalf("replicas %d/%d, master %d/%d", replicas.Idles(), replicas.Actives(), master.Idles(), master.Actives())
+	}
+	c := master.Pop()
+	if c != c1 && c != c2 {
+		t.Fatal("conn redialed")
+	}
+	if _, e := c.Call("SET", "k", "v"); e == nil {
+		t.Fatal("options of the new pool not applied")
+	}
+
+	other := NewPool("127.0.0.1:1", "")
+	if e := other.Attach(c); e != ErrAddressMismatch {
+		t.Fatalf("got %v", e)
+	}
+	master.Detach(c)
+	if master.Actives() != 0 {
+		t.Fatalf("%d actives", master.Actives())
+	}
+	if e := replicas.Attach(c); e != nil {
+		t.Fatal(e)
+	}
+	replicas.Push(c)
+	if replicas.Idles() != 1 || replicas.Actives() != 0 {
+		t.Fatalf("replicas %d/%d", replicas.Idles(), replicas.Actives())
+	}
+}
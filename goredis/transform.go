@@ -0,0 +1,315 @@
+package msgredis
+
+import (
+	"errors"
+	"strings"
+)
+
+// ValueTransformer encrypts values before they are written and decrypts
+// them after they are read, e.g. field-level encryption of PII. key is the
+// key the value belongs to, usable as associated data: the conn never moves
+// a transformed value to another key.
+type ValueTransformer interface {
+	Encrypt(key string, value []byte) ([]byte, error)
+	Decrypt(key string, value []byte) ([]byte, error)
+}
+
+// TransformError is returned when the transformer fails, an Encrypt
+// failure means the command was not sent
+type TransformError struct {
+	Command string
+	Key     string
+	Err     error
+}
+
+func (e *TransformError) Error() string {
+	return CommonErrPrefix + e.Command + " " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errReaderTransform  = errors.New("values streamed from a reader cannot be transformed")
+	errNotTransformable = errors.New("the command works on part of a value, it cannot be transformed")
+	errMoveTransformed  = errors.New("transformed values are bound to their key, they cannot move to another")
+)
+
+// commands working on part of a value or computing on it, refused on
+// transformed keys as they would store plaintext or read ciphertext
+var transformRefused = map[string]bool{
+	"APPEND":       true,
+	"SETRANGE":     true,
+	"GETRANGE":     true,
+	"SUBSTR":       true,
+	"SETBIT":       true,
+	"GETBIT":       true,
+	"BITCOUNT":     true,
+	"BITPOS":       true,
+	"BITFIELD":     true,
+	"BITFIELD_RO":  true,
+	"BITOP":        true,
+	"LCS":          true,
+	"INCR":         true,
+	"INCRBY":       true,
+	"INCRBYFLOAT":  true,
+	"DECR":         true,
+	"DECRBY":       true,
+	"HINCRBY":      true,
+	"HINCRBYFLOAT": true,
+	"LINSERT":      true,
+	"LREM":         true,
+	"LPOS":         true,
+}
+
+// commands moving values from their first key to their second
+var transformMoves = map[string]bool{
+	"RENAME":     true,
+	"RENAMENX":   true,
+	"COPY":       true,
+	"LMOVE":      true,
+	"BLMOVE":     true,
+	"RPOPLPUSH":  true,
+	"BRPOPLPUSH": true,
+}
+
+// value arguments of a write: args[first], args[first+step]... step 0 for
+// a single value. pairKeys: the key of each value is the argument before
+// it, else args[0].
+type valueArgs struct {
+	first, step int
+	pairKeys    bool
+}
+
+var transformWrites = map[string]valueArgs{
+	"SET":    {1, 0, false},
+	"SETNX":  {1, 0, false},
+	"GETSET": {1, 0, false},
+	"SETEX":  {2, 0, false},
+	"PSETEX": {2, 0, false},
+	"MSET":   {1, 2, true},
+	"MSETNX": {1, 2, true},
+	"HSET":   {2, 2, false},
+	"HMSET":  {2, 2, false},
+	"HSETNX": {2, 0, false},
+	"LPUSH":  {1, 1, false},
+	"RPUSH":  {1, 1, false},
+	"LPUSHX": {1, 1, false},
+	"RPUSHX": {1, 1, false},
+	"LSET":   {2, 0, false},
+}
+
+// how values are laid out in the reply of a read
+const (
+	// a bulk string or an array of them, all of args[0]
+	valueReply = iota
+	// MGET: element i belongs to args[i]
+	valuePerKey
+	// HGETALL: field, value, field, value
+	valuePairs
+	// BLPOP: key, value; LMPOP: key, [values]
+	valueKeyed
+)
+
+var transformReads = map[string]int{
+	"GET":    valueReply,
+	"GETSET": valueReply,
+	"GETDEL": valueReply,
+	"GETEX":  valueReply,
+	"HGET":   valueReply,
+	"HMGET":  valueReply,
+	"HVALS":  valueReply,
+	"LINDEX": valueReply,
+	"LRANGE": valueReply,
+	"LPOP":   valueReply,
+	"RPOP":   valueReply,
+	// moves within one key, see transformMoves
+	"LMOVE":      valueReply,
+	"BLMOVE":     valueReply,
+	"RPOPLPUSH":  valueReply,
+	"BRPOPLPUSH": valueReply,
+	"MGET":       valuePerKey,
+	"HGETALL":    valuePairs,
+	"BLPOP":      valueKeyed,
+	"BRPOP":      valueKeyed,
+	"LMPOP":      valueKeyed,
+	"BLMPOP":     valueKeyed,
+}
+
+// readLayout returns the layout of the values in the reply of command, ok
+// is false if it returns none. SET returns the old value with GET.
+func readLayout(command string, args []interface{}) (layout int, ok bool) {
+	name := strings.ToUpper(command)
+	if name == "SET" {
+		for i := 2; i < len(args); i++ {
+			if strings.EqualFold(argString(args[i]), "GET") {
+				return valueReply, true
+			}
+		}
+		return 0, false
+	}
+	layout, ok = transformReads[name]
+	return layout, ok
+}
+
+// SetValueTransformer applies t to the values of string, hash and list
+// commands on keys starting with one of prefixes, every key without
+// prefixes. Sets and sorted sets are left alone, as encrypted members
+// would no longer compare equal. Call, PipeSend and TransQueue transform,
+// the replies of pipelines in PipeExec and of transactions in TransExec;
+// scripts send and return values as they are. Commands working on part of
+// a transformed value (APPEND, SETRANGE, INCR, LINSERT...) and moves of
+// transformed values to another key (RENAME, LMOVE...) are refused with a
+// *TransformError.
+func (c *Conn) SetValueTransformer(t ValueTransformer, prefixes ...string) {
+	c.transformer = t
+	c.transformPrefixes = prefixes
+}
+
+func (c *Conn) transforms(key string) bool {
+	if len(c.transformPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range c.transformPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptArgs returns a copy of args with the values encrypted
+func (c *Conn) encryptArgs(command string, args []interface{}) ([]interface{}, error) {
+	if c.transformer == nil {
+		return args, nil
+	}
+	name := strings.ToUpper(command)
+	if e := c.checkTransformable(name, args); e != nil {
+		return nil, e
+	}
+	spec, ok := transformWrites[name]
+	if !ok || len(args) <= spec.first {
+		return args, nil
+	}
+	var out []interface{}
+	for i := spec.first; i < len(args); i += spec.step {
+		key := argString(args[0])
+		if spec.pairKeys {
+			key = argString(args[i-1])
+		}
+		if _, ok := args[i].(ReaderArg); ok && c.transforms(key) {
+			return nil, &TransformError{Command: name, Key: key, Err: errReaderTransform}
+		}
+		if c.transforms(key) {
+			v, e := c.transformer.Encrypt(key, []byte(argString(args[i])))
+			if e != nil {
+				return nil, &TransformError{Command: name, Key: key, Err: e}
+			}
+			if out == nil {
+				out = append([]interface{}(nil), args...)
+			}
+			out[i] = v
+		}
+		if spec.step == 0 {
+			break
+		}
+	}
+	if out == nil {
+		return args, nil
+	}
+	return out, nil
+}
+
+func (c *Conn) checkTransformable(name string, args []interface{}) error {
+	refused, move := transformRefused[name], transformMoves[name]
+	if !refused && !move {
+		return nil
+	}
+	ci := LookupCommand(name)
+	if ci == nil {
+		return nil
+	}
+	keys := []string{}
+	for _, pos := range ci.KeyPositions(args) {
+		keys = append(keys, argString(args[pos]))
+	}
+	if move {
+		// a rotation within one key keeps the value where it was written
+		if len(keys) == 2 && keys[0] != keys[1] && (c.transforms(keys[0]) || c.transforms(keys[1])) {
+			return &TransformError{Command: name, Key: keys[0], Err: errMoveTransformed}
+		}
+		return nil
+	}
+	for _, key := range keys {
+		if c.transforms(key) {
+			return &TransformError{Command: name, Key: key, Err: errNotTransformable}
+		}
+	}
+	return nil
+}
+
+// a pipelined read, decrypted by PipeExec
+type pipeRead struct {
+	index   int
+	command string
+	args    []interface{}
+}
+
+// decryptReply decrypts the values of a read reply in place. Reads queued
+// in a transaction reply QUEUED, their values come with EXEC.
+func (c *Conn) decryptReply(command string, args []interface{}, ret interface{}, e error) (interface{}, error) {
+	if c.transformer == nil || e != nil || len(args) == 0 || c.state&stateMulti != 0 {
+		return ret, e
+	}
+	name := strings.ToUpper(command)
+	layout, ok := readLayout(name, args)
+	if !ok {
+		return ret, nil
+	}
+	decrypt := func(key string, v interface{}) (interface{}, error) {
+		b, ok := v.([]byte)
+		if !ok || !c.transforms(key) {
+			return v, nil
+		}
+		plain, e := c.transformer.Decrypt(key, b)
+		if e != nil {
+			return nil, &TransformError{Command: name, Key: key, Err: e}
+		}
+		return plain, nil
+	}
+	arr, isArr := ret.([]interface{})
+	if !isArr {
+		return decrypt(argString(args[0]), ret)
+	}
+	if layout == valueKeyed {
+		if len(arr) != 2 {
+			return arr, nil
+		}
+		key := argString(arr[0])
+		values, ok := arr[1].([]interface{})
+		if !ok {
+			arr[1], e = decrypt(key, arr[1])
+			return arr, e
+		}
+		for i := range values {
+			if values[i], e = decrypt(key, values[i]); e != nil {
+				return nil, e
+			}
+		}
+		return arr, nil
+	}
+	for i := range arr {
+		key := argString(args[0])
+		switch {
+		case layout == valuePerKey && i < len(args):
+			key = argString(args[i])
+		case layout == valuePairs && i%2 == 0:
+			continue
+		}
+		if arr[i], e = decrypt(key, arr[i]); e != nil {
+			return nil, e
+		}
+	}
+	return arr, nil
+}
@@ -0,0 +1,170 @@
+package msgredis
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// prefixes values with "enc:", refuses to decrypt anything else
+type tagTransformer struct{}
+
+func (tagTransformer) Encrypt(key string, v []byte) ([]byte, error) {
+	return append([]byte("enc:"), v...), nil
+}
+
+func (tagTransformer) Decrypt(key string, v []byte) ([]byte, error) {
+	if len(v) < 4 || string(v[:4]) != "enc:" {
+		return nil, errors.New("not encrypted")
+	}
+	return v[4:], nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestValueTransformer(t *testing.T) {
+	var mu sync.Mutex
+	store := map[string]string{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "SET":
+			store[args[1]] = args[2]
+		case "MSET":
+			for i := 1; i+1 < len(args); i += 2 {
+				store[args[i]] = args[i+1]
+			}
+		case "HSET":
+			store[args[1]+"/"+args[2]] = args[3]
+			return ":1\r\n"
+		case "GET":
+			return bulk(store[args[1]])
+		case "MGET":
+			r := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+			for _, k := range args[1:] {
+				r += bulk(store[k])
+			}
+			return r
+		case "HGETALL":
+			return "*2\r\n" + bulk("f") + bulk(store[args[1]+"/f"])
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	c.SetValueTransformer(tagTransformer{}, "pii:")
+
+	c.Call("SET", "pii:email", "a@b.c", "EX", 10)
+	c.Call("MSET", "pii:name", "ann", "plain", "x")
+	c.Call("HSET", "pii:user", "f", "v")
+	if store["pii:email"] != "enc:a@b.c" || store["pii:name"] != "enc:ann" || store["plain"] != "x" ||
+		store["pii:user/f"] != "enc:v" {
+		t.Fatalf("stored %v", store)
+	}
+	if v, e := c.Call("GET", "pii:email"); e != nil || string(v.([]byte)) != "a@b.c" {
+		t.Fatalf("got %v %v", v, e)
+	}
+	v, e := c.Call("MGET", "pii:name", "plain")
+	arr, _ := v.([]interface{})
+	if e != nil || string(arr[0].([]byte)) != "ann" || string(arr[1].([]byte)) != "x" {
+		t.Fatalf("got %v %v", v, e)
+	}
+	v, _ = c.Call("HGETALL", "pii:user")
+	if arr = v.([]interface{}); string(arr[0].([]byte)) != "f" || string(arr[1].([]byte)) != "v" {
+		t.Fatalf("got %v", v)
+	}
+
+	store["pii:raw"] = "cleartext"
+	_, e = c.Call("GET", "pii:raw")
+	if te, ok := e.(*TransformError); !ok || te.Key != "pii:raw" {
+		t.Fatalf("got %v", e)
+	}
+}
+
+func TestValueTransformerPipelines(t *testing.T) {
+	st := newTxStore()
+	c := dialFake(t, newFakeServer(t, st.handle))
+	c.SetValueTransformer(tagTransformer{}, "pii:")
+
+	c.PipeSend("HSET", "pii:a", "f", "v")
+	c.PipeSend("HGETALL", "pii:a")
+	ret, e := c.PipeExec()
+	if e != nil || st.hashes["pii:a"]["f"] != "enc:v" {
+		t.Fatalf("pipeline: %v %v", e, st.hashes)
+	}
+	if arr := ret[1].([]interface{}); string(arr[1].([]byte)) != "v" {
+		t.Fatalf("pipeline read: %q", arr)
+	}
+
+	if e = c.MULTI(); e != nil {
+		t.Fatal(e)
+	}
+	c.TransQueue("HSET", "pii:b", "f", "w")
+	get, e := c.TransQueue("HGETALL", "pii:b")
+	if e != nil {
+		t.Fatalf("queued read: %v", e)
+	}
+	if _, e = c.TransExec(); e != nil || st.hashes["pii:b"]["f"] != "enc:w" {
+		t.Fatalf("transaction: %v %v", e, st.hashes)
+	}
+	if v, e := get.Result(); e != nil || string(v.([]interface{})[1].([]byte)) != "w" {
+		t.Fatalf("transaction read: %v %v", v, e)
+	}
+}
+
+func TestValueTransformerRefused(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	c := dialFake(t, newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, args[0])
+		switch args[0] {
+		case "SET", "LMOVE":
+			return bulk("enc:old")
+		case "BRPOP":
+			return respArray(args[1], "enc:v")
+		}
+		return ":1\r\n"
+	}))
+	c.SetValueTransformer(tagTransformer{}, "pii:")
+
+	for _, args := range [][]interface{}{
+		{"APPEND", "pii:a", "x"},
+		{"LINSERT", "pii:l", "BEFORE", "p", "x"},
+		{"INCR", "pii:n"},
+		{"RENAME", "pii:a", "pii:b"},
+		{"LMOVE", "pii:l", "plain", "LEFT", "RIGHT"},
+	} {
+		_, e := c.Call(args[0].(string), args[1:]...)
+		if te, ok := e.(*TransformError); !ok || te.Command != args[0] {
+			t.Fatalf("%v: %v", args, e)
+		}
+		if e = c.PipeSend(args[0].(string), args[1:]...); e == nil {
+			t.Fatalf("%v pipelined", args)
+		}
+	}
+	if _, e := c.Call("APPEND", "plain", "x"); e != nil {
+		t.Fatal(e)
+	}
+	mu.Lock()
+	if len(sent) != 1 {
+		t.Fatalf("sent %v", sent)
+	}
+	mu.Unlock()
+
+	// values that stay on their key are decrypted
+	if v, e := c.Call("SET", "pii:k", "new", "GET"); e != nil || string(v.([]byte)) != "old" {
+		t.Fatalf("set get: %v %v", v, e)
+	}
+	if v, e := c.Call("LMOVE", "pii:l", "pii:l", "LEFT", "RIGHT"); e != nil || string(v.([]byte)) != "old" {
+		t.Fatalf("rotation: %v %v", v, e)
+	}
+	v, e := c.Call("BRPOP", "pii:l", 1)
+	if arr, _ := v.([]interface{}); e != nil || len(arr) != 2 || string(arr[1].([]byte)) != "v" {
+		t.Fatalf("brpop: %v %v", v, e)
+	}
+}
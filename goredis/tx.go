@@ -0,0 +1,99 @@
+package msgredis
+
+import (
+	"errors"
+)
+
+var (
+	ErrTxPending   = errors.New(CommonErrPrefix + "transaction not executed yet")
+	ErrTxDiscarded = errors.New(CommonErrPrefix + "transaction discarded")
+)
+
+// TxCmd is a command queued in MULTI, see Conn.TransQueue. After TransExec
+// it holds the reply of its EXEC element or the error of that element.
+type TxCmd struct {
+	Command string
+	Val     interface{}
+	Err     error
+	// the keys of the values to decrypt, see SetValueTransformer
+	args []interface{}
+}
+
+func (cmd *TxCmd) Result() (interface{}, error) {
+	return cmd.Val, cmd.Err
+}
+
+func (cmd *TxCmd) Int64() (int64, error) {
+	if cmd.Err != nil {
+		return 0, cmd.Err
+	}
+	n, ok := cmd.Val.(int64)
+	if !ok {
+		return 0, ErrBadType
+	}
+	return n, nil
+}
+
+// Bytes returns ErrNil for a nil reply, e.g. GET of a missing key
+func (cmd *TxCmd) Bytes() ([]byte, error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	if cmd.Val == nil {
+		return nil, ErrNil
+	}
+	b, ok := cmd.Val.([]byte)
+	if !ok {
+		return nil, ErrBadType
+	}
+	return b, nil
+}
+
+func (cmd *TxCmd) String() (string, error) {
+	b, e := cmd.Bytes()
+	return string(b), e
+}
+
+func (cmd *TxCmd) Float64() (float64, error) {
+	if cmd.Err != nil {
+		return 0, cmd.Err
+	}
+	return replyFloat(cmd.Val)
+}
+
+func failTx(cmds []*TxCmd, e error) {
+	for _, cmd := range cmds {
+		cmd.Err = e
+	}
+}
+
+// decodeTx checks every EXEC element against its queued command, errors
+// replace the element in arr so callers of the raw array see them too.
+// Commands queued with Call instead of TransSend leave the handles
+// misaligned, nothing is checked then.
+func decodeTx(c *Conn, cmds []*TxCmd, arr []interface{}) {
+	if len(cmds) != len(arr) {
+		failTx(cmds, ErrBadType)
+		return
+	}
+	for i, v := range arr {
+		cmd := cmds[i]
+		if e, ok := v.(error); ok {
+			cmd.Err = e
+			continue
+		}
+		v, e := c.decryptReply(cmd.Command, cmd.args, v, nil)
+		if e != nil {
+			arr[i] = e
+			cmd.Err = e
+			continue
+		}
+		arr[i] = v
+		if e := c.checkReply(cmd.Command, v); e != nil {
+			arr[i] = e
+			cmd.Err = e
+			continue
+		}
+		cmd.Val, cmd.Err = v, nil
+	}
+}
@@ -0,0 +1,58 @@
+package msgredis
+
+import (
+	"testing"
+)
+
+func TestTransExecDecode(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "MULTI", "DISCARD":
+			return "+OK\r\n"
+		case "EXEC":
+			// INCR fails, GET answers an integer
+			return "*3\r\n:5\r\n-WRONGTYPE Operation against a key\r\n:7\r\n"
+		}
+		return "+QUEUED\r\n"
+	})
+	c := dialFake(t, s)
+	c.SetStrict(true)
+
+	if e := c.MULTI(); e != nil {
+		t.Fatal(e)
+	}
+	incr, e := c.TransQueue("INCR", "a")
+	if e != nil {
+		t.Fatal(e)
+	}
+	bad, _ := c.TransQueue("incr", "s")
+	get, _ := c.TransQueue("GET", "b")
+	if _, e = incr.Int64(); e != ErrTxPending {
+		t.Fatalf("got %v", e)
+	}
+	arr, e := c.TransExec()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if n, e := incr.Int64(); e != nil || n != 5 {
+		t.Fatalf("incr %d %v", n, e)
+	}
+	if _, e = bad.Int64(); !isReplyError(e) {
+		t.Fatalf("bad %v", e)
+	}
+	if _, e = get.Bytes(); e == nil {
+		t.Fatal("integer accepted for GET")
+	} else if ue, ok := e.(*UnexpectedReplyError); !ok || ue.Command != "GET" {
+		t.Fatalf("get %v", e)
+	}
+	if _, ok := arr[2].(*UnexpectedReplyError); !ok {
+		t.Fatalf("raw element %v", arr[2])
+	}
+
+	c.MULTI()
+	cmd, _ := c.TransQueue("SET", "k", "v")
+	c.Discard()
+	if _, e = cmd.Result(); e != ErrTxDiscarded {
+		t.Fatalf("got %v", e)
+	}
+}
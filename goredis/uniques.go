@@ -0,0 +1,99 @@
+package msgredis
+
+import (
+	"strconv"
+	"time"
+)
+
+// UniqueCounter counts unique members over a sliding window of HyperLogLog
+// buckets, e.g. Bucket=time.Hour and Buckets=24 for daily uniques updated
+// hourly. Buckets expire on their own once they leave the window.
+type UniqueCounter struct {
+	Prefix  string
+	Bucket  time.Duration
+	Buckets int
+
+	p *Pool
+}
+
+func NewUniqueCounter(p *Pool, prefix string, bucket time.Duration, buckets int) *UniqueCounter {
+	return &UniqueCounter{Prefix: prefix, Bucket: bucket, Buckets: buckets, p: p}
+}
+
+func (u *UniqueCounter) bucketKey(t time.Time) string {
+	return u.Prefix + strconv.FormatInt(t.UnixNano()/int64(u.Bucket), 10)
+}
+
+// the n buckets ending with the one holding t, newest first
+func (u *UniqueCounter) bucketKeys(t time.Time, n int) []string {
+	if n <= 0 || n > u.Buckets {
+		n = u.Buckets
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = u.bucketKey(t.Add(-time.Duration(i) * u.Bucket))
+	}
+	return keys
+}
+
+// Add records members in the current bucket
+func (u *UniqueCounter) Add(members ...string) error {
+	return u.AddAt(time.Now(), members...)
+}
+
+func (u *UniqueCounter) AddAt(t time.Time, members ...string) error {
+	c := u.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer u.p.Push(c)
+	key := u.bucketKey(t)
+	e := c.PipeSend("PFADD", Args{key}.Add(members)...)
+	if e == nil {
+		// kept one bucket longer than the window so the oldest one stays whole
+		e = c.PipeSend("PEXPIRE", key, time.Duration(u.Buckets+1)*u.Bucket)
+	}
+	// read what was queued even after a failed PipeSend
+	if _, pe := c.pipeExecAll(); e == nil {
+		e = pe
+	}
+	return e
+}
+
+// Count estimates the uniques of the last n buckets, all of them if n <= 0
+func (u *UniqueCounter) Count(n int) (int64, error) {
+	return u.CountAt(time.Now(), n)
+}
+
+// CountAt is Count for the window ending at t. PFCOUNT merges the buckets
+// on the fly, nothing is written.
+func (u *UniqueCounter) CountAt(t time.Time, n int) (int64, error) {
+	c := u.p.Pop()
+	if c == nil {
+		return -1, ErrNoConn
+	}
+	defer u.p.Push(c)
+	v, e := c.Call("PFCOUNT", Args{}.Add(u.bucketKeys(t, n))...)
+	if e != nil {
+		return -1, e
+	}
+	return v.(int64), nil
+}
+
+// Merge stores the union of the last n buckets in dest with PFMERGE, e.g.
+// to keep a daily snapshot after its buckets expire. ttl 0 keeps dest.
+func (u *UniqueCounter) Merge(dest string, n int, ttl time.Duration) error {
+	c := u.p.Pop()
+	if c == nil {
+		return ErrNoConn
+	}
+	defer u.p.Push(c)
+	if e := c.okCall("PFMERGE", Args{dest}.Add(u.bucketKeys(time.Now(), n))...); e != nil {
+		return e
+	}
+	if ttl > 0 {
+		_, e := c.Call("PEXPIRE", dest, ttl)
+		return e
+	}
+	return nil
+}
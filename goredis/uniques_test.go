@@ -0,0 +1,63 @@
+package msgredis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUniqueCounter(t *testing.T) {
+	var mu sync.Mutex
+	hll := map[string]map[string]bool{}
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "PFADD":
+			if hll[args[1]] == nil {
+				hll[args[1]] = map[string]bool{}
+			}
+			for _, m := range args[2:] {
+				hll[args[1]][m] = true
+			}
+			return ":1\r\n"
+		case "PFCOUNT":
+			union := map[string]bool{}
+			for _, k := range args[1:] {
+				for m := range hll[k] {
+					union[m] = true
+				}
+			}
+			return ":" + strconv.Itoa(len(union)) + "\r\n"
+		}
+		return ":1\r\n"
+	})
+	u := NewUniqueCounter(NewPool(s.Addr(), ""), "uv:", time.Hour, 24)
+
+	now := time.Date(2024, 1, 2, 12, 30, 0, 0, time.UTC)
+	u.AddAt(now.Add(-30*time.Hour), "old")
+	u.AddAt(now.Add(-2*time.Hour), "a", "b")
+	u.AddAt(now, "b", "c")
+
+	if n, e := u.CountAt(now, 1); e != nil || n != 2 {
+		t.Fatalf("last hour: %d %v", n, e)
+	}
+	if n, e := u.CountAt(now, 0); e != nil || n != 3 {
+		t.Fatalf("last day: %d %v", n, e)
+	}
+}
+
+func TestUniqueCounterAddError(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "PFADD" {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return ":1\r\n"
+	})
+	u := NewUniqueCounter(NewPool(s.Addr(), ""), "uv:", time.Hour, 24)
+	// the PEXPIRE after it succeeds
+	if e := u.Add("a"); e == nil || !isReplyError(e) {
+		t.Fatalf("got %v", e)
+	}
+}
@@ -0,0 +1,89 @@
+package msgredis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// what the watchdog does on the server besides dropping the conn
+type WatchdogAction int
+
+const (
+	// CLIENT KILL ID the stuck client, e.g. a blocked command that would
+	// otherwise keep running without anybody reading its reply
+	WatchdogKill WatchdogAction = 1 << iota
+	// CLIENT UNPAUSE, for commands held by a forgotten CLIENT PAUSE
+	WatchdogUnpause
+)
+
+// WatchdogError is returned for a command that outlived the watchdog
+// timeout. The conn was closed under the read, the pool replaces it.
+type WatchdogError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e *WatchdogError) Error() string {
+	return CommonErrPrefix + e.Command + " exceeded the watchdog timeout of " + e.Timeout.String()
+}
+
+// SetWatchdog closes the conn when a command gets no reply within timeout,
+// whatever the read deadlines are, so one slow command cannot freeze the
+// caller. 0 turns it off. WatchdogKill needs the client id, asked here.
+func (c *Conn) SetWatchdog(timeout time.Duration, actions WatchdogAction) error {
+	c.watchdog, c.watchdogActions = timeout, actions
+	if timeout > 0 && actions&WatchdogKill != 0 {
+		_, e := c.ClientID()
+		return e
+	}
+	return nil
+}
+
+// armWatchdog starts the timer of a flushed command, nil when off
+func (c *Conn) armWatchdog() *time.Timer {
+	if c.watchdog <= 0 {
+		return nil
+	}
+	conn, id, actions := c.conn, c.clientID, c.watchdogActions
+	return time.AfterFunc(c.watchdog, func() {
+		// unblocks the read at once
+		conn.Close()
+		if actions != 0 {
+			c.unstick(id, actions)
+		}
+	})
+}
+
+// disarmWatchdog reports whether the timer fired, the reply is lost then
+func disarmWatchdog(t *time.Timer) bool {
+	return t != nil && !t.Stop()
+}
+
+// unstick runs the server side actions on a fresh conn
+func (c *Conn) unstick(id int64, actions WatchdogAction) {
+	admin, e := dialContext(context.Background(), c.addr, DialOptions{
+		Username:       c.username,
+		Password:       c.password,
+		ConnectTimeout: ConnectTimeout,
+		ReadTimeout:    ReadTimeout,
+		WriteTimeout:   WriteTimeout,
+		TLSConfig:      c.tlsConfig,
+	})
+	if e != nil {
+		fmt.Println("[Watchdog] " + e.Error())
+		return
+	}
+	defer admin.Close()
+	if actions&WatchdogUnpause != 0 {
+		if e = admin.okCall("CLIENT", "UNPAUSE"); e != nil {
+			fmt.Println("[Watchdog] unpause: " + e.Error())
+		}
+	}
+	if actions&WatchdogKill != 0 && id != 0 {
+		if _, e = admin.CLIENTKILLID(id); e != nil {
+			fmt.Println("[Watchdog] kill " + strconv.FormatInt(id, 10) + ": " + e.Error())
+		}
+	}
+}
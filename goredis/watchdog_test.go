@@ -0,0 +1,89 @@
+package msgredis
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	admin := make(chan string, 2)
+	s := newFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "SLOW":
+			return ""
+		case len(args) > 1 && args[1] == "ID":
+			return ":7\r\n"
+		case len(args) > 1 && args[1] == "KILL":
+			admin <- strings.Join(args, " ")
+			return ":1\r\n"
+		case len(args) > 1 && args[1] == "UNPAUSE":
+			admin <- strings.Join(args, " ")
+		}
+		return "+OK\r\n"
+	})
+	c := dialFake(t, s)
+	if e := c.SetWatchdog(50*time.Millisecond, WatchdogKill|WatchdogUnpause); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := c.Call("FAST"); e != nil {
+		t.Fatal(e)
+	}
+	start := time.Now()
+	_, e := c.Call("SLOW")
+	if we, ok := e.(*WatchdogError); !ok || we.Command != "SLOW" {
+		t.Fatalf("got %v", e)
+	}
+	if time.Since(start) > time.Second || !c.Broken() {
+		t.Fatalf("took %v, broken %v", time.Since(start), c.Broken())
+	}
+	for _, want := range []string{"CLIENT UNPAUSE", "CLIENT KILL ID 7 SKIPME yes"} {
+		select {
+		case got := <-admin:
+			if got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s", want)
+		}
+	}
+}
+
+// the admin conn authenticates and dials as the stuck one did
+func TestWatchdogAdminConn(t *testing.T) {
+	admin := make(chan string, 1)
+	var auths int32
+	s, cfg := newTLSFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "SLOW":
+			return ""
+		case args[0] == "AUTH":
+			if strings.Join(args[1:], " ") != "app secret" {
+				return "-WRONGPASS invalid username-password pair\r\n"
+			}
+			atomic.AddInt32(&auths, 1)
+		case len(args) > 1 && args[1] == "UNPAUSE":
+			admin <- strings.Join(args, " ")
+		}
+		return "+OK\r\n"
+	})
+	c, e := DialWith(context.Background(), s.Addr(), DialOptions{Username: "app", Password: "secret", TLSConfig: cfg})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+	if e = c.SetWatchdog(50*time.Millisecond, WatchdogUnpause); e != nil {
+		t.Fatal(e)
+	}
+	c.Call("SLOW")
+	select {
+	case <-admin:
+	case <-time.After(time.Second):
+		t.Fatal("no CLIENT UNPAUSE")
+	}
+	if n := atomic.LoadInt32(&auths); n != 2 {
+		t.Fatalf("%d AUTH app secret", n)
+	}
+}